@@ -12,21 +12,39 @@ import (
 )
 
 func main() {
+    // Unlocking a wallet runs this program again as the agent holding its key
+    if len(os.Args) > 1 && os.Args[1] == wallet.AgentCommand {
+        if err := wallet.RunAgent(os.Args[2:]); err != nil {
+            log.Fatalf("Wallet agent error: %v", err)
+        }
+        return
+    }
+
+    // Global options come before the command, which the CLI reads from os.Args
+    dataDir := flag.String("datadir", ".", "Directory holding the blockchain database")
+    network := flag.String("network", "", "Network whose address format commands without a blockchain use (default mainnet)")
+    policy := blockchain.DefaultPolicy()
+    flag.IntVar(&policy.DustThreshold, "dustthreshold", policy.DustThreshold, "Smallest output value relayed and mined")
+    flag.IntVar(&policy.MaxDataCarrierSize, "datacarriersize", policy.MaxDataCarrierSize, "Most bytes of data relayed and mined in a null data output")
+    flag.IntVar(&policy.MinRelayFee, "minrelayfee", policy.MinRelayFee, "Fee per 1000 bytes required to relay and mine a transaction")
+    flag.Parse()
+    os.Args = append(os.Args[:1], flag.Args()...)
+
     // Check for commands that don't require blockchain initialization
     if len(os.Args) > 1 {
         switch os.Args[1] {
-        case "createwallet":
-            // Create a new wallet
-            w := wallet.NewWallet()
-            address := w.GetAddress()
-            fmt.Printf("Your new address: %s\n", address)
-            return
-            
-        case "listaddresses":
-            // List all wallet addresses
-            addresses := wallet.ListAddresses()
-            for _, address := range addresses {
-                fmt.Println(address)
+        case "createwallet", "decoderawtx", "encryptwallet", "getnewaddress", "listaddresses",
+            "psbt-combine", "psbt-finalize", "psbt-sign", "validateaddress",
+            "walletlock", "walletpassphrase", "walletpassphrasechange":
+            // Partially signed transactions carry what signing needs, so these
+            // commands run without a blockchain, e.g. on an air-gapped machine;
+            // decoding a raw transaction or managing wallets needs nothing else
+            // either
+            if *network != "" {
+                wallet.SetNetwork(*network)
+            }
+            if err := cli.NewCLI(nil).Run(); err != nil {
+                log.Fatalf("CLI error: %v", err)
             }
             return
             
@@ -34,6 +52,8 @@ func main() {
             // Initialize blockchain with genesis block
             initCmd := flag.NewFlagSet("init", flag.ExitOnError)
             initAddress := initCmd.String("address", "", "The address to use for mining the genesis block")
+            initMaturity := initCmd.Int64("maturity", blockchain.DefaultCoinbaseMaturity, "Blocks required on top of a coinbase before it can be spent")
+            initNetwork := initCmd.String("network", blockchain.DefaultNetwork, "Name of the network, which signatures commit to together with the genesis block")
             
             if err := initCmd.Parse(os.Args[2:]); err != nil {
                 log.Fatalf("Failed to parse init command: %v", err)
@@ -41,31 +61,36 @@ func main() {
             
             if *initAddress == "" {
                 fmt.Println("Error: Address is required")
-                fmt.Println("Usage: blockchain init -address WALLET_ADDRESS")
+                fmt.Println("Usage: blockchain init -address WALLET_ADDRESS [-maturity BLOCKS] [-network NAME]")
                 return
             }
             
             // Validate wallet exists
-            minerWallet := wallet.LoadWallet(*initAddress)
-            if minerWallet == nil {
-                fmt.Printf("Error: Wallet not found for address: %s\n", *initAddress)
+            if _, err := wallet.LoadWallet(*initAddress); err != nil {
+                fmt.Printf("Error: %v\n", err)
                 return
             }
             
             // Create blockchain with genesis block
-            bc, err := createBlockchain(*initAddress)
+            config := blockchain.DefaultConfig()
+            config.CoinbaseMaturity = *initMaturity
+            config.Network = *initNetwork
+            wallet.SetNetwork(config.Network)
+
+            bc, err := createBlockchain(*dataDir, *initAddress, config)
             if err != nil {
                 log.Fatalf("Failed to create blockchain: %v", err)
             }
             defer bc.CloseDB()
             
             fmt.Println("Blockchain initialized with genesis block!")
+            fmt.Printf("Network: %s, chain ID: %s\n", config.Network, bc.ChainID())
             return
         }
     }
     
     // For all other commands, initialize blockchain
-    bc, err := blockchain.NewBlockchain()
+    bc, err := blockchain.NewBlockchainInDir(*dataDir)
     if err != nil {
         log.Fatalf("Failed to create blockchain: %v", err)
     }
@@ -76,6 +101,15 @@ func main() {
             log.Printf("Error closing database: %v", err)
         }
     }()
+    // Addresses are shown and paid to in the format of the chain's network
+    chainNetwork := bc.GetConfig().Network
+    if *network != "" && *network != chainNetwork {
+        log.Fatalf("The blockchain belongs to network %s, not %s", chainNetwork, *network)
+    }
+    wallet.SetNetwork(chainNetwork)
+    if err := bc.SetPolicy(policy); err != nil {
+        log.Fatalf("Invalid policy: %v", err)
+    }
     
     // Initialize and run CLI
     cli := cli.NewCLI(bc)
@@ -84,17 +118,17 @@ func main() {
     }
 }
 
-// createBlockchain creates a new blockchain with a genesis block and rewards the miner
-func createBlockchain(minerAddress string) (*blockchain.Blockchain, error) {
+// createBlockchain creates a new blockchain in dataDir with a genesis block and rewards the miner
+func createBlockchain(dataDir, minerAddress string, config blockchain.Config) (*blockchain.Blockchain, error) {
     // Load the wallet for the miner - this will be checked again in CreateBlockchain
     // but we do it here first to provide a better error message
-    minerWallet := wallet.LoadWallet(minerAddress)
-    if minerWallet == nil {
-        return nil, fmt.Errorf("wallet not found for address: %s", minerAddress)
+    minerWallet, err := wallet.LoadWallet(minerAddress)
+    if err != nil {
+        return nil, err
     }
     
     // Create a new blockchain with the genesis block
-    bc, err := blockchain.CreateBlockchain(minerWallet)
+    bc, err := blockchain.CreateBlockchainInDir(dataDir, minerWallet, config)
     if err != nil {
         return nil, fmt.Errorf("failed to create blockchain: %v", err)
     }
@@ -5,6 +5,7 @@ go 1.24.2
 require (
 	go.etcd.io/bbolt v1.4.0
 	golang.org/x/crypto v0.38.0
+	golang.org/x/term v0.32.0
 )
 
 require golang.org/x/sys v0.33.0 // indirect
@@ -3,42 +3,54 @@ package block
 import (
 	"bytes"
 	"crypto/sha256"
-	"encoding/gob"
 	"fmt"
-	"strconv"
 	"sync"
 	"time"
 
 	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
 )
 
+// BaseVersion is the version of blocks built without any deployment signaling
+const BaseVersion int32 = 1
+
+// BlockHeader holds the metadata of a block: everything except its transactions and hash
+type BlockHeader struct {
+	Version         int32  // Header version, used to signal support for consensus deployments
+	Height          int64  // Number of blocks preceding this one in the chain
+	Timestamp       int64  // Records when block was created/mined
+	PrevBlockHash   []byte // Stores the Hash of previous Block in the chain
+	Nonce           int    // Number used in proof of work (retained for structural consistency, might be zero in PoS)
+	Bits            int64  // Stores the difficulty target bits for this block (retained, might be zero or repurposed in PoS)
+	ValidatorPubKey []byte // Public key of the validator who signed this block
+	Signature       []byte // Signature of the block by the validator
+}
+
 // Block represents a block in the blockchain
 type Block struct {
-	Timestamp       int64                      // Records when block was created/mined
-	Transactions    []*transaction.Transaction // stores Transactions
-	PrevBlockHash   []byte                     // Stores the Hash of previous Block in the chain
-	Hash            []byte                     // Stores the Hash of current block in the chain
-	Nonce           int                        // Number used in proof of work (retained for structural consistency, might be zero in PoS)
-	Bits            int64                      // Stores the difficulty target bits for this block (retained, might be zero or repurposed in PoS)
-	ValidatorPubKey []byte                     // Public key of the validator who signed this block
-	Signature       []byte                     // Signature of the block by the validator
-	mu              sync.RWMutex               // Mutex for thread safety
+	BlockHeader
+	Transactions []*transaction.Transaction // stores Transactions
+	Hash         []byte                     // Stores the Hash of current block in the chain
+	mu           sync.RWMutex               // Mutex for thread safety
 }
 
 // NewBlock creates and returns a new Block
 // In PoS, the hash, nonce, bits, validator key, and signature are filled later by the consensus mechanism.
 func NewBlock(transactions []*transaction.Transaction, prevBlockHash []byte) *Block {
 	block := &Block{
-		Timestamp:       time.Now().Unix(),
-		Transactions:    transactions,
-		PrevBlockHash:   prevBlockHash,
-		Hash:            []byte{},
-		Nonce:           0,
-		Bits:            0,
-		ValidatorPubKey: nil, // Initialize new fields
-		Signature:       nil, // Initialize new fields
+		BlockHeader: BlockHeader{
+			Version:         BaseVersion,
+			Height:          0,
+			Timestamp:       time.Now().Unix(),
+			PrevBlockHash:   prevBlockHash,
+			Nonce:           0,
+			Bits:            0,
+			ValidatorPubKey: nil, // Initialize new fields
+			Signature:       nil, // Initialize new fields
+		},
+		Transactions: transactions,
+		Hash:         []byte{},
 	}
-	// The actual Hash, Nonce, Bits, ValidatorPubKey, and Signature will be set by the consensus mechanism (PoW or PoS)
+	// The actual Version, Height, Hash, Nonce, Bits, ValidatorPubKey, and Signature will be set by the consensus mechanism (PoW or PoS)
 	return block
 }
 
@@ -71,80 +83,48 @@ func (b *Block) hashTransactionsInternal() []byte {
 	return txHash[:]
 }
 
-// PrepareData prepares data for hashing for PoW (still used by PoWConsensus)
-// For PoS, a similar function might be needed that includes PoS-specific header fields.
-func (b *Block) PrepareData(nonce int, targetBits int64) []byte {
-	data := bytes.Join(
-		[][]byte{
-			b.PrevBlockHash,
-			b.hashTransactionsInternal(),
-			IntToHex(b.Timestamp),
-			IntToHex(targetBits),
-			IntToHex(int64(nonce)),
-		},
-		[]byte{},
-	)
-	return data
-}
-
-// GetHashableDataPoS prepares data for hashing specifically for PoS block signature.
-// This includes all relevant fields that define the block's identity before signing.
-func (b *Block) GetHashableDataPoS() []byte {
+// HashWitnesses returns a hash of the witness hashes of the transactions in the block,
+// which unlike their IDs cover the unlocking scripts
+func (b *Block) HashWitnesses() []byte {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	data := bytes.Join(
-		[][]byte{
-			b.PrevBlockHash,
-			b.hashTransactionsInternal(), // Merkle root/hash of transactions
-			IntToHex(b.Timestamp),
-			IntToHex(b.Bits),         // Might be 0 or repurposed in PoS
-			IntToHex(int64(b.Nonce)), // Might be 0 or repurposed in PoS
-			// b.ValidatorPubKey should be included here if it's set before signing
-			// If ValidatorPubKey is set after signing, it shouldn't be included.
-			b.ValidatorPubKey,
-		},
-		[]byte{},
-	)
-	return data
+	return b.hashWitnessesInternal()
 }
 
-// Serialize serializes the block
-func (b *Block) Serialize() ([]byte, error) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
+// hashWitnessesInternal is HashWitnesses without taking the lock
+func (b *Block) hashWitnessesInternal() []byte {
+	var witnessHashes [][]byte
 
-	err := encoder.Encode(b)
-	if err != nil {
-		return nil, err
+	for _, tx := range b.Transactions {
+		witnessHashes = append(witnessHashes, tx.WitnessHash())
 	}
+	witnessHash := sha256.Sum256(bytes.Join(witnessHashes, []byte{}))
 
-	return result.Bytes(), nil
+	return witnessHash[:]
 }
 
-// DeserializeBlock deserializes a block
-func DeserializeBlock(d []byte) (*Block, error) {
-	var block Block
-
-	decoder := gob.NewDecoder(bytes.NewReader(d))
-	err := decoder.Decode(&block)
-	if err != nil {
-		return nil, err
-	}
-
-	return &block, nil
+// PrepareData prepares data for hashing for PoW (still used by PoWConsensus)
+// The preimage is the canonical header encoding with the given nonce and bits,
+// the transactions and witnesses hashes in place of the transactions, and without
+// the signature.
+func (b *Block) PrepareData(nonce int, targetBits int64) []byte {
+	return b.headerPreimage(nonce, targetBits)
 }
 
-// IntToHex converts an int64 to a byte array
-func IntToHex(num int64) []byte {
-	return []byte(strconv.FormatInt(num, 10))
+// GetHashableDataPoS prepares data for hashing specifically for PoS block signature.
+// This includes all relevant fields that define the block's identity before signing,
+// including the validator's public key but not the signature itself.
+func (b *Block) GetHashableDataPoS() []byte {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.headerPreimage(b.Nonce, b.Bits)
 }
 
-// ValidateBlock validates the block and its transactions in parallel
-func (b *Block) ValidateBlock(prevTXs map[string]transaction.Transaction) error {
+// ValidateBlock validates the block and its transactions, signed for chain chainID,
+// in parallel. Signatures found in sigCache, which may be nil, are not checked again.
+func (b *Block) ValidateBlock(prevTXs map[string]transaction.Transaction, chainID transaction.ChainID, sigCache *transaction.SigCache) error {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
@@ -178,7 +158,7 @@ func (b *Block) ValidateBlock(prevTXs map[string]transaction.Transaction) error
 		wg.Add(1)
 		go func(tx *transaction.Transaction, i int) {
 			defer wg.Done()
-			if err := tx.ValidateTransaction(prevTXs); err != nil {
+			if err := tx.ValidateTransaction(prevTXs, chainID, sigCache); err != nil {
 				errs <- fmt.Errorf("invalid transaction at index %d: %v", i, err)
 			}
 		}(tx, i)
@@ -230,6 +210,34 @@ func (b *Block) GetPoSHash() []byte {
 	return hash[:]
 }
 
+// SetVersion sets the version of the block in a thread-safe manner
+func (b *Block) SetVersion(version int32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Version = version
+}
+
+// GetVersion returns the version of the block in a thread-safe manner
+func (b *Block) GetVersion() int32 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.Version
+}
+
+// SetHeight sets the height of the block in a thread-safe manner
+func (b *Block) SetHeight(height int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Height = height
+}
+
+// GetHeight returns the height of the block in a thread-safe manner
+func (b *Block) GetHeight() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.Height
+}
+
 // SetNonce sets the nonce of the block in a thread-safe manner
 func (b *Block) SetNonce(nonce int) {
 	b.mu.Lock()
@@ -0,0 +1,136 @@
+package block
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// testChainID is the chain ID transactions of the tests are signed for
+var testChainID = transaction.NewChainID([]byte("test genesis"), "mainnet")
+
+// Helper to create a transaction spending n outputs of one wallet, signed for
+// testChainID, with the transactions it spends
+func manyInputTx(tb testing.TB, n int) (*transaction.Transaction, map[string]transaction.Transaction) {
+	tb.Helper()
+	w := wallet.NewWallet()
+	prev := transaction.Transaction{}
+	for i := 0; i < n; i++ {
+		prev.Vout = append(prev.Vout, transaction.NewTxOutput(1, wallet.HashPubKey(w.PublicKey)))
+	}
+	prev.ID = prev.Hash()
+	prevTXs := map[string]transaction.Transaction{hex.EncodeToString(prev.ID): prev}
+
+	vouts := make([]int, n)
+	for i := range vouts {
+		vouts[i] = i
+	}
+	tx := &transaction.Transaction{Vout: []transaction.TxOutput{transaction.NewTxOutput(n, []byte("recipient"))}}
+	for _, vout := range vouts {
+		tx.Vin = append(tx.Vin, transaction.TxInput{Txid: prev.ID, Vout: vout})
+	}
+	tx.ID = tx.Hash()
+	if err := tx.Sign(w, prevTXs, testChainID); err != nil {
+		tb.Fatalf("Failed to sign transaction: %v", err)
+	}
+	return tx, prevTXs
+}
+
+// manyInputBlock builds a block with txs transactions of inputs inputs each, with the
+// transactions they spend
+func manyInputBlock(b testing.TB, txs, inputs int) (*Block, map[string]transaction.Transaction) {
+	b.Helper()
+	blockTxs := []*transaction.Transaction{transaction.NewCoinbaseTx(wallet.NewWallet().PublicKey, "", 1)}
+	prevTXs := make(map[string]transaction.Transaction)
+	for i := 0; i < txs; i++ {
+		tx, txPrevTXs := manyInputTx(b, inputs)
+		blockTxs = append(blockTxs, tx)
+		for id, prevTx := range txPrevTXs {
+			prevTXs[id] = prevTx
+		}
+	}
+	return NewBlock(blockTxs, []byte("previous block")), prevTXs
+}
+
+// Test validating a block again takes its signatures from the cache, and a cached
+// signature does not validate under another signature hash, chain or public key
+func TestValidateBlockSigCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	blk, prevTXs := manyInputBlock(t, 2, 3)
+	cache := transaction.NewSigCache(transaction.DefaultSigCacheSize)
+	if err := blk.ValidateBlock(prevTXs, testChainID, cache); err != nil {
+		t.Fatalf("Failed to validate block: %v", err)
+	}
+	if cache.Len() != 6 || cache.Hits() != 0 {
+		t.Fatalf("Expected 6 cached signatures and no hits, got %d and %d", cache.Len(), cache.Hits())
+	}
+	if err := blk.ValidateBlock(prevTXs, testChainID, cache); err != nil {
+		t.Fatalf("Failed to validate block again: %v", err)
+	}
+	if cache.Len() != 6 || cache.Hits() != 6 {
+		t.Fatalf("Expected every signature from the cache, got %d hits of %d", cache.Hits(), cache.Len())
+	}
+
+	if err := blk.ValidateBlock(prevTXs, transaction.NewChainID([]byte("other genesis"), "mainnet"), cache); err == nil {
+		t.Error("Cached signatures validated on another chain")
+	}
+
+	// The same signature bytes with another hash type byte sign another signature hash
+	tx := blk.Transactions[1]
+	pushes, err := script.PushedData(tx.Vin[0].UnlockingScript)
+	if err != nil || len(pushes) != 2 {
+		t.Fatalf("Unexpected unlocking script: %v", err)
+	}
+	sig, pubKey := pushes[0][:len(pushes[0])-1], pushes[1]
+	retagged := *tx
+	retagged.Vin = append([]transaction.TxInput{}, tx.Vin...)
+	retagged.Vin[0].UnlockingScript = script.PubKeyHashUnlockingScript(
+		append(append([]byte{}, sig...), byte(transaction.SigHashAll|transaction.SigHashAnyOneCanPay)), pubKey)
+	retagged.ID = retagged.Hash()
+	other := NewBlock([]*transaction.Transaction{blk.Transactions[0], &retagged}, []byte("previous block"))
+	if err := other.ValidateBlock(prevTXs, testChainID, cache); err == nil {
+		t.Error("Cached signature validated under another hash type")
+	}
+
+	// Nor is it found for another public key over the same signature hash
+	prevOut := prevTXs[hex.EncodeToString(tx.Vin[0].Txid)].Vout[tx.Vin[0].Vout]
+	hash, err := tx.SignatureHash(0, prevOut.LockingScript, testChainID, transaction.SigHashAll)
+	if err != nil {
+		t.Fatalf("Failed to compute signature hash: %v", err)
+	}
+	if !cache.Exists(hash, sig, pubKey) {
+		t.Fatal("Expected the signature to be cached")
+	}
+	if cache.Exists(hash, sig, wallet.NewWallet().PublicKey) {
+		t.Error("Cached signature found for another public key")
+	}
+}
+
+// Benchmark validating a block of many-input transactions without and with their
+// signatures cached, as they are once the transactions have been verified on arrival
+func BenchmarkValidateBlockSigCache(b *testing.B) {
+	b.Setenv("HOME", b.TempDir())
+	blk, prevTXs := manyInputBlock(b, 10, 100)
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := blk.ValidateBlock(prevTXs, testChainID, transaction.NewSigCache(transaction.DefaultSigCacheSize)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("warm", func(b *testing.B) {
+		cache := transaction.NewSigCache(transaction.DefaultSigCacheSize)
+		if err := blk.ValidateBlock(prevTXs, testChainID, cache); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := blk.ValidateBlock(prevTXs, testChainID, cache); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package block
+
+import (
+	"fmt"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/codec"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+)
+
+// minTransactionSize is the smallest canonical encoding of a transaction
+const minTransactionSize = 4 + 4 + 4
+
+// Encode writes the canonical encoding of the header:
+// Version (int32), Height (int64), Timestamp (int64), PrevBlockHash (bytes),
+// Nonce (int64), Bits (int64), ValidatorPubKey (bytes), Signature (bytes)
+func (h *BlockHeader) Encode(w *codec.Writer) {
+	w.WriteInt32(h.Version)
+	w.WriteInt64(h.Height)
+	w.WriteInt64(h.Timestamp)
+	w.WriteBytes(h.PrevBlockHash)
+	w.WriteInt(h.Nonce)
+	w.WriteInt64(h.Bits)
+	w.WriteBytes(h.ValidatorPubKey)
+	w.WriteBytes(h.Signature)
+}
+
+// DecodeBlockHeader reads a header written by BlockHeader.Encode
+func DecodeBlockHeader(r *codec.Reader) BlockHeader {
+	var h BlockHeader
+	h.Version = r.ReadInt32()
+	h.Height = r.ReadInt64()
+	h.Timestamp = r.ReadInt64()
+	h.PrevBlockHash = r.ReadBytes()
+	h.Nonce = r.ReadInt()
+	h.Bits = r.ReadInt64()
+	h.ValidatorPubKey = r.ReadBytes()
+	h.Signature = r.ReadBytes()
+	return h
+}
+
+// headerPreimage returns the data committed to by the block hash:
+// Version (int32), Height (int64), PrevBlockHash (bytes), transactions hash (bytes),
+// witnesses hash (bytes), Timestamp (int64), Bits (int64), Nonce (int64),
+// ValidatorPubKey (bytes)
+// It must be called with the lock held or when thread safety isn't required.
+func (b *Block) headerPreimage(nonce int, bits int64) []byte {
+	w := codec.NewWriter()
+	w.WriteInt32(b.Version)
+	w.WriteInt64(b.Height)
+	w.WriteBytes(b.PrevBlockHash)
+	w.WriteBytes(b.hashTransactionsInternal())
+	w.WriteBytes(b.hashWitnessesInternal())
+	w.WriteInt64(b.Timestamp)
+	w.WriteInt64(bits)
+	w.WriteInt(nonce)
+	w.WriteBytes(b.ValidatorPubKey)
+	return w.Bytes()
+}
+
+// Serialize serializes the block with the canonical encoding:
+// header (BlockHeader), Hash (bytes), Transactions (list of Transaction)
+func (b *Block) Serialize() ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	w := codec.NewWriter()
+	b.BlockHeader.Encode(w)
+	w.WriteBytes(b.Hash)
+	w.WriteCount(len(b.Transactions))
+	for _, tx := range b.Transactions {
+		tx.Encode(w)
+	}
+
+	return w.Bytes(), nil
+}
+
+// DeserializeBlock deserializes a block
+func DeserializeBlock(d []byte) (*Block, error) {
+	r := codec.NewReader(d)
+
+	block := &Block{}
+	block.BlockHeader = DecodeBlockHeader(r)
+	block.Hash = r.ReadBytes()
+	n := r.ReadCount(minTransactionSize)
+	for i := 0; i < n; i++ {
+		block.Transactions = append(block.Transactions, transaction.DecodeTransaction(r))
+	}
+
+	if err := r.Finish(); err != nil {
+		return nil, fmt.Errorf("failed to decode block: %v", err)
+	}
+
+	return block, nil
+}
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 
 	"github.com/OmSingh2003/decentralized-ledger/internal/block"
 	"github.com/OmSingh2003/decentralized-ledger/internal/consensus"
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
 	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
 	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
 	"go.etcd.io/bbolt"
@@ -19,19 +22,28 @@ const (
 	dbFile              = "blockchain.db"
 	blocksBucket        = "blocks"
 	lastHashKey         = "l" // Key for storing the last block hash
+	genesisHashKey      = "g" // Key for storing the genesis block hash
 	genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
 	// New constrains for difficulty adjustment
 	TARGET_BLOCK_TIME_SECONDS    = 600  // 10 minutes per block
 	DIFFICULTY_ADJUSTMENT_BLOCKS = 2025 // Adjust difficulty every 2025 blocks
 	MAX_ADJUSTMENT_FACTOR        = 4    // Limit difficulty to 4X (1/4 or 4X )
 	INITIAL_TARGET_BITS          = 24   // Starting difficulty for genesis block
+
+	// medianTimeBlocks is the number of blocks whose median timestamp time locks are
+	// checked against
+	medianTimeBlocks = 11
 )
 
 // Blockchain represents the blockchain structure
 type Blockchain struct {
 	tip       []byte                // Hash of the latest block
 	db        *bbolt.DB             // Database connection
-	consensus consensus.Consensus    // Consensus mechanism (PoW or PoS)
+	consensus consensus.Consensus   // Consensus mechanism (PoW or PoS)
+	config    Config                // Settings fixed at creation
+	chainID   transaction.ChainID   // ID of the chain, which signatures commit to
+	sigCache  *transaction.SigCache // Signatures already verified
+	policy    Policy                // Standardness rules for submitted transactions
 	mu        sync.RWMutex          // Mutex for thread safety
 }
 
@@ -41,27 +53,51 @@ type BlockchainIterator struct {
 	db          *bbolt.DB
 }
 
-// NewBlockchain opens an existing blockchain with PoS consensus
+// NewBlockchain opens an existing blockchain with PoS consensus in the working directory
 func NewBlockchain() (*Blockchain, error) {
+	return NewBlockchainInDir(".")
+}
+
+// NewBlockchainInDir opens an existing blockchain with PoS consensus stored in dataDir
+func NewBlockchainInDir(dataDir string) (*Blockchain, error) {
 	// Only open existing blockchain
-	if !DbExists() {
-		return nil, fmt.Errorf("no existing blockchain found")
+	if !DbExistsInDir(dataDir) {
+		return nil, fmt.Errorf("no existing blockchain found in %s", dataDir)
 	}
 
-	db, err := bbolt.Open(dbFile, 0o600, nil)
+	return openBlockchain(filepath.Join(dataDir, dbFile))
+}
+
+// openBlockchain opens the blockchain stored in the database at dbPath
+func openBlockchain(dbPath string) (*Blockchain, error) {
+	db, err := bbolt.Open(dbPath, 0o600, nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open blockchain db: %v", err)
 	}
 
-	var tip []byte
+	var tip, genesisHash []byte
+	var config Config
 	err = db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		if b == nil {
 			return fmt.Errorf("no existing blockchain found")
 		}
-		tip = b.Get([]byte(lastHashKey))
-		return nil
+		tip = append([]byte(nil), b.Get([]byte(lastHashKey))...)
+		genesisHash = append([]byte(nil), b.Get([]byte(genesisHashKey))...)
+
+		config, err = loadConfig(tx)
+		return err
 	})
+	if err == nil && len(genesisHash) == 0 {
+		// Chains created before the genesis hash was stored
+		err = db.Update(func(tx *bbolt.Tx) error {
+			b := tx.Bucket([]byte(blocksBucket))
+			if genesisHash, err = findGenesisHash(b, tip); err != nil {
+				return err
+			}
+			return b.Put([]byte(genesisHashKey), genesisHash)
+		})
+	}
 	if err != nil {
 		db.Close()
 		return nil, err
@@ -69,24 +105,75 @@ func NewBlockchain() (*Blockchain, error) {
 
 	// Use PoS consensus by default
 	posConsensus := consensus.NewPoSConsensus(db)
-	bc := Blockchain{tip, db, posConsensus, sync.RWMutex{}}
+	bc := Blockchain{
+		tip:       tip,
+		db:        db,
+		consensus: posConsensus,
+		config:    config,
+		chainID:   transaction.NewChainID(genesisHash, config.Network),
+		sigCache:  transaction.NewSigCache(transaction.DefaultSigCacheSize),
+		policy:    DefaultPolicy(),
+	}
 	return &bc, nil
 }
 
-// CreateBlockchain creates a new blockchain with a genesis block using PoS
+// findGenesisHash follows the blocks in b back from tip to the genesis block and
+// returns its hash
+func findGenesisHash(b *bbolt.Bucket, tip []byte) ([]byte, error) {
+	hash := tip
+	for {
+		data := b.Get(hash)
+		if data == nil {
+			return nil, fmt.Errorf("block %x not found", hash)
+		}
+		blk, err := block.DeserializeBlock(append([]byte(nil), data...))
+		if err != nil {
+			return nil, err
+		}
+		if len(blk.PrevBlockHash) == 0 {
+			return hash, nil
+		}
+		hash = blk.PrevBlockHash
+	}
+}
+
+// CreateBlockchain creates a new blockchain with a genesis block using PoS and the default config
 func CreateBlockchain(minerWallet *wallet.Wallet) (*Blockchain, error) {
+	return CreateBlockchainWithConfig(minerWallet, DefaultConfig())
+}
+
+// CreateBlockchainWithConfig creates a new blockchain with a genesis block using PoS and the given config
+func CreateBlockchainWithConfig(minerWallet *wallet.Wallet, config Config) (*Blockchain, error) {
+	return CreateBlockchainInDir(".", minerWallet, config)
+}
+
+// CreateBlockchainInDir is like CreateBlockchainWithConfig for a blockchain stored in dataDir,
+// which is created if needed. Chains in different directories are independent.
+func CreateBlockchainInDir(dataDir string, minerWallet *wallet.Wallet, config Config) (*Blockchain, error) {
 	// Check if blockchain already exists
-	if DbExists() {
-		return nil, fmt.Errorf("blockchain already exists")
+	if DbExistsInDir(dataDir) {
+		return nil, fmt.Errorf("blockchain already exists in %s", dataDir)
 	}
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		return nil, fmt.Errorf("cannot create data directory: %v", err)
+	}
+
+	return createBlockchain(filepath.Join(dataDir, dbFile), minerWallet, config)
+}
 
+// createBlockchain creates a new blockchain with a genesis block in the database at dbPath
+func createBlockchain(dbPath string, minerWallet *wallet.Wallet, config Config) (*Blockchain, error) {
 	// Validate miner wallet
 	if minerWallet == nil {
 		return nil, fmt.Errorf("miner wallet is required to create blockchain")
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+
 	// Open database
-	db, err := bbolt.Open(dbFile, 0o600, nil)
+	db, err := bbolt.Open(dbPath, 0o600, nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open blockchain db: %v", err)
 	}
@@ -102,10 +189,11 @@ func CreateBlockchain(minerWallet *wallet.Wallet) (*Blockchain, error) {
 	var tip []byte
 	err = db.Update(func(tx *bbolt.Tx) error {
 		// Create coinbase transaction with miner's address
-		cbtx := transaction.NewCoinbaseTx(minerWallet.PublicKey, genesisCoinbaseData)
+		cbtx := transaction.NewCoinbaseTx(minerWallet.PublicKey, genesisCoinbaseData, 0)
 
-		// Use PoS to propose the genesis block
-		genesisBlock, err := posConsensus.ProposeBlock(minerWallet, []*transaction.Transaction{cbtx}, []byte{}, []byte{})
+		// Use PoS to propose the genesis block, whose hash is not part of its own chain ID
+		genesisBlock, err := posConsensus.ProposeBlock(minerWallet, []*transaction.Transaction{cbtx}, []byte{}, []byte{},
+			transaction.NewChainID(nil, config.Network))
 		if err != nil {
 			return fmt.Errorf("failed to propose genesis block: %v", err)
 		}
@@ -133,8 +221,16 @@ func CreateBlockchain(minerWallet *wallet.Wallet) (*Blockchain, error) {
 			return err
 		}
 
+		// Store the genesis block hash, which identifies the chain
+		err = b.Put([]byte(genesisHashKey), genesisBlock.Hash)
+		if err != nil {
+			return err
+		}
+
 		tip = genesisBlock.Hash
-		return nil
+
+		// Store the chain settings
+		return saveConfig(tx, config)
 	})
 	if err != nil {
 		db.Close()
@@ -142,7 +238,15 @@ func CreateBlockchain(minerWallet *wallet.Wallet) (*Blockchain, error) {
 	}
 
 	// Create blockchain instance with PoS consensus
-	bc := Blockchain{tip, db, posConsensus, sync.RWMutex{}}
+	bc := Blockchain{
+		tip:       tip,
+		db:        db,
+		consensus: posConsensus,
+		config:    config,
+		chainID:   transaction.NewChainID(tip, config.Network),
+		sigCache:  transaction.NewSigCache(transaction.DefaultSigCacheSize),
+		policy:    DefaultPolicy(),
+	}
 
 	// Initialize UTXO set
 	utxo := UTXOSet{&bc}
@@ -160,38 +264,51 @@ func (bc *Blockchain) MineBlock(transactions []*transaction.Transaction, propose
 	bc.mu.Lock()
 	defer bc.mu.Unlock()
 
+	// Verify each transaction against the transactions it spends, which may come
+	// earlier in the same block
+	prevTXs := make(map[string]transaction.Transaction)
+	earlier := make(map[string]*transaction.Transaction)
 	for _, tx := range transactions {
 		if !tx.IsCoinbase() {
-			if err := bc.VerifyTransaction(tx); err != nil {
+			for _, vin := range tx.Vin {
+				txid := hex.EncodeToString(vin.Txid)
+				if prevTX, ok := earlier[txid]; ok {
+					prevTXs[txid] = *prevTX
+					continue
+				}
+				prevTX, err := bc.FindTransaction(vin.Txid)
+				if err != nil {
+					return nil, fmt.Errorf("invalid transaction: %v", err)
+				}
+				prevTXs[txid] = *prevTX
+			}
+			valid, err := tx.VerifyWithCache(prevTXs, bc.chainID, bc.sigCache)
+			if err != nil {
 				return nil, fmt.Errorf("invalid transaction: %v", err)
 			}
+			if !valid {
+				return nil, fmt.Errorf("invalid transaction: invalid transaction signature")
+			}
 		}
+		earlier[hex.EncodeToString(tx.ID)] = tx
 	}
 
 	lastHash := bc.tip
 
 	// Use PoS consensus to propose the block
-	newBlock, err := bc.consensus.ProposeBlock(proposerWallet, transactions, lastHash, bc.tip)
+	newBlock, err := bc.consensus.ProposeBlock(proposerWallet, transactions, lastHash, bc.tip, bc.chainID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to propose block with PoS: %v", err)
 	}
 
 	// Validate the proposed block
-	prevTXs := make(map[string]transaction.Transaction)
-	for _, tx := range transactions {
-		if !tx.IsCoinbase() {
-			for _, vin := range tx.Vin {
-				prevTX, err := bc.FindTransaction(vin.Txid)
-				if err != nil {
-					return nil, err
-				}
-				prevTXs[hex.EncodeToString(prevTX.ID)] = *prevTX
-			}
-		}
+	valid, err := bc.consensus.ValidateBlock(newBlock, prevTXs, bc.chainID, bc.sigCache)
+	if err != nil || !valid {
+		return nil, fmt.Errorf("block validation failed: %v", err)
 	}
 
-	valid, err := bc.consensus.ValidateBlock(newBlock, prevTXs)
-	if err != nil || !valid {
+	// Validate the transactions against the UTXO set
+	if err := bc.CheckConnectBlock(newBlock); err != nil {
 		return nil, fmt.Errorf("block validation failed: %v", err)
 	}
 
@@ -213,6 +330,10 @@ func (bc *Blockchain) MineBlock(transactions []*transaction.Transaction, propose
 			return err
 		}
 
+		if err := consensus.CacheDeploymentStates(tx, newBlock.Hash); err != nil {
+			return err
+		}
+
 		bc.tip = newBlock.Hash
 		return nil
 	})
@@ -338,10 +459,12 @@ func (bc *Blockchain) FindBlock(hash []byte) (*block.Block, error) {
 	var blockData []byte
 	err := bc.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		blockData = b.Get(hash)
-		if blockData == nil {
+		data := b.Get(hash)
+		if data == nil {
 			return fmt.Errorf("block not found for hash: %x", hash)
 		}
+		// Values returned by bbolt are only valid for the life of the transaction
+		blockData = append([]byte(nil), data...)
 		return nil
 	})
 	if err != nil {
@@ -367,7 +490,10 @@ func (i *BlockchainIterator) Next() (*block.Block, error) {
 
 	err := i.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		blockData = b.Get(i.currentHash)
+		if data := b.Get(i.currentHash); data != nil {
+			// Values returned by bbolt are only valid for the life of the transaction
+			blockData = append([]byte(nil), data...)
+		}
 		return nil
 	})
 	if err != nil {
@@ -388,11 +514,11 @@ func (i *BlockchainIterator) Next() (*block.Block, error) {
 }
 
 // FindUTXO finds and returns all unspent transaction outputs
-func (bc *Blockchain) FindUTXO() map[string][]transaction.TxOutput {
+func (bc *Blockchain) FindUTXO() map[string][]UTXOEntry {
 	bc.mu.RLock()
 	defer bc.mu.RUnlock()
 
-	UTXO := make(map[string][]transaction.TxOutput)
+	UTXO := make(map[string][]UTXOEntry)
 	spentTXOs := make(map[string][]int)
 	// Don't call Iterator() as it tries to acquire the same lock
 	bci := &BlockchainIterator{bc.tip, bc.db}
@@ -405,12 +531,19 @@ func (bc *Blockchain) FindUTXO() map[string][]transaction.TxOutput {
 		if block == nil {
 			break
 		}
+		medianTime, err := bc.medianTimePast(block.PrevBlockHash)
+		if err != nil {
+			break
+		}
 
 		for _, tx := range block.Transactions {
 			txID := hex.EncodeToString(tx.ID)
 
 		Outputs:
 			for outIdx, out := range tx.Vout {
+				if out.IsUnspendable() {
+					continue
+				}
 				// Was the output spent?
 				if spentTXOs[txID] != nil {
 					for _, spentOutIdx := range spentTXOs[txID] {
@@ -421,7 +554,13 @@ func (bc *Blockchain) FindUTXO() map[string][]transaction.TxOutput {
 				}
 
 				outs := UTXO[txID]
-				outs = append(outs, out)
+				outs = append(outs, UTXOEntry{
+					Index:      outIdx,
+					Height:     block.Height,
+					Time:       medianTime,
+					IsCoinbase: tx.IsCoinbase(),
+					Output:     out,
+				})
 				UTXO[txID] = outs
 			}
 
@@ -443,6 +582,12 @@ func (bc *Blockchain) FindUTXO() map[string][]transaction.TxOutput {
 
 // SignTransaction signs a transaction using the provided wallet
 func (bc *Blockchain) SignTransaction(tx *transaction.Transaction, w *wallet.Wallet) error {
+	return bc.SignTransactionWithHashType(tx, w, transaction.SigHashAll)
+}
+
+// SignTransactionWithHashType is like SignTransaction, committing each signature to
+// the parts of the transaction selected by hashType
+func (bc *Blockchain) SignTransactionWithHashType(tx *transaction.Transaction, w *wallet.Wallet, hashType transaction.SigHashType) error {
 	if tx.IsCoinbase() {
 		return nil
 	}
@@ -460,10 +605,11 @@ func (bc *Blockchain) SignTransaction(tx *transaction.Transaction, w *wallet.Wal
 		prevTXs[hex.EncodeToString(prevTX.ID)] = *prevTX
 	}
 
-	return tx.Sign(w, prevTXs)
+	return tx.SignWithHashType(w, prevTXs, bc.chainID, hashType)
 }
 
-// VerifyTransaction verifies transaction input signatures
+// VerifyTransaction verifies transaction input signatures, remembering them so that
+// validating a block including the transaction does not check them again
 func (bc *Blockchain) VerifyTransaction(tx *transaction.Transaction) error {
 	if tx.IsCoinbase() {
 		return nil
@@ -482,7 +628,7 @@ func (bc *Blockchain) VerifyTransaction(tx *transaction.Transaction) error {
 		prevTXs[hex.EncodeToString(prevTX.ID)] = *prevTX
 	}
 
-	valid, err := tx.Verify(prevTXs)
+	valid, err := tx.VerifyWithCache(prevTXs, bc.chainID, bc.sigCache)
 	if err != nil {
 		return err
 	}
@@ -522,6 +668,220 @@ func (bc *Blockchain) FindTransaction(ID []byte) (*transaction.Transaction, erro
 	return nil, fmt.Errorf("transaction not found")
 }
 
+// FindSpendingTransaction finds the transaction spending output vout of transaction
+// txid, or returns nil if the output has not been spent
+func (bc *Blockchain) FindSpendingTransaction(txid []byte, vout int) (*transaction.Transaction, error) {
+	bc.mu.RLock()
+	bci := &BlockchainIterator{bc.tip, bc.db}
+	bc.mu.RUnlock()
+
+	for {
+		block, err := bci.Next()
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, nil
+		}
+
+		for _, tx := range block.Transactions {
+			if tx.IsCoinbase() {
+				continue
+			}
+			for _, vin := range tx.Vin {
+				if bytes.Equal(vin.Txid, txid) && vin.Vout == vout {
+					return tx, nil
+				}
+			}
+		}
+	}
+}
+
+// FindNullData finds the earliest transaction with a null data output carrying data,
+// and the block that includes it. Both are nil if no transaction carries the data.
+func (bc *Blockchain) FindNullData(data []byte) (*transaction.Transaction, *block.Block, error) {
+	bc.mu.RLock()
+	bci := &BlockchainIterator{bc.tip, bc.db}
+	bc.mu.RUnlock()
+
+	var foundTx *transaction.Transaction
+	var foundBlock *block.Block
+	for {
+		blk, err := bci.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if blk == nil {
+			return foundTx, foundBlock, nil
+		}
+
+		// Blocks are visited from the tip, so keep the last match seen
+		if tx := findNullDataTx(blk, data); tx != nil {
+			foundTx, foundBlock = tx, blk
+		}
+	}
+}
+
+// findNullDataTx returns the first transaction in blk with a null data output
+// carrying data, or nil
+func findNullDataTx(blk *block.Block, data []byte) *transaction.Transaction {
+	for _, tx := range blk.Transactions {
+		for _, out := range tx.Vout {
+			if carried, ok := script.ExtractNullData(out.LockingScript); ok && bytes.Equal(carried, data) {
+				return tx
+			}
+		}
+	}
+	return nil
+}
+
+// UsedPubKeyHashes returns the hex encoded public key hashes that any output in the
+// chain pays to, spent or not
+func (bc *Blockchain) UsedPubKeyHashes() (map[string]bool, error) {
+	bc.mu.RLock()
+	bci := &BlockchainIterator{bc.tip, bc.db}
+	bc.mu.RUnlock()
+
+	used := make(map[string]bool)
+	for {
+		blk, err := bci.Next()
+		if err != nil {
+			return nil, err
+		}
+		if blk == nil {
+			return used, nil
+		}
+		for _, tx := range blk.Transactions {
+			for _, out := range tx.Vout {
+				if pubKeyHash := script.ExtractPubKeyHash(out.LockingScript); pubKeyHash != nil {
+					used[hex.EncodeToString(pubKeyHash)] = true
+				}
+			}
+		}
+	}
+}
+
+// GetBestHeight returns the height of the latest block
+func (bc *Blockchain) GetBestHeight() (int64, error) {
+	bc.mu.RLock()
+	tip := bc.tip
+	bc.mu.RUnlock()
+
+	tipBlock, err := bc.FindBlock(tip)
+	if err != nil {
+		return 0, err
+	}
+	return tipBlock.Height, nil
+}
+
+// MedianTimePast returns the median timestamp of the last blocks up to the tip, the
+// time a block built on the tip checks time locks against
+func (bc *Blockchain) MedianTimePast() (int64, error) {
+	bc.mu.RLock()
+	tip := bc.tip
+	bc.mu.RUnlock()
+
+	return bc.medianTimePast(tip)
+}
+
+// medianTimePast returns the median timestamp of the medianTimeBlocks blocks ending at
+// hash, or 0 if hash is empty. It does not take the lock, so MineBlock can use it.
+func (bc *Blockchain) medianTimePast(hash []byte) (int64, error) {
+	var timestamps []int64
+	iter := &BlockchainIterator{hash, bc.db}
+	for len(timestamps) < medianTimeBlocks {
+		b, err := iter.Next()
+		if err != nil {
+			return 0, err
+		}
+		if b == nil {
+			break
+		}
+		timestamps = append(timestamps, b.Timestamp)
+	}
+	if len(timestamps) == 0 {
+		return 0, nil
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2], nil
+}
+
+// IsDeploymentActive reports whether the named deployment is active for a block at the given height.
+// The height may be at most one above the current tip.
+func (bc *Blockchain) IsDeploymentActive(name string, height int64) (bool, error) {
+	prevHash, err := bc.ancestorHash(height - 1)
+	if err != nil {
+		return false, err
+	}
+	return consensus.IsDeploymentActive(bc.db, name, prevHash)
+}
+
+// DeploymentInfo describes the state of a deployment for the next block
+type DeploymentInfo struct {
+	Deployment    consensus.Deployment
+	State         consensus.ThresholdState
+	Height        int64 // Height of the next block, at which State applies
+	PeriodSignals int64 // Signaling blocks so far in the current period
+	PeriodElapsed int64 // Blocks so far in the current period
+}
+
+// GetDeploymentInfo returns the state of every known deployment for the next block
+func (bc *Blockchain) GetDeploymentInfo() ([]DeploymentInfo, error) {
+	bc.mu.RLock()
+	tip := bc.tip
+	bc.mu.RUnlock()
+
+	statuses, err := consensus.StatusAfter(bc.db, tip)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []DeploymentInfo
+	for i, d := range consensus.Deployments {
+		infos = append(infos, DeploymentInfo{
+			Deployment:    d,
+			State:         statuses[i].State,
+			Height:        statuses[i].Height,
+			PeriodSignals: statuses[i].PeriodSignals,
+			PeriodElapsed: statuses[i].PeriodElapsed,
+		})
+	}
+	return infos, nil
+}
+
+// ancestorHash returns the hash of the block at the given height of the chain, or
+// nil for height -1
+func (bc *Blockchain) ancestorHash(height int64) ([]byte, error) {
+	if height < 0 {
+		return nil, nil
+	}
+	bci := bc.Iterator()
+	for {
+		b, err := bci.Next()
+		if err != nil {
+			return nil, err
+		}
+		if b == nil || b.Height < height {
+			return nil, fmt.Errorf("height %d is beyond the next block", height+1)
+		}
+		if b.Height == height {
+			return b.Hash, nil
+		}
+	}
+}
+
+// ChainID returns the ID of the chain, derived from its network and genesis block,
+// which every transaction and block signature commits to
+func (bc *Blockchain) ChainID() transaction.ChainID {
+	return bc.chainID
+}
+
+// GetConfig returns the settings the blockchain was created with
+func (bc *Blockchain) GetConfig() Config {
+	return bc.config
+}
+
 // GetConsensus returns the consensus mechanism used by the blockchain
 func (bc *Blockchain) GetConsensus() consensus.Consensus {
 	bc.mu.RLock()
@@ -536,8 +896,13 @@ func (bc *Blockchain) CloseDB() error {
 	return bc.db.Close()
 }
 
-// DbExists checks if the blockchain database exists
+// DbExists checks if the blockchain database exists in the working directory
 func DbExists() bool {
-	_, err := os.Stat(dbFile)
+	return DbExistsInDir(".")
+}
+
+// DbExistsInDir checks if the blockchain database exists in dataDir
+func DbExistsInDir(dataDir string) bool {
+	_, err := os.Stat(filepath.Join(dataDir, dbFile))
 	return !os.IsNotExist(err)
 }
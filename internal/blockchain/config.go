@@ -0,0 +1,94 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/codec"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	configBucket = "config"
+	configKey    = "c"
+
+	// DefaultCoinbaseMaturity is the number of blocks that must be built on top of a
+	// coinbase before its outputs can be spent
+	DefaultCoinbaseMaturity = 100
+
+	// DefaultNetwork is the network of chains created without one, and of chains
+	// created before the network was stored
+	DefaultNetwork = "mainnet"
+)
+
+// Config holds the settings fixed when a blockchain is created. They are consensus
+// rules, so they are stored with the chain rather than passed on every start.
+type Config struct {
+	CoinbaseMaturity int64  // Blocks required on top of a coinbase before it can be spent
+	Network          string // Name of the network, part of the chain ID that signatures commit to
+}
+
+// DefaultConfig returns the settings used when none are given
+func DefaultConfig() Config {
+	return Config{
+		CoinbaseMaturity: DefaultCoinbaseMaturity,
+		Network:          DefaultNetwork,
+	}
+}
+
+// Validate checks that the settings are usable
+func (c Config) Validate() error {
+	if c.CoinbaseMaturity < 0 {
+		return fmt.Errorf("coinbase maturity cannot be negative")
+	}
+	if c.Network == "" {
+		return fmt.Errorf("network name cannot be empty")
+	}
+	return nil
+}
+
+// serialize encodes the config: CoinbaseMaturity (int64) and Network (bytes)
+func (c Config) serialize() []byte {
+	w := codec.NewWriter()
+	w.WriteInt64(c.CoinbaseMaturity)
+	w.WriteBytes([]byte(c.Network))
+	return w.Bytes()
+}
+
+// deserializeConfig decodes a config written by serialize. Configs stored before the
+// network was added end after CoinbaseMaturity and get DefaultNetwork.
+func deserializeConfig(data []byte) (Config, error) {
+	var c Config
+	r := codec.NewReader(data)
+	c.CoinbaseMaturity = r.ReadInt64()
+	c.Network = DefaultNetwork
+	if r.Remaining() > 0 {
+		c.Network = string(r.ReadBytes())
+	}
+	if err := r.Finish(); err != nil {
+		return Config{}, fmt.Errorf("failed to decode chain config: %v", err)
+	}
+	return c, nil
+}
+
+// saveConfig stores the config in the database
+func saveConfig(tx *bbolt.Tx, c Config) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(configBucket))
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(configKey), c.serialize())
+}
+
+// loadConfig reads the config from the database, falling back to the defaults for
+// chains created before settings were stored
+func loadConfig(tx *bbolt.Tx) (Config, error) {
+	b := tx.Bucket([]byte(configBucket))
+	if b == nil {
+		return DefaultConfig(), nil
+	}
+	data := b.Get([]byte(configKey))
+	if data == nil {
+		return DefaultConfig(), nil
+	}
+	return deserializeConfig(data)
+}
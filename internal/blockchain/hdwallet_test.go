@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// Test the chain reports the key hashes its outputs pay to, so a deterministic wallet
+// restored from its seed finds the addresses paid to
+func TestUsedPubKeyHashes(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	seed := bytes.Repeat([]byte{0x42}, 32)
+	h, err := wallet.NewHDWallet(seed)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	keyHash := func(account, chain, index uint32) []byte {
+		w, err := h.DeriveWallet(account, chain, index)
+		if err != nil {
+			t.Fatalf("Failed to derive key: %v", err)
+		}
+		return wallet.HashPubKey(w.PublicKey)
+	}
+
+	paid := [][]byte{
+		keyHash(0, wallet.ExternalChain, 0),
+		keyHash(0, wallet.ExternalChain, 2),
+		keyHash(0, wallet.InternalChain, 1),
+		keyHash(1, wallet.ExternalChain, 0),
+	}
+	tx := spendTx(genesisCoinbase(t, bc).ID, []int{0})
+	tx.Vout = nil
+	for _, pubKeyHash := range paid {
+		tx.Vout = append(tx.Vout, transaction.NewTxOutput(10, pubKeyHash))
+	}
+	tx.ID = tx.Hash()
+	if err := bc.SignTransaction(tx, minerWallet); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	if err := mineTx(bc, minerWallet, tx); err != nil {
+		t.Fatalf("Failed to mine transaction: %v", err)
+	}
+
+	used, err := bc.UsedPubKeyHashes()
+	if err != nil {
+		t.Fatalf("Failed to scan the chain: %v", err)
+	}
+	// The spent genesis output counts as well as the new ones
+	for _, pubKeyHash := range append(paid, wallet.HashPubKey(minerWallet.PublicKey)) {
+		if !used[hex.EncodeToString(pubKeyHash)] {
+			t.Errorf("Expected key hash %x to be used", pubKeyHash)
+		}
+	}
+	if used[hex.EncodeToString(keyHash(0, wallet.ExternalChain, 1))] {
+		t.Error("Key hash no output pays to is used")
+	}
+
+	restored, _ := wallet.NewHDWallet(seed)
+	isUsed := func(pubKeyHash []byte) bool { return used[hex.EncodeToString(pubKeyHash)] }
+	if err := restored.Rescan(isUsed, 5); err != nil {
+		t.Fatalf("Failed to rescan: %v", err)
+	}
+	want := []wallet.HDAccount{{Next: [2]uint32{3, 2}}, {Next: [2]uint32{1, 0}}}
+	if len(restored.Accounts) != len(want) || restored.Accounts[0] != want[0] || restored.Accounts[1] != want[1] {
+		t.Errorf("Expected accounts %v, got %v", want, restored.Accounts)
+	}
+}
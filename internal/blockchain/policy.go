@@ -0,0 +1,175 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+)
+
+// DefaultMinRelayFee is the fee per 1000 bytes required by default: one coin, the
+// lowest rate that is not zero, so a transaction paying no fee is rejected unless the
+// node is run with -minrelayfee 0
+const DefaultMinRelayFee = 1
+
+// RejectCode identifies the standardness rule a transaction broke
+type RejectCode int
+
+const (
+	RejectDust            RejectCode = iota // Spendable output is worth less than the dust threshold
+	RejectDataCarrier                       // Null data output is too large, or not the only one
+	RejectNonStandard                       // Output follows no standard script template
+	RejectInsufficientFee                   // Fee is below the minimum relay fee for the transaction's size
+)
+
+// rejectCodeNames maps each RejectCode to the name of the rule it reports
+var rejectCodeNames = map[RejectCode]string{
+	RejectDust:            "RejectDust",
+	RejectDataCarrier:     "RejectDataCarrier",
+	RejectNonStandard:     "RejectNonStandard",
+	RejectInsufficientFee: "RejectInsufficientFee",
+}
+
+// String returns the name of the rule
+func (c RejectCode) String() string {
+	if name, ok := rejectCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown RejectCode (%d)", int(c))
+}
+
+// PolicyError is returned when a transaction is valid but not standard, so the node
+// does not relay or mine it
+type PolicyError struct {
+	Code        RejectCode // Rule that failed
+	Description string     // Human readable details
+}
+
+// Error returns the rule name and its description
+func (e PolicyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// policyError creates a PolicyError for the given rule
+func policyError(code RejectCode, format string, args ...interface{}) PolicyError {
+	return PolicyError{Code: code, Description: fmt.Sprintf(format, args...)}
+}
+
+// Policy holds the standardness rules a node applies, on top of the consensus rules,
+// to transactions it relays or mines. Blocks with non-standard transactions are still
+// valid, so unlike Config the policy is not stored with the chain and each node may
+// choose its own.
+type Policy struct {
+	DustThreshold      int // Smallest value of a spendable output
+	MaxDataCarrierSize int // Most bytes a null data output may carry
+	MinRelayFee        int // Fee required per 1000 bytes of the encoded transaction
+}
+
+// DefaultPolicy returns the rules used when none are given
+func DefaultPolicy() Policy {
+	return Policy{
+		DustThreshold:      transaction.DustThreshold,
+		MaxDataCarrierSize: script.MaxDataCarrierSize,
+		MinRelayFee:        DefaultMinRelayFee,
+	}
+}
+
+// Validate checks that the rules are usable
+func (p Policy) Validate() error {
+	if p.DustThreshold < 0 {
+		return fmt.Errorf("dust threshold cannot be negative")
+	}
+	if p.MaxDataCarrierSize < 0 {
+		return fmt.Errorf("data carrier size cannot be negative")
+	}
+	if p.MinRelayFee < 0 {
+		return fmt.Errorf("minimum relay fee cannot be negative")
+	}
+	return nil
+}
+
+// RequiredFee returns the fee a transaction of size bytes must pay, rounded up
+func (p Policy) RequiredFee(size int) int {
+	return (size*p.MinRelayFee + 999) / 1000
+}
+
+// CheckTransaction reports why tx, whose inputs spend prevOutputs, is not standard,
+// or returns nil if it is. It assumes tx passed the consensus checks.
+func (p Policy) CheckTransaction(tx *transaction.Transaction, prevOutputs []transaction.TxOutput) error {
+	dataOutputs := 0
+	totalOut := 0
+	for i, out := range tx.Vout {
+		switch script.GetScriptClass(out.LockingScript) {
+		case script.NonStandardTy:
+			return policyError(RejectNonStandard, "output %d of transaction %x follows no standard script template", i, tx.ID)
+		case script.NullDataTy:
+			data, _ := script.ExtractNullData(out.LockingScript)
+			if len(data) > p.MaxDataCarrierSize {
+				return policyError(RejectDataCarrier, "output %d of transaction %x carries %d bytes, more than %d",
+					i, tx.ID, len(data), p.MaxDataCarrierSize)
+			}
+			dataOutputs++
+			if dataOutputs > 1 {
+				return policyError(RejectDataCarrier, "transaction %x has more than one null data output", tx.ID)
+			}
+			continue
+		}
+		if out.Value < p.DustThreshold {
+			return policyError(RejectDust, "output %d of transaction %x pays %d, less than the dust threshold %d",
+				i, tx.ID, out.Value, p.DustThreshold)
+		}
+		totalOut += out.Value
+	}
+
+	totalIn := 0
+	for _, prevOut := range prevOutputs {
+		totalIn += prevOut.Value
+	}
+	size := len(tx.Serialize())
+	if fee, required := totalIn-totalOut, p.RequiredFee(size); fee < required {
+		return policyError(RejectInsufficientFee, "transaction %x pays fee %d, less than %d for its %d bytes",
+			tx.ID, fee, required, size)
+	}
+	return nil
+}
+
+// Policy returns the standardness rules the node applies
+func (bc *Blockchain) Policy() Policy {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.policy
+}
+
+// SetPolicy replaces the standardness rules the node applies
+func (bc *Blockchain) SetPolicy(p Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.policy = p
+	return nil
+}
+
+// CheckStandard applies the node's policy to tx, which is submitted for relay or
+// mining. Its inputs must spend unspent outputs. A coinbase is always standard.
+func (bc *Blockchain) CheckStandard(tx *transaction.Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	utxoSet := UTXOSet{bc}
+	var prevOutputs []transaction.TxOutput
+	for i, vin := range tx.Vin {
+		entry, ok, err := utxoSet.FindOutput(vin.Txid, vin.Vout)
+		if err != nil {
+			return fmt.Errorf("failed to look up the output spent by input %d: %v", i, err)
+		}
+		if !ok {
+			return ruleError(ErrMissingTxOut, "input %d of transaction %x spends %x:%d, which does not exist or is already spent",
+				i, tx.ID, vin.Txid, vin.Vout)
+		}
+		prevOutputs = append(prevOutputs, entry.Output)
+	}
+	return bc.Policy().CheckTransaction(tx, prevOutputs)
+}
@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// Helper to assert err is a PolicyError with the expected code
+func assertPolicyError(t *testing.T, err error, code RejectCode) {
+	t.Helper()
+	var policyErr PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Expected PolicyError %s, got %v", code, err)
+	}
+	if policyErr.Code != code {
+		t.Errorf("Expected PolicyError %s, got %s", code, policyErr)
+	}
+}
+
+// Helper to create a transaction with the given outputs spending one output worth 50
+func policyTx(outputs ...transaction.TxOutput) (*transaction.Transaction, []transaction.TxOutput) {
+	tx := &transaction.Transaction{
+		Vin:  []transaction.TxInput{{Txid: []byte("previous"), Vout: 0}},
+		Vout: outputs,
+	}
+	tx.ID = tx.Hash()
+	return tx, []transaction.TxOutput{transaction.NewTxOutput(50, wallet.HashPubKey([]byte("owner")))}
+}
+
+// Test each standardness rule rejects with its own reason
+func TestPolicyCheckTransaction(t *testing.T) {
+	payment := transaction.NewTxOutput(20, wallet.HashPubKey([]byte("recipient")))
+	data, _ := script.NullDataScript([]byte("some data carried by the output"))
+	otherData, _ := script.NullDataScript([]byte("more data"))
+	policy := DefaultPolicy()
+
+	tx, prevOutputs := policyTx(payment, transaction.TxOutput{Value: 0, LockingScript: data})
+	if err := policy.CheckTransaction(tx, prevOutputs); err != nil {
+		t.Fatalf("Standard transaction was rejected: %v", err)
+	}
+
+	tx, prevOutputs = policyTx(payment, transaction.NewTxOutput(transaction.DustThreshold-1, wallet.HashPubKey([]byte("owner"))))
+	assertPolicyError(t, policy.CheckTransaction(tx, prevOutputs), RejectDust)
+
+	tx, prevOutputs = policyTx(payment, transaction.TxOutput{Value: 5, LockingScript: []byte{script.Op1}})
+	assertPolicyError(t, policy.CheckTransaction(tx, prevOutputs), RejectNonStandard)
+
+	tx, prevOutputs = policyTx(payment, transaction.TxOutput{Value: 0, LockingScript: data}, transaction.TxOutput{Value: 0, LockingScript: otherData})
+	assertPolicyError(t, policy.CheckTransaction(tx, prevOutputs), RejectDataCarrier)
+
+	small := policy
+	small.MaxDataCarrierSize = 10
+	tx, prevOutputs = policyTx(payment, transaction.TxOutput{Value: 0, LockingScript: data})
+	assertPolicyError(t, small.CheckTransaction(tx, prevOutputs), RejectDataCarrier)
+
+	// The default of one coin per 1000 bytes rejects a transaction paying no fee
+	tx, prevOutputs = policyTx(payment, transaction.NewTxOutput(30, wallet.HashPubKey([]byte("owner"))))
+	assertPolicyError(t, policy.CheckTransaction(tx, prevOutputs), RejectInsufficientFee)
+	tx, prevOutputs = policyTx(payment, transaction.NewTxOutput(29, wallet.HashPubKey([]byte("owner"))))
+	if err := policy.CheckTransaction(tx, prevOutputs); err != nil {
+		t.Errorf("Transaction paying the minimum relay fee was rejected: %v", err)
+	}
+
+	free := policy
+	free.MinRelayFee = 0
+	tx, prevOutputs = policyTx(payment, transaction.NewTxOutput(30, wallet.HashPubKey([]byte("owner"))))
+	if err := free.CheckTransaction(tx, prevOutputs); err != nil {
+		t.Errorf("Transaction paying no fee was rejected without a minimum relay fee: %v", err)
+	}
+}
+
+// Test the wallet pays the fee it is given and adds change below the dust threshold
+// to it
+func TestDustChangeFolded(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	w := wallet.NewWallet()
+	recipient := script.PayToPubKeyHash(wallet.HashPubKey([]byte("recipient")))
+	findSpendableOutputs := func([]byte, int) (int, map[string][]int, error) {
+		return 50, map[string][]int{"0011": {0}}, nil
+	}
+
+	tx, err := transaction.NewUTXOTransaction(w, recipient, 50-transaction.DustThreshold, 1, findSpendableOutputs)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if len(tx.Vout) != 1 {
+		t.Errorf("Expected dust change to be left as fee, got %d outputs", len(tx.Vout))
+	}
+
+	tx, err = transaction.NewUTXOTransaction(w, recipient, 50-transaction.DustThreshold-1, 1, findSpendableOutputs)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if len(tx.Vout) != 2 || tx.Vout[1].Value != transaction.DustThreshold {
+		t.Errorf("Expected change of %d, got outputs %+v", transaction.DustThreshold, tx.Vout)
+	}
+	if err := DefaultPolicy().CheckTransaction(tx, []transaction.TxOutput{{Value: 50}}); err != nil {
+		t.Errorf("Wallet transaction was rejected: %v", err)
+	}
+}
+
+// Test the node's policy is applied to transactions spending the chain's outputs and
+// can be replaced
+func TestCheckStandard(t *testing.T) {
+	bc, _ := createTestBlockchain(t)
+	cbTx := genesisCoinbase(t, bc)
+
+	tx := spendTx(cbTx.ID, []int{0}, transaction.Subsidy-1)
+	tx.Vout[0] = transaction.NewTxOutput(transaction.Subsidy-1, wallet.HashPubKey([]byte("recipient")))
+	tx.ID = tx.Hash()
+	if err := bc.CheckStandard(tx); err != nil {
+		t.Fatalf("Standard transaction was rejected: %v", err)
+	}
+
+	if err := bc.SetPolicy(Policy{MinRelayFee: -1}); err == nil {
+		t.Error("Expected a negative minimum relay fee to be refused")
+	}
+	policy := bc.Policy()
+	policy.MinRelayFee = 10000
+	if err := bc.SetPolicy(policy); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+	assertPolicyError(t, bc.CheckStandard(tx), RejectInsufficientFee)
+
+	missing := spendTx([]byte("missing"), []int{0}, 10)
+	assertRuleError(t, bc.CheckStandard(missing), ErrMissingTxOut)
+}
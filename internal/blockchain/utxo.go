@@ -3,20 +3,77 @@ package blockchain
 import (
     "bytes"
     "encoding/hex"
+    "fmt"
     "log"
 
     "github.com/OmSingh2003/decentralized-ledger/internal/block"
+    "github.com/OmSingh2003/decentralized-ledger/internal/codec"
     "github.com/OmSingh2003/decentralized-ledger/internal/transaction"
     "go.etcd.io/bbolt"
 )
 
 const utxoBucket = "chainstate"
 
+// minEntrySize is the smallest encoding of a UTXOEntry
+const minEntrySize = 4 + 8 + 8 + 1 + 8 + 4
+
 // UTXOSet represents UTXO set
 type UTXOSet struct {
     Blockchain *Blockchain
 }
 
+// UTXOEntry is an unspent output together with its index in the transaction that created it
+type UTXOEntry struct {
+    Index      int
+    Height     int64 // Height of the block that created the output
+    Time       int64 // Median time past of the blocks before the one that created the output
+    IsCoinbase bool  // Whether the output was created by a coinbase transaction
+    Output     transaction.TxOutput
+}
+
+// IsMature reports whether the output can be spent in a block at spendHeight.
+// Coinbase outputs need maturity blocks built on top of them first.
+func (e UTXOEntry) IsMature(spendHeight, maturity int64) bool {
+    return !e.IsCoinbase || spendHeight-e.Height >= maturity
+}
+
+// serializeEntries encodes the unspent outputs of one transaction: a list of
+// Index (int32), Height (int64), Time (int64) and IsCoinbase (bool) followed by the output
+func serializeEntries(entries []UTXOEntry) []byte {
+    w := codec.NewWriter()
+    w.WriteCount(len(entries))
+    for i := range entries {
+        w.WriteInt32(int32(entries[i].Index))
+        w.WriteInt64(entries[i].Height)
+        w.WriteInt64(entries[i].Time)
+        w.WriteBool(entries[i].IsCoinbase)
+        entries[i].Output.Encode(w)
+    }
+    return w.Bytes()
+}
+
+// deserializeEntries decodes the unspent outputs of one transaction
+func deserializeEntries(data []byte) ([]UTXOEntry, error) {
+    var entries []UTXOEntry
+
+    r := codec.NewReader(data)
+    n := r.ReadCount(minEntrySize)
+    for i := 0; i < n; i++ {
+        var entry UTXOEntry
+        entry.Index = int(r.ReadInt32())
+        entry.Height = r.ReadInt64()
+        entry.Time = r.ReadInt64()
+        entry.IsCoinbase = r.ReadBool()
+        entry.Output = transaction.DecodeTxOutput(r)
+        entries = append(entries, entry)
+    }
+    if err := r.Finish(); err != nil {
+        return nil, fmt.Errorf("failed to decode UTXO entries: %v", err)
+    }
+
+    return entries, nil
+}
+
 // Reindex rebuilds the UTXO set
 func (u UTXOSet) Reindex() error {
     db := u.Blockchain.db
@@ -41,13 +98,13 @@ func (u UTXOSet) Reindex() error {
     err = db.Update(func(tx *bbolt.Tx) error {
         b := tx.Bucket(bucketName)
 
-        for txID, outs := range UTXO {
+        for txID, entries := range UTXO {
             key, err := hex.DecodeString(txID)
             if err != nil {
                 return err
             }
 
-            err = b.Put(key, transaction.SerializeOutputs(outs))
+            err = b.Put(key, serializeEntries(entries))
             if err != nil {
                 return err
             }
@@ -59,13 +116,36 @@ func (u UTXOSet) Reindex() error {
     return err
 }
 
-// FindSpendableOutputs finds and returns unspent outputs to reference in inputs
+// FindSpendableOutputs finds and returns unspent outputs locked with pubkeyHash to
+// reference in inputs. Coinbase outputs that are not yet mature for the next block are skipped.
 func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[string][]int, error) {
+    return u.findSpendable(func(out transaction.TxOutput) bool {
+        return out.IsLockedWithKey(pubkeyHash)
+    }, amount)
+}
+
+// FindSpendableScriptOutputs is like FindSpendableOutputs for outputs locked with
+// exactly lockingScript
+func (u UTXOSet) FindSpendableScriptOutputs(lockingScript []byte, amount int) (int, map[string][]int, error) {
+    return u.findSpendable(func(out transaction.TxOutput) bool {
+        return bytes.Equal(out.LockingScript, lockingScript)
+    }, amount)
+}
+
+// findSpendable selects mature unspent outputs accepted by match until amount is reached
+func (u UTXOSet) findSpendable(match func(transaction.TxOutput) bool, amount int) (int, map[string][]int, error) {
     unspentOutputs := make(map[string][]int)
     accumulated := 0
     db := u.Blockchain.db
 
-    err := db.View(func(tx *bbolt.Tx) error {
+    bestHeight, err := u.Blockchain.GetBestHeight()
+    if err != nil {
+        return 0, nil, err
+    }
+    spendHeight := bestHeight + 1
+    maturity := u.Blockchain.config.CoinbaseMaturity
+
+    err = db.View(func(tx *bbolt.Tx) error {
         b := tx.Bucket([]byte(utxoBucket))
         if b == nil {
             return bbolt.ErrBucketNotFound
@@ -75,12 +155,18 @@ func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[s
 
         for k, v := c.First(); k != nil; k, v = c.Next() {
             txID := hex.EncodeToString(k)
-            outs := transaction.DeserializeOutputs(v)
+            entries, err := deserializeEntries(v)
+            if err != nil {
+                return err
+            }
 
-            for outIdx, out := range outs {
-                if out.IsLockedWithKey(pubkeyHash) && accumulated < amount {
-                    accumulated += out.Value
-                    unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
+            for _, entry := range entries {
+                if !entry.IsMature(spendHeight, maturity) {
+                    continue
+                }
+                if match(entry.Output) && accumulated < amount {
+                    accumulated += entry.Output.Value
+                    unspentOutputs[txID] = append(unspentOutputs[txID], entry.Index)
 
                     if accumulated >= amount {
                         break
@@ -113,14 +199,17 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []transaction.TxOutput {
         c := b.Cursor()
 
         for k, v := c.First(); k != nil; k, v = c.Next() {
-            outs := transaction.DeserializeOutputs(v)
+            entries, err := deserializeEntries(v)
+            if err != nil {
+                return err
+            }
 
-            for _, out := range outs {
+            for _, entry := range entries {
                 // Check if this is a query for all UTXOs or specifically for this pubKeyHash
                 if pubKeyHash == nil {
-                    UTXOs = append(UTXOs, out)
-                } else if bytes.Compare(out.PubKeyHash, pubKeyHash) == 0 {
-                    UTXOs = append(UTXOs, out)
+                    UTXOs = append(UTXOs, entry.Output)
+                } else if entry.Output.IsLockedWithKey(pubKeyHash) {
+                    UTXOs = append(UTXOs, entry.Output)
                 }
             }
         }
@@ -136,11 +225,124 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []transaction.TxOutput {
     return UTXOs
 }
 
-// Update updates the UTXO set with the transactions from the Block
+// FindBalance returns the value of the outputs locked with pubKeyHash that can be spent in
+// the next block, and the value of coinbase outputs that are not mature yet
+func (u UTXOSet) FindBalance(pubKeyHash []byte) (int, int, error) {
+    return u.findBalance(func(out transaction.TxOutput) bool {
+        return out.IsLockedWithKey(pubKeyHash)
+    })
+}
+
+// FindScriptBalance is like FindBalance for outputs locked with exactly lockingScript
+func (u UTXOSet) FindScriptBalance(lockingScript []byte) (int, int, error) {
+    return u.findBalance(func(out transaction.TxOutput) bool {
+        return bytes.Equal(out.LockingScript, lockingScript)
+    })
+}
+
+// findBalance sums the spendable and immature unspent outputs accepted by match
+func (u UTXOSet) findBalance(match func(transaction.TxOutput) bool) (int, int, error) {
+    bestHeight, err := u.Blockchain.GetBestHeight()
+    if err != nil {
+        return 0, 0, err
+    }
+    spendHeight := bestHeight + 1
+    maturity := u.Blockchain.config.CoinbaseMaturity
+
+    spendable, immature := 0, 0
+    err = u.Blockchain.db.View(func(tx *bbolt.Tx) error {
+        b := tx.Bucket([]byte(utxoBucket))
+        if b == nil {
+            return bbolt.ErrBucketNotFound
+        }
+
+        c := b.Cursor()
+        for k, v := c.First(); k != nil; k, v = c.Next() {
+            entries, err := deserializeEntries(v)
+            if err != nil {
+                return err
+            }
+
+            for _, entry := range entries {
+                if !match(entry.Output) {
+                    continue
+                }
+                if entry.IsMature(spendHeight, maturity) {
+                    spendable += entry.Output.Value
+                } else {
+                    immature += entry.Output.Value
+                }
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return 0, 0, err
+    }
+
+    return spendable, immature, nil
+}
+
+// FindOutput returns the unspent output at index vout of transaction txid.
+// The boolean is false if the output does not exist or has been spent.
+func (u UTXOSet) FindOutput(txid []byte, vout int) (UTXOEntry, bool, error) {
+    var output UTXOEntry
+    found := false
+
+    err := u.Blockchain.db.View(func(tx *bbolt.Tx) error {
+        b := tx.Bucket([]byte(utxoBucket))
+        if b == nil {
+            return bbolt.ErrBucketNotFound
+        }
+
+        data := b.Get(txid)
+        if data == nil {
+            return nil
+        }
+
+        entries, err := deserializeEntries(data)
+        if err != nil {
+            return err
+        }
+
+        for _, entry := range entries {
+            if entry.Index == vout {
+                output = entry
+                found = true
+                break
+            }
+        }
+        return nil
+    })
+
+    return output, found, err
+}
+
+// HasUnspentOutputs reports whether transaction txid has any unspent outputs
+func (u UTXOSet) HasUnspentOutputs(txid []byte) (bool, error) {
+    found := false
+    err := u.Blockchain.db.View(func(tx *bbolt.Tx) error {
+        b := tx.Bucket([]byte(utxoBucket))
+        if b == nil {
+            return bbolt.ErrBucketNotFound
+        }
+        found = b.Get(txid) != nil
+        return nil
+    })
+    return found, err
+}
+
+// Update updates the UTXO set with the transactions from the Block.
+// A transaction whose ID still has unspent outputs is rejected rather than overwriting them.
 func (u UTXOSet) Update(block *block.Block) error {
     db := u.Blockchain.db
 
-    err := db.Update(func(tx *bbolt.Tx) error {
+    medianTime, err := u.Blockchain.medianTimePast(block.PrevBlockHash)
+    if err != nil {
+        return err
+    }
+
+    err = db.Update(func(tx *bbolt.Tx) error {
         b := tx.Bucket([]byte(utxoBucket))
         if b == nil {
             return bbolt.ErrBucketNotFound
@@ -149,23 +351,25 @@ func (u UTXOSet) Update(block *block.Block) error {
         for _, tx := range block.Transactions {
             if !tx.IsCoinbase() {
                 for _, vin := range tx.Vin {
-                    updatedOuts := []transaction.TxOutput{}
-                    outsBytes := b.Get(vin.Txid)
-                    outs := transaction.DeserializeOutputs(outsBytes)
+                    updatedEntries := []UTXOEntry{}
+                    entries, err := deserializeEntries(b.Get(vin.Txid))
+                    if err != nil {
+                        return err
+                    }
 
-                    for outIdx, out := range outs {
-                        if outIdx != vin.Vout {
-                            updatedOuts = append(updatedOuts, out)
+                    for _, entry := range entries {
+                        if entry.Index != vin.Vout {
+                            updatedEntries = append(updatedEntries, entry)
                         }
                     }
 
-                    if len(updatedOuts) == 0 {
+                    if len(updatedEntries) == 0 {
                         err := b.Delete(vin.Txid)
                         if err != nil {
                             return err
                         }
                     } else {
-                        err := b.Put(vin.Txid, transaction.SerializeOutputs(updatedOuts))
+                        err := b.Put(vin.Txid, serializeEntries(updatedEntries))
                         if err != nil {
                             return err
                         }
@@ -173,8 +377,26 @@ func (u UTXOSet) Update(block *block.Block) error {
                 }
             }
 
-            newOutputs := transaction.SerializeOutputs(tx.Vout)
-            err := b.Put(tx.ID, newOutputs)
+            var newEntries []UTXOEntry
+            for outIdx, out := range tx.Vout {
+                if out.IsUnspendable() {
+                    continue
+                }
+                newEntries = append(newEntries, UTXOEntry{
+                    Index:      outIdx,
+                    Height:     block.Height,
+                    Time:       medianTime,
+                    IsCoinbase: tx.IsCoinbase(),
+                    Output:     out,
+                })
+            }
+            if b.Get(tx.ID) != nil {
+                return fmt.Errorf("transaction %x already has unspent outputs", tx.ID)
+            }
+            if len(newEntries) == 0 {
+                continue
+            }
+            err := b.Put(tx.ID, serializeEntries(newEntries))
             if err != nil {
                 return err
             }
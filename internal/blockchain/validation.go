@@ -0,0 +1,315 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/block"
+	"github.com/OmSingh2003/decentralized-ledger/internal/consensus"
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+)
+
+// ErrorCode identifies the consensus rule a block or transaction violated
+type ErrorCode int
+
+const (
+	ErrNoTransactions     ErrorCode = iota // Block has no transactions
+	ErrFirstTxNotCoinbase                  // First transaction of the block is not a coinbase
+	ErrMultipleCoinbases                   // A transaction other than the first is a coinbase
+	ErrNoTxInputs                          // Transaction has no inputs
+	ErrNoTxOutputs                         // Transaction has no outputs
+	ErrBadTxOutValue                       // Output value is zero or negative
+	ErrValueOverflow                       // Sum of input or output values overflows
+	ErrMissingTxOut                        // Input spends an output that does not exist or is already spent
+	ErrDoubleSpend                         // Two inputs in the block spend the same output
+	ErrSpendTooHigh                        // Transaction outputs exceed its inputs
+	ErrBadCoinbaseValue                    // Coinbase creates more than the subsidy plus fees
+	ErrImmatureSpend                       // Input spends a coinbase output before it is mature
+	ErrBadTxID                             // Transaction ID does not match the transaction hash
+	ErrDuplicateTx                         // Transaction ID already has unspent outputs or repeats in the block
+	ErrBadCoinbaseHeight                   // Coinbase does not commit to the height of its block
+	ErrBadLockTime                         // Transaction lock time is negative
+	ErrUnfinalizedTx                       // Transaction lock time has not been reached
+	ErrSequenceLocked                      // Input's relative lock time has not passed since the spent output was confirmed
+	ErrBadDataCarrier                      // Unspendable output is not a null data output of allowed size
+)
+
+// errorCodeNames maps each ErrorCode to the name of the rule it reports
+var errorCodeNames = map[ErrorCode]string{
+	ErrNoTransactions:     "ErrNoTransactions",
+	ErrFirstTxNotCoinbase: "ErrFirstTxNotCoinbase",
+	ErrMultipleCoinbases:  "ErrMultipleCoinbases",
+	ErrNoTxInputs:         "ErrNoTxInputs",
+	ErrNoTxOutputs:        "ErrNoTxOutputs",
+	ErrBadTxOutValue:      "ErrBadTxOutValue",
+	ErrValueOverflow:      "ErrValueOverflow",
+	ErrMissingTxOut:       "ErrMissingTxOut",
+	ErrDoubleSpend:        "ErrDoubleSpend",
+	ErrSpendTooHigh:       "ErrSpendTooHigh",
+	ErrBadCoinbaseValue:   "ErrBadCoinbaseValue",
+	ErrImmatureSpend:      "ErrImmatureSpend",
+	ErrBadTxID:            "ErrBadTxID",
+	ErrDuplicateTx:        "ErrDuplicateTx",
+	ErrBadCoinbaseHeight:  "ErrBadCoinbaseHeight",
+	ErrBadLockTime:        "ErrBadLockTime",
+	ErrUnfinalizedTx:      "ErrUnfinalizedTx",
+	ErrSequenceLocked:     "ErrSequenceLocked",
+	ErrBadDataCarrier:     "ErrBadDataCarrier",
+}
+
+// String returns the name of the rule
+func (e ErrorCode) String() string {
+	if name, ok := errorCodeNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown ErrorCode (%d)", int(e))
+}
+
+// RuleError is returned when a block or transaction violates a consensus rule
+type RuleError struct {
+	Code        ErrorCode // Rule that failed
+	Description string    // Human readable details
+}
+
+// Error returns the rule name and its description
+func (e RuleError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// ruleError creates a RuleError for the given rule
+func ruleError(code ErrorCode, format string, args ...interface{}) RuleError {
+	return RuleError{Code: code, Description: fmt.Sprintf(format, args...)}
+}
+
+// outpointKey identifies an output by its transaction ID and index
+func outpointKey(txid []byte, vout int) string {
+	return fmt.Sprintf("%s:%d", hex.EncodeToString(txid), vout)
+}
+
+// addValue adds v to sum, reporting whether the result overflows
+func addValue(sum, v int) (int, bool) {
+	if v > 0 && sum > math.MaxInt-v {
+		return 0, false
+	}
+	return sum + v, true
+}
+
+// deploymentRules holds the consensus rules introduced by deployments, keyed by the
+// deployment name. CheckConnectBlock applies each rule to the blocks its deployment
+// is active for; a rule reports a violation as RuleError.
+var deploymentRules = map[string]func(bc *Blockchain, b *block.Block) error{}
+
+// checkDeploymentRules applies the rule of every deployment active for b
+func (bc *Blockchain) checkDeploymentRules(b *block.Block) error {
+	for _, d := range consensus.Deployments {
+		rule, ok := deploymentRules[d.Name]
+		if !ok {
+			continue
+		}
+		active, err := consensus.IsDeploymentActive(bc.db, d.Name, b.PrevBlockHash)
+		if err != nil {
+			return fmt.Errorf("failed to compute state of deployment %s: %v", d.Name, err)
+		}
+		if active {
+			if err := rule(bc, b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkTransactionSanity runs the context-free checks on a transaction's shape and outputs
+func checkTransactionSanity(tx *transaction.Transaction) error {
+	if !tx.HasValidID() {
+		return ruleError(ErrBadTxID, "transaction ID %x does not match its hash %x", tx.ID, tx.Hash())
+	}
+	if len(tx.Vin) == 0 {
+		return ruleError(ErrNoTxInputs, "transaction %x has no inputs", tx.ID)
+	}
+	if len(tx.Vout) == 0 {
+		return ruleError(ErrNoTxOutputs, "transaction %x has no outputs", tx.ID)
+	}
+	if tx.LockTime < 0 {
+		return ruleError(ErrBadLockTime, "transaction %x has negative lock time %d", tx.ID, tx.LockTime)
+	}
+
+	total := 0
+	for i, out := range tx.Vout {
+		if out.IsUnspendable() {
+			// Null data outputs carry no value and never enter the UTXO set
+			if out.Value != 0 {
+				return ruleError(ErrBadTxOutValue, "null data output %d of transaction %x has value %d", i, tx.ID, out.Value)
+			}
+			if _, ok := script.ExtractNullData(out.LockingScript); !ok {
+				return ruleError(ErrBadDataCarrier, "output %d of transaction %x is unspendable but not a null data output of at most %d bytes",
+					i, tx.ID, script.MaxDataCarrierSize)
+			}
+			continue
+		}
+		if out.Value <= 0 {
+			return ruleError(ErrBadTxOutValue, "output %d of transaction %x has value %d", i, tx.ID, out.Value)
+		}
+		var ok bool
+		total, ok = addValue(total, out.Value)
+		if !ok {
+			return ruleError(ErrValueOverflow, "output values of transaction %x overflow", tx.ID)
+		}
+	}
+	return nil
+}
+
+// sequenceLockPassed reports whether the relative lock time of vin, which spends the
+// output in entry, allows it in a block at the given height and median time past
+func sequenceLockPassed(vin transaction.TxInput, entry UTXOEntry, height, medianTime int64) bool {
+	value, isSeconds, ok := vin.RelativeLock()
+	if !ok {
+		return true
+	}
+	if isSeconds {
+		return medianTime >= entry.Time+value
+	}
+	return height >= entry.Height+value
+}
+
+// CheckConnectBlock validates the transactions of a block against the UTXO set before it
+// is connected to the chain. Every input must spend an existing unspent output, possibly
+// created earlier in the same block, and no output may be spent twice. Transactions may
+// not create value, coinbase outputs must have reached the configured maturity, and the
+// coinbase may claim at most the subsidy plus the fees. Every transaction ID must match
+// the transaction's hash and may not collide with a transaction that still has unspent
+// outputs, and the coinbase must commit to the block height. A transaction's lock time
+// must have been reached by the block height or by the median time past of the
+// preceding blocks, and the relative lock time of each input must have passed since
+// the output it spends was confirmed. The rules of deployments active for the block
+// apply as well.
+// Violations are reported as RuleError.
+func (bc *Blockchain) CheckConnectBlock(b *block.Block) error {
+	if len(b.Transactions) == 0 {
+		return ruleError(ErrNoTransactions, "block %x has no transactions", b.Hash)
+	}
+	if !b.Transactions[0].IsCoinbase() {
+		return ruleError(ErrFirstTxNotCoinbase, "first transaction of block %x is not a coinbase", b.Hash)
+	}
+	cbHeight, err := transaction.CoinbaseHeight(b.Transactions[0])
+	if err != nil || cbHeight != b.Height {
+		return ruleError(ErrBadCoinbaseHeight, "coinbase of block %x at height %d does not commit to its height", b.Hash, b.Height)
+	}
+	if err := bc.checkDeploymentRules(b); err != nil {
+		return err
+	}
+
+	medianTime, err := bc.medianTimePast(b.PrevBlockHash)
+	if err != nil {
+		return fmt.Errorf("failed to compute median time past: %v", err)
+	}
+
+	utxoSet := UTXOSet{bc}
+	created := make(map[string]UTXOEntry) // Outputs created by earlier transactions in the block
+	spent := make(map[string]bool)        // Outputs spent by earlier inputs in the block
+	seen := make(map[string]bool)         // IDs of earlier transactions in the block
+	fees := 0
+
+	for i, tx := range b.Transactions {
+		if i > 0 && tx.IsCoinbase() {
+			return ruleError(ErrMultipleCoinbases, "transaction %d of block %x is a second coinbase", i, b.Hash)
+		}
+		if err := checkTransactionSanity(tx); err != nil {
+			return err
+		}
+
+		txid := hex.EncodeToString(tx.ID)
+		if seen[txid] {
+			return ruleError(ErrDuplicateTx, "transaction %s appears twice in block %x", txid, b.Hash)
+		}
+		seen[txid] = true
+		if !tx.IsFinal(b.Height, medianTime) {
+			return ruleError(ErrUnfinalizedTx, "transaction %s is locked until %d, block %x is at height %d with median time %d",
+				txid, tx.LockTime, b.Hash, b.Height, medianTime)
+		}
+		exists, err := utxoSet.HasUnspentOutputs(tx.ID)
+		if err != nil {
+			return fmt.Errorf("failed to look up transaction %s: %v", txid, err)
+		}
+		if exists {
+			return ruleError(ErrDuplicateTx, "transaction %s would overwrite unspent outputs", txid)
+		}
+
+		if !tx.IsCoinbase() {
+			totalIn := 0
+			for _, vin := range tx.Vin {
+				key := outpointKey(vin.Txid, vin.Vout)
+				if spent[key] {
+					return ruleError(ErrDoubleSpend, "output %s is spent twice in block %x", key, b.Hash)
+				}
+
+				entry, ok := created[key]
+				if !ok {
+					var err error
+					entry, ok, err = utxoSet.FindOutput(vin.Txid, vin.Vout)
+					if err != nil {
+						return fmt.Errorf("failed to look up output %s: %v", key, err)
+					}
+				}
+				if !ok {
+					return ruleError(ErrMissingTxOut, "transaction %x spends missing or spent output %s", tx.ID, key)
+				}
+				if !entry.IsMature(b.Height, bc.config.CoinbaseMaturity) {
+					return ruleError(ErrImmatureSpend, "transaction %x spends coinbase output %s from height %d at height %d, maturity is %d",
+						tx.ID, key, entry.Height, b.Height, bc.config.CoinbaseMaturity)
+				}
+				if !sequenceLockPassed(vin, entry, b.Height, medianTime) {
+					return ruleError(ErrSequenceLocked, "transaction %x spends output %s confirmed at height %d before its relative lock time %d passed",
+						tx.ID, key, entry.Height, vin.Sequence)
+				}
+				spent[key] = true
+
+				totalIn, ok = addValue(totalIn, entry.Output.Value)
+				if !ok {
+					return ruleError(ErrValueOverflow, "input values of transaction %x overflow", tx.ID)
+				}
+			}
+
+			totalOut := 0
+			for _, out := range tx.Vout {
+				totalOut += out.Value // Overflow was ruled out by checkTransactionSanity
+			}
+			if totalOut > totalIn {
+				return ruleError(ErrSpendTooHigh, "transaction %x spends %d but only has %d in inputs", tx.ID, totalOut, totalIn)
+			}
+
+			var ok bool
+			fees, ok = addValue(fees, totalIn-totalOut)
+			if !ok {
+				return ruleError(ErrValueOverflow, "fees of block %x overflow", b.Hash)
+			}
+		}
+
+		for outIdx, out := range tx.Vout {
+			if out.IsUnspendable() {
+				continue
+			}
+			created[outpointKey(tx.ID, outIdx)] = UTXOEntry{
+				Index:      outIdx,
+				Height:     b.Height,
+				Time:       medianTime,
+				IsCoinbase: tx.IsCoinbase(),
+				Output:     out,
+			}
+		}
+	}
+
+	coinbaseOut := 0
+	for _, out := range b.Transactions[0].Vout {
+		coinbaseOut += out.Value
+	}
+	maxCoinbase, ok := addValue(transaction.Subsidy, fees)
+	if !ok || coinbaseOut > maxCoinbase {
+		return ruleError(ErrBadCoinbaseValue, "coinbase of block %x pays %d, more than subsidy %d plus fees %d",
+			b.Hash, coinbaseOut, transaction.Subsidy, fees)
+	}
+
+	return nil
+}
@@ -0,0 +1,1049 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/block"
+	"github.com/OmSingh2003/decentralized-ledger/internal/consensus"
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// Helper to create a blockchain with a genesis block in a temporary directory.
+// Coinbase maturity is disabled so the genesis reward can be spent right away.
+func createTestBlockchain(t *testing.T) (*Blockchain, *wallet.Wallet) {
+	return createTestBlockchainWithConfig(t, Config{CoinbaseMaturity: 0, Network: DefaultNetwork})
+}
+
+// Helper to create a blockchain with the given config in a temporary directory, with
+// wallets in a temporary home directory
+func createTestBlockchainWithConfig(t *testing.T, config Config) (*Blockchain, *wallet.Wallet) {
+	t.Setenv("HOME", t.TempDir())
+	minerWallet := wallet.NewWallet()
+	bc, err := createBlockchain(filepath.Join(t.TempDir(), "test.db"), minerWallet, config)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	t.Cleanup(func() { bc.CloseDB() })
+	return bc, minerWallet
+}
+
+// testChainID is the chain ID of tests that sign transactions without a blockchain
+var testChainID = transaction.NewChainID([]byte("test genesis"), DefaultNetwork)
+
+// Helper to get the genesis coinbase transaction
+func genesisCoinbase(t *testing.T, bc *Blockchain) *transaction.Transaction {
+	genesis, err := bc.FindBlock(bc.tip)
+	if err != nil {
+		t.Fatalf("Failed to find genesis block: %v", err)
+	}
+	return genesis.Transactions[0]
+}
+
+// Helper to create an unsigned transaction spending the given outpoints
+func spendTx(prevID []byte, vouts []int, values ...int) *transaction.Transaction {
+	tx := &transaction.Transaction{}
+	for _, vout := range vouts {
+		tx.Vin = append(tx.Vin, transaction.TxInput{Txid: prevID, Vout: vout})
+	}
+	for _, value := range values {
+		tx.Vout = append(tx.Vout, transaction.NewTxOutput(value, []byte("recipient")))
+	}
+	tx.ID = tx.Hash()
+	return tx
+}
+
+// Helper to build a block on top of the tip with a coinbase paying coinbaseValue
+func testBlock(bc *Blockchain, minerWallet *wallet.Wallet, coinbaseValue int, txs ...*transaction.Transaction) *block.Block {
+	height, _ := bc.GetBestHeight()
+	return testBlockAtHeight(bc, minerWallet, height+1, coinbaseValue, txs...)
+}
+
+// Helper to build a block at the given height with a coinbase paying coinbaseValue
+func testBlockAtHeight(bc *Blockchain, minerWallet *wallet.Wallet, height int64, coinbaseValue int, txs ...*transaction.Transaction) *block.Block {
+	cbTx := transaction.NewCoinbaseTx(minerWallet.PublicKey, "", height)
+	cbTx.Vout[0].Value = coinbaseValue
+	cbTx.ID = cbTx.Hash()
+	b := block.NewBlock(append([]*transaction.Transaction{cbTx}, txs...), bc.tip)
+	b.SetHeight(height)
+	return b
+}
+
+// Helper to assert err is a RuleError with the expected code
+func assertRuleError(t *testing.T, err error, code ErrorCode) {
+	t.Helper()
+	var ruleErr RuleError
+	if !errors.As(err, &ruleErr) {
+		t.Fatalf("Expected RuleError %s, got %v", code, err)
+	}
+	if ruleErr.Code != code {
+		t.Errorf("Expected RuleError %s, got %s", code, ruleErr)
+	}
+}
+
+// Test a block spending existing outputs with fees is accepted
+func TestCheckConnectBlockValid(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	prevID := genesisCoinbase(t, bc).ID
+
+	spend := spendTx(prevID, []int{0}, 30, 15)
+	chained := spendTx(spend.ID, []int{1}, 15) // Spends an output created earlier in the block
+	b := testBlock(bc, minerWallet, transaction.Subsidy+5, spend, chained)
+
+	if err := bc.CheckConnectBlock(b); err != nil {
+		t.Fatalf("Valid block was rejected: %v", err)
+	}
+}
+
+// Test each rule of the connect validator reports its own error code
+func TestCheckConnectBlockRules(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	prevID := genesisCoinbase(t, bc).ID
+
+	tests := []struct {
+		name  string
+		block *block.Block
+		code  ErrorCode
+	}{
+		{"zero value output", testBlock(bc, minerWallet, transaction.Subsidy, spendTx(prevID, []int{0}, 50, 0)), ErrBadTxOutValue},
+		{"negative value output", testBlock(bc, minerWallet, transaction.Subsidy, spendTx(prevID, []int{0}, 60, -10)), ErrBadTxOutValue},
+		{"value creation", testBlock(bc, minerWallet, transaction.Subsidy, spendTx(prevID, []int{0}, 51)), ErrSpendTooHigh},
+		{"missing output", testBlock(bc, minerWallet, transaction.Subsidy, spendTx(prevID, []int{1}, 10)), ErrMissingTxOut},
+		{"double spend in transaction", testBlock(bc, minerWallet, transaction.Subsidy, spendTx(prevID, []int{0, 0}, 10)), ErrDoubleSpend},
+		{"double spend in block", testBlock(bc, minerWallet, transaction.Subsidy,
+			spendTx(prevID, []int{0}, 50), spendTx(prevID, []int{0}, 40)), ErrDoubleSpend},
+		{"output overflow", testBlock(bc, minerWallet, transaction.Subsidy,
+			spendTx(prevID, []int{0}, int(^uint(0)>>1), 1)), ErrValueOverflow},
+		{"coinbase too large", testBlock(bc, minerWallet, transaction.Subsidy+1), ErrBadCoinbaseValue},
+		{"second coinbase", testBlock(bc, minerWallet, transaction.Subsidy,
+			transaction.NewCoinbaseTx(minerWallet.PublicKey, "", 1)), ErrMultipleCoinbases},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertRuleError(t, bc.CheckConnectBlock(tt.block), tt.code)
+		})
+	}
+}
+
+// Test transaction IDs must match their hash and may not be reused
+func TestCheckConnectBlockTransactionIDs(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	genesisCb := genesisCoinbase(t, bc)
+	prevID := genesisCb.ID
+
+	tampered := spendTx(prevID, []int{0}, 50)
+	tampered.Vout[0].Value = 40
+	assertRuleError(t, bc.CheckConnectBlock(testBlock(bc, minerWallet, transaction.Subsidy, tampered)), ErrBadTxID)
+
+	// Unlocking scripts are not part of the ID, so signing does not change it
+	signed := spendTx(prevID, []int{0}, 50)
+	signed.Vin[0].UnlockingScript = []byte("signature")
+	if err := bc.CheckConnectBlock(testBlock(bc, minerWallet, transaction.Subsidy, signed)); err != nil {
+		t.Fatalf("Signed transaction was rejected: %v", err)
+	}
+
+	spend := spendTx(prevID, []int{0}, 50)
+	assertRuleError(t, bc.CheckConnectBlock(testBlock(bc, minerWallet, transaction.Subsidy, spend, spend)), ErrDuplicateTx)
+
+	// A copy of the genesis coinbase would overwrite its unspent output
+	replay := testBlockAtHeight(bc, minerWallet, 0, transaction.Subsidy)
+	replay.Transactions[0] = genesisCb
+	assertRuleError(t, bc.CheckConnectBlock(replay), ErrDuplicateTx)
+	if err := (UTXOSet{bc}).Update(replay); err == nil {
+		t.Error("UTXO set update overwrote unspent outputs")
+	}
+}
+
+// Test the coinbase must commit to the height of its block
+func TestCheckConnectBlockCoinbaseHeight(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+
+	b := testBlock(bc, minerWallet, transaction.Subsidy)
+	b.SetHeight(5)
+	assertRuleError(t, bc.CheckConnectBlock(b), ErrBadCoinbaseHeight)
+
+	// Coinbases of different heights paying the same key get different IDs
+	first := transaction.NewCoinbaseTx(minerWallet.PublicKey, "reward", 1)
+	second := transaction.NewCoinbaseTx(minerWallet.PublicKey, "reward", 2)
+	if string(first.ID) == string(second.ID) {
+		t.Error("Coinbases at different heights have the same ID")
+	}
+}
+
+// Test outputs spent in a connected block can no longer be spent
+func TestCheckConnectBlockSpentOutput(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	prevID := genesisCoinbase(t, bc).ID
+
+	first := testBlock(bc, minerWallet, transaction.Subsidy, spendTx(prevID, []int{0}, 50))
+	utxoSet := UTXOSet{bc}
+	if err := utxoSet.Update(first); err != nil {
+		t.Fatalf("Failed to update UTXO set: %v", err)
+	}
+
+	second := testBlock(bc, minerWallet, transaction.Subsidy, spendTx(prevID, []int{0}, 49))
+	assertRuleError(t, bc.CheckConnectBlock(second), ErrMissingTxOut)
+}
+
+// Test coinbase outputs cannot be spent before they are mature
+func TestCheckConnectBlockCoinbaseMaturity(t *testing.T) {
+	bc, minerWallet := createTestBlockchainWithConfig(t, Config{CoinbaseMaturity: 2, Network: DefaultNetwork})
+	prevID := genesisCoinbase(t, bc).ID
+
+	immature := testBlock(bc, minerWallet, transaction.Subsidy, spendTx(prevID, []int{0}, 50))
+	assertRuleError(t, bc.CheckConnectBlock(immature), ErrImmatureSpend)
+
+	mature := testBlockAtHeight(bc, minerWallet, 2, transaction.Subsidy, spendTx(prevID, []int{0}, 50))
+	if err := bc.CheckConnectBlock(mature); err != nil {
+		t.Fatalf("Mature coinbase spend was rejected: %v", err)
+	}
+
+	// Coinbase outputs of the same block are never mature
+	sameBlock := testBlock(bc, minerWallet, transaction.Subsidy)
+	sameBlock.Transactions = append(sameBlock.Transactions, spendTx(sameBlock.Transactions[0].ID, []int{0}, 50))
+	assertRuleError(t, bc.CheckConnectBlock(sameBlock), ErrImmatureSpend)
+}
+
+// Helper to create a spend of the genesis coinbase with the given lock time
+func lockedSpend(prevID []byte, lockTime int64) *transaction.Transaction {
+	tx := spendTx(prevID, []int{0}, 50)
+	tx.LockTime = lockTime
+	tx.ID = tx.Hash()
+	return tx
+}
+
+// Test transactions are only accepted once their lock time height or time is reached
+func TestCheckConnectBlockLockTime(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	prevID := genesisCoinbase(t, bc).ID
+
+	// With only the genesis block, the median time past is its timestamp
+	medianTime, err := bc.MedianTimePast()
+	if err != nil {
+		t.Fatalf("Failed to get median time past: %v", err)
+	}
+	genesis, _ := bc.FindBlock(bc.tip)
+	if medianTime != genesis.Timestamp {
+		t.Fatalf("Expected median time past %d, got %d", genesis.Timestamp, medianTime)
+	}
+
+	tests := []struct {
+		name     string
+		lockTime int64
+		final    bool
+	}{
+		{"height reached", 1, true},
+		{"height not reached", 2, false},
+		{"time reached", medianTime, true},
+		{"time not reached", medianTime + 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bc.CheckConnectBlock(testBlock(bc, minerWallet, transaction.Subsidy, lockedSpend(prevID, tt.lockTime)))
+			if tt.final && err != nil {
+				t.Fatalf("Final transaction was rejected: %v", err)
+			}
+			if !tt.final {
+				assertRuleError(t, err, ErrUnfinalizedTx)
+			}
+		})
+	}
+
+	negative := testBlock(bc, minerWallet, transaction.Subsidy, lockedSpend(prevID, -1))
+	assertRuleError(t, bc.CheckConnectBlock(negative), ErrBadLockTime)
+}
+
+// Helper to create a spend of output 0 of prevID with the given input sequence
+func sequencedSpend(prevID []byte, sequence uint32, value int) *transaction.Transaction {
+	tx := spendTx(prevID, []int{0}, value)
+	tx.Vin[0].Sequence = sequence
+	tx.ID = tx.Hash()
+	return tx
+}
+
+// Test inputs are only accepted once their relative lock time has passed since the
+// spent output was confirmed
+func TestCheckConnectBlockSequenceLock(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	prevID := genesisCoinbase(t, bc).ID
+	const seconds = transaction.SequenceLockTimeIsSeconds
+
+	// The genesis output was confirmed at height 0, the next block is at height 1
+	tests := []struct {
+		name     string
+		sequence uint32
+		final    bool
+	}{
+		{"no lock", 0, true},
+		{"blocks passed", 1, true},
+		{"blocks not passed", 2, false},
+		{"disabled", transaction.SequenceLockTimeDisabled | 100, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bc.CheckConnectBlock(testBlock(bc, minerWallet, transaction.Subsidy, sequencedSpend(prevID, tt.sequence, 50)))
+			if tt.final && err != nil {
+				t.Fatalf("Unlocked input was rejected: %v", err)
+			}
+			if !tt.final {
+				assertRuleError(t, err, ErrSequenceLocked)
+			}
+		})
+	}
+
+	// Outputs remember the median time past when they were created, which has not
+	// moved on 512 seconds later
+	utxoSet := UTXOSet{bc}
+	first := sequencedSpend(prevID, 0, 50)
+	if err := utxoSet.Update(testBlock(bc, minerWallet, transaction.Subsidy, first)); err != nil {
+		t.Fatalf("Failed to update UTXO set: %v", err)
+	}
+	entry, _, _ := utxoSet.FindOutput(first.ID, 0)
+	medianTime, _ := bc.MedianTimePast()
+	if entry.Time != medianTime {
+		t.Fatalf("Expected output time %d, got %d", medianTime, entry.Time)
+	}
+	if err := bc.CheckConnectBlock(testBlock(bc, minerWallet, transaction.Subsidy, sequencedSpend(first.ID, seconds, 50))); err != nil {
+		t.Fatalf("Unlocked input was rejected: %v", err)
+	}
+	locked := testBlock(bc, minerWallet, transaction.Subsidy, sequencedSpend(first.ID, seconds|1, 50))
+	assertRuleError(t, bc.CheckConnectBlock(locked), ErrSequenceLocked)
+}
+
+// Test a rule introduced by a deployment applies to blocks once the deployment is
+// active, and not while it is only signaled or locked in
+func TestCheckConnectBlockDeploymentRule(t *testing.T) {
+	d := consensus.Deployment{Name: "fullsubsidy", Bit: 1, StartHeight: 0, TimeoutHeight: 1 << 62, Window: 4, Threshold: 3}
+	defer func(saved []consensus.Deployment) { consensus.Deployments = saved }(consensus.Deployments)
+	consensus.Deployments = []consensus.Deployment{d}
+	deploymentRules[d.Name] = func(bc *Blockchain, b *block.Block) error {
+		if b.Transactions[0].Vout[0].Value < transaction.Subsidy {
+			return ruleError(ErrBadCoinbaseValue, "coinbase of block %x pays less than the subsidy", b.Hash)
+		}
+		return nil
+	}
+	defer delete(deploymentRules, d.Name)
+
+	// Blocks signal from the start, so the deployment locks in at height 4 and is
+	// active from height 8
+	bc, minerWallet := createTestBlockchain(t)
+	for height := int64(1); height < 7; height++ {
+		cbTx := transaction.NewCoinbaseTx(minerWallet.PublicKey, "", height)
+		if _, err := bc.MineBlock([]*transaction.Transaction{cbTx}, minerWallet); err != nil {
+			t.Fatalf("Failed to mine block %d: %v", height, err)
+		}
+	}
+	if err := bc.CheckConnectBlock(testBlock(bc, minerWallet, transaction.Subsidy-1)); err != nil {
+		t.Fatalf("Rule applied before its deployment was active: %v", err)
+	}
+	if active, err := bc.IsDeploymentActive(d.Name, 7); err != nil || active {
+		t.Fatalf("Expected the deployment to be inactive at height 7, got %v: %v", active, err)
+	}
+
+	cbTx := transaction.NewCoinbaseTx(minerWallet.PublicKey, "", 7)
+	if _, err := bc.MineBlock([]*transaction.Transaction{cbTx}, minerWallet); err != nil {
+		t.Fatalf("Failed to mine block 7: %v", err)
+	}
+	if active, err := bc.IsDeploymentActive(d.Name, 8); err != nil || !active {
+		t.Fatalf("Expected the deployment to be active at height 8, got %v: %v", active, err)
+	}
+	assertRuleError(t, bc.CheckConnectBlock(testBlock(bc, minerWallet, transaction.Subsidy-1)), ErrBadCoinbaseValue)
+	if err := bc.CheckConnectBlock(testBlock(bc, minerWallet, transaction.Subsidy)); err != nil {
+		t.Errorf("Block following the rule was rejected: %v", err)
+	}
+}
+
+// Test immature coinbase outputs are reported separately and not selected for spending
+func TestFindBalanceImmature(t *testing.T) {
+	bc, minerWallet := createTestBlockchainWithConfig(t, Config{CoinbaseMaturity: 2, Network: DefaultNetwork})
+	utxoSet := UTXOSet{bc}
+	pubKeyHash := wallet.HashPubKey(minerWallet.PublicKey)
+
+	spendable, immature, err := utxoSet.FindBalance(pubKeyHash)
+	if err != nil {
+		t.Fatalf("Failed to find balance: %v", err)
+	}
+	if spendable != 0 || immature != transaction.Subsidy {
+		t.Errorf("Expected 0 spendable and %d immature, got %d and %d", transaction.Subsidy, spendable, immature)
+	}
+
+	acc, _, err := utxoSet.FindSpendableOutputs(pubKeyHash, 10)
+	if err != nil {
+		t.Fatalf("Failed to find spendable outputs: %v", err)
+	}
+	if acc != 0 {
+		t.Errorf("Immature coinbase should not be spendable, got %d", acc)
+	}
+}
+
+// Test null data outputs are accepted without value, found again by their data and
+// never added to the UTXO set
+func TestNullDataOutputs(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	utxoSet := UTXOSet{bc}
+	data := sha256.Sum256([]byte("document"))
+
+	tx, err := transaction.NewNullDataTransaction(minerWallet, data[:], 0, utxoSet.FindSpendableOutputs)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if err := bc.SignTransaction(tx, minerWallet); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	if err := mineTx(bc, minerWallet, tx); err != nil {
+		t.Fatalf("Failed to mine null data transaction: %v", err)
+	}
+
+	found, blk, err := bc.FindNullData(data[:])
+	if err != nil || found == nil || !bytes.Equal(found.ID, tx.ID) || blk.Height != 1 {
+		t.Fatalf("Expected transaction %x at height 1, got %v, %v", tx.ID, found, err)
+	}
+	if missing, _, _ := bc.FindNullData([]byte("other")); missing != nil {
+		t.Errorf("Found data that was never recorded in %x", missing.ID)
+	}
+
+	for _, reindex := range []bool{false, true} {
+		if reindex {
+			if err := utxoSet.Reindex(); err != nil {
+				t.Fatalf("Failed to reindex: %v", err)
+			}
+		}
+		if _, ok, _ := utxoSet.FindOutput(tx.ID, 0); ok {
+			t.Errorf("Null data output is in the UTXO set (reindexed: %v)", reindex)
+		}
+		if _, ok, _ := utxoSet.FindOutput(tx.ID, 1); !ok {
+			t.Errorf("Change output is missing from the UTXO set (reindexed: %v)", reindex)
+		}
+	}
+	spend := testBlock(bc, minerWallet, transaction.Subsidy, spendTx(tx.ID, []int{0}, 1))
+	assertRuleError(t, bc.CheckConnectBlock(spend), ErrMissingTxOut)
+
+	dataScript, _ := script.NullDataScript(data[:])
+	tests := []struct {
+		name string
+		out  transaction.TxOutput
+		code ErrorCode
+	}{
+		{"null data with value", transaction.TxOutput{Value: 1, LockingScript: dataScript}, ErrBadTxOutValue},
+		{"too much data", transaction.TxOutput{LockingScript: script.NewBuilder().AddOp(script.OpReturn).
+			AddData(make([]byte, script.MaxDataCarrierSize+1)).Script()}, ErrBadDataCarrier},
+		{"unspendable but not null data", transaction.TxOutput{LockingScript: []byte{script.OpReturn, script.OpDup}}, ErrBadDataCarrier},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := spendTx(tx.ID, []int{1}, 1)
+			bad.Vout = append(bad.Vout, tt.out)
+			bad.ID = bad.Hash()
+			assertRuleError(t, bc.CheckConnectBlock(testBlock(bc, minerWallet, transaction.Subsidy, bad)), tt.code)
+		})
+	}
+}
+
+// Test signed inputs satisfy the locking scripts of the outputs they spend
+func TestSignAndVerifyTransaction(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	utxoSet := UTXOSet{bc}
+	recipient := script.PayToPubKeyHash(wallet.HashPubKey([]byte("recipient")))
+
+	tx, err := transaction.NewUTXOTransaction(minerWallet, recipient, 20, 0, utxoSet.FindSpendableOutputs)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if err := bc.SignTransaction(tx, minerWallet); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	if err := bc.VerifyTransaction(tx); err != nil {
+		t.Fatalf("Signed transaction was rejected: %v", err)
+	}
+	if !tx.HasValidID() {
+		t.Error("Signing changed the transaction ID")
+	}
+
+	// Redirecting the payment invalidates the signature
+	tx.Vout[0] = transaction.NewTxOutput(20, wallet.HashPubKey([]byte("thief")))
+	if err := bc.VerifyTransaction(tx); err == nil {
+		t.Error("Tampered transaction was accepted")
+	}
+
+	// A wallet cannot sign outputs locked to another key
+	other, err := transaction.NewUTXOTransaction(minerWallet, recipient, 20, 0, utxoSet.FindSpendableOutputs)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if err := bc.SignTransaction(other, wallet.NewWallet()); err == nil {
+		t.Error("Signed an input locked to another wallet")
+	}
+}
+
+// Test a block can include a transaction spending an output created earlier in the
+// same block, but not one created later
+func TestMineBlockChainedSpend(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	utxoSet := UTXOSet{bc}
+	payee := wallet.NewWallet()
+
+	fund, err := transaction.NewUTXOTransaction(minerWallet, script.PayToPubKeyHash(wallet.HashPubKey(payee.PublicKey)), 20, 0, utxoSet.FindSpendableOutputs)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if err := bc.SignTransaction(fund, minerWallet); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+
+	// The payee spends the output before it is in the chain
+	findFund := func([]byte, int) (int, map[string][]int, error) {
+		return 20, map[string][]int{hex.EncodeToString(fund.ID): {0}}, nil
+	}
+	recipient := script.PayToPubKeyHash(wallet.HashPubKey([]byte("recipient")))
+	chained, err := transaction.NewUTXOTransaction(payee, recipient, 15, 0, findFund)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if err := chained.Sign(payee, map[string]transaction.Transaction{hex.EncodeToString(fund.ID): *fund}, bc.ChainID()); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+
+	cbTx := transaction.NewCoinbaseTx(minerWallet.PublicKey, "", 1)
+	if _, err := bc.MineBlock([]*transaction.Transaction{cbTx, chained, fund}, minerWallet); err == nil {
+		t.Fatal("Mined a block spending an output before the transaction creating it")
+	}
+	b, err := bc.MineBlock([]*transaction.Transaction{cbTx, fund, chained}, minerWallet)
+	if err != nil {
+		t.Fatalf("Block with a chained spend was rejected: %v", err)
+	}
+	if err := utxoSet.Update(b); err != nil {
+		t.Fatalf("Failed to update UTXO set: %v", err)
+	}
+	if balance, _, _ := utxoSet.FindScriptBalance(recipient); balance != 15 {
+		t.Errorf("Recipient has %d, expected 15", balance)
+	}
+}
+
+// Test signatures commit to the chain ID, so they are not valid on another network or
+// on another chain of the same network, and the ID survives reopening the chain
+func TestChainIDReplayProtection(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	minerWallet := wallet.NewWallet()
+	bc, err := createBlockchain(dbPath, minerWallet, Config{Network: "testnet"})
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	cbTx := genesisCoinbase(t, bc)
+	genesisHash := bc.tip
+	chainID := transaction.NewChainID(genesisHash, "testnet")
+	if bc.ChainID() != chainID {
+		t.Fatalf("Expected chain ID %s, got %s", chainID, bc.ChainID())
+	}
+	bc.CloseDB()
+
+	bc, err = openBlockchain(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen blockchain: %v", err)
+	}
+	defer bc.CloseDB()
+	if bc.ChainID() != chainID {
+		t.Fatalf("Reopened chain has chain ID %s, expected %s", bc.ChainID(), chainID)
+	}
+
+	tx := spendTx(cbTx.ID, []int{0}, transaction.Subsidy)
+	if err := bc.SignTransaction(tx, minerWallet); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	if err := bc.VerifyTransaction(tx); err != nil {
+		t.Fatalf("Signed transaction was rejected: %v", err)
+	}
+
+	prevTXs := map[string]transaction.Transaction{hex.EncodeToString(cbTx.ID): *cbTx}
+	for _, other := range []transaction.ChainID{
+		transaction.NewChainID(genesisHash, "mainnet"),
+		transaction.NewChainID([]byte("other genesis"), "testnet"),
+	} {
+		if valid, _ := tx.Verify(prevTXs, other); valid {
+			t.Errorf("Signature was accepted on chain %s", other)
+		}
+	}
+
+	// A block signed for another chain is rejected
+	cb := transaction.NewCoinbaseTx(minerWallet.PublicKey, "", 1)
+	blk, err := bc.consensus.ProposeBlock(minerWallet, []*transaction.Transaction{cb, tx}, bc.tip, bc.tip, testChainID)
+	if err != nil {
+		t.Fatalf("Failed to propose block: %v", err)
+	}
+	if valid, _ := bc.consensus.ValidateBlock(blk, prevTXs, bc.ChainID(), nil); valid {
+		t.Error("Block signed for another chain was accepted")
+	}
+}
+
+// Test the transaction ID leaves out signatures while the witness hash covers them,
+// and a block commits to the witness hashes of its transactions
+func TestWitnessHash(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	cbTx := genesisCoinbase(t, bc)
+	if !bytes.Equal(cbTx.WitnessHash(), cbTx.ID) {
+		t.Error("Expected the witness hash of a coinbase to equal its ID")
+	}
+
+	tx := spendTx(cbTx.ID, []int{0}, transaction.Subsidy)
+	id, unsignedWitness := tx.ID, tx.WitnessHash()
+	if err := bc.SignTransaction(tx, minerWallet); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	if !bytes.Equal(tx.ID, id) || !tx.HasValidID() {
+		t.Error("Signing changed the transaction ID")
+	}
+	if bytes.Equal(tx.WitnessHash(), unsignedWitness) {
+		t.Error("Signing did not change the witness hash")
+	}
+
+	cb := transaction.NewCoinbaseTx(minerWallet.PublicKey, "", 1)
+	blk, err := bc.consensus.ProposeBlock(minerWallet, []*transaction.Transaction{cb, tx}, bc.tip, bc.tip, bc.ChainID())
+	if err != nil {
+		t.Fatalf("Failed to propose block: %v", err)
+	}
+	prevTXs := map[string]transaction.Transaction{hex.EncodeToString(cbTx.ID): *cbTx}
+	if valid, err := bc.consensus.ValidateBlock(blk, prevTXs, bc.ChainID(), nil); !valid {
+		t.Fatalf("Block was rejected: %v", err)
+	}
+
+	// Signing again gives another valid signature with the same ID, which the block
+	// does not commit to
+	witness := tx.WitnessHash()
+	if err := bc.SignTransaction(tx, minerWallet); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	if err := bc.VerifyTransaction(tx); err != nil || !tx.HasValidID() || bytes.Equal(tx.WitnessHash(), witness) {
+		t.Fatalf("Expected a new valid signature with the same ID: %v", err)
+	}
+	if valid, _ := bc.consensus.ValidateBlock(blk, prevTXs, bc.ChainID(), nil); valid {
+		t.Error("Block with a replaced signature was accepted")
+	}
+}
+
+// Test a batch transaction makes every payment with a single change output and
+// connects as one unit
+func TestBatchTransaction(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	utxoSet := UTXOSet{bc}
+
+	var payments []transaction.TxOutput
+	for i, value := range []int{5, 10, 15} {
+		payments = append(payments, transaction.NewTxOutput(value, wallet.HashPubKey([]byte{byte(i)})))
+	}
+	tx, err := transaction.NewBatchTransaction(minerWallet, payments, 0, utxoSet.FindSpendableOutputs)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if len(tx.Vout) != len(payments)+1 || tx.Vout[len(payments)].Value != transaction.Subsidy-30 {
+		t.Fatalf("Expected %d payments and change of %d, got outputs %v", len(payments), transaction.Subsidy-30, tx.Vout)
+	}
+	if err := bc.SignTransaction(tx, minerWallet); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	if err := mineTx(bc, minerWallet, tx); err != nil {
+		t.Fatalf("Failed to mine batch transaction: %v", err)
+	}
+	for i := range payments {
+		if _, ok, _ := utxoSet.FindOutput(tx.ID, i); !ok {
+			t.Errorf("Payment %d is missing from the UTXO set", i)
+		}
+	}
+
+	bad := append(payments, transaction.NewTxOutput(0, []byte("zero")))
+	if _, err := transaction.NewBatchTransaction(minerWallet, bad, 0, utxoSet.FindSpendableOutputs); err == nil {
+		t.Error("Accepted a payment of zero")
+	}
+	if _, err := transaction.NewBatchTransaction(minerWallet, nil, 0, utxoSet.FindSpendableOutputs); err == nil {
+		t.Error("Accepted a batch without payments")
+	}
+}
+
+// Helper to pay amount from the miner to lockingScript in a new block. The payment is
+// output 0 of the returned transaction.
+func fundScript(t *testing.T, bc *Blockchain, minerWallet *wallet.Wallet, lockingScript []byte, amount int) *transaction.Transaction {
+	t.Helper()
+	utxoSet := UTXOSet{bc}
+	fund, err := transaction.NewUTXOTransaction(minerWallet, lockingScript, amount, 0, utxoSet.FindSpendableOutputs)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if err := bc.SignTransaction(fund, minerWallet); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	height, _ := bc.GetBestHeight()
+	cbTx := transaction.NewCoinbaseTx(minerWallet.PublicKey, "", height+1)
+	b, err := bc.MineBlock([]*transaction.Transaction{cbTx, fund}, minerWallet)
+	if err != nil {
+		t.Fatalf("Failed to mine block: %v", err)
+	}
+	if err := utxoSet.Update(b); err != nil {
+		t.Fatalf("Failed to update UTXO set: %v", err)
+	}
+	if balance, _, _ := utxoSet.FindScriptBalance(lockingScript); balance != amount {
+		t.Fatalf("Expected balance %d, got %d", amount, balance)
+	}
+	return fund
+}
+
+// Helper to include tx in a new block proposed by minerWallet
+func mineTx(bc *Blockchain, minerWallet *wallet.Wallet, tx *transaction.Transaction) error {
+	height, err := bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+	cbTx := transaction.NewCoinbaseTx(minerWallet.PublicKey, "", height+1)
+	b, err := bc.MineBlock([]*transaction.Transaction{cbTx, tx}, minerWallet)
+	if err != nil {
+		return err
+	}
+	return UTXOSet{bc}.Update(b)
+}
+
+// Helper to start a partially signed spend of the outputs locked with lockingScript
+func newPartialSpend(t *testing.T, bc *Blockchain, lockingScript, redeemScript []byte) *transaction.PartialTx {
+	t.Helper()
+	utxoSet := UTXOSet{bc}
+	recipient := script.PayToPubKeyHash(wallet.HashPubKey([]byte("recipient")))
+	spend, err := transaction.NewScriptTransaction(lockingScript, recipient, 25, 0, utxoSet.FindSpendableScriptOutputs)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+
+	var prevOutputs []transaction.TxOutput
+	var redeemScripts [][]byte
+	for _, vin := range spend.Vin {
+		entry, _, _ := utxoSet.FindOutput(vin.Txid, vin.Vout)
+		prevOutputs = append(prevOutputs, entry.Output)
+		redeemScripts = append(redeemScripts, redeemScript)
+	}
+	partial, err := transaction.NewPartialTx(spend, bc.ChainID(), prevOutputs, redeemScripts)
+	if err != nil {
+		t.Fatalf("Failed to create partial transaction: %v", err)
+	}
+	return partial
+}
+
+// Test a 2-of-3 multisig output is spent once two co-signers have signed in turn
+func TestMultisigSpend(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	signers := []*wallet.Wallet{wallet.NewWallet(), wallet.NewWallet(), wallet.NewWallet()}
+	multisig, err := script.MultiSigScript(2, [][]byte{signers[0].PublicKey, signers[1].PublicKey, signers[2].PublicKey})
+	if err != nil {
+		t.Fatalf("Failed to create multisig script: %v", err)
+	}
+
+	// Spend it with signatures from the first and third keys
+	fundScript(t, bc, minerWallet, multisig, 30)
+	partial := newPartialSpend(t, bc, multisig, nil)
+	size := partial.SignedSize()
+
+	if _, err := partial.Sign(signers[2]); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	// The partial transaction survives being passed between co-signers
+	partial, err = transaction.DeserializePartialTx(partial.Serialize())
+	if err != nil {
+		t.Fatalf("Failed to round-trip partial transaction: %v", err)
+	}
+	if partial.RemainingSignatures() != 1 {
+		t.Errorf("Expected 1 remaining signature, got %d", partial.RemainingSignatures())
+	}
+	if _, err := partial.Finalize(); err == nil {
+		t.Fatal("Finalized with one of two signatures")
+	}
+
+	if added, _ := partial.Sign(wallet.NewWallet()); added != 0 {
+		t.Errorf("Outsider added %d signatures", added)
+	}
+	if _, err := partial.Sign(signers[0]); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	signed, err := partial.Finalize()
+	if err != nil {
+		t.Fatalf("Failed to finalize: %v", err)
+	}
+	if err := bc.VerifyTransaction(signed); err != nil {
+		t.Fatalf("Multisig spend was rejected: %v", err)
+	}
+	if len(signed.Serialize()) != size {
+		t.Errorf("Signed size is %d, estimated %d", len(signed.Serialize()), size)
+	}
+
+	// Signatures must follow the order of the keys
+	pushes, _ := script.PushedData(signed.Vin[0].UnlockingScript)
+	signed.Vin[0].UnlockingScript = script.MultiSigUnlockingScript([][]byte{pushes[1], pushes[0]})
+	if err := bc.VerifyTransaction(signed); err == nil {
+		t.Error("Signatures out of key order were accepted")
+	}
+}
+
+// Test copies of a partially signed transaction spending key hash and multisig
+// outputs are signed without the chain, combined and finalized
+func TestPartialTxCombine(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	signers := []*wallet.Wallet{wallet.NewWallet(), wallet.NewWallet()}
+	multisig, err := script.MultiSigScript(2, [][]byte{signers[0].PublicKey, signers[1].PublicKey})
+	if err != nil {
+		t.Fatalf("Failed to create multisig script: %v", err)
+	}
+
+	// Spend the multisig output and the miner's change from funding it
+	fund := fundScript(t, bc, minerWallet, multisig, 30)
+	spend := spendTx(fund.ID, []int{0, 1}, transaction.Subsidy)
+	partial, err := transaction.NewPartialTx(spend, bc.ChainID(), fund.Vout, nil)
+	if err != nil {
+		t.Fatalf("Failed to create partial transaction: %v", err)
+	}
+
+	copies := make([]*transaction.PartialTx, 2)
+	for i := range copies {
+		if copies[i], err = transaction.DeserializePartialTx(partial.Serialize()); err != nil {
+			t.Fatalf("Failed to round-trip partial transaction: %v", err)
+		}
+	}
+	for _, w := range []*wallet.Wallet{minerWallet, signers[0]} {
+		if added, err := copies[0].Sign(w); err != nil || added != 1 {
+			t.Fatalf("Expected 1 signature, added %d: %v", added, err)
+		}
+	}
+	if added, err := copies[1].Sign(signers[1]); err != nil || added != 1 {
+		t.Fatalf("Expected 1 signature, added %d: %v", added, err)
+	}
+	if _, err := copies[0].Finalize(); err == nil {
+		t.Fatal("Finalized with one of two multisig signatures")
+	}
+
+	other := spendTx(fund.ID, []int{0, 1}, transaction.Subsidy-1)
+	otherPartial, _ := transaction.NewPartialTx(other, bc.ChainID(), fund.Vout, nil)
+	if _, err := copies[0].Combine(otherPartial); err == nil {
+		t.Error("Combined partial transactions of different transactions")
+	}
+
+	if added, err := copies[0].Combine(copies[1]); err != nil || added != 1 {
+		t.Fatalf("Expected 1 combined signature, added %d: %v", added, err)
+	}
+	if copies[0].RemainingSignatures() != 0 {
+		t.Errorf("Expected no remaining signatures, got %d", copies[0].RemainingSignatures())
+	}
+	signed, err := copies[0].Finalize()
+	if err != nil {
+		t.Fatalf("Failed to finalize: %v", err)
+	}
+	if err := mineTx(bc, minerWallet, signed); err != nil {
+		t.Fatalf("Combined transaction was rejected: %v", err)
+	}
+}
+
+// Test a multisig redeem script behind a pay-to-script-hash output is revealed and
+// satisfied when spending
+func TestScriptHashMultisigSpend(t *testing.T) {
+	bc, minerWallet := createTestBlockchain(t)
+	signers := []*wallet.Wallet{wallet.NewWallet(), wallet.NewWallet()}
+	redeemScript, err := script.MultiSigScript(2, [][]byte{signers[0].PublicKey, signers[1].PublicKey})
+	if err != nil {
+		t.Fatalf("Failed to create multisig script: %v", err)
+	}
+	lockingScript, err := wallet.AddressLockingScript(wallet.ScriptAddress(redeemScript))
+	if err != nil {
+		t.Fatalf("Failed to decode script address: %v", err)
+	}
+	if script.GetScriptClass(lockingScript) != script.ScriptHashTy {
+		t.Fatalf("Script address does not pay to the script hash: %x", lockingScript)
+	}
+
+	fundScript(t, bc, minerWallet, lockingScript, 30)
+	partial := newPartialSpend(t, bc, lockingScript, redeemScript)
+	size := partial.SignedSize()
+	for _, signer := range signers {
+		if _, err := partial.Sign(signer); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+	}
+	signed, err := partial.Finalize()
+	if err != nil {
+		t.Fatalf("Failed to finalize: %v", err)
+	}
+	if err := bc.VerifyTransaction(signed); err != nil {
+		t.Fatalf("Script hash spend was rejected: %v", err)
+	}
+	if len(signed.Serialize()) != size {
+		t.Errorf("Signed size is %d, estimated %d", len(signed.Serialize()), size)
+	}
+
+	if _, err := transaction.NewPartialTx(signed, bc.ChainID(), partial.PrevOutputs, [][]byte{[]byte("other script")}); err == nil {
+		t.Error("Accepted a redeem script that does not match the script hash")
+	}
+}
+
+// Test an atomic swap between two independent chains: each party locks coins in a
+// hashed time-locked contract, and redeeming one reveals the secret for the other
+func TestHTLCAtomicSwap(t *testing.T) {
+	chainA, alice := createTestBlockchain(t)
+	chainB, bob := createTestBlockchain(t)
+	secret := bytes.Repeat([]byte{0x07}, script.HTLCSecretSize)
+	secretHash := sha256.Sum256(secret)
+
+	// Alice locks 20 on chain A for Bob, Bob locks 10 on chain B for Alice with a
+	// shorter lock time so he can refund before Alice could
+	htlc := func(recipient, sender *wallet.Wallet, lockTime int64) []byte {
+		locking, err := script.HTLCScript(script.HTLCContract{
+			SecretHash:          secretHash[:],
+			RecipientPubKeyHash: wallet.HashPubKey(recipient.PublicKey),
+			RefundPubKeyHash:    wallet.HashPubKey(sender.PublicKey),
+			LockTime:            lockTime,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create HTLC script: %v", err)
+		}
+		return locking
+	}
+	contractA := fundScript(t, chainA, alice, htlc(bob, alice, 10), 20)
+	contractB := fundScript(t, chainB, bob, htlc(alice, bob, 5), 10)
+
+	spend := func(bc *Blockchain, contract *transaction.Transaction, w *wallet.Wallet, secret []byte, lockTime int64) *transaction.Transaction {
+		toScript := script.PayToPubKeyHash(wallet.HashPubKey(w.PublicKey))
+		tx, err := transaction.NewHTLCSpend(contract.ID, 0, contract.Vout[0], toScript, 0, lockTime)
+		if err != nil {
+			t.Fatalf("Failed to create HTLC spend: %v", err)
+		}
+		if err := tx.SignHTLC(0, w, contract.Vout[0], bc.ChainID(), secret); err != nil {
+			t.Fatalf("Failed to sign HTLC spend: %v", err)
+		}
+		return tx
+	}
+
+	// Bob cannot refund before the lock time, and a wrong secret is rejected
+	if err := mineTx(chainB, bob, spend(chainB, contractB, bob, nil, 5)); err == nil {
+		t.Fatal("Refund before the lock time was accepted")
+	}
+	wrong := spend(chainB, contractB, alice, secret, 0)
+	wrong.Vin[0].UnlockingScript = script.HTLCRedeemUnlockingScript([]byte("sig"), alice.PublicKey, bytes.Repeat([]byte{1}, script.HTLCSecretSize))
+	if err := chainB.VerifyTransaction(wrong); err == nil {
+		t.Fatal("Redeem with the wrong secret was accepted")
+	}
+
+	// Alice redeems on chain B, which reveals the secret to Bob
+	if err := mineTx(chainB, bob, spend(chainB, contractB, alice, secret, 0)); err != nil {
+		t.Fatalf("Alice failed to redeem on chain B: %v", err)
+	}
+	redeem, err := chainB.FindSpendingTransaction(contractB.ID, 0)
+	if err != nil || redeem == nil {
+		t.Fatalf("Redeem transaction not found: %v", err)
+	}
+	revealed := script.ExtractHTLCSecret(redeem.Vin[0].UnlockingScript)
+	if !bytes.Equal(revealed, secret) {
+		t.Fatalf("Expected revealed secret %x, got %x", secret, revealed)
+	}
+
+	// Bob redeems on chain A with the revealed secret
+	if err := mineTx(chainA, alice, spend(chainA, contractA, bob, revealed, 0)); err != nil {
+		t.Fatalf("Bob failed to redeem on chain A: %v", err)
+	}
+	bobScript := script.PayToPubKeyHash(wallet.HashPubKey(bob.PublicKey))
+	if balance, _, _ := (UTXOSet{chainA}).FindScriptBalance(bobScript); balance != 20 {
+		t.Errorf("Expected Bob to have 20 on chain A, got %d", balance)
+	}
+	if spent, _ := chainA.FindSpendingTransaction(contractA.ID, 1); spent != nil {
+		t.Error("Unspent change output reported as spent")
+	}
+}
+
+
+// Test the sender of an HTLC gets the coins back once its lock time passes, both
+// for a lock time that is a height and one that is compared to the median time past
+func TestHTLCRefund(t *testing.T) {
+	bc, alice := createTestBlockchain(t)
+	bob := wallet.NewWallet()
+	secretHash := sha256.Sum256(bytes.Repeat([]byte{0x07}, script.HTLCSecretSize))
+
+	fundHTLC := func(lockTime int64) *transaction.Transaction {
+		locking, err := script.HTLCScript(script.HTLCContract{
+			SecretHash:          secretHash[:],
+			RecipientPubKeyHash: wallet.HashPubKey(bob.PublicKey),
+			RefundPubKeyHash:    wallet.HashPubKey(alice.PublicKey),
+			LockTime:            lockTime,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create HTLC script: %v", err)
+		}
+		return fundScript(t, bc, alice, locking, 10)
+	}
+	refund := func(contract *transaction.Transaction, lockTime int64) *transaction.Transaction {
+		toScript := script.PayToPubKeyHash(wallet.HashPubKey(alice.PublicKey))
+		tx, err := transaction.NewHTLCSpend(contract.ID, 0, contract.Vout[0], toScript, 0, lockTime)
+		if err != nil {
+			t.Fatalf("Failed to create HTLC refund: %v", err)
+		}
+		if err := tx.SignHTLC(0, alice, contract.Vout[0], bc.ChainID(), nil); err != nil {
+			t.Fatalf("Failed to sign HTLC refund: %v", err)
+		}
+		return tx
+	}
+	mineEmpty := func() {
+		height, _ := bc.GetBestHeight()
+		cbTx := transaction.NewCoinbaseTx(alice.PublicKey, "", height+1)
+		b, err := bc.MineBlock([]*transaction.Transaction{cbTx}, alice)
+		if err != nil {
+			t.Fatalf("Failed to mine block: %v", err)
+		}
+		if err := (UTXOSet{bc}).Update(b); err != nil {
+			t.Fatalf("Failed to update UTXO set: %v", err)
+		}
+	}
+
+	// By height: the refund is final in the block at the lock time
+	height, _ := bc.GetBestHeight()
+	lockHeight := height + 3
+	byHeight := fundHTLC(lockHeight)
+	if err := mineTx(bc, alice, refund(byHeight, lockHeight)); err == nil {
+		t.Fatal("Refund before the lock height was accepted")
+	}
+	for height, _ = bc.GetBestHeight(); height+1 < lockHeight; height, _ = bc.GetBestHeight() {
+		mineEmpty()
+	}
+	if err := mineTx(bc, alice, refund(byHeight, lockHeight)); err != nil {
+		t.Fatalf("Refund at the lock height was rejected: %v", err)
+	}
+
+	// By time: the refund is final once the median time past of the previous blocks
+	// reaches the lock time, whatever the height
+	medianTime, err := bc.MedianTimePast()
+	if err != nil {
+		t.Fatalf("Failed to get median time past: %v", err)
+	}
+	lockTime := medianTime + 1
+	if lockTime < transaction.LockTimeThreshold {
+		t.Fatalf("Lock time %d is not a time", lockTime)
+	}
+	byTime := fundHTLC(lockTime)
+	if err := mineTx(bc, alice, refund(byTime, lockTime)); err == nil {
+		t.Fatal("Refund before the lock time was accepted")
+	}
+	time.Sleep(time.Until(time.Unix(lockTime, 0)))
+	for i := 0; medianTime < lockTime; i++ {
+		if i == medianTimeBlocks {
+			t.Fatalf("Median time past %d did not reach %d", medianTime, lockTime)
+		}
+		mineEmpty()
+		medianTime, _ = bc.MedianTimePast()
+	}
+	if err := mineTx(bc, alice, refund(byTime, lockTime)); err != nil {
+		t.Fatalf("Refund at the lock time was rejected: %v", err)
+	}
+
+	aliceScript := script.PayToPubKeyHash(wallet.HashPubKey(alice.PublicKey))
+	for _, contract := range []*transaction.Transaction{byHeight, byTime} {
+		spent, err := bc.FindSpendingTransaction(contract.ID, 0)
+		if err != nil || spent == nil || !bytes.Equal(spent.Vout[0].LockingScript, aliceScript) {
+			t.Errorf("HTLC %x was not refunded to Alice: %v", contract.ID, err)
+		}
+	}
+}
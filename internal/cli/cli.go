@@ -5,6 +5,8 @@ import (
     "fmt"
     "os"
     "strconv"
+    "strings"
+    "time"
 
     "github.com/OmSingh2003/decentralized-ledger/internal/blockchain"
     "github.com/OmSingh2003/decentralized-ledger/internal/consensus"
@@ -24,14 +26,46 @@ func NewCLI(bc *blockchain.Blockchain) *CLI {
 }
 
 func (cli *CLI) printUsage() {
-	fmt.Println("Usage:")
-	fmt.Println("  createwallet - Creates a new wallet")
+	fmt.Println("Usage: [-datadir DIR] [-network NAME] [-dustthreshold N] [-datacarriersize N] [-minrelayfee N] COMMAND, where DIR holds the blockchain (default: working directory)")
+	fmt.Println("  and NAME selects the address format of commands that do not open the blockchain (default: mainnet); others use the blockchain's network")
+	fmt.Println("  and transactions with outputs below N, null data over N bytes or fees below N per 1000 bytes are rejected as non-standard")
+	fmt.Println("  createmultisig -m M -keys KEY1,KEY2,... - Create a shared address spendable with M of the keys (wallet addresses or hex public keys)")
+	fmt.Println("  createrawtx -inputs TXID:VOUT,... -outputs ADDRESS:AMOUNT,... [-locktime N] - Print an unsigned transaction spending the given outputs as hex; what the payments leave is the fee")
+	fmt.Println("  createwallet [-type TYPE] [-unencrypted] [-hd [-words N] [-passphrase]] - Creates a new wallet encrypted with a passphrase read from standard input, unless -unencrypted, or with -hd the deterministic wallet all getnewaddress keys derive from, backed up by a mnemonic of N words (12 or 24) and optionally a passphrase read from standard input; TYPE is the address encoding, base58 (default) or bech32")
+	fmt.Println("  decoderawtx HEX - Show the inputs and outputs of a hex transaction (offline)")
+	fmt.Println("  encryptwallet -address ADDRESS - Encrypt the wallet of ADDRESS with a passphrase read from standard input")
 	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS")
-	fmt.Println("  listaddresses - Lists all addresses from the wallet file")
+	fmt.Println("  getnewaddress [-account N] [-change] - Derive the next unused receiving, or change, address of account N of the deterministic wallet")
+	fmt.Println("  getdeploymentinfo - Show the activation state of each consensus deployment")
+	fmt.Println("  htlc-audit -txid TXID -vout N - Show the terms of a contract output and whether it was redeemed or refunded")
+	fmt.Println("  htlc-create -from FROM -to TO -amount AMOUNT -locktime N [-hash HASH] - Lock AMOUNT for TO against a secret with HASH (new if omitted), refundable to FROM from block height or Unix time N")
+	fmt.Println("  htlc-redeem -txid TXID -vout N -secret SECRET -address ADDRESS -miner MINER - Claim a contract output for ADDRESS by revealing SECRET")
+	fmt.Println("  htlc-refund -txid TXID -vout N -address ADDRESS -miner MINER - Take a contract output back to ADDRESS after its lock time")
+	fmt.Println("  listaddresses - Lists the addresses of all wallet files and the deterministic wallet, with derivation paths")
+	fmt.Println("  mine -miner ADDRESS [-blocks N] - Propose N blocks (default 1) holding only a coinbase paying ADDRESS, so earlier coinbase rewards mature")
+	fmt.Println("  notarize -file FILE -from FROM - Anchor the SHA-256 hash of FILE in the chain, paid for and proposed by FROM")
+	fmt.Println("  psbt-broadcast -file FILE -miner ADDRESS - Finalize a partially signed transaction and broadcast it in a block proposed by ADDRESS")
+	fmt.Println("  psbt-combine -in FILE1,FILE2,... -out FILE - Merge the signatures of copies of a partially signed transaction (offline)")
+	fmt.Println("  psbt-create -from FROM -to ADDRESS:AMOUNT,... -file FILE - Write an unsigned transaction from FROM, with the outputs it spends, to FILE")
+	fmt.Println("  psbt-finalize -file FILE -out TXFILE - Check the signatures of a partially signed transaction and write the signed transaction for sendtx (offline)")
+	fmt.Println("  psbt-sign -file FILE -address ADDRESS [-sighash TYPE] - Add the signatures of ADDRESS to a partially signed transaction (offline)")
 	fmt.Println("  printchain - Print all the blocks of the blockchain")
 	fmt.Println("  reindexutxo - Rebuilds the UTXO set")
-	fmt.Println("  send -from FROM -to TO -amount AMOUNT - Send AMOUNT of coins from FROM address to TO")
+	fmt.Println("  restorewallet -mnemonic WORDS [-passphrase] [-gaplimit N] - Rebuild the deterministic wallet from its mnemonic and find its used addresses in the chain, stopping after N unused ones in a row")
+	fmt.Println("  sendrawtx HEX -miner ADDRESS - Check the signatures of a hex transaction and broadcast it in a block proposed by ADDRESS")
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT [-locktime N -file FILE] [-sighash TYPE] - Send AMOUNT of coins from FROM address to TO, not before block height or Unix time N, signing with hash TYPE")
+	fmt.Println("  sendmany -from FROM (-file FILE | -to ADDRESS:AMOUNT,...) - Make many payments from FROM in one transaction, read from a CSV FILE of address,amount rows or given as a list")
+	fmt.Println("  sendmultisig -file FILE -miner ADDRESS - Broadcast a fully signed multisig spend in a block proposed by ADDRESS")
+	fmt.Println("  sendtx -file FILE -miner ADDRESS - Broadcast a signed transaction from FILE in a block proposed by ADDRESS")
+	fmt.Println("  signrawtx HEX -address ADDRESS [-sighash TYPE] - Sign every input of a hex transaction with the wallet at ADDRESS and print the result")
+	fmt.Println("  signmultisig -file FILE -address ADDRESS - Add the signatures of ADDRESS to a multisig spend")
+	fmt.Println("  spendmultisig -from FROM -to TO -amount AMOUNT -file FILE - Start a multisig spend from shared address FROM and write it to FILE")
 	fmt.Println("  stake -address ADDRESS -amount AMOUNT - Add stake for PoS validator")
+	fmt.Println("  verify-notarization -file FILE - Find the transaction and block anchoring the SHA-256 hash of FILE")
+	fmt.Println("  validateaddress ADDRESS - Show the network, type and encoding of ADDRESS, or why it is invalid (offline)")
+	fmt.Println("  walletlock -address ADDRESS - Lock the encrypted wallet of ADDRESS before its unlock timeout")
+	fmt.Println("  walletpassphrase -address ADDRESS -timeout SECONDS - Unlock the encrypted wallet of ADDRESS for SECONDS with a passphrase read from standard input")
+	fmt.Println("  walletpassphrasechange -address ADDRESS - Change the passphrase of the encrypted wallet of ADDRESS, reading the old and new passphrase from standard input")
 }
 
 // validateArgs validates command line arguments
@@ -46,66 +80,372 @@ func (cli *CLI) validateArgs() {
 func (cli *CLI) Run() error {
     cli.validateArgs()
 
+	createMultisigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	createRawTxCmd := flag.NewFlagSet("createrawtx", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	decodeRawTxCmd := flag.NewFlagSet("decoderawtx", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
+	getDeploymentInfoCmd := flag.NewFlagSet("getdeploymentinfo", flag.ExitOnError)
+	getNewAddressCmd := flag.NewFlagSet("getnewaddress", flag.ExitOnError)
+	htlcAuditCmd := flag.NewFlagSet("htlc-audit", flag.ExitOnError)
+	htlcCreateCmd := flag.NewFlagSet("htlc-create", flag.ExitOnError)
+	htlcRedeemCmd := flag.NewFlagSet("htlc-redeem", flag.ExitOnError)
+	htlcRefundCmd := flag.NewFlagSet("htlc-refund", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	mineCmd := flag.NewFlagSet("mine", flag.ExitOnError)
+	notarizeCmd := flag.NewFlagSet("notarize", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
+	psbtBroadcastCmd := flag.NewFlagSet("psbt-broadcast", flag.ExitOnError)
+	psbtCombineCmd := flag.NewFlagSet("psbt-combine", flag.ExitOnError)
+	psbtCreateCmd := flag.NewFlagSet("psbt-create", flag.ExitOnError)
+	psbtFinalizeCmd := flag.NewFlagSet("psbt-finalize", flag.ExitOnError)
+	psbtSignCmd := flag.NewFlagSet("psbt-sign", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
+	restoreWalletCmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
+	sendMultisigCmd := flag.NewFlagSet("sendmultisig", flag.ExitOnError)
+	sendRawTxCmd := flag.NewFlagSet("sendrawtx", flag.ExitOnError)
+	sendTxCmd := flag.NewFlagSet("sendtx", flag.ExitOnError)
+	signMultisigCmd := flag.NewFlagSet("signmultisig", flag.ExitOnError)
+	signRawTxCmd := flag.NewFlagSet("signrawtx", flag.ExitOnError)
+	spendMultisigCmd := flag.NewFlagSet("spendmultisig", flag.ExitOnError)
 	stakeCmd := flag.NewFlagSet("stake", flag.ExitOnError)
+	validateAddressCmd := flag.NewFlagSet("validateaddress", flag.ExitOnError)
+	verifyNotarizationCmd := flag.NewFlagSet("verify-notarization", flag.ExitOnError)
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	walletPassphraseCmd := flag.NewFlagSet("walletpassphrase", flag.ExitOnError)
+	walletPassphraseChangeCmd := flag.NewFlagSet("walletpassphrasechange", flag.ExitOnError)
 
+	createMultisigM := createMultisigCmd.Int("m", 0, "Number of signatures required")
+	createMultisigKeys := createMultisigCmd.String("keys", "", "Comma separated wallet addresses or hex public keys")
+	createRawTxInputs := createRawTxCmd.String("inputs", "", "Comma separated txid:vout pairs of the outputs to spend")
+	createRawTxOutputs := createRawTxCmd.String("outputs", "", "Comma separated address:amount pairs")
+	createRawTxLockTime := createRawTxCmd.Int64("locktime", 0, "Block height, or Unix time if at least 500000000, before which the transaction cannot be included")
+	createWalletHD := createWalletCmd.Bool("hd", false, "Create the deterministic wallet, whose seed derives every key")
+	createWalletWords := createWalletCmd.Int("words", 12, "Number of words of the mnemonic of a deterministic wallet: 12 or 24")
+	createWalletPassphrase := createWalletCmd.Bool("passphrase", false, "Protect the mnemonic with a passphrase read from standard input")
+	createWalletType := createWalletCmd.String("type", wallet.Base58Encoding.String(), "Encoding of the new address: base58 or bech32")
+	createWalletUnencrypted := createWalletCmd.Bool("unencrypted", false, "Write the key of the new wallet without encrypting it")
+	encryptWalletAddress := encryptWalletCmd.String("address", "", "Address of the wallet to encrypt")
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+	getNewAddressAccount := getNewAddressCmd.Uint("account", 0, "Account to derive the address in; the number of accounts adds one")
+	getNewAddressChange := getNewAddressCmd.Bool("change", false, "Derive a change address instead of a receiving address")
+	htlcAuditTxid := htlcAuditCmd.String("txid", "", "Contract transaction ID")
+	htlcAuditVout := htlcAuditCmd.Int("vout", 0, "Contract output index")
+	htlcCreateFrom := htlcCreateCmd.String("from", "", "Sender wallet address, which can refund the contract")
+	htlcCreateTo := htlcCreateCmd.String("to", "", "Recipient address, which can redeem the contract")
+	htlcCreateAmount := htlcCreateCmd.Int("amount", 0, "Amount to lock")
+	htlcCreateLockTime := htlcCreateCmd.Int64("locktime", 0, "Block height, or Unix time if at least 500000000, from which the sender can refund")
+	htlcCreateHash := htlcCreateCmd.String("hash", "", "Hex SHA-256 of the secret; a new secret is generated if omitted")
+	htlcRedeemTxid := htlcRedeemCmd.String("txid", "", "Contract transaction ID")
+	htlcRedeemVout := htlcRedeemCmd.Int("vout", 0, "Contract output index")
+	htlcRedeemSecret := htlcRedeemCmd.String("secret", "", "Hex secret matching the contract's hash")
+	htlcRedeemAddress := htlcRedeemCmd.String("address", "", "Recipient wallet address")
+	htlcRedeemMiner := htlcRedeemCmd.String("miner", "", "Validator address proposing the block")
+	htlcRefundTxid := htlcRefundCmd.String("txid", "", "Contract transaction ID")
+	htlcRefundVout := htlcRefundCmd.Int("vout", 0, "Contract output index")
+	htlcRefundAddress := htlcRefundCmd.String("address", "", "Sender wallet address")
+	htlcRefundMiner := htlcRefundCmd.String("miner", "", "Validator address proposing the block")
+	mineMiner := mineCmd.String("miner", "", "Validator address proposing the blocks and receiving their rewards")
+	mineBlocks := mineCmd.Int("blocks", 1, "Number of blocks to propose")
+	notarizeFile := notarizeCmd.String("file", "", "File whose hash to anchor")
+	notarizeFrom := notarizeCmd.String("from", "", "Wallet address paying for and proposing the block")
+	psbtBroadcastFile := psbtBroadcastCmd.String("file", "", "Partially signed transaction file")
+	psbtBroadcastMiner := psbtBroadcastCmd.String("miner", "", "Validator address proposing the block")
+	psbtCombineIn := psbtCombineCmd.String("in", "", "Comma separated partially signed transaction files")
+	psbtCombineOut := psbtCombineCmd.String("out", "", "File to write the combined transaction to")
+	psbtCreateFrom := psbtCreateCmd.String("from", "", "Key address or saved multisig address to spend from")
+	psbtCreateTo := psbtCreateCmd.String("to", "", "Comma separated address:amount pairs")
+	psbtCreateFile := psbtCreateCmd.String("file", "", "File to write the partially signed transaction to")
+	psbtFinalizeFile := psbtFinalizeCmd.String("file", "", "Partially signed transaction file")
+	psbtFinalizeOut := psbtFinalizeCmd.String("out", "", "File to write the signed transaction to")
+	psbtSignFile := psbtSignCmd.String("file", "", "Partially signed transaction file")
+	psbtSignAddress := psbtSignCmd.String("address", "", "Address of the signing wallet")
+	psbtSignSigHash := psbtSignCmd.String("sighash", "ALL", "Signature hash type: ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY")
 	sendFrom := sendCmd.String("from", "", "Source wallet address")
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
 	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendLockTime := sendCmd.Int64("locktime", 0, "Block height, or Unix time if at least 500000000, before which the payment cannot be included")
+	sendFile := sendCmd.String("file", "", "File to write the signed payment to if its lock time has not been reached")
+	sendSigHash := sendCmd.String("sighash", "ALL", "Signature hash type: ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY")
+	sendManyFrom := sendManyCmd.String("from", "", "Source wallet address, which also proposes the block")
+	sendManyFile := sendManyCmd.String("file", "", "CSV file of address,amount rows")
+	sendManyTo := sendManyCmd.String("to", "", "Comma separated address:amount pairs")
+	sendMultisigFile := sendMultisigCmd.String("file", "", "Partially signed transaction file")
+	sendMultisigMiner := sendMultisigCmd.String("miner", "", "Validator address proposing the block")
+	restoreWalletMnemonic := restoreWalletCmd.String("mnemonic", "", "Mnemonic of the deterministic wallet")
+	restoreWalletPassphrase := restoreWalletCmd.Bool("passphrase", false, "Read the mnemonic passphrase from standard input")
+	restoreWalletGapLimit := restoreWalletCmd.Int("gaplimit", wallet.DefaultGapLimit, "Unused addresses in a row after which the search stops")
+	sendRawTxMiner := sendRawTxCmd.String("miner", "", "Validator address proposing the block")
+	sendTxFile := sendTxCmd.String("file", "", "Signed transaction file")
+	sendTxMiner := sendTxCmd.String("miner", "", "Validator address proposing the block")
+	signMultisigFile := signMultisigCmd.String("file", "", "Partially signed transaction file")
+	signMultisigAddress := signMultisigCmd.String("address", "", "Address of the co-signer")
+	signRawTxAddress := signRawTxCmd.String("address", "", "Address of the signing wallet")
+	signRawTxSigHash := signRawTxCmd.String("sighash", "ALL", "Signature hash type: ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY")
+	spendMultisigFrom := spendMultisigCmd.String("from", "", "Shared multisig address")
+	spendMultisigTo := spendMultisigCmd.String("to", "", "Destination address")
+	spendMultisigAmount := spendMultisigCmd.Int("amount", 0, "Amount to send")
+	spendMultisigFile := spendMultisigCmd.String("file", "", "File to write the partially signed transaction to")
 	stakeAddress := stakeCmd.String("address", "", "The address to stake from")
 	stakeAmount := stakeCmd.Int64("amount", 0, "Amount to stake")
+	verifyNotarizationFile := verifyNotarizationCmd.String("file", "", "File whose hash to look up")
+	walletLockAddress := walletLockCmd.String("address", "", "Address of the wallet to lock")
+	walletPassphraseAddress := walletPassphraseCmd.String("address", "", "Address of the wallet to unlock")
+	walletPassphraseTimeout := walletPassphraseCmd.Int("timeout", 0, "Seconds the wallet stays unlocked")
+	walletPassphraseChangeAddress := walletPassphraseChangeCmd.String("address", "", "Address of the wallet whose passphrase to change")
 
+	var rawTx string
+	var validateAddress string
     switch os.Args[1] {
+	case "createmultisig":
+		err := createMultisigCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "createrawtx":
+		err := createRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
     case "createwallet":
         err := createWalletCmd.Parse(os.Args[2:])
         if err != nil {
             return err
         }
+	case "decoderawtx":
+		var err error
+		rawTx, err = parseWithArg(decodeRawTxCmd, os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
     case "getbalance":
         err := getBalanceCmd.Parse(os.Args[2:])
         if err != nil {
             return err
         }
+	case "getdeploymentinfo":
+		err := getDeploymentInfoCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "getnewaddress":
+		err := getNewAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "htlc-audit":
+		err := htlcAuditCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "htlc-create":
+		err := htlcCreateCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "htlc-redeem":
+		err := htlcRedeemCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "htlc-refund":
+		err := htlcRefundCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
     case "listaddresses":
         err := listAddressesCmd.Parse(os.Args[2:])
         if err != nil {
             return err
         }
+	case "mine":
+		err := mineCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "notarize":
+		err := notarizeCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
     case "printchain":
         err := printChainCmd.Parse(os.Args[2:])
         if err != nil {
             return err
         }
+	case "psbt-broadcast":
+		err := psbtBroadcastCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "psbt-combine":
+		err := psbtCombineCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "psbt-create":
+		err := psbtCreateCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "psbt-finalize":
+		err := psbtFinalizeCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "psbt-sign":
+		err := psbtSignCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
     case "reindexutxo":
         err := reindexUTXOCmd.Parse(os.Args[2:])
         if err != nil {
             return err
         }
+	case "restorewallet":
+		err := restoreWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
 	case "send":
 		err := sendCmd.Parse(os.Args[2:])
 		if err != nil {
 			return err
 		}
+	case "sendmany":
+		err := sendManyCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "sendmultisig":
+		err := sendMultisigCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "sendrawtx":
+		var err error
+		rawTx, err = parseWithArg(sendRawTxCmd, os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "sendtx":
+		err := sendTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "signmultisig":
+		err := signMultisigCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "signrawtx":
+		var err error
+		rawTx, err = parseWithArg(signRawTxCmd, os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "spendmultisig":
+		err := spendMultisigCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
 	case "stake":
 		err := stakeCmd.Parse(os.Args[2:])
 		if err != nil {
 			return err
 		}
+	case "validateaddress":
+		var err error
+		validateAddress, err = parseWithArg(validateAddressCmd, os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "verify-notarization":
+		err := verifyNotarizationCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "walletlock":
+		err := walletLockCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "walletpassphrase":
+		err := walletPassphraseCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
+	case "walletpassphrasechange":
+		err := walletPassphraseChangeCmd.Parse(os.Args[2:])
+		if err != nil {
+			return err
+		}
 	default:
 		cli.printUsage()
 		return fmt.Errorf("invalid command")
     }
 
+	if createMultisigCmd.Parsed() {
+		if *createMultisigM <= 0 || *createMultisigKeys == "" {
+			createMultisigCmd.Usage()
+			return fmt.Errorf("m and keys are required")
+		}
+		return cli.createMultisig(*createMultisigM, strings.Split(*createMultisigKeys, ","))
+	}
+
+	if createRawTxCmd.Parsed() {
+		if *createRawTxInputs == "" || *createRawTxOutputs == "" {
+			createRawTxCmd.Usage()
+			return fmt.Errorf("inputs and outputs are required")
+		}
+		if *createRawTxLockTime < 0 {
+			createRawTxCmd.Usage()
+			return fmt.Errorf("locktime cannot be negative")
+		}
+		return cli.createRawTx(*createRawTxInputs, *createRawTxOutputs, *createRawTxLockTime)
+	}
+
     if createWalletCmd.Parsed() {
-        return cli.createWallet()
+        encoding, err := wallet.ParseAddressEncoding(*createWalletType)
+        if err != nil {
+            createWalletCmd.Usage()
+            return err
+        }
+        if *createWalletHD {
+            if *createWalletWords != 12 && *createWalletWords != 24 {
+                createWalletCmd.Usage()
+                return fmt.Errorf("words must be 12 or 24")
+            }
+            return cli.createHDWallet(*createWalletWords, *createWalletPassphrase, encoding)
+        }
+        return cli.createWallet(encoding, *createWalletUnencrypted)
     }
 
+	if decodeRawTxCmd.Parsed() {
+		if rawTx == "" {
+			decodeRawTxCmd.Usage()
+			return fmt.Errorf("raw transaction is required")
+		}
+		return cli.decodeRawTx(rawTx)
+	}
+
+	if encryptWalletCmd.Parsed() {
+		if *encryptWalletAddress == "" {
+			encryptWalletCmd.Usage()
+			return fmt.Errorf("address is required")
+		}
+		return cli.encryptWallet(*encryptWalletAddress)
+	}
+
     if getBalanceCmd.Parsed() {
         if *getBalanceAddress == "" {
             getBalanceCmd.Usage()
@@ -114,24 +454,211 @@ func (cli *CLI) Run() error {
         return cli.getBalance(*getBalanceAddress)
     }
 
+	if getNewAddressCmd.Parsed() {
+		if *getNewAddressAccount >= uint(wallet.HardenedKeyStart) {
+			getNewAddressCmd.Usage()
+			return fmt.Errorf("account must be below %d", wallet.HardenedKeyStart)
+		}
+		return cli.getNewAddress(uint32(*getNewAddressAccount), *getNewAddressChange)
+	}
+
+	if getDeploymentInfoCmd.Parsed() {
+		return cli.getDeploymentInfo()
+	}
+
+	if htlcAuditCmd.Parsed() {
+		if *htlcAuditTxid == "" {
+			htlcAuditCmd.Usage()
+			return fmt.Errorf("txid is required")
+		}
+		return cli.htlcAudit(*htlcAuditTxid, *htlcAuditVout)
+	}
+
+	if htlcCreateCmd.Parsed() {
+		if *htlcCreateFrom == "" || *htlcCreateTo == "" || *htlcCreateAmount <= 0 || *htlcCreateLockTime <= 0 {
+			htlcCreateCmd.Usage()
+			return fmt.Errorf("from, to, amount and locktime are required")
+		}
+		return cli.htlcCreate(*htlcCreateFrom, *htlcCreateTo, *htlcCreateAmount, *htlcCreateLockTime, *htlcCreateHash)
+	}
+
+	if htlcRedeemCmd.Parsed() {
+		if *htlcRedeemTxid == "" || *htlcRedeemSecret == "" || *htlcRedeemAddress == "" || *htlcRedeemMiner == "" {
+			htlcRedeemCmd.Usage()
+			return fmt.Errorf("txid, secret, address and miner are required")
+		}
+		return cli.htlcRedeem(*htlcRedeemTxid, *htlcRedeemVout, *htlcRedeemSecret, *htlcRedeemAddress, *htlcRedeemMiner)
+	}
+
+	if htlcRefundCmd.Parsed() {
+		if *htlcRefundTxid == "" || *htlcRefundAddress == "" || *htlcRefundMiner == "" {
+			htlcRefundCmd.Usage()
+			return fmt.Errorf("txid, address and miner are required")
+		}
+		return cli.htlcRefund(*htlcRefundTxid, *htlcRefundVout, *htlcRefundAddress, *htlcRefundMiner)
+	}
+
     if listAddressesCmd.Parsed() {
         return cli.listAddresses()
     }
 
+	if mineCmd.Parsed() {
+		if *mineMiner == "" {
+			mineCmd.Usage()
+			return fmt.Errorf("miner is required")
+		}
+		if *mineBlocks <= 0 {
+			mineCmd.Usage()
+			return fmt.Errorf("blocks must be positive")
+		}
+		return cli.mine(*mineMiner, *mineBlocks)
+	}
+
+	if notarizeCmd.Parsed() {
+		if *notarizeFile == "" || *notarizeFrom == "" {
+			notarizeCmd.Usage()
+			return fmt.Errorf("file and from are required")
+		}
+		return cli.notarize(*notarizeFile, *notarizeFrom)
+	}
+
     if printChainCmd.Parsed() {
         return cli.printChain()
     }
 
+	if psbtBroadcastCmd.Parsed() {
+		if *psbtBroadcastFile == "" || *psbtBroadcastMiner == "" {
+			psbtBroadcastCmd.Usage()
+			return fmt.Errorf("file and miner are required")
+		}
+		return cli.psbtBroadcast(*psbtBroadcastFile, *psbtBroadcastMiner)
+	}
+
+	if psbtCombineCmd.Parsed() {
+		if *psbtCombineIn == "" || *psbtCombineOut == "" {
+			psbtCombineCmd.Usage()
+			return fmt.Errorf("in and out are required")
+		}
+		return cli.psbtCombine(strings.Split(*psbtCombineIn, ","), *psbtCombineOut)
+	}
+
+	if psbtCreateCmd.Parsed() {
+		if *psbtCreateFrom == "" || *psbtCreateTo == "" || *psbtCreateFile == "" {
+			psbtCreateCmd.Usage()
+			return fmt.Errorf("from, to and file are required")
+		}
+		return cli.psbtCreate(*psbtCreateFrom, *psbtCreateTo, *psbtCreateFile)
+	}
+
+	if psbtFinalizeCmd.Parsed() {
+		if *psbtFinalizeFile == "" || *psbtFinalizeOut == "" {
+			psbtFinalizeCmd.Usage()
+			return fmt.Errorf("file and out are required")
+		}
+		return cli.psbtFinalize(*psbtFinalizeFile, *psbtFinalizeOut)
+	}
+
+	if psbtSignCmd.Parsed() {
+		if *psbtSignFile == "" || *psbtSignAddress == "" {
+			psbtSignCmd.Usage()
+			return fmt.Errorf("file and address are required")
+		}
+		hashType, err := transaction.ParseSigHashType(*psbtSignSigHash)
+		if err != nil {
+			psbtSignCmd.Usage()
+			return err
+		}
+		return cli.psbtSign(*psbtSignFile, *psbtSignAddress, hashType)
+	}
+
     if reindexUTXOCmd.Parsed() {
         return cli.reindexUTXO()
     }
 
+	if restoreWalletCmd.Parsed() {
+		if *restoreWalletMnemonic == "" || *restoreWalletGapLimit <= 0 {
+			restoreWalletCmd.Usage()
+			return fmt.Errorf("mnemonic and a positive gap limit are required")
+		}
+		return cli.restoreWallet(*restoreWalletMnemonic, *restoreWalletPassphrase, *restoreWalletGapLimit)
+	}
+
 	if sendCmd.Parsed() {
 		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
 			sendCmd.Usage()
 			return fmt.Errorf("from, to and amount are required")
 		}
-		return cli.send(*sendFrom, *sendTo, *sendAmount)
+		if *sendLockTime < 0 {
+			sendCmd.Usage()
+			return fmt.Errorf("locktime cannot be negative")
+		}
+		hashType, err := transaction.ParseSigHashType(*sendSigHash)
+		if err != nil {
+			sendCmd.Usage()
+			return err
+		}
+		return cli.send(*sendFrom, *sendTo, *sendAmount, *sendLockTime, *sendFile, hashType)
+	}
+
+	if sendManyCmd.Parsed() {
+		if *sendManyFrom == "" || (*sendManyFile == "") == (*sendManyTo == "") {
+			sendManyCmd.Usage()
+			return fmt.Errorf("from and one of file or to are required")
+		}
+		return cli.sendMany(*sendManyFrom, *sendManyFile, *sendManyTo)
+	}
+
+	if sendMultisigCmd.Parsed() {
+		if *sendMultisigFile == "" || *sendMultisigMiner == "" {
+			sendMultisigCmd.Usage()
+			return fmt.Errorf("file and miner are required")
+		}
+		return cli.sendMultisig(*sendMultisigFile, *sendMultisigMiner)
+	}
+
+	if sendRawTxCmd.Parsed() {
+		if rawTx == "" || *sendRawTxMiner == "" {
+			sendRawTxCmd.Usage()
+			return fmt.Errorf("raw transaction and miner are required")
+		}
+		return cli.sendRawTx(rawTx, *sendRawTxMiner)
+	}
+
+	if sendTxCmd.Parsed() {
+		if *sendTxFile == "" || *sendTxMiner == "" {
+			sendTxCmd.Usage()
+			return fmt.Errorf("file and miner are required")
+		}
+		return cli.sendTx(*sendTxFile, *sendTxMiner)
+	}
+
+	if signMultisigCmd.Parsed() {
+		if *signMultisigFile == "" || *signMultisigAddress == "" {
+			signMultisigCmd.Usage()
+			return fmt.Errorf("file and address are required")
+		}
+		return cli.signMultisig(*signMultisigFile, *signMultisigAddress)
+	}
+
+	if signRawTxCmd.Parsed() {
+		if rawTx == "" || *signRawTxAddress == "" {
+			signRawTxCmd.Usage()
+			return fmt.Errorf("raw transaction and address are required")
+		}
+		hashType, err := transaction.ParseSigHashType(*signRawTxSigHash)
+		if err != nil {
+			signRawTxCmd.Usage()
+			return err
+		}
+		return cli.signRawTx(rawTx, *signRawTxAddress, hashType)
+	}
+
+	if spendMultisigCmd.Parsed() {
+		if *spendMultisigFrom == "" || *spendMultisigTo == "" || *spendMultisigAmount <= 0 || *spendMultisigFile == "" {
+			spendMultisigCmd.Usage()
+			return fmt.Errorf("from, to, amount and file are required")
+		}
+		return cli.spendMultisig(*spendMultisigFrom, *spendMultisigTo, *spendMultisigAmount, *spendMultisigFile)
 	}
 
 	if stakeCmd.Parsed() {
@@ -142,40 +669,165 @@ func (cli *CLI) Run() error {
 		return cli.addStake(*stakeAddress, *stakeAmount)
 	}
 
+	if validateAddressCmd.Parsed() {
+		if validateAddress == "" {
+			validateAddressCmd.Usage()
+			return fmt.Errorf("address is required")
+		}
+		return cli.validateAddress(validateAddress)
+	}
+
+	if verifyNotarizationCmd.Parsed() {
+		if *verifyNotarizationFile == "" {
+			verifyNotarizationCmd.Usage()
+			return fmt.Errorf("file is required")
+		}
+		return cli.verifyNotarization(*verifyNotarizationFile)
+	}
+
+	if walletLockCmd.Parsed() {
+		if *walletLockAddress == "" {
+			walletLockCmd.Usage()
+			return fmt.Errorf("address is required")
+		}
+		return cli.walletLock(*walletLockAddress)
+	}
+
+	if walletPassphraseCmd.Parsed() {
+		if *walletPassphraseAddress == "" || *walletPassphraseTimeout <= 0 {
+			walletPassphraseCmd.Usage()
+			return fmt.Errorf("address and a positive timeout are required")
+		}
+		return cli.walletPassphrase(*walletPassphraseAddress, time.Duration(*walletPassphraseTimeout)*time.Second)
+	}
+
+	if walletPassphraseChangeCmd.Parsed() {
+		if *walletPassphraseChangeAddress == "" {
+			walletPassphraseChangeCmd.Usage()
+			return fmt.Errorf("address is required")
+		}
+		return cli.walletPassphraseChange(*walletPassphraseChangeAddress)
+	}
+
 	return nil
 }
 
-func (cli *CLI) createWallet() error {
-    w := wallet.NewWallet()
-    address := w.GetAddress()
+// createWallet creates a wallet encrypted with a passphrase read from standard input,
+// so its key is never written in the clear, or an unencrypted one if unencrypted
+func (cli *CLI) createWallet(encoding wallet.AddressEncoding, unencrypted bool) error {
+    var w *wallet.Wallet
+    if unencrypted {
+        w = wallet.NewWallet()
+    } else {
+        passphrase, err := newPassphraseReader().readNew("New passphrase: ")
+        if err != nil {
+            return err
+        }
+        if w, err = wallet.NewEncryptedWallet(passphrase); err != nil {
+            return err
+        }
+    }
+    address, err := wallet.EncodeAddress(w.GetAddress(), encoding)
+    if err != nil {
+        return err
+    }
     fmt.Printf("Your new address: %s\n", address)
+    if !unencrypted {
+        fmt.Println("The wallet is encrypted and locked; unlock it with walletpassphrase to sign")
+    }
     return nil
 }
 
 func (cli *CLI) getBalance(address string) error {
-    w := wallet.LoadWallet(address)
-    if w == nil {
-        return fmt.Errorf("wallet not found for address: %s", address)
+    lockingScript, err := resolveLockingScript(address)
+    if err != nil {
+        return err
     }
 
-    pubKeyHash := wallet.HashPubKey(w.PublicKey)
-
     UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
-    UTXOs := UTXOSet.FindUTXO(pubKeyHash)
-
-    balance := 0
-    for _, out := range UTXOs {
-        balance += out.Value
+    balance, immature, err := UTXOSet.FindScriptBalance(lockingScript)
+    if err != nil {
+        return fmt.Errorf("failed to get balance: %v", err)
     }
 
     fmt.Printf("Balance of '%s': %d\n", address, balance)
+    if immature > 0 {
+        fmt.Printf("Immature coinbase balance: %d (spendable after %d confirmations)\n",
+            immature, cli.bc.GetConfig().CoinbaseMaturity)
+    }
     return nil
 }
 
+// getDeploymentInfo prints the state of every known deployment for the next block
+func (cli *CLI) getDeploymentInfo() error {
+	infos, err := cli.bc.GetDeploymentInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get deployment info: %v", err)
+	}
+
+	for _, info := range infos {
+		d := info.Deployment
+		fmt.Printf("%s:\n", d.Name)
+		fmt.Printf("  State at height %d: %s\n", info.Height, info.State)
+		if d.IsBuried() {
+			fmt.Printf("  Type: buried, activation height %d\n", d.ActivationHeight)
+			continue
+		}
+		fmt.Printf("  Type: version bits, bit %d\n", d.Bit)
+		fmt.Printf("  Start height: %d, timeout height: %d\n", d.StartHeight, d.TimeoutHeight)
+		fmt.Printf("  Period: %d/%d blocks signaled (window %d, threshold %d)\n",
+			info.PeriodSignals, info.PeriodElapsed, d.Window, d.Threshold)
+	}
+	return nil
+}
+
 func (cli *CLI) listAddresses() error {
-    addresses := wallet.ListAddresses()
+    addresses, err := wallet.ListAddresses()
+    if err != nil {
+        return err
+    }
+    derived, err := wallet.ListDerivedAddresses()
+    if err != nil {
+        return err
+    }
+    paths := make(map[string]string)
+    for _, d := range derived {
+        paths[d.Address] = wallet.FormatPath(d.Path())
+    }
+
     for _, address := range addresses {
-        fmt.Println(address)
+        if path, ok := paths[address]; ok {
+            fmt.Printf("%s %s\n", address, path)
+        } else {
+            fmt.Println(address)
+        }
+    }
+    return nil
+}
+
+// validateAddress prints the network and type of an address and whether it can be
+// paid to on the active network
+func (cli *CLI) validateAddress(address string) error {
+    info, err := wallet.ValidateAddress(address)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("Address: %s\n", address)
+    fmt.Printf("Network: %s\n", info.Network)
+    fmt.Printf("Type: %s\n", info.Type)
+    fmt.Printf("Encoding: %s\n", info.Encoding)
+    fmt.Printf("Hash: %x\n", info.Hash)
+    for _, encoding := range []wallet.AddressEncoding{wallet.Base58Encoding, wallet.Bech32Encoding} {
+        if encoding != info.Encoding {
+            other, err := wallet.EncodeAddress(address, encoding)
+            if err != nil {
+                return err
+            }
+            fmt.Printf("As %s: %s\n", encoding, other)
+        }
+    }
+    if active := wallet.ActiveNetwork().Name; info.Network != active {
+        fmt.Printf("Not payable on %s\n", active)
     }
     return nil
 }
@@ -193,6 +845,8 @@ func (cli *CLI) printChain() error {
         }
 
         fmt.Printf("============ Block %x ============\n", block.Hash)
+        fmt.Printf("Height: %d\n", block.Height)
+        fmt.Printf("Version: 0x%08x\n", block.Version)
         fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
         
         // Check if this is a PoS block (has validator signature)
@@ -230,52 +884,198 @@ func (cli *CLI) reindexUTXO() error {
     return nil
 }
 
-func (cli *CLI) send(from, to string, amount int) error {
-    fromWallet := wallet.LoadWallet(from)
-    if fromWallet == nil {
-        return fmt.Errorf("wallet not found for address: %s", from)
+// send pays amount from a local wallet to any address, signing with hashType. A
+// payment with a lock time the next block cannot meet yet is written to file for
+// sendtx instead.
+func (cli *CLI) send(from, to string, amount int, lockTime int64, file string, hashType transaction.SigHashType) error {
+    fromWallet, err := wallet.LoadWallet(from)
+    if err != nil {
+        return err
+    }
+
+    toScript, err := resolveLockingScript(to)
+    if err != nil {
+        return err
     }
 
-    toWallet := wallet.LoadWallet(to)
-    if toWallet == nil {
-        return fmt.Errorf("wallet not found for address: %s", to)
+    changeScript, markUsed, err := changeDestination(from, fromWallet)
+    if err != nil {
+        return err
     }
 
     UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
 
-    tx, err := transaction.NewUTXOTransaction(fromWallet, wallet.HashPubKey(toWallet.PublicKey), amount, UTXOSet.FindSpendableOutputs)
+    payment := []transaction.TxOutput{{Value: amount, LockingScript: toScript}}
+    var tx *transaction.Transaction
+    err = cli.payRequiredFee(func(fee int) (int, error) {
+        tx, err = transaction.NewBatchTransactionWithChange(fromWallet, payment, changeScript, fee, UTXOSet.FindSpendableOutputs)
+        if err != nil {
+            return 0, fmt.Errorf("failed to create transaction: %v", err)
+        }
+        tx.LockTime = lockTime
+        tx.ID = tx.Hash() // The lock time is part of the ID
+
+        // Sign the transaction
+        if err := cli.bc.SignTransactionWithHashType(tx, fromWallet, hashType); err != nil {
+            return 0, fmt.Errorf("failed to sign transaction: %v", err)
+        }
+        return len(tx.Serialize()), nil
+    })
     if err != nil {
-        return fmt.Errorf("failed to create transaction: %v", err)
+        return err
     }
 
-    // Sign the transaction
-    err = cli.bc.SignTransaction(tx, fromWallet)
+    final, err := cli.isFinalForNextBlock(tx)
     if err != nil {
-        return fmt.Errorf("failed to sign transaction: %v", err)
+        return err
+    }
+    if !final {
+        if file == "" {
+            return fmt.Errorf("transaction is locked until %d, give -file to save it for sendtx", lockTime)
+        }
+        if err := os.WriteFile(file, tx.Serialize(), 0600); err != nil {
+            return fmt.Errorf("failed to write %s: %v", file, err)
+        }
+        fmt.Printf("Transaction %x is locked until %d, written to %s\n", tx.ID, lockTime, file)
+        return markUsed(tx)
     }
 
-    cbTx := transaction.NewCoinbaseTx(fromWallet.PublicKey, "")
-    txs := []*transaction.Transaction{cbTx, tx}
+    if err := cli.mineTransaction(tx, fromWallet); err != nil {
+        return err
+    }
+    fmt.Println("Success!")
+    return markUsed(tx)
+}
 
-	newBlock, err := cli.bc.MineBlock(txs, fromWallet)
-	if err != nil {
-		return fmt.Errorf("failed to mine new block: %v", err)
-	}
+// sendTx includes the signed transaction in file in a block proposed by miner
+func (cli *CLI) sendTx(file, miner string) error {
+    data, err := os.ReadFile(file)
+    if err != nil {
+        return fmt.Errorf("failed to read %s: %v", file, err)
+    }
+    tx, err := transaction.DeserializeTransaction(data)
+    if err != nil {
+        return err
+    }
+    minerWallet, err := wallet.LoadWallet(miner)
+    if err != nil {
+        return err
+    }
 
-    err = UTXOSet.Update(newBlock)
+    final, err := cli.isFinalForNextBlock(tx)
     if err != nil {
-        return fmt.Errorf("failed to update UTXO set: %v", err)
+        return err
+    }
+    if !final {
+        return fmt.Errorf("transaction is locked until %d", tx.LockTime)
     }
 
-    fmt.Println("Success!")
+    if err := cli.mineTransaction(tx, minerWallet); err != nil {
+        return err
+    }
+    fmt.Printf("Success! Transaction %x\n", tx.ID)
+    return nil
+}
+
+// isFinalForNextBlock reports whether the lock time of tx allows it in the next block
+func (cli *CLI) isFinalForNextBlock(tx *transaction.Transaction) (bool, error) {
+    bestHeight, err := cli.bc.GetBestHeight()
+    if err != nil {
+        return false, fmt.Errorf("failed to get best height: %v", err)
+    }
+    medianTime, err := cli.bc.MedianTimePast()
+    if err != nil {
+        return false, fmt.Errorf("failed to get median time past: %v", err)
+    }
+    return tx.IsFinal(bestHeight+1, medianTime), nil
+}
+
+// mineTransaction includes tx in a new block proposed by minerWallet, which also
+// collects the coinbase, and updates the UTXO set. Transactions the node's policy
+// finds non-standard are rejected with the reason.
+func (cli *CLI) mineTransaction(tx *transaction.Transaction, minerWallet *wallet.Wallet) error {
+    if err := cli.bc.CheckStandard(tx); err != nil {
+        return fmt.Errorf("transaction rejected: %v", err)
+    }
+    return cli.mineBlock(minerWallet, tx)
+}
+
+// payRequiredFee calls build with a fee, starting at none, until the fee covers the
+// node's minimum relay fee for the size of the transaction build returns. Adding to
+// the fee can change the inputs and change output, and so the size.
+func (cli *CLI) payRequiredFee(build func(fee int) (size int, err error)) error {
+    fee := 0
+    for {
+        size, err := build(fee)
+        if err != nil {
+            return err
+        }
+        required := cli.bc.Policy().RequiredFee(size)
+        if fee >= required {
+            return nil
+        }
+        fee = required
+    }
+}
+
+// mine proposes n blocks holding only a coinbase paying the wallet of miner. Nothing
+// else builds blocks without a payment, so a new chain relies on it to mature the
+// genesis reward.
+func (cli *CLI) mine(miner string, n int) error {
+    minerWallet, err := wallet.LoadWallet(miner)
+    if err != nil {
+        return err
+    }
+    for i := 0; i < n; i++ {
+        if err := cli.mineBlock(minerWallet); err != nil {
+            return err
+        }
+    }
+
+    bestHeight, err := cli.bc.GetBestHeight()
+    if err != nil {
+        return fmt.Errorf("failed to get best height: %v", err)
+    }
+    fmt.Printf("Mined %d blocks, best height %d\n", n, bestHeight)
     return nil
 }
 
+// mineBlock proposes a block with a coinbase paying minerWallet followed by txs, and
+// adds it to the UTXO set
+func (cli *CLI) mineBlock(minerWallet *wallet.Wallet, txs ...*transaction.Transaction) error {
+    bestHeight, err := cli.bc.GetBestHeight()
+    if err != nil {
+        return fmt.Errorf("failed to get best height: %v", err)
+    }
+    cbTx := transaction.NewCoinbaseTx(minerWallet.PublicKey, "", bestHeight+1)
+
+    newBlock, err := cli.bc.MineBlock(append([]*transaction.Transaction{cbTx}, txs...), minerWallet)
+    if err != nil {
+        return fmt.Errorf("failed to mine new block: %v", err)
+    }
+
+    UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
+    if err := UTXOSet.Update(newBlock); err != nil {
+        return fmt.Errorf("failed to update UTXO set: %v", err)
+    }
+    return nil
+}
+
+// resolveLockingScript returns the locking script paying to address, which may be a
+// key address or a script address
+func resolveLockingScript(address string) ([]byte, error) {
+    lockingScript, err := wallet.AddressLockingScript(address)
+    if err != nil {
+        return nil, fmt.Errorf("invalid address: %v", err)
+    }
+    return lockingScript, nil
+}
+
 // addStake adds stake for a PoS validator
 func (cli *CLI) addStake(address string, amount int64) error {
-	w := wallet.LoadWallet(address)
-	if w == nil {
-		return fmt.Errorf("wallet not found for address: %s", address)
+	w, err := wallet.LoadWallet(address)
+	if err != nil {
+		return err
 	}
 
 	// Get the PoS consensus instance from blockchain
@@ -285,7 +1085,7 @@ func (cli *CLI) addStake(address string, amount int64) error {
 	}
 
 	// Add stake for the validator
-	err := posConsensus.AddStake(amount, w)
+	err = posConsensus.AddStake(amount, w)
 	if err != nil {
 		return fmt.Errorf("failed to add stake: %v", err)
 	}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/blockchain"
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// newTestCLI creates a blockchain with config in a temporary directory, with wallets
+// in a temporary home directory, and returns a CLI on it with the miner's wallet
+func newTestCLI(t *testing.T, config blockchain.Config) (*CLI, *wallet.Wallet) {
+	t.Setenv("HOME", t.TempDir())
+	minerWallet := wallet.NewWallet()
+	bc, err := blockchain.CreateBlockchainInDir(t.TempDir(), minerWallet, config)
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	t.Cleanup(func() { bc.CloseDB() })
+	return NewCLI(bc), minerWallet
+}
+
+// balance returns the spendable and immature balance of address
+func balance(t *testing.T, cli *CLI, address string) (int, int) {
+	lockingScript, err := resolveLockingScript(address)
+	if err != nil {
+		t.Fatalf("Invalid address: %v", err)
+	}
+	UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
+	spendable, immature, err := UTXOSet.FindScriptBalance(lockingScript)
+	if err != nil {
+		t.Fatalf("Failed to get balance: %v", err)
+	}
+	return spendable, immature
+}
+
+// Test a chain created with the default maturity moves: the genesis reward is
+// immature, mining empty blocks matures it, and it can then be spent
+func TestInitMatureSpend(t *testing.T) {
+	cli, minerWallet := newTestCLI(t, blockchain.DefaultConfig())
+	miner := minerWallet.GetAddress()
+	recipient := wallet.NewWallet().GetAddress()
+
+	if spendable, immature := balance(t, cli, miner); spendable != 0 || immature == 0 {
+		t.Fatalf("Expected only an immature genesis reward, got %d spendable and %d immature", spendable, immature)
+	}
+	err := cli.send(miner, recipient, 5, 0, "", transaction.SigHashAll)
+	if err == nil || !strings.Contains(err.Error(), "not enough funds") {
+		t.Fatalf("Expected spending the immature reward to fail, got %v", err)
+	}
+
+	// The genesis reward is spendable from the block at the maturity height
+	if err := cli.mine(miner, int(blockchain.DefaultCoinbaseMaturity)-1); err != nil {
+		t.Fatalf("Failed to mine: %v", err)
+	}
+	if spendable, _ := balance(t, cli, miner); spendable == 0 {
+		t.Fatalf("Genesis reward did not mature")
+	}
+	if err := cli.send(miner, recipient, 5, 0, "", transaction.SigHashAll); err != nil {
+		t.Fatalf("Failed to spend the matured reward: %v", err)
+	}
+	if spendable, _ := balance(t, cli, recipient); spendable != 5 {
+		t.Errorf("Recipient has %d, expected 5", spendable)
+	}
+}
+
+// Test chains of two networks without their own address format do not pay to each
+// other's addresses, in either encoding
+func TestSendBetweenCustomNetworks(t *testing.T) {
+	defer wallet.SetNetwork(blockchain.DefaultNetwork)
+	config := blockchain.DefaultConfig()
+	config.CoinbaseMaturity = 0
+
+	// An address of alpha, kept while its wallet directory is replaced
+	config.Network = "alpha"
+	wallet.SetNetwork(config.Network)
+	newTestCLI(t, config)
+	alphaAddress := wallet.NewWallet().GetAddress()
+	alphaBech32, err := wallet.EncodeAddress(alphaAddress, wallet.Bech32Encoding)
+	if err != nil {
+		t.Fatalf("Failed to encode alpha address: %v", err)
+	}
+
+	config.Network = "beta"
+	wallet.SetNetwork(config.Network)
+	cli, minerWallet := newTestCLI(t, config)
+	miner := minerWallet.GetAddress()
+	betaAddress := wallet.NewWallet().GetAddress()
+
+	for _, to := range []string{alphaAddress, alphaBech32} {
+		err := cli.send(miner, to, 5, 0, "", transaction.SigHashAll)
+		if err == nil || !strings.Contains(err.Error(), "not beta") {
+			t.Errorf("Expected paying alpha address %s on beta to fail, got %v", to, err)
+		}
+		info, err := wallet.ValidateAddress(to)
+		if err != nil {
+			t.Fatalf("Failed to validate %s: %v", to, err)
+		}
+		if info.Network == "beta" || info.Network == "testnet" {
+			t.Errorf("Alpha address %s reported as %s", to, info.Network)
+		}
+	}
+	if err := cli.send(miner, betaAddress, 5, 0, "", transaction.SigHashAll); err != nil {
+		t.Fatalf("Failed to pay beta address: %v", err)
+	}
+	if spendable, _ := balance(t, cli, betaAddress); spendable != 5 {
+		t.Errorf("Recipient has %d, expected 5", spendable)
+	}
+}
+
+// Test wallet payments pay the node's minimum relay fee, including a multisig spend
+// whose fee is set before its co-signers sign
+func TestPaymentsPayMinRelayFee(t *testing.T) {
+	cli, minerWallet := newTestCLI(t, blockchain.Config{CoinbaseMaturity: 0, Network: blockchain.DefaultNetwork})
+	policy := blockchain.DefaultPolicy()
+	policy.MinRelayFee = 50
+	if err := cli.bc.SetPolicy(policy); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+	miner := minerWallet.GetAddress()
+	if err := cli.mine(miner, 1); err != nil {
+		t.Fatalf("Failed to mine: %v", err)
+	}
+
+	signers := []*wallet.Wallet{wallet.NewWallet(), wallet.NewWallet()}
+	redeemScript, err := script.MultiSigScript(2, [][]byte{signers[0].PublicKey, signers[1].PublicKey})
+	if err != nil {
+		t.Fatalf("Failed to create multisig script: %v", err)
+	}
+	multisig, err := wallet.SaveRedeemScript(redeemScript)
+	if err != nil {
+		t.Fatalf("Failed to save redeem script: %v", err)
+	}
+	if err := cli.send(miner, multisig, 60, 0, "", transaction.SigHashAll); err != nil {
+		t.Fatalf("Failed to fund multisig address: %v", err)
+	}
+
+	recipient := wallet.NewWallet().GetAddress()
+	file := filepath.Join(t.TempDir(), "spend.psbt")
+	if err := cli.spendMultisig(multisig, recipient, 20, file); err != nil {
+		t.Fatalf("Failed to create multisig spend: %v", err)
+	}
+	for _, signer := range signers {
+		if err := cli.signMultisig(file, signer.GetAddress()); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+	}
+	if err := cli.sendMultisig(file, miner); err != nil {
+		t.Fatalf("Failed to send multisig spend: %v", err)
+	}
+	if spendable, _ := balance(t, cli, recipient); spendable != 20 {
+		t.Errorf("Recipient has %d, expected 20", spendable)
+	}
+	if spendable, _ := balance(t, cli, multisig); spendable >= 40 {
+		t.Errorf("Multisig change of %d leaves no fee", spendable)
+	}
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/blockchain"
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// createHDWallet creates the deterministic wallet from a new mnemonic of numWords
+// words, prompting for its passphrase if withPassphrase, and prints the mnemonic and
+// the first receiving address in encoding
+func (cli *CLI) createHDWallet(numWords int, withPassphrase bool, encoding wallet.AddressEncoding) error {
+	if _, err := wallet.LoadHDWallet(); err != wallet.ErrNoHDWallet {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("a deterministic wallet already exists in %s", wallet.HDWalletPath())
+	}
+	mnemonic, err := wallet.NewMnemonic(numWords)
+	if err != nil {
+		return err
+	}
+	passphrase, err := readMnemonicPassphrase(withPassphrase, true)
+	if err != nil {
+		return err
+	}
+	seed, err := wallet.MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return err
+	}
+	if _, err := wallet.CreateHDWallet(seed); err != nil {
+		return err
+	}
+	d, err := wallet.NewDerivedAddress(0, wallet.ExternalChain)
+	if err != nil {
+		return err
+	}
+	address, err := wallet.EncodeAddress(d.Address, encoding)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Your new address: %s\n", address)
+	fmt.Printf("Mnemonic: %s\n", mnemonic)
+	fmt.Println("Write the mnemonic down and keep it private: with restorewallet it recovers every address of this wallet, including future ones.")
+	if withPassphrase {
+		fmt.Println("The mnemonic passphrase is needed as well; a different one restores a different, empty wallet.")
+	}
+	return nil
+}
+
+// restoreWallet rebuilds the deterministic wallet from mnemonic, prompting for its
+// passphrase if withPassphrase, then searches the chain for the addresses in use,
+// stopping after gapLimit unused addresses in a row
+func (cli *CLI) restoreWallet(mnemonic string, withPassphrase bool, gapLimit int) error {
+	passphrase, err := readMnemonicPassphrase(withPassphrase, false)
+	if err != nil {
+		return err
+	}
+	seed, err := wallet.MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return fmt.Errorf("invalid mnemonic: %v", err)
+	}
+
+	h, err := wallet.LoadHDWallet()
+	if err == wallet.ErrNoHDWallet {
+		h, err = wallet.NewHDWallet(seed)
+	} else if err == nil && h.Seed == nil {
+		err = fmt.Errorf("%w: unlock the deterministic wallet with walletpassphrase and one of its addresses", wallet.ErrWalletLocked)
+	} else if err == nil && !bytes.Equal(h.Seed, seed) {
+		err = fmt.Errorf("a different deterministic wallet already exists in %s", wallet.HDWalletPath())
+	}
+	if err != nil {
+		return err
+	}
+
+	used, err := cli.bc.UsedPubKeyHashes()
+	if err != nil {
+		return fmt.Errorf("failed to scan the chain: %v", err)
+	}
+	isUsed := func(pubKeyHash []byte) bool {
+		return used[hex.EncodeToString(pubKeyHash)]
+	}
+	if err := h.Rescan(isUsed, gapLimit); err != nil {
+		return err
+	}
+	if err := h.Save(); err != nil {
+		return err
+	}
+
+	addresses, err := h.Addresses()
+	if err != nil {
+		return err
+	}
+	UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
+	total := 0
+	for _, d := range addresses {
+		lockingScript, err := wallet.AddressLockingScript(d.Address)
+		if err != nil {
+			return err
+		}
+		balance, _, err := UTXOSet.FindScriptBalance(lockingScript)
+		if err != nil {
+			return fmt.Errorf("failed to get balance: %v", err)
+		}
+		total += balance
+		fmt.Printf("%s %s balance %d\n", d.Address, wallet.FormatPath(d.Path()), balance)
+	}
+	fmt.Printf("Accounts: %d, addresses in use: %d, total balance: %d\n", len(h.Accounts), len(addresses), total)
+	return nil
+}
+
+// readMnemonicPassphrase reads the passphrase protecting a mnemonic from standard
+// input if prompt is set, twice for a new one, or returns the empty passphrase
+func readMnemonicPassphrase(prompt, isNew bool) (string, error) {
+	if !prompt {
+		return "", nil
+	}
+	reader := newPassphraseReader()
+	var passphrase []byte
+	var err error
+	if isNew {
+		passphrase, err = reader.readNew("Mnemonic passphrase: ")
+	} else {
+		passphrase, err = reader.read("Mnemonic passphrase: ")
+	}
+	return string(passphrase), err
+}
+
+// getNewAddress derives and prints the next unused receiving or change address of
+// account of the deterministic wallet
+func (cli *CLI) getNewAddress(account uint32, change bool) error {
+	chain := wallet.ExternalChain
+	if change {
+		chain = wallet.InternalChain
+	}
+	d, err := wallet.NewDerivedAddress(account, chain)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s\n", d.Address, wallet.FormatPath(d.Path()))
+	return nil
+}
+
+// changeDestination returns the locking script that change of a payment from address,
+// whose key is w, goes to: the next unused change address of its account if address
+// belongs to the deterministic wallet, or address itself otherwise. Once the payment
+// is sent, markUsed records the change address as used if tx pays to it.
+func changeDestination(address string, w *wallet.Wallet) (changeScript []byte, markUsed func(tx *transaction.Transaction) error, err error) {
+	noop := func(*transaction.Transaction) error { return nil }
+	d, err := wallet.FindDerivedAddress(address)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d == nil {
+		return script.PayToPubKeyHash(wallet.HashPubKey(w.PublicKey)), noop, nil
+	}
+
+	h, err := wallet.LoadHDWallet()
+	if err != nil {
+		return nil, nil, err
+	}
+	change, err := h.PeekAddress(d.Account, wallet.InternalChain)
+	if err != nil {
+		return nil, nil, err
+	}
+	changeScript, err = wallet.AddressLockingScript(change.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+	markUsed = func(tx *transaction.Transaction) error {
+		for _, out := range tx.Vout {
+			if bytes.Equal(out.LockingScript, changeScript) {
+				if _, err := wallet.NewDerivedAddress(d.Account, wallet.InternalChain); err != nil {
+					return fmt.Errorf("payment sent, but failed to record change address %s as used: %v", change.Address, err)
+				}
+				return nil
+			}
+		}
+		return nil
+	}
+	return changeScript, markUsed, nil
+}
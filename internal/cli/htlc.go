@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/blockchain"
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// htlcCreate locks amount from a local wallet in a hashed time-locked contract paying
+// to if it reveals the secret, and back to from after lockTime. Without secretHashHex
+// a new secret is generated; the party answering a swap passes the initiator's hash.
+func (cli *CLI) htlcCreate(from, to string, amount int, lockTime int64, secretHashHex string) error {
+	fromWallet, err := wallet.LoadWallet(from)
+	if err != nil {
+		return err
+	}
+	toType, toHash, err := wallet.DecodeAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid address: %v", err)
+	}
+	if toType == wallet.ScriptHashAddr {
+		return fmt.Errorf("contract recipient must be a key address")
+	}
+
+	var secret, secretHash []byte
+	if secretHashHex == "" {
+		secret = make([]byte, script.HTLCSecretSize)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("failed to generate secret: %v", err)
+		}
+		hash := sha256.Sum256(secret)
+		secretHash = hash[:]
+	} else if secretHash, err = hex.DecodeString(secretHashHex); err != nil {
+		return fmt.Errorf("secret hash is not hex encoded")
+	}
+
+	lockingScript, err := script.HTLCScript(script.HTLCContract{
+		SecretHash:          secretHash,
+		RecipientPubKeyHash: toHash,
+		RefundPubKeyHash:    wallet.HashPubKey(fromWallet.PublicKey),
+		LockTime:            lockTime,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid contract: %v", err)
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
+	var tx *transaction.Transaction
+	err = cli.payRequiredFee(func(fee int) (int, error) {
+		tx, err = transaction.NewUTXOTransaction(fromWallet, lockingScript, amount, fee, UTXOSet.FindSpendableOutputs)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create transaction: %v", err)
+		}
+		if err := cli.bc.SignTransaction(tx, fromWallet); err != nil {
+			return 0, fmt.Errorf("failed to sign transaction: %v", err)
+		}
+		return len(tx.Serialize()), nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := cli.mineTransaction(tx, fromWallet); err != nil {
+		return err
+	}
+
+	fmt.Printf("Contract output: %x:0\n", tx.ID)
+	fmt.Printf("Secret hash: %x\n", secretHash)
+	if secret != nil {
+		fmt.Printf("Secret: %x (keep it private until you redeem the other side)\n", secret)
+	}
+	return nil
+}
+
+// htlcRedeem claims a contract output for the recipient wallet at address by revealing
+// the secret, in a block proposed by miner
+func (cli *CLI) htlcRedeem(txidHex string, vout int, secretHex, address, miner string) error {
+	secret, err := hex.DecodeString(secretHex)
+	if err != nil {
+		return fmt.Errorf("secret is not hex encoded")
+	}
+	return cli.htlcSpend(txidHex, vout, secret, address, miner)
+}
+
+// htlcRefund takes a contract output back for the sender wallet at address once its
+// lock time has passed, in a block proposed by miner
+func (cli *CLI) htlcRefund(txidHex string, vout int, address, miner string) error {
+	return cli.htlcSpend(txidHex, vout, nil, address, miner)
+}
+
+// htlcSpend pays a contract output, less the node's minimum relay fee, to the wallet
+// at address, redeeming it with secret or refunding it if secret is nil
+func (cli *CLI) htlcSpend(txidHex string, vout int, secret []byte, address, miner string) error {
+	txid, err := hex.DecodeString(txidHex)
+	if err != nil {
+		return fmt.Errorf("transaction ID is not hex encoded")
+	}
+	w, err := wallet.LoadWallet(address)
+	if err != nil {
+		return err
+	}
+	minerWallet, err := wallet.LoadWallet(miner)
+	if err != nil {
+		return err
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
+	entry, ok, err := UTXOSet.FindOutput(txid, vout)
+	if err != nil {
+		return fmt.Errorf("failed to look up contract output: %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("output %s:%d is not unspent", txidHex, vout)
+	}
+	contract, ok := script.ExtractHTLC(entry.Output.LockingScript)
+	if !ok {
+		return fmt.Errorf("output %s:%d is not a hashed time-locked contract", txidHex, vout)
+	}
+
+	lockTime := int64(0)
+	if secret == nil {
+		lockTime = contract.LockTime
+	}
+	toScript := script.PayToPubKeyHash(wallet.HashPubKey(w.PublicKey))
+	var tx *transaction.Transaction
+	err = cli.payRequiredFee(func(fee int) (int, error) {
+		tx, err = transaction.NewHTLCSpend(txid, vout, entry.Output, toScript, fee, lockTime)
+		if err != nil {
+			return 0, err
+		}
+		if err := tx.SignHTLC(0, w, entry.Output, cli.bc.ChainID(), secret); err != nil {
+			return 0, err
+		}
+		return len(tx.Serialize()), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	final, err := cli.isFinalForNextBlock(tx)
+	if err != nil {
+		return err
+	}
+	if !final {
+		return fmt.Errorf("contract cannot be refunded until %d", contract.LockTime)
+	}
+	if err := cli.mineTransaction(tx, minerWallet); err != nil {
+		return err
+	}
+
+	fmt.Printf("Success! Transaction %x pays %d to %s\n", tx.ID, tx.Vout[0].Value, address)
+	return nil
+}
+
+// htlcAudit prints the terms of a contract output and whether it was redeemed, with
+// the revealed secret, or refunded
+func (cli *CLI) htlcAudit(txidHex string, vout int) error {
+	txid, err := hex.DecodeString(txidHex)
+	if err != nil {
+		return fmt.Errorf("transaction ID is not hex encoded")
+	}
+	tx, err := cli.bc.FindTransaction(txid)
+	if err != nil {
+		return fmt.Errorf("contract transaction %s: %v", txidHex, err)
+	}
+	if vout < 0 || vout >= len(tx.Vout) {
+		return fmt.Errorf("transaction %s has no output %d", txidHex, vout)
+	}
+	out := tx.Vout[vout]
+	contract, ok := script.ExtractHTLC(out.LockingScript)
+	if !ok {
+		return fmt.Errorf("output %s:%d is not a hashed time-locked contract", txidHex, vout)
+	}
+
+	lockTimeKind := "block height"
+	if contract.LockTime >= transaction.LockTimeThreshold {
+		lockTimeKind = "Unix time"
+	}
+	bestHeight, err := cli.bc.GetBestHeight()
+	if err != nil {
+		return fmt.Errorf("failed to get best height: %v", err)
+	}
+
+	fmt.Printf("Contract output: %s:%d\n", txidHex, vout)
+	fmt.Printf("Amount: %d\n", out.Value)
+	fmt.Printf("Recipient: %s\n", wallet.KeyHashAddress(contract.RecipientPubKeyHash))
+	fmt.Printf("Refund to: %s\n", wallet.KeyHashAddress(contract.RefundPubKeyHash))
+	fmt.Printf("Secret hash: %x\n", contract.SecretHash)
+	fmt.Printf("Lock time: %d (%s), chain is at height %d\n", contract.LockTime, lockTimeKind, bestHeight)
+
+	spender, err := cli.bc.FindSpendingTransaction(txid, vout)
+	if err != nil {
+		return fmt.Errorf("failed to look up spending transaction: %v", err)
+	}
+	if spender == nil {
+		fmt.Println("Status: unspent")
+		return nil
+	}
+	for _, vin := range spender.Vin {
+		if !bytes.Equal(vin.Txid, txid) || vin.Vout != vout {
+			continue
+		}
+		if secret := script.ExtractHTLCSecret(vin.UnlockingScript); secret != nil {
+			fmt.Printf("Status: redeemed by transaction %x\n", spender.ID)
+			fmt.Printf("Secret: %x\n", secret)
+		} else {
+			fmt.Printf("Status: refunded by transaction %x\n", spender.ID)
+		}
+	}
+	return nil
+}
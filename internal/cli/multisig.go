@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// createMultisig creates and saves a shared address spendable with m of the given keys.
+// Keys are addresses of local wallets or hex encoded public keys.
+func (cli *CLI) createMultisig(m int, keys []string) error {
+	var pubKeys [][]byte
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if pubKey, err := wallet.LoadPublicKey(key); err == nil {
+			pubKeys = append(pubKeys, pubKey)
+			continue
+		}
+
+		pubKey, err := hex.DecodeString(key)
+		if err != nil || len(pubKey) != 64 {
+			return fmt.Errorf("%s is neither a local wallet address nor a hex public key", key)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	redeemScript, err := script.MultiSigScript(m, pubKeys)
+	if err != nil {
+		return fmt.Errorf("failed to create multisig script: %v", err)
+	}
+	address, err := wallet.SaveRedeemScript(redeemScript)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Your new %d-of-%d multisig address: %s\n", m, len(pubKeys), address)
+	fmt.Printf("Redeem script: %x\n", redeemScript)
+	return nil
+}
+
+// spendMultisig creates an unsigned spend from a shared multisig address and writes it
+// to file for the co-signers
+func (cli *CLI) spendMultisig(from, to string, amount int, file string) error {
+	redeemScript, err := wallet.LoadRedeemScript(from)
+	if err != nil {
+		return err
+	}
+	if redeemScript == nil {
+		return fmt.Errorf("no redeem script saved for address: %s", from)
+	}
+	fromScript := script.PayToScriptHash(script.Hash160(redeemScript))
+	toScript, err := resolveLockingScript(to)
+	if err != nil {
+		return err
+	}
+
+	partial, err := cli.newPartialTx(fromScript, redeemScript, []transaction.TxOutput{{Value: amount, LockingScript: toScript}})
+	if err != nil {
+		return err
+	}
+	if err := writePartialTx(file, partial); err != nil {
+		return err
+	}
+
+	fmt.Printf("Transaction %x needs %d signatures, written to %s\n", partial.Tx.ID, partial.RemainingSignatures(), file)
+	return nil
+}
+
+// signMultisig adds the signatures of a co-signer to the spend in file
+func (cli *CLI) signMultisig(file, address string) error {
+	partial, err := readPartialTx(file)
+	if err != nil {
+		return err
+	}
+	w, err := wallet.LoadWallet(address)
+	if err != nil {
+		return err
+	}
+
+	added, err := partial.Sign(w)
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return fmt.Errorf("%s holds none of the unsigned keys of this transaction", address)
+	}
+	if err := writePartialTx(file, partial); err != nil {
+		return err
+	}
+
+	fmt.Printf("Added %d signatures, %d more required\n", added, partial.RemainingSignatures())
+	return nil
+}
+
+// sendMultisig finalizes the fully signed spend in file and includes it in a block
+// proposed by miner
+func (cli *CLI) sendMultisig(file, miner string) error {
+	partial, err := readPartialTx(file)
+	if err != nil {
+		return err
+	}
+	minerWallet, err := wallet.LoadWallet(miner)
+	if err != nil {
+		return err
+	}
+	if err := cli.checkChainID(partial); err != nil {
+		return err
+	}
+
+	tx, err := partial.Finalize()
+	if err != nil {
+		return err
+	}
+
+	if err := cli.mineTransaction(tx, minerWallet); err != nil {
+		return err
+	}
+	fmt.Printf("Success! Transaction %x\n", tx.ID)
+	return nil
+}
+
+// readPartialTx reads a partially signed transaction from file
+func readPartialTx(file string) (*transaction.PartialTx, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	return transaction.DeserializePartialTx(data)
+}
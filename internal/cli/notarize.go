@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/blockchain"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// notarize anchors the SHA-256 hash of file in a null data output paid for by the
+// local wallet at from, in a block proposed by the same wallet. A file that is
+// already anchored is not anchored again.
+func (cli *CLI) notarize(file, from string) error {
+	fileHash, err := hashFile(file)
+	if err != nil {
+		return err
+	}
+
+	tx, blk, err := cli.bc.FindNullData(fileHash)
+	if err != nil {
+		return fmt.Errorf("failed to search the chain: %v", err)
+	}
+	if tx != nil {
+		fmt.Printf("%s (SHA-256 %x) is already notarized in transaction %x at height %d\n", file, fileHash, tx.ID, blk.Height)
+		return nil
+	}
+
+	fromWallet, err := wallet.LoadWallet(from)
+	if err != nil {
+		return err
+	}
+
+	// Pay the node's minimum relay fee for the signed size, which the fee itself and
+	// the varying length of signatures can change
+	UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
+	err = cli.payRequiredFee(func(fee int) (int, error) {
+		tx, err = transaction.NewNullDataTransaction(fromWallet, fileHash, fee, UTXOSet.FindSpendableOutputs)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create transaction: %v", err)
+		}
+		if err := cli.bc.SignTransaction(tx, fromWallet); err != nil {
+			return 0, fmt.Errorf("failed to sign transaction: %v", err)
+		}
+		return len(tx.Serialize()), nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := cli.mineTransaction(tx, fromWallet); err != nil {
+		return err
+	}
+
+	fmt.Printf("Notarized %s (SHA-256 %x) in transaction %x\n", file, fileHash, tx.ID)
+	return nil
+}
+
+// verifyNotarization looks up the transaction anchoring the SHA-256 hash of file and
+// the block that includes it
+func (cli *CLI) verifyNotarization(file string) error {
+	fileHash, err := hashFile(file)
+	if err != nil {
+		return err
+	}
+
+	tx, blk, err := cli.bc.FindNullData(fileHash)
+	if err != nil {
+		return fmt.Errorf("failed to search the chain: %v", err)
+	}
+	if tx == nil {
+		return fmt.Errorf("%s (SHA-256 %x) is not notarized", file, fileHash)
+	}
+
+	fmt.Printf("File: %s\n", file)
+	fmt.Printf("SHA-256: %x\n", fileHash)
+	fmt.Printf("Transaction: %x\n", tx.ID)
+	fmt.Printf("Block: %x\n", blk.Hash)
+	fmt.Printf("Height: %d\n", blk.Height)
+	fmt.Printf("Time: %s\n", time.Unix(blk.Timestamp, 0).UTC().Format(time.RFC3339))
+	return nil
+}
+
+// hashFile returns the SHA-256 hash of the contents of file
+func hashFile(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
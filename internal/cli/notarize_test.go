@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/blockchain"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+)
+
+// Test notarize pays the node's minimum relay fee, so the transaction it creates is
+// standard and gets mined
+func TestNotarizeMinRelayFee(t *testing.T) {
+	cli, minerWallet := newTestCLI(t, blockchain.Config{CoinbaseMaturity: 0, Network: blockchain.DefaultNetwork})
+	policy := blockchain.DefaultPolicy()
+	policy.MinRelayFee = 50
+	if err := cli.bc.SetPolicy(policy); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "document.txt")
+	if err := os.WriteFile(file, []byte("document"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := cli.notarize(file, minerWallet.GetAddress()); err != nil {
+		t.Fatalf("Failed to notarize: %v", err)
+	}
+
+	fileHash, _ := hashFile(file)
+	tx, _, err := cli.bc.FindNullData(fileHash)
+	if err != nil || tx == nil {
+		t.Fatalf("Notarization not found: %v", err)
+	}
+	if len(tx.Vout) != 2 {
+		t.Fatalf("Expected a null data and a change output, got %d outputs", len(tx.Vout))
+	}
+	fee := transaction.Subsidy - tx.Vout[1].Value
+	if required := policy.RequiredFee(len(tx.Serialize())); fee < required {
+		t.Errorf("Notarization pays fee %d, less than %d", fee, required)
+	}
+}
@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/blockchain"
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// psbtCreate creates an unsigned transaction making the payments in to from address
+// from, a key address or a shared multisig address saved in the wallet directory,
+// and writes it to file as a partially signed transaction. No private key is needed.
+func (cli *CLI) psbtCreate(from, to, file string) error {
+	payments, err := parsePayments(to)
+	if err != nil {
+		return err
+	}
+	fromScript, err := resolveLockingScript(from)
+	if err != nil {
+		return err
+	}
+
+	var redeemScript []byte
+	if script.ExtractScriptHash(fromScript) != nil {
+		if redeemScript, err = wallet.LoadRedeemScript(from); err != nil {
+			return err
+		}
+		if redeemScript == nil {
+			return fmt.Errorf("no redeem script saved for address: %s", from)
+		}
+	}
+
+	partial, err := cli.newPartialTx(fromScript, redeemScript, payments)
+	if err != nil {
+		return err
+	}
+	if err := writePartialTx(file, partial); err != nil {
+		return err
+	}
+
+	fmt.Printf("Transaction %x needs %d signatures, written to %s\n", partial.Tx.ID, partial.RemainingSignatures(), file)
+	return nil
+}
+
+// psbtSign adds the signatures of the wallet at address to the partially signed
+// transaction in file. It does not use the chain.
+func (cli *CLI) psbtSign(file, address string, hashType transaction.SigHashType) error {
+	partial, err := readPartialTx(file)
+	if err != nil {
+		return err
+	}
+	w, err := wallet.LoadWallet(address)
+	if err != nil {
+		return err
+	}
+
+	added, err := partial.SignWithHashType(w, hashType)
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return fmt.Errorf("%s holds none of the unsigned keys of this transaction", address)
+	}
+	if err := writePartialTx(file, partial); err != nil {
+		return err
+	}
+
+	fmt.Printf("Added %d signatures, %d more required\n", added, partial.RemainingSignatures())
+	return nil
+}
+
+// psbtCombine merges the signatures of copies of one partially signed transaction,
+// signed by different parties, into out. It does not use the chain.
+func (cli *CLI) psbtCombine(files []string, out string) error {
+	combined, err := readPartialTx(files[0])
+	if err != nil {
+		return err
+	}
+	for _, file := range files[1:] {
+		partial, err := readPartialTx(file)
+		if err != nil {
+			return err
+		}
+		if _, err := combined.Combine(partial); err != nil {
+			return fmt.Errorf("failed to combine %s: %v", file, err)
+		}
+	}
+	if err := writePartialTx(out, combined); err != nil {
+		return err
+	}
+
+	fmt.Printf("Combined %d files into %s, %d more signatures required\n", len(files), out, combined.RemainingSignatures())
+	return nil
+}
+
+// psbtFinalize checks the signatures of the partially signed transaction in file and
+// writes the signed transaction to out for sendtx. It does not use the chain.
+func (cli *CLI) psbtFinalize(file, out string) error {
+	partial, err := readPartialTx(file)
+	if err != nil {
+		return err
+	}
+	tx, err := partial.Finalize()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, tx.Serialize(), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", out, err)
+	}
+
+	fmt.Printf("Transaction %x is signed, written to %s\n", tx.ID, out)
+	return nil
+}
+
+// psbtBroadcast finalizes the partially signed transaction in file and includes it in
+// a block proposed by miner
+func (cli *CLI) psbtBroadcast(file, miner string) error {
+	partial, err := readPartialTx(file)
+	if err != nil {
+		return err
+	}
+	minerWallet, err := wallet.LoadWallet(miner)
+	if err != nil {
+		return err
+	}
+	if err := cli.checkChainID(partial); err != nil {
+		return err
+	}
+
+	tx, err := partial.Finalize()
+	if err != nil {
+		return err
+	}
+	final, err := cli.isFinalForNextBlock(tx)
+	if err != nil {
+		return err
+	}
+	if !final {
+		return fmt.Errorf("transaction is locked until %d", tx.LockTime)
+	}
+
+	if err := cli.mineTransaction(tx, minerWallet); err != nil {
+		return err
+	}
+	fmt.Printf("Success! Transaction %x\n", tx.ID)
+	return nil
+}
+
+// newPartialTx creates an unsigned transaction making the payments from the outputs
+// locked with fromScript, with change back to it, together with the outputs it spends.
+// It pays the node's minimum relay fee for its size once signed. redeemScript is the
+// redeem script of a pay-to-script-hash fromScript.
+func (cli *CLI) newPartialTx(fromScript, redeemScript []byte, payments []transaction.TxOutput) (*transaction.PartialTx, error) {
+	var partial *transaction.PartialTx
+	err := cli.payRequiredFee(func(fee int) (int, error) {
+		var err error
+		partial, err = cli.newPartialTxWithFee(fromScript, redeemScript, payments, fee)
+		if err != nil {
+			return 0, err
+		}
+		return partial.SignedSize(), nil
+	})
+	return partial, err
+}
+
+// newPartialTxWithFee is like newPartialTx, paying fee
+func (cli *CLI) newPartialTxWithFee(fromScript, redeemScript []byte, payments []transaction.TxOutput, fee int) (*transaction.PartialTx, error) {
+	UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
+	tx, err := transaction.NewScriptBatchTransaction(fromScript, payments, fee, UTXOSet.FindSpendableScriptOutputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %v", err)
+	}
+
+	var prevOutputs []transaction.TxOutput
+	var redeemScripts [][]byte
+	for _, vin := range tx.Vin {
+		entry, ok, err := UTXOSet.FindOutput(vin.Txid, vin.Vout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up spent output: %v", err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("output %x:%d is not unspent", vin.Txid, vin.Vout)
+		}
+		prevOutputs = append(prevOutputs, entry.Output)
+		redeemScripts = append(redeemScripts, redeemScript)
+	}
+
+	return transaction.NewPartialTx(tx, cli.bc.ChainID(), prevOutputs, redeemScripts)
+}
+
+// checkChainID returns an error if the signatures of partial are for another chain
+func (cli *CLI) checkChainID(partial *transaction.PartialTx) error {
+	if partial.ChainID != cli.bc.ChainID() {
+		return fmt.Errorf("transaction is signed for chain %s, not this chain %s", partial.ChainID, cli.bc.ChainID())
+	}
+	return nil
+}
+
+// writePartialTx writes a partially signed transaction to file
+func writePartialTx(file string, partial *transaction.PartialTx) error {
+	if err := os.WriteFile(file, partial.Serialize(), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", file, err)
+	}
+	return nil
+}
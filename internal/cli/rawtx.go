@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/blockchain"
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// createRawTx creates an unsigned transaction spending the given outputs, listed as
+// txid:vout pairs, and making the payments in outputs. Nothing goes back as change:
+// whatever the inputs hold beyond the payments is left as fee. It prints the
+// transaction as hex for signrawtx.
+func (cli *CLI) createRawTx(inputs, outputs string, lockTime int64) error {
+	payments, err := parsePayments(outputs)
+	if err != nil {
+		return err
+	}
+
+	tx := &transaction.Transaction{Vout: payments, LockTime: lockTime}
+	for _, pair := range strings.Split(inputs, ",") {
+		txidHex, voutStr, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return fmt.Errorf("input %q is not of the form txid:vout", pair)
+		}
+		txid, err := hex.DecodeString(txidHex)
+		if err != nil || len(txid) == 0 {
+			return fmt.Errorf("input %q has a transaction ID that is not hex encoded", pair)
+		}
+		vout, err := strconv.Atoi(voutStr)
+		if err != nil || vout < 0 {
+			return fmt.Errorf("input %q has an invalid output index", pair)
+		}
+		tx.Vin = append(tx.Vin, transaction.TxInput{Txid: txid, Vout: vout})
+	}
+
+	prevOutputs, err := cli.rawTxPrevOutputs(tx)
+	if err != nil {
+		return err
+	}
+	in, out := 0, 0
+	for _, prevOut := range prevOutputs {
+		in += prevOut.Value
+	}
+	for _, payment := range payments {
+		out += payment.Value
+	}
+	if out > in {
+		return fmt.Errorf("outputs pay %d but the inputs hold only %d", out, in)
+	}
+
+	tx.ID = tx.Hash()
+	fmt.Printf("%x\n", tx.Serialize())
+	if in > out {
+		// Kept off stdout so the hex can be piped to signrawtx
+		fmt.Fprintf(os.Stderr, "Fee: %d\n", in-out)
+	}
+	return nil
+}
+
+// decodeRawTx prints the inputs and outputs of a transaction given as hex. It does
+// not use the chain.
+func (cli *CLI) decodeRawTx(rawTx string) error {
+	tx, err := decodeRawTx(rawTx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Transaction: %x\n", tx.ID)
+	if !tx.HasValidID() {
+		fmt.Printf("Warning: the ID does not match the transaction, which hashes to %x\n", tx.Hash())
+	}
+	fmt.Printf("Witness hash: %x\n", tx.WitnessHash())
+	fmt.Printf("Lock time: %d\n", tx.LockTime)
+	for i, vin := range tx.Vin {
+		unlocking, err := script.Disassemble(vin.UnlockingScript)
+		if err != nil {
+			unlocking = fmt.Sprintf("%x (%v)", vin.UnlockingScript, err)
+		}
+		if len(vin.UnlockingScript) == 0 {
+			unlocking = "(unsigned)"
+		}
+		fmt.Printf("Input %d: %x:%d\n", i, vin.Txid, vin.Vout)
+		fmt.Printf("  Sequence: 0x%08x\n", vin.Sequence)
+		fmt.Printf("  Unlocking script: %s\n", unlocking)
+	}
+	for i, vout := range tx.Vout {
+		locking, err := script.Disassemble(vout.LockingScript)
+		if err != nil {
+			locking = fmt.Sprintf("%x (%v)", vout.LockingScript, err)
+		}
+		fmt.Printf("Output %d: %d\n", i, vout.Value)
+		fmt.Printf("  Type: %s\n", script.GetScriptClass(vout.LockingScript))
+		if address := lockingScriptAddress(vout.LockingScript); address != "" {
+			fmt.Printf("  Address: %s\n", address)
+		}
+		fmt.Printf("  Locking script: %s\n", locking)
+	}
+	return nil
+}
+
+// signRawTx signs every input of a transaction given as hex with the wallet at
+// address, and prints the signed transaction as hex for sendrawtx
+func (cli *CLI) signRawTx(rawTx, address string, hashType transaction.SigHashType) error {
+	tx, err := decodeRawTx(rawTx)
+	if err != nil {
+		return err
+	}
+	w, err := wallet.LoadWallet(address)
+	if err != nil {
+		return err
+	}
+
+	if _, err := cli.rawTxPrevOutputs(tx); err != nil {
+		return err
+	}
+	if err := cli.bc.SignTransactionWithHashType(tx, w, hashType); err != nil {
+		return fmt.Errorf("failed to sign transaction: %v", err)
+	}
+
+	fmt.Printf("%x\n", tx.Serialize())
+	return nil
+}
+
+// sendRawTx checks the signatures of a transaction given as hex and includes it in a
+// block proposed by miner
+func (cli *CLI) sendRawTx(rawTx, miner string) error {
+	tx, err := decodeRawTx(rawTx)
+	if err != nil {
+		return err
+	}
+	minerWallet, err := wallet.LoadWallet(miner)
+	if err != nil {
+		return err
+	}
+
+	if !tx.HasValidID() {
+		return fmt.Errorf("transaction ID %x does not match its hash %x", tx.ID, tx.Hash())
+	}
+	if _, err := cli.rawTxPrevOutputs(tx); err != nil {
+		return err
+	}
+	if err := cli.bc.VerifyTransaction(tx); err != nil {
+		return fmt.Errorf("bad signature: %v", err)
+	}
+	final, err := cli.isFinalForNextBlock(tx)
+	if err != nil {
+		return err
+	}
+	if !final {
+		return fmt.Errorf("transaction is locked until %d", tx.LockTime)
+	}
+
+	if err := cli.mineTransaction(tx, minerWallet); err != nil {
+		return err
+	}
+	fmt.Printf("Success! Transaction %x\n", tx.ID)
+	return nil
+}
+
+// rawTxPrevOutputs returns the outputs spent by the inputs of tx, which must all be
+// unspent
+func (cli *CLI) rawTxPrevOutputs(tx *transaction.Transaction) ([]transaction.TxOutput, error) {
+	if len(tx.Vin) == 0 {
+		return nil, fmt.Errorf("transaction has no inputs")
+	}
+	if tx.IsCoinbase() {
+		return nil, fmt.Errorf("coinbase transactions cannot be sent")
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
+	var prevOutputs []transaction.TxOutput
+	for i, vin := range tx.Vin {
+		entry, ok, err := UTXOSet.FindOutput(vin.Txid, vin.Vout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up the output spent by input %d: %v", i, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("input %d spends %x:%d, which does not exist or is already spent", i, vin.Txid, vin.Vout)
+		}
+		prevOutputs = append(prevOutputs, entry.Output)
+	}
+	return prevOutputs, nil
+}
+
+// decodeRawTx decodes a transaction given as hex
+func decodeRawTx(rawTx string) (*transaction.Transaction, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(rawTx))
+	if err != nil {
+		return nil, fmt.Errorf("raw transaction is not hex encoded")
+	}
+	tx, err := transaction.DeserializeTransaction(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode raw transaction: %v", err)
+	}
+	return tx, nil
+}
+
+// lockingScriptAddress returns the address a locking script pays to, or "" if it does
+// not pay to a single key or script hash
+func lockingScriptAddress(lockingScript []byte) string {
+	if pubKeyHash := script.ExtractPubKeyHash(lockingScript); pubKeyHash != nil {
+		return wallet.KeyHashAddress(pubKeyHash)
+	}
+	if scriptHash := script.ExtractScriptHash(lockingScript); scriptHash != nil {
+		return wallet.ScriptHashAddress(scriptHash)
+	}
+	return ""
+}
+
+// parseWithArg parses the flags of a command that takes one positional argument,
+// given before or after the flags, and returns the argument
+func parseWithArg(fs *flag.FlagSet, args []string) (string, error) {
+	var arg string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		arg, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if arg == "" {
+		arg = fs.Arg(0)
+	}
+	return arg, nil
+}
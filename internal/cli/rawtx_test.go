@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/blockchain"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// captureStdout runs fn and returns what it printed to stdout
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	err = fn()
+	w.Close()
+	return <-output, err
+}
+
+// Helper to create a CLI on a chain whose genesis reward can be spent right away,
+// returning the miner's wallet and the txid:vout of the reward
+func newRawTxCLI(t *testing.T) (*CLI, *wallet.Wallet, string) {
+	cli, minerWallet := newTestCLI(t, blockchain.Config{CoinbaseMaturity: 0, Network: blockchain.DefaultNetwork})
+	UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
+	_, outputs, err := UTXOSet.FindSpendableOutputs(wallet.HashPubKey(minerWallet.PublicKey), 1)
+	if err != nil || len(outputs) != 1 {
+		t.Fatalf("Expected the genesis reward, got %v: %v", outputs, err)
+	}
+	for txid, vouts := range outputs {
+		return cli, minerWallet, fmt.Sprintf("%s:%d", txid, vouts[0])
+	}
+	return nil, nil, ""
+}
+
+// Test a transaction goes through createrawtx, decoderawtx, signrawtx and sendrawtx
+// as hex and pays the recipient
+func TestRawTxRoundTrip(t *testing.T) {
+	cli, minerWallet, input := newRawTxCLI(t)
+	miner := minerWallet.GetAddress()
+	recipient := wallet.NewWallet().GetAddress()
+
+	unsigned, err := captureStdout(t, func() error {
+		return cli.createRawTx(input, fmt.Sprintf("%s:20,%s:%d", recipient, miner, transaction.Subsidy-25), 0)
+	})
+	if err != nil {
+		t.Fatalf("Failed to create raw transaction: %v", err)
+	}
+	decoded, err := captureStdout(t, func() error { return cli.decodeRawTx(unsigned) })
+	if err != nil {
+		t.Fatalf("Failed to decode raw transaction: %v", err)
+	}
+	if !strings.Contains(decoded, "Address: "+recipient) || !strings.Contains(decoded, "(unsigned)") {
+		t.Errorf("Decoded transaction does not show an unsigned payment to the recipient:\n%s", decoded)
+	}
+
+	signed, err := captureStdout(t, func() error { return cli.signRawTx(unsigned, miner, transaction.SigHashAll) })
+	if err != nil {
+		t.Fatalf("Failed to sign raw transaction: %v", err)
+	}
+	unsignedTx, _ := decodeRawTx(unsigned)
+	signedTx, err := decodeRawTx(signed)
+	if err != nil {
+		t.Fatalf("Signed transaction does not decode: %v", err)
+	}
+	if !bytes.Equal(signedTx.ID, unsignedTx.ID) {
+		t.Errorf("Signing changed the transaction ID from %x to %x", unsignedTx.ID, signedTx.ID)
+	}
+
+	if _, err := captureStdout(t, func() error { return cli.sendRawTx(signed, miner) }); err != nil {
+		t.Fatalf("Failed to send raw transaction: %v", err)
+	}
+	if spendable, _ := balance(t, cli, recipient); spendable != 20 {
+		t.Errorf("Recipient has %d, expected 20", spendable)
+	}
+
+	// The reward is now spent, so the same transaction cannot be sent again
+	_, err = captureStdout(t, func() error { return cli.sendRawTx(signed, miner) })
+	if err == nil || !strings.Contains(err.Error(), "does not exist or is already spent") {
+		t.Errorf("Expected the spent output to be reported, got %v", err)
+	}
+}
+
+// Test raw transactions are rejected when they spend a missing output, carry a bad
+// signature or are signed for another chain
+func TestRawTxErrors(t *testing.T) {
+	cli, minerWallet, input := newRawTxCLI(t)
+	miner := minerWallet.GetAddress()
+	outputs := fmt.Sprintf("%s:10", wallet.NewWallet().GetAddress())
+
+	missing := strings.Repeat("ab", 32) + ":0"
+	_, err := captureStdout(t, func() error { return cli.createRawTx(missing, outputs, 0) })
+	if err == nil || !strings.Contains(err.Error(), "does not exist or is already spent") {
+		t.Errorf("Expected a missing previous output error, got %v", err)
+	}
+
+	unsigned, err := captureStdout(t, func() error { return cli.createRawTx(input, outputs, 0) })
+	if err != nil {
+		t.Fatalf("Failed to create raw transaction: %v", err)
+	}
+	signed, err := captureStdout(t, func() error { return cli.signRawTx(unsigned, miner, transaction.SigHashAll) })
+	if err != nil {
+		t.Fatalf("Failed to sign raw transaction: %v", err)
+	}
+
+	tampered, _ := decodeRawTx(signed)
+	tampered.Vin[0].UnlockingScript[10] ^= 0x01
+	_, err = captureStdout(t, func() error { return cli.sendRawTx(hex.EncodeToString(tampered.Serialize()), miner) })
+	if err == nil || !strings.Contains(err.Error(), "bad signature") {
+		t.Errorf("Expected a bad signature error, got %v", err)
+	}
+
+	otherChain, _ := decodeRawTx(unsigned)
+	prevTX, err := cli.bc.FindTransaction(otherChain.Vin[0].Txid)
+	if err != nil || prevTX == nil {
+		t.Fatalf("Genesis coinbase not found: %v", err)
+	}
+	prevTXs := map[string]transaction.Transaction{hex.EncodeToString(prevTX.ID): *prevTX}
+	otherID := transaction.NewChainID([]byte("other genesis"), blockchain.DefaultNetwork)
+	if err := otherChain.Sign(minerWallet, prevTXs, otherID); err != nil {
+		t.Fatalf("Failed to sign for another chain: %v", err)
+	}
+	_, err = captureStdout(t, func() error { return cli.sendRawTx(hex.EncodeToString(otherChain.Serialize()), miner) })
+	if err == nil || !strings.Contains(err.Error(), "bad signature") {
+		t.Errorf("Expected a transaction signed for another chain to be rejected, got %v", err)
+	}
+
+	if _, err := captureStdout(t, func() error { return cli.sendRawTx(signed, miner) }); err != nil {
+		t.Errorf("Valid transaction was rejected: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/blockchain"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// sendMany makes every payment in one transaction from a local wallet, with one
+// change output, and includes it in a block proposed by the same wallet. Payments
+// come from a CSV file of address,amount rows or a list of address:amount pairs.
+func (cli *CLI) sendMany(from, file, to string) error {
+	var payments []transaction.TxOutput
+	var err error
+	if file != "" {
+		payments, err = readPayments(file)
+	} else {
+		payments, err = parsePayments(to)
+	}
+	if err != nil {
+		return err
+	}
+
+	fromWallet, err := wallet.LoadWallet(from)
+	if err != nil {
+		return err
+	}
+
+	changeScript, markUsed, err := changeDestination(from, fromWallet)
+	if err != nil {
+		return err
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: cli.bc}
+	var tx *transaction.Transaction
+	err = cli.payRequiredFee(func(fee int) (int, error) {
+		tx, err = transaction.NewBatchTransactionWithChange(fromWallet, payments, changeScript, fee, UTXOSet.FindSpendableOutputs)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create transaction: %v", err)
+		}
+		if err := cli.bc.SignTransaction(tx, fromWallet); err != nil {
+			return 0, fmt.Errorf("failed to sign transaction: %v", err)
+		}
+		return len(tx.Serialize()), nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := cli.bc.VerifyTransaction(tx); err != nil {
+		return fmt.Errorf("transaction is invalid: %v", err)
+	}
+	if err := cli.mineTransaction(tx, fromWallet); err != nil {
+		return err
+	}
+
+	total := 0
+	for _, payment := range payments {
+		total += payment.Value
+	}
+	fmt.Printf("Success! Transaction %x pays %d to %d recipients\n", tx.ID, total, len(payments))
+	return markUsed(tx)
+}
+
+// readPayments reads payments from a CSV file with one address,amount row per
+// payment. A first row of address,amount is taken as a header and skipped.
+func readPayments(file string) ([]transaction.TxOutput, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", file, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	var payments []transaction.TxOutput
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		if line == 1 && strings.EqualFold(record[0], "address") && strings.EqualFold(record[1], "amount") {
+			continue
+		}
+		payment, err := newPaymentOutput(record[0], record[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s, row %d: %v", file, line, err)
+		}
+		payments = append(payments, payment)
+	}
+	if len(payments) == 0 {
+		return nil, fmt.Errorf("%s holds no payments", file)
+	}
+	return payments, nil
+}
+
+// parsePayments parses payments given as comma separated address:amount pairs
+func parsePayments(list string) ([]transaction.TxOutput, error) {
+	var payments []transaction.TxOutput
+	for _, pair := range strings.Split(list, ",") {
+		address, amount, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("payment %q is not of the form address:amount", pair)
+		}
+		payment, err := newPaymentOutput(address, amount)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
+// newPaymentOutput returns the output paying amount to address
+func newPaymentOutput(address, amount string) (transaction.TxOutput, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(amount))
+	if err != nil || value <= 0 {
+		return transaction.TxOutput{}, fmt.Errorf("invalid amount %q for %s", amount, address)
+	}
+	lockingScript, err := resolveLockingScript(strings.TrimSpace(address))
+	if err != nil {
+		return transaction.TxOutput{}, err
+	}
+	return transaction.TxOutput{Value: value, LockingScript: lockingScript}, nil
+}
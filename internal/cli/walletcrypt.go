@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+	"golang.org/x/term"
+)
+
+// passphraseReader reads passphrases from standard input one line at a time, without
+// echoing them if it is a terminal. Prompts go to standard error, so they stay out
+// of redirected output.
+type passphraseReader struct {
+	r        *bufio.Reader
+	terminal bool
+}
+
+// newPassphraseReader returns a reader of standard input
+func newPassphraseReader() *passphraseReader {
+	return &passphraseReader{bufio.NewReader(os.Stdin), term.IsTerminal(int(os.Stdin.Fd()))}
+}
+
+// read prompts for and returns the next line without its line ending
+func (p *passphraseReader) read(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if p.terminal {
+		passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+		// The line ending the user typed was not echoed either
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase: %v", err)
+		}
+		return passphrase, nil
+	}
+	line, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return nil, fmt.Errorf("failed to read passphrase: %v", err)
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
+
+// readNew prompts for a new passphrase twice and returns it if both match
+func (p *passphraseReader) readNew(prompt string) ([]byte, error) {
+	passphrase, err := p.read(prompt)
+	if err != nil {
+		return nil, err
+	}
+	repeated, err := p.read("Repeat passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if string(passphrase) != string(repeated) {
+		return nil, fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// encryptWallet encrypts the wallet of address with a passphrase read from standard
+// input. The wallet is locked afterwards.
+func (cli *CLI) encryptWallet(address string) error {
+	encrypted, err := wallet.IsEncrypted(address)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		return fmt.Errorf("wallet %s is already encrypted; use walletpassphrasechange", address)
+	}
+	passphrase, err := newPassphraseReader().readNew("New passphrase: ")
+	if err != nil {
+		return err
+	}
+	if err := wallet.EncryptWallet(address, passphrase); err != nil {
+		return err
+	}
+	fmt.Printf("Wallet %s is encrypted and locked; unlock it with walletpassphrase to sign\n", address)
+	if d, _ := wallet.FindDerivedAddress(address); d != nil {
+		fmt.Println("Its key derives from the seed of the deterministic wallet, so every address of that wallet is encrypted with it")
+	}
+	return nil
+}
+
+// walletPassphrase unlocks the wallet of address for timeout with a passphrase read
+// from standard input
+func (cli *CLI) walletPassphrase(address string, timeout time.Duration) error {
+	passphrase, err := newPassphraseReader().read("Passphrase: ")
+	if err != nil {
+		return err
+	}
+	if err := wallet.UnlockWallet(address, passphrase, timeout); err != nil {
+		return err
+	}
+	fmt.Printf("Wallet %s is unlocked until %s\n", address, time.Now().Add(timeout).Format(time.RFC3339))
+	return nil
+}
+
+// walletLock locks the wallet of address before its unlock timeout
+func (cli *CLI) walletLock(address string) error {
+	if err := wallet.LockWallet(address); err != nil {
+		return err
+	}
+	fmt.Printf("Wallet %s is locked\n", address)
+	return nil
+}
+
+// walletPassphraseChange re-encrypts the wallet of address, reading the old and the
+// new passphrase from standard input. The wallet is locked afterwards.
+func (cli *CLI) walletPassphraseChange(address string) error {
+	reader := newPassphraseReader()
+	oldPassphrase, err := reader.read("Old passphrase: ")
+	if err != nil {
+		return err
+	}
+	newPassphrase, err := reader.readNew("New passphrase: ")
+	if err != nil {
+		return err
+	}
+	if err := wallet.ChangePassphrase(address, oldPassphrase, newPassphrase); err != nil {
+		return err
+	}
+	fmt.Printf("Passphrase of wallet %s is changed and the wallet is locked\n", address)
+	return nil
+}
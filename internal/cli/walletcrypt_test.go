@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// Test createwallet encrypts the new wallet with the passphrase read from standard
+// input, and writes it unencrypted only when asked to
+func TestCreateWalletEncrypted(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	input := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(input, []byte("secret\nsecret\n"), 0600); err != nil {
+		t.Fatalf("Failed to write passphrase: %v", err)
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		t.Fatalf("Failed to open passphrase: %v", err)
+	}
+	defer f.Close()
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+
+	createWallet := func(unencrypted bool) string {
+		output, err := captureStdout(t, func() error { return (&CLI{}).createWallet(wallet.Base58Encoding, unencrypted) })
+		if err != nil {
+			t.Fatalf("Failed to create wallet: %v", err)
+		}
+		address, _, _ := strings.Cut(strings.TrimPrefix(output, "Your new address: "), "\n")
+		return address
+	}
+
+	address := createWallet(false)
+	if encrypted, err := wallet.IsEncrypted(address); err != nil || !encrypted {
+		t.Fatalf("Expected %s to be encrypted, got %v: %v", address, encrypted, err)
+	}
+	if _, err := wallet.LoadWallet(address); !errors.Is(err, wallet.ErrWalletLocked) {
+		t.Errorf("Expected the new wallet to be locked, got %v", err)
+	}
+	if _, err := wallet.LoadWalletWithPassphrase(address, []byte("secret")); err != nil {
+		t.Errorf("Failed to load the new wallet with its passphrase: %v", err)
+	}
+
+	address = createWallet(true)
+	if encrypted, err := wallet.IsEncrypted(address); err != nil || encrypted {
+		t.Errorf("Expected %s to be unencrypted, got %v: %v", address, encrypted, err)
+	}
+}
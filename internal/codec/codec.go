@@ -0,0 +1,225 @@
+// Package codec implements the canonical binary encoding used to store and hash
+// blocks and transactions.
+//
+// The encoding is deterministic and independent of the Go runtime:
+//   - Fixed-size integers are written little-endian with their exact width
+//     (int32 as 4 bytes, int64 and Go int as 8 bytes, two's complement for negatives).
+//   - Byte strings are written as a uint32 little-endian length followed by the bytes.
+//     Nil and empty byte strings encode identically.
+//   - Lists are written as a uint32 little-endian element count followed by the elements.
+//   - Booleans are a single byte, 0x00 or 0x01.
+//
+// Structures are encoded field by field in the order documented on their encoders,
+// with no padding, tags or field names.
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// MaxBytesLen bounds the length of a single byte string or list accepted by the Reader
+const MaxBytesLen = 32 * 1024 * 1024
+
+// Writer accumulates a canonical encoding
+type Writer struct {
+	buf bytes.Buffer
+}
+
+// NewWriter returns an empty Writer
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+// Bytes returns the encoded data
+func (w *Writer) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// WriteUint8 writes a single byte
+func (w *Writer) WriteUint8(v uint8) {
+	w.buf.WriteByte(v)
+}
+
+// WriteBool writes a boolean as 0x00 or 0x01
+func (w *Writer) WriteBool(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+// WriteUint32 writes a 4-byte little-endian unsigned integer
+func (w *Writer) WriteUint32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.buf.Write(b[:])
+}
+
+// WriteInt32 writes a 4-byte little-endian signed integer
+func (w *Writer) WriteInt32(v int32) {
+	w.WriteUint32(uint32(v))
+}
+
+// WriteUint64 writes an 8-byte little-endian unsigned integer
+func (w *Writer) WriteUint64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	w.buf.Write(b[:])
+}
+
+// WriteInt64 writes an 8-byte little-endian signed integer
+func (w *Writer) WriteInt64(v int64) {
+	w.WriteUint64(uint64(v))
+}
+
+// WriteInt writes a Go int as an 8-byte little-endian signed integer
+func (w *Writer) WriteInt(v int) {
+	w.WriteInt64(int64(v))
+}
+
+// WriteBytes writes a length-prefixed byte string
+func (w *Writer) WriteBytes(v []byte) {
+	w.WriteUint32(uint32(len(v)))
+	w.buf.Write(v)
+}
+
+// WriteCount writes the element count of a list
+func (w *Writer) WriteCount(n int) {
+	w.WriteUint32(uint32(n))
+}
+
+// Reader decodes a canonical encoding. The first error is sticky: once a read fails,
+// every later read returns a zero value and Err reports the failure.
+type Reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// NewReader returns a Reader over data
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+// Err returns the first error encountered while reading
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Finish returns the first read error, or an error if unread bytes remain
+func (r *Reader) Finish() error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.pos != len(r.data) {
+		return fmt.Errorf("codec: %d trailing bytes", len(r.data)-r.pos)
+	}
+	return nil
+}
+
+// Remaining returns the number of unread bytes
+func (r *Reader) Remaining() int {
+	return len(r.data) - r.pos
+}
+
+// next consumes n bytes, or records an error if fewer remain
+func (r *Reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data)-r.pos < n {
+		r.err = fmt.Errorf("codec: unexpected end of data at offset %d", r.pos)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// ReadUint8 reads a single byte
+func (r *Reader) ReadUint8() uint8 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+// ReadBool reads a boolean, rejecting values other than 0x00 and 0x01
+func (r *Reader) ReadBool() bool {
+	v := r.ReadUint8()
+	if v > 1 && r.err == nil {
+		r.err = fmt.Errorf("codec: invalid boolean %d at offset %d", v, r.pos-1)
+	}
+	return v == 1
+}
+
+// ReadUint32 reads a 4-byte little-endian unsigned integer
+func (r *Reader) ReadUint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+// ReadInt32 reads a 4-byte little-endian signed integer
+func (r *Reader) ReadInt32() int32 {
+	return int32(r.ReadUint32())
+}
+
+// ReadUint64 reads an 8-byte little-endian unsigned integer
+func (r *Reader) ReadUint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// ReadInt64 reads an 8-byte little-endian signed integer
+func (r *Reader) ReadInt64() int64 {
+	return int64(r.ReadUint64())
+}
+
+// ReadInt reads an 8-byte little-endian signed integer into a Go int
+func (r *Reader) ReadInt() int {
+	return int(r.ReadInt64())
+}
+
+// ReadBytes reads a length-prefixed byte string. The result is a copy of the input.
+func (r *Reader) ReadBytes() []byte {
+	n := r.ReadUint32()
+	if r.err != nil {
+		return nil
+	}
+	if n > MaxBytesLen {
+		r.err = fmt.Errorf("codec: byte string of %d bytes exceeds limit", n)
+		return nil
+	}
+	b := r.next(int(n))
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// ReadCount reads the element count of a list. Each element occupies at least
+// minElemSize bytes, which bounds the count by the remaining input.
+func (r *Reader) ReadCount(minElemSize int) int {
+	n := r.ReadUint32()
+	if r.err != nil {
+		return 0
+	}
+	if minElemSize < 1 {
+		minElemSize = 1
+	}
+	if uint64(n)*uint64(minElemSize) > uint64(len(r.data)-r.pos) {
+		r.err = fmt.Errorf("codec: list of %d elements exceeds remaining data", n)
+		return 0
+	}
+	return int(n)
+}
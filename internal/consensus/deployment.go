@@ -0,0 +1,441 @@
+package consensus
+
+import (
+	"fmt"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/block"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// VersionBitsTopBits marks a block version as taking part in deployment signaling
+	VersionBitsTopBits int32 = 0x20000000
+	// versionBitsTopMask selects the bits that must equal VersionBitsTopBits
+	versionBitsTopMask uint32 = 0xe0000000
+
+	// BuriedDeployment is the StartHeight of a deployment that activates at a fixed height
+	// instead of through version-bit signaling
+	BuriedDeployment = -1
+
+	// Names of the known deployments
+	DeploymentTestDummy = "testdummy"
+)
+
+// ThresholdState is the activation state of a deployment at a given height
+type ThresholdState int
+
+const (
+	ThresholdDefined  ThresholdState = iota // Deployment is known but not yet started
+	ThresholdStarted                        // Proposers signal for the deployment
+	ThresholdLockedIn                       // Signaling succeeded, activation happens next period
+	ThresholdActive                         // Rules of the deployment are enforced
+	ThresholdFailed                         // Deployment timed out without activating
+)
+
+// String returns a human readable name of the state
+func (s ThresholdState) String() string {
+	switch s {
+	case ThresholdDefined:
+		return "defined"
+	case ThresholdStarted:
+		return "started"
+	case ThresholdLockedIn:
+		return "locked_in"
+	case ThresholdActive:
+		return "active"
+	case ThresholdFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// Deployment describes a named consensus rule change and how it activates.
+// A buried deployment (StartHeight == BuriedDeployment) is active from ActivationHeight on.
+// Otherwise the deployment follows version-bit signaling: once started, it locks in when
+// at least Threshold blocks of a Window-sized period set Bit in their version, and becomes
+// active one period later. It fails if it has not locked in by TimeoutHeight.
+type Deployment struct {
+	Name             string
+	Bit              uint8 // Version bit used for signaling (0-28)
+	StartHeight      int64 // First height of signaling, or BuriedDeployment
+	TimeoutHeight    int64 // Height from which an unfinished deployment fails
+	ActivationHeight int64 // Activation height of a buried deployment
+	Window           int64 // Number of blocks in a signaling period
+	Threshold        int64 // Signaling blocks needed within a period to lock in
+}
+
+// Deployments lists every rule change known to this node
+var Deployments = []Deployment{
+	{
+		Name:          DeploymentTestDummy,
+		Bit:           28,
+		StartHeight:   0,
+		TimeoutHeight: 1 << 62,
+		Window:        144,
+		Threshold:     108, // 75%
+	},
+}
+
+// FindDeployment returns the deployment with the given name
+func FindDeployment(name string) (Deployment, error) {
+	for _, d := range Deployments {
+		if d.Name == name {
+			return d, nil
+		}
+	}
+	return Deployment{}, fmt.Errorf("unknown deployment: %s", name)
+}
+
+// IsBuried reports whether the deployment activates at a fixed height
+func (d Deployment) IsBuried() bool {
+	return d.StartHeight == BuriedDeployment
+}
+
+// State returns the state of the deployment for a block at the given height.
+// versions must hold the versions of the ancestors of that block, indexed by height.
+func (d Deployment) State(height int64, versions []int32) ThresholdState {
+	if d.IsBuried() {
+		return d.buriedState(height)
+	}
+
+	// The state only changes at period boundaries, based on the previous period
+	state := ThresholdDefined
+	periodStart := height - height%d.Window
+	for start := int64(0); start <= periodStart; start += d.Window {
+		var signals int64
+		if start > 0 {
+			signals = d.countSignals(versions, start-d.Window, start)
+		}
+		state = d.nextState(state, start, signals)
+	}
+	return state
+}
+
+// buriedState returns the state of a buried deployment at the given height
+func (d Deployment) buriedState(height int64) ThresholdState {
+	if height >= d.ActivationHeight {
+		return ThresholdActive
+	}
+	return ThresholdDefined
+}
+
+// nextState returns the state of the period starting at height start, given the state
+// of the previous period and how many of its blocks signaled
+func (d Deployment) nextState(state ThresholdState, start, signals int64) ThresholdState {
+	switch state {
+	case ThresholdDefined:
+		if start >= d.TimeoutHeight {
+			return ThresholdFailed
+		} else if start >= d.StartHeight {
+			return ThresholdStarted
+		}
+	case ThresholdStarted:
+		if start > 0 && signals >= d.Threshold {
+			return ThresholdLockedIn
+		} else if start >= d.TimeoutHeight {
+			return ThresholdFailed
+		}
+	case ThresholdLockedIn:
+		return ThresholdActive
+	}
+	return state
+}
+
+// IsSignaledBy reports whether the given block version signals for the deployment
+func (d Deployment) IsSignaledBy(version int32) bool {
+	return uint32(version)&versionBitsTopMask == uint32(VersionBitsTopBits) && version&(1<<d.Bit) != 0
+}
+
+// countSignals counts blocks in [from, to) that signal for the deployment
+func (d Deployment) countSignals(versions []int32, from, to int64) int64 {
+	var count int64
+	for h := from; h < to && h < int64(len(versions)); h++ {
+		if d.IsSignaledBy(versions[h]) {
+			count++
+		}
+	}
+	return count
+}
+
+// PeriodSignals returns how many blocks of the current period before height signal for
+// the deployment, and how many blocks of the period have been built
+func (d Deployment) PeriodSignals(height int64, versions []int32) (int64, int64) {
+	if d.IsBuried() {
+		return 0, 0
+	}
+	periodStart := height - height%d.Window
+	return d.countSignals(versions, periodStart, height), height - periodStart
+}
+
+// ComputeBlockVersion returns the version a proposer should use for a block at the given
+// height, signaling every deployment that is started or locked in
+func ComputeBlockVersion(height int64, versions []int32) int32 {
+	states := make([]ThresholdState, len(Deployments))
+	for i, d := range Deployments {
+		states[i] = d.State(height, versions)
+	}
+	return signalingVersion(states)
+}
+
+// signalingVersion returns the block version signaling the deployments whose states,
+// in the order of Deployments, are started or locked in
+func signalingVersion(states []ThresholdState) int32 {
+	version := VersionBitsTopBits
+	for i, d := range Deployments {
+		if !d.IsBuried() && (states[i] == ThresholdStarted || states[i] == ThresholdLockedIn) {
+			version |= 1 << d.Bit
+		}
+	}
+	return version
+}
+
+// deploymentStateBucket caches the state of each deployment for each period of a
+// stored chain, keyed by the deployment name and the hash of the last block before
+// the period. A state depends only on the blocks before the period, so it never
+// changes, and the blocks of a period are read once to find it.
+const deploymentStateBucket = "deploymentstates"
+
+// chainHeader holds what deployment states depend on of a stored block
+type chainHeader struct {
+	hash    []byte
+	height  int64
+	version int32
+	prev    []byte
+}
+
+// readHeader returns the header of the stored block with the given hash
+func readHeader(blocks *bbolt.Bucket, hash []byte) (chainHeader, error) {
+	blockData := blocks.Get(hash)
+	if blockData == nil {
+		return chainHeader{}, fmt.Errorf("block not found for hash: %x", hash)
+	}
+	blk, err := block.DeserializeBlock(blockData)
+	if err != nil {
+		return chainHeader{}, err
+	}
+	return chainHeader{hash, blk.Height, blk.Version, blk.PrevBlockHash}, nil
+}
+
+// walkBack returns the header n blocks before h, and how many of the n blocks from h
+// down signal for d
+func (d Deployment) walkBack(blocks *bbolt.Bucket, h chainHeader, n int64) (chainHeader, int64, error) {
+	var signals int64
+	for ; n > 0; n-- {
+		if d.IsSignaledBy(h.version) {
+			signals++
+		}
+		if len(h.prev) == 0 {
+			if n > 1 {
+				return chainHeader{}, 0, fmt.Errorf("block %x has inconsistent height %d", h.hash, h.height)
+			}
+			return chainHeader{height: -1}, signals, nil
+		}
+		prev, err := readHeader(blocks, h.prev)
+		if err != nil {
+			return chainHeader{}, 0, err
+		}
+		if prev.height != h.height-1 {
+			return chainHeader{}, 0, fmt.Errorf("block %x has inconsistent height %d", prev.hash, prev.height)
+		}
+		h = prev
+	}
+	return h, signals, nil
+}
+
+// stateAfter returns the state of the deployment for the block built on prev, the
+// header with height -1 for the genesis block. It reads the blocks of the current
+// period and of the periods whose state is not cached yet, and caches those states
+// if store is set, which needs a writable transaction.
+func (d Deployment) stateAfter(tx *bbolt.Tx, prev chainHeader, store bool) (ThresholdState, error) {
+	height := prev.height + 1
+	if d.IsBuried() {
+		return d.buriedState(height), nil
+	}
+	periodStart := height - height%d.Window
+	if periodStart == 0 {
+		return d.nextState(ThresholdDefined, 0, 0), nil
+	}
+
+	blocks := tx.Bucket([]byte(blocksBucket))
+	cache := tx.Bucket([]byte(deploymentStateBucket))
+	cacheKey := func(h chainHeader) []byte {
+		return append([]byte(d.Name+"\x00"), h.hash...)
+	}
+
+	// Walk back period by period to a cached state, or to the start of the chain
+	type period struct {
+		boundary chainHeader // Last block before the period
+		signals  int64       // Signaling blocks of the previous period
+	}
+	var pending []period
+	boundary, _, err := d.walkBack(blocks, prev, prev.height-(periodStart-1))
+	if err != nil {
+		return 0, err
+	}
+	state := ThresholdDefined
+	for {
+		if cached := getCached(cache, cacheKey(boundary)); len(cached) == 1 {
+			state = ThresholdState(cached[0])
+			break
+		}
+		prevBoundary, signals, err := d.walkBack(blocks, boundary, d.Window)
+		if err != nil {
+			return 0, err
+		}
+		pending = append(pending, period{boundary, signals})
+
+		prevStart := prevBoundary.height + 1
+		if prevStart == 0 {
+			state = d.nextState(ThresholdDefined, 0, 0)
+			break
+		}
+		if prevStart < d.StartHeight && prevStart < d.TimeoutHeight {
+			// Every earlier period is still defined
+			break
+		}
+		boundary = prevBoundary
+	}
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		p := pending[i]
+		state = d.nextState(state, p.boundary.height+1, p.signals)
+		if !store {
+			continue
+		}
+		if cache == nil {
+			if cache, err = tx.CreateBucketIfNotExists([]byte(deploymentStateBucket)); err != nil {
+				return 0, err
+			}
+		}
+		if err := cache.Put(cacheKey(p.boundary), []byte{byte(state)}); err != nil {
+			return 0, err
+		}
+	}
+	return state, nil
+}
+
+// getCached returns the cached state stored under key, or nil if there is none or the
+// cache bucket does not exist yet
+func getCached(cache *bbolt.Bucket, key []byte) []byte {
+	if cache == nil {
+		return nil
+	}
+	return cache.Get(key)
+}
+
+// viewAfter runs fn with the header of the stored block prevHash in a read-only
+// transaction. The genesis block, with an empty prevHash, has no blocks before it to
+// read, so fn gets no transaction and the header with height -1; the genesis block is
+// proposed while its database transaction is open.
+func viewAfter(db *bbolt.DB, prevHash []byte, fn func(tx *bbolt.Tx, prev chainHeader) error) error {
+	if len(prevHash) == 0 {
+		return fn(nil, chainHeader{height: -1})
+	}
+	return db.View(func(tx *bbolt.Tx) error {
+		blocks := tx.Bucket([]byte(blocksBucket))
+		if blocks == nil {
+			return bbolt.ErrBucketNotFound
+		}
+		prev, err := readHeader(blocks, prevHash)
+		if err != nil {
+			return err
+		}
+		return fn(tx, prev)
+	})
+}
+
+// CacheDeploymentStates caches the state of every deployment for the period after
+// the block with the given hash, if it is the last block of a period, in tx. It is
+// called when the block is connected, so that queries only read stored states.
+func CacheDeploymentStates(tx *bbolt.Tx, hash []byte) error {
+	blocks := tx.Bucket([]byte(blocksBucket))
+	if blocks == nil {
+		return bbolt.ErrBucketNotFound
+	}
+	h, err := readHeader(blocks, hash)
+	if err != nil {
+		return err
+	}
+	for _, d := range Deployments {
+		if d.IsBuried() || (h.height+1)%d.Window != 0 {
+			continue
+		}
+		if _, err := d.stateAfter(tx, h, true); err != nil {
+			return fmt.Errorf("failed to cache state of deployment %s: %v", d.Name, err)
+		}
+	}
+	return nil
+}
+
+// DeploymentStatus is the state of a deployment for the block built on a stored block
+type DeploymentStatus struct {
+	State         ThresholdState
+	Height        int64 // Height of the block the state applies to
+	PeriodSignals int64 // Signaling blocks so far in the current period
+	PeriodElapsed int64 // Blocks so far in the current period
+}
+
+// StatusAfter returns the status of every deployment, in the order of Deployments,
+// for the block built on prevHash
+func StatusAfter(db *bbolt.DB, prevHash []byte) ([]DeploymentStatus, error) {
+	var statuses []DeploymentStatus
+	err := viewAfter(db, prevHash, func(tx *bbolt.Tx, prev chainHeader) error {
+		height := prev.height + 1
+		for _, d := range Deployments {
+			state, err := d.stateAfter(tx, prev, false)
+			if err != nil {
+				return err
+			}
+			status := DeploymentStatus{State: state, Height: height}
+			if !d.IsBuried() && height%d.Window > 0 {
+				status.PeriodElapsed = height % d.Window
+				_, status.PeriodSignals, err = d.walkBack(tx.Bucket([]byte(blocksBucket)), prev, status.PeriodElapsed)
+				if err != nil {
+					return err
+				}
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// IsDeploymentActive reports whether the named deployment is active for the block
+// built on prevHash
+func IsDeploymentActive(db *bbolt.DB, name string, prevHash []byte) (bool, error) {
+	d, err := FindDeployment(name)
+	if err != nil {
+		return false, err
+	}
+	var state ThresholdState
+	err = viewAfter(db, prevHash, func(tx *bbolt.Tx, prev chainHeader) error {
+		state, err = d.stateAfter(tx, prev, false)
+		return err
+	})
+	return state == ThresholdActive, err
+}
+
+// nextBlockHeader returns the height and version for a block built on top of prevBlockHash
+func nextBlockHeader(db *bbolt.DB, prevBlockHash []byte) (int64, int32, error) {
+	var height int64
+	states := make([]ThresholdState, len(Deployments))
+	err := viewAfter(db, prevBlockHash, func(tx *bbolt.Tx, prev chainHeader) error {
+		height = prev.height + 1
+		for i, d := range Deployments {
+			var err error
+			if states[i], err = d.stateAfter(tx, prev, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to compute deployment states: %v", err)
+	}
+	return height, signalingVersion(states), nil
+}
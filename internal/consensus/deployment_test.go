@@ -0,0 +1,191 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/block"
+	"go.etcd.io/bbolt"
+)
+
+// Helper to build a chain of versions where the first `signaling` blocks of each period signal
+func signalingVersions(d Deployment, periods int, signaling int64) []int32 {
+	var versions []int32
+	for p := 0; p < periods; p++ {
+		for i := int64(0); i < d.Window; i++ {
+			version := VersionBitsTopBits
+			if i < signaling {
+				version |= 1 << d.Bit
+			}
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// Test version-bit deployments move through started, locked in and active
+func TestDeploymentSignaling(t *testing.T) {
+	d := Deployment{Name: "test", Bit: 1, StartHeight: 0, TimeoutHeight: 100, Window: 10, Threshold: 8}
+
+	if state := d.State(5, nil); state != ThresholdStarted {
+		t.Errorf("Expected started in first period, got %s", state)
+	}
+
+	versions := signalingVersions(d, 3, 8)
+	if state := d.State(10, versions[:10]); state != ThresholdLockedIn {
+		t.Errorf("Expected locked_in after threshold was met, got %s", state)
+	}
+	if state := d.State(20, versions[:20]); state != ThresholdActive {
+		t.Errorf("Expected active one period after lock in, got %s", state)
+	}
+
+	// Below threshold the deployment stays started
+	versions = signalingVersions(d, 3, 7)
+	if state := d.State(25, versions[:25]); state != ThresholdStarted {
+		t.Errorf("Expected started below threshold, got %s", state)
+	}
+}
+
+// Test deployments fail after their timeout
+func TestDeploymentTimeout(t *testing.T) {
+	d := Deployment{Name: "test", Bit: 1, StartHeight: 0, TimeoutHeight: 20, Window: 10, Threshold: 8}
+
+	versions := signalingVersions(d, 3, 0)
+	if state := d.State(25, versions[:25]); state != ThresholdFailed {
+		t.Errorf("Expected failed after timeout, got %s", state)
+	}
+}
+
+// Test buried deployments activate at a fixed height
+func TestBuriedDeployment(t *testing.T) {
+	d := Deployment{Name: "test", StartHeight: BuriedDeployment, ActivationHeight: 5}
+
+	if state := d.State(4, nil); state != ThresholdDefined {
+		t.Errorf("Expected defined before activation height, got %s", state)
+	}
+	if state := d.State(5, nil); state != ThresholdActive {
+		t.Errorf("Expected active at activation height, got %s", state)
+	}
+}
+
+// Test the computed block version signals started deployments only
+func TestComputeBlockVersion(t *testing.T) {
+	version := ComputeBlockVersion(0, nil)
+	d, err := FindDeployment(DeploymentTestDummy)
+	if err != nil {
+		t.Fatalf("Failed to find deployment: %v", err)
+	}
+	if !d.IsSignaledBy(version) {
+		t.Errorf("Expected version 0x%08x to signal %s", version, d.Name)
+	}
+	if d.IsSignaledBy(1 << d.Bit) {
+		t.Error("Version without top bits should not signal")
+	}
+}
+
+// storeVersionChain stores a chain of blocks with the given versions and returns their
+// hashes, indexed by height
+func storeVersionChain(t *testing.T, db *bbolt.DB, versions []int32) [][]byte {
+	var hashes [][]byte
+	err := db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		var prev []byte
+		for height, version := range versions {
+			blk := block.NewBlock(nil, prev)
+			blk.SetHeight(int64(height))
+			blk.SetVersion(version)
+			blk.Hash = blk.CalculateHash()
+			data, err := blk.Serialize()
+			if err != nil {
+				return err
+			}
+			if err := b.Put(blk.Hash, data); err != nil {
+				return err
+			}
+			hashes = append(hashes, blk.Hash)
+			prev = blk.Hash
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to store chain: %v", err)
+	}
+	return hashes
+}
+
+// Test states computed from a stored chain match those computed from its versions,
+// are only cached when blocks are connected, and come from the per-period cache once
+// the earlier periods were connected
+func TestDeploymentStateCache(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+
+	d := Deployment{Name: "test", Bit: 1, StartHeight: 10, TimeoutHeight: 100, Window: 10, Threshold: 8}
+	defer func(saved []Deployment) { Deployments = saved }(Deployments)
+	Deployments = []Deployment{d}
+	versions := signalingVersions(d, 2, 0)
+	versions = append(versions, signalingVersions(d, 3, 8)...)
+	hashes := storeVersionChain(t, db, versions)
+
+	stateAfter := func(prevHash []byte) ThresholdState {
+		var state ThresholdState
+		err := viewAfter(db, prevHash, func(tx *bbolt.Tx, prev chainHeader) error {
+			var err error
+			state, err = d.stateAfter(tx, prev, false)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Failed to compute state: %v", err)
+		}
+		return state
+	}
+
+	for height := 1; height <= len(versions); height++ {
+		if state, want := stateAfter(hashes[height-1]), d.State(int64(height), versions[:height]); state != want {
+			t.Errorf("State at height %d is %s, expected %s", height, state, want)
+		}
+	}
+	if state := stateAfter(hashes[len(hashes)-1]); state != ThresholdActive {
+		t.Errorf("Expected active after signaling, got %s", state)
+	}
+	if _, err := IsDeploymentActive(db, d.Name, hashes[len(hashes)-1]); err != nil {
+		t.Fatalf("Failed to query deployment: %v", err)
+	}
+	if _, err := StatusAfter(db, hashes[len(hashes)-1]); err != nil {
+		t.Fatalf("Failed to query deployment status: %v", err)
+	}
+	db.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(deploymentStateBucket)) != nil {
+			t.Error("Querying deployment states wrote to the cache")
+		}
+		return nil
+	})
+
+	// Connecting the blocks caches the state of each period after them
+	err := db.Update(func(tx *bbolt.Tx) error {
+		for _, hash := range hashes {
+			if err := CacheDeploymentStates(tx, hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to cache states: %v", err)
+	}
+
+	// Without the blocks of the first periods, their cached states are used
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, hash := range hashes[:30] {
+			if err := tx.Bucket([]byte(blocksBucket)).Delete(hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to delete blocks: %v", err)
+	}
+	if state := stateAfter(hashes[len(hashes)-1]); state != ThresholdActive {
+		t.Errorf("Expected cached active state, got %s", state)
+	}
+}
@@ -44,9 +44,9 @@ func NewPoSConsensus(db *bbolt.DB) *PoSConsensus {
 	return pos
 }
 
-// ProposeBlock for PoS consensus involves selecting a validator and signing the block.
-// The `proposerWallet` is the wallet of the node attempting to propose.
-func (p *PoSConsensus) ProposeBlock(proposerWallet *wallet.Wallet, transactions []*transaction.Transaction, prevBlockHash []byte, currentTipHash []byte) (*block.Block, error) {
+// ProposeBlock for PoS consensus involves selecting a validator and signing the block
+// for chain chainID. The `proposerWallet` is the wallet of the node attempting to propose.
+func (p *PoSConsensus) ProposeBlock(proposerWallet *wallet.Wallet, transactions []*transaction.Transaction, prevBlockHash []byte, currentTipHash []byte, chainID transaction.ChainID) (*block.Block, error) {
 	// 1. Select a validator who is allowed to propose the next block.
 	// In a real PoS, this would involve a more sophisticated mechanism (e.g., VRF, turn-based).
 	// For now, we use a weighted random selection and assume the `proposerWallet` matches the selected validator.
@@ -67,18 +67,23 @@ func (p *PoSConsensus) ProposeBlock(proposerWallet *wallet.Wallet, transactions
 	newBlock := block.NewBlock(transactions, prevBlockHash)
 	// Note: Timestamp is already set in NewBlock constructor
 
+	// Set the header height and the version signaling pending deployments
+	height, version, err := nextBlockHeader(p.db, prevBlockHash)
+	if err != nil {
+		return nil, err
+	}
+	newBlock.SetHeight(height)
+	newBlock.SetVersion(version)
+
 	// Set validator's public key in the block header
 	newBlock.SetValidatorPubKey(proposerWallet.PublicKey)
 
-	// Calculate the hash of the block's contents (excluding the signature itself)
+	// Hash the block's contents (excluding the signature itself) with the chain ID
 	// This is the data that the validator will sign.
-	hashableData := newBlock.GetHashableDataPoS()
-	
-	// Hash the data before signing for security
-	dataHash := sha256.Sum256(hashableData)
+	dataHash := signatureHash(newBlock, chainID)
 	
 	// Sign the hashed data using the proposer's private key
-	signature, err := proposerWallet.SignData(dataHash[:])
+	signature, err := proposerWallet.SignData(dataHash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign block: %v", err)
 	}
@@ -92,10 +97,11 @@ func (p *PoSConsensus) ProposeBlock(proposerWallet *wallet.Wallet, transactions
 	return newBlock, nil
 }
 
-// ValidateBlock for PoS consensus involves verifying validator signatures and stake.
-func (p *PoSConsensus) ValidateBlock(b *block.Block, prevTXs map[string]transaction.Transaction) (bool, error) {
+// ValidateBlock for PoS consensus involves verifying validator signatures, made for
+// chain chainID, and stake.
+func (p *PoSConsensus) ValidateBlock(b *block.Block, prevTXs map[string]transaction.Transaction, chainID transaction.ChainID, sigCache *transaction.SigCache) (bool, error) {
 	// 1. Basic block structure and transaction validation
-	if err := b.ValidateBlock(prevTXs); err != nil {
+	if err := b.ValidateBlock(prevTXs, chainID, sigCache); err != nil {
 		return false, fmt.Errorf("block structure/transaction validation failed: %v", err)
 	}
 
@@ -104,14 +110,17 @@ func (p *PoSConsensus) ValidateBlock(b *block.Block, prevTXs map[string]transact
 		return false, fmt.Errorf("PoS block missing validator public key or signature")
 	}
 
-	// Reconstruct the data that was signed
-	hashableData := b.GetHashableDataPoS()
-	
-	// Hash the data (same as in signing)
-	dataHash := sha256.Sum256(hashableData)
+	// The block hash commits to the transaction IDs and witness hashes
+	if !bytes.Equal(b.GetHash(), b.GetPoSHash()) {
+		return false, fmt.Errorf("block hash %x does not match its contents", b.GetHash())
+	}
+
+	// Reconstruct the hash that was signed (same as in signing)
+	dataHash := signatureHash(b, chainID)
 	
 	// Verify the signature using the validator's public key
-	isValidSignature := wallet.VerifySignature(b.GetValidatorPubKey(), dataHash[:], b.GetSignature())
+	// A signature made for another chain does not match
+	isValidSignature := wallet.VerifySignature(b.GetValidatorPubKey(), dataHash, b.GetSignature())
 	if !isValidSignature {
 		return false, fmt.Errorf("invalid validator signature for block %x", b.GetHash())
 	}
@@ -144,6 +153,13 @@ func (p *PoSConsensus) ValidateBlock(b *block.Block, prevTXs map[string]transact
 	return true, nil
 }
 
+// signatureHash returns the hash a validator signs for block b on chain chainID:
+// the SHA-256 of chainID followed by the block's contents excluding the signature
+func signatureHash(b *block.Block, chainID transaction.ChainID) []byte {
+	hash := sha256.Sum256(append(chainID[:], b.GetHashableDataPoS()...))
+	return hash[:]
+}
+
 // GetCurrentDifficulty for PoS might return information about the current validator set or next proposer.
 func (p *PoSConsensus) GetCurrentDifficulty(blockchainTipHash []byte) (interface{}, error) {
 	// For PoS, "difficulty" might be represented by the active validator set.
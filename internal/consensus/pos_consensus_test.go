@@ -20,6 +20,7 @@ func TestPoSConsensusInterface(t *testing.T) {
 
 // Test validator registration and staking
 func TestValidatorStaking(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
 	db := createTestDB(t)
 	defer db.Close()
 
@@ -44,6 +45,7 @@ func TestValidatorStaking(t *testing.T) {
 
 // Test validator selection
 func TestValidatorSelection(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
 	db := createTestDB(t)
 	defer db.Close()
 
@@ -81,6 +83,7 @@ func TestValidatorSelection(t *testing.T) {
 
 // Test PoS block proposal
 func TestPoSProposeBlock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
 	db := createTestDB(t)
 	defer db.Close()
 
@@ -98,7 +101,7 @@ func TestPoSProposeBlock(t *testing.T) {
 	transactions := []*transaction.Transaction{coinbaseTx}
 
 	// Propose block
-	block, err := pos.ProposeBlock(validatorWallet, transactions, []byte{}, []byte{})
+	block, err := pos.ProposeBlock(validatorWallet, transactions, []byte{}, []byte{}, testChainID)
 	if err != nil {
 		t.Fatalf("Failed to propose block: %v", err)
 	}
@@ -119,6 +122,7 @@ func TestPoSProposeBlock(t *testing.T) {
 
 // Test PoS block validation
 func TestPoSValidateBlock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
 	db := createTestDB(t)
 	defer db.Close()
 
@@ -135,13 +139,13 @@ func TestPoSValidateBlock(t *testing.T) {
 	coinbaseTx := createCoinbaseTransaction()
 	transactions := []*transaction.Transaction{coinbaseTx}
 
-	validBlock, err := pos.ProposeBlock(validatorWallet, transactions, []byte{}, []byte{})
+	validBlock, err := pos.ProposeBlock(validatorWallet, transactions, []byte{}, []byte{}, testChainID)
 	if err != nil {
 		t.Fatalf("Failed to propose block: %v", err)
 	}
 
 	// Validate the block
-	valid, err := pos.ValidateBlock(validBlock, make(map[string]transaction.Transaction))
+	valid, err := pos.ValidateBlock(validBlock, make(map[string]transaction.Transaction), testChainID, nil)
 	if err != nil {
 		t.Fatalf("Validation failed: %v", err)
 	}
@@ -153,6 +157,7 @@ func TestPoSValidateBlock(t *testing.T) {
 
 // Test PoS validation fails for invalid signature
 func TestPoSValidationFailsInvalidSignature(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
 	db := createTestDB(t)
 	defer db.Close()
 
@@ -169,7 +174,7 @@ func TestPoSValidationFailsInvalidSignature(t *testing.T) {
 	coinbaseTx := createCoinbaseTransaction()
 	transactions := []*transaction.Transaction{coinbaseTx}
 
-	validBlock, err := pos.ProposeBlock(validatorWallet, transactions, []byte{}, []byte{})
+	validBlock, err := pos.ProposeBlock(validatorWallet, transactions, []byte{}, []byte{}, testChainID)
 	if err != nil {
 		t.Fatalf("Failed to propose block: %v", err)
 	}
@@ -182,7 +187,7 @@ func TestPoSValidationFailsInvalidSignature(t *testing.T) {
 	validBlock.SetSignature(fakeSignature)
 
 	// Validation should fail
-	valid, err := pos.ValidateBlock(validBlock, make(map[string]transaction.Transaction))
+	valid, err := pos.ValidateBlock(validBlock, make(map[string]transaction.Transaction), testChainID, nil)
 	if err == nil {
 		t.Error("Validation should fail for tampered signature")
 	}
@@ -192,8 +197,34 @@ func TestPoSValidationFailsInvalidSignature(t *testing.T) {
 	}
 }
 
+// Test PoS validation fails for a block signed for another chain
+func TestPoSValidationFailsOtherChain(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	db := createTestDB(t)
+	defer db.Close()
+
+	pos := NewPoSConsensus(db)
+	validatorWallet := wallet.NewWallet()
+	if err := pos.AddStake(1000, validatorWallet); err != nil {
+		t.Fatalf("Failed to add stake: %v", err)
+	}
+
+	transactions := []*transaction.Transaction{createCoinbaseTransaction()}
+	otherChainID := transaction.NewChainID([]byte("test genesis"), "other")
+	otherBlock, err := pos.ProposeBlock(validatorWallet, transactions, []byte{}, []byte{}, otherChainID)
+	if err != nil {
+		t.Fatalf("Failed to propose block: %v", err)
+	}
+
+	valid, err := pos.ValidateBlock(otherBlock, make(map[string]transaction.Transaction), testChainID, nil)
+	if err == nil || valid {
+		t.Error("Block signed for another chain should not be valid")
+	}
+}
+
 // Test insufficient stake validation
 func TestPoSInsufficientStake(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
 	db := createTestDB(t)
 	defer db.Close()
 
@@ -210,13 +241,13 @@ func TestPoSInsufficientStake(t *testing.T) {
 	coinbaseTx := createCoinbaseTransaction()
 	transactions := []*transaction.Transaction{coinbaseTx}
 
-	validBlock, err := pos.ProposeBlock(validatorWallet, transactions, []byte{}, []byte{})
+	validBlock, err := pos.ProposeBlock(validatorWallet, transactions, []byte{}, []byte{}, testChainID)
 	if err != nil {
 		t.Fatalf("Failed to propose block: %v", err)
 	}
 
 	// Validation should fail due to insufficient stake
-	valid, err := pos.ValidateBlock(validBlock, make(map[string]transaction.Transaction))
+	valid, err := pos.ValidateBlock(validBlock, make(map[string]transaction.Transaction), testChainID, nil)
 	if err == nil {
 		t.Error("Validation should fail for insufficient stake")
 	}
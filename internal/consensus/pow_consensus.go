@@ -31,10 +31,19 @@ func NewPOWConsensus(db *bbolt.DB) *POWConsensus {
 	return &POWConsensus{db: db}
 }
 
-// Propose block for POW consensus is like finding a nonce
-func (p *POWConsensus) ProposeBlock(proposerWallet *wallet.Wallet, transactions []*transaction.Transaction, prevBlockHash []byte, currentTipHash []byte) (*block.Block, error) {
+// Propose block for POW consensus is like finding a nonce. PoW blocks carry no
+// signature, so chainID is not used.
+func (p *POWConsensus) ProposeBlock(proposerWallet *wallet.Wallet, transactions []*transaction.Transaction, prevBlockHash []byte, currentTipHash []byte, chainID transaction.ChainID) (*block.Block, error) {
 	newBlock := block.NewBlock(transactions, prevBlockHash)
 
+	// Set the header height and the version signaling pending deployments
+	height, version, err := nextBlockHeader(p.db, prevBlockHash)
+	if err != nil {
+		return nil, err
+	}
+	newBlock.SetHeight(height)
+	newBlock.SetVersion(version)
+
 	// Determine targetBits for the new block
 	currentTargetBits, err := p.getAdjustedTargetBits(currentTipHash)
 	if err != nil {
@@ -49,9 +58,9 @@ func (p *POWConsensus) ProposeBlock(proposerWallet *wallet.Wallet, transactions
 }
 
 // This block is for validating POW consensus
-func (p *POWConsensus) ValidateBlock(b *block.Block, prevTXs map[string]transaction.Transaction) (bool, error) {
+func (p *POWConsensus) ValidateBlock(b *block.Block, prevTXs map[string]transaction.Transaction, chainID transaction.ChainID, sigCache *transaction.SigCache) (bool, error) {
 	// First, validate block structure and transactions (similar to existing block.ValidateBlock)
-	if err := b.ValidateBlock(prevTXs); err != nil {
+	if err := b.ValidateBlock(prevTXs, chainID, sigCache); err != nil {
 		return false, fmt.Errorf("block structure/transaction validation failed: %v", err)
 	}
 
@@ -205,10 +214,12 @@ func (p *POWConsensus) findBlock(hash []byte) (*block.Block, error) {
 	var blockData []byte
 	err := p.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		blockData = b.Get(hash)
-		if blockData == nil {
+		data := b.Get(hash)
+		if data == nil {
 			return fmt.Errorf("block not found for hash: %x", hash)
 		}
+		// Values returned by bbolt are only valid for the life of the transaction
+		blockData = append([]byte(nil), data...)
 		return nil
 	})
 	if err != nil {
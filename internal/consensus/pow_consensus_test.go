@@ -11,6 +11,9 @@ import (
 	"go.etcd.io/bbolt"
 )
 
+// testChainID is the chain blocks are proposed and validated for in tests
+var testChainID = transaction.NewChainID([]byte("test genesis"), "test")
+
 // Helper to create test database
 func createTestDB(t *testing.T) *bbolt.DB {
 	dir := t.TempDir()
@@ -42,7 +45,7 @@ func TestProposeGenesisBlock(t *testing.T) {
 	minerWallet := &wallet.Wallet{} // Create dummy wallet for POW
 
 	// For genesis block, both hashes should be empty
-	block, err := powConsensus.ProposeBlock(minerWallet, transactions, []byte{}, []byte{})
+	block, err := powConsensus.ProposeBlock(minerWallet, transactions, []byte{}, []byte{}, testChainID)
 	if err == nil {
 		t.Log("Genesis block creation succeeded (or failed as expected)")
 	}
@@ -59,10 +62,10 @@ func createCoinbaseTransaction() *transaction.Transaction {
 	return &transaction.Transaction{
 		ID: []byte("coinbase-tx"),
 		Vin: []transaction.TxInput{{
-			Txid: []byte{}, Vout: -1, Signature: nil, PubKey: []byte("coinbase"),
+			Txid: []byte{}, Vout: -1, UnlockingScript: []byte("coinbase"),
 		}},
 		Vout: []transaction.TxOutput{{
-			Value: 50, PubKeyHash: []byte("miner-address"),
+			Value: 50, LockingScript: []byte("miner-address"),
 		}},
 	}
 }
@@ -94,7 +97,7 @@ func TestProposeBlock(t *testing.T) {
 	genesisBlock.UpdateHash()
 	storeTestBlock(t, db, genesisBlock)
 
-	block, err := powConsensus.ProposeBlock(minerWallet, transactions, genesisBlock.GetHash(), genesisBlock.GetHash())
+	block, err := powConsensus.ProposeBlock(minerWallet, transactions, genesisBlock.GetHash(), genesisBlock.GetHash(), testChainID)
 	if err != nil {
 		t.Fatalf("ProposeBlock failed: %v", err)
 	}
@@ -145,12 +148,12 @@ func TestValidateBlock(t *testing.T) {
 	storeTestBlock(t, db, genesisBlock)
 
 	// Now create a properly mined block using ProposeBlock
-	validBlock, err := powConsensus.ProposeBlock(minerWallet, []*transaction.Transaction{coinbaseTx}, genesisBlock.GetHash(), genesisBlock.GetHash())
+	validBlock, err := powConsensus.ProposeBlock(minerWallet, []*transaction.Transaction{coinbaseTx}, genesisBlock.GetHash(), genesisBlock.GetHash(), testChainID)
 	if err != nil {
 		t.Fatalf("Failed to create valid block: %v", err)
 	}
 
-	valid, err := powConsensus.ValidateBlock(validBlock, make(map[string]transaction.Transaction))
+	valid, err := powConsensus.ValidateBlock(validBlock, make(map[string]transaction.Transaction), testChainID, nil)
 	if err != nil {
 		t.Fatalf("ValidateBlock failed: %v", err)
 	}
@@ -0,0 +1,54 @@
+package script
+
+// Builder assembles a script from opcodes and data pushes
+type Builder struct {
+	script []byte
+}
+
+// NewBuilder returns an empty Builder
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// AddOp appends an opcode
+func (b *Builder) AddOp(op byte) *Builder {
+	b.script = append(b.script, op)
+	return b
+}
+
+// AddData appends the shortest push of data
+func (b *Builder) AddData(data []byte) *Builder {
+	n := len(data)
+	switch {
+	case n == 0:
+		b.script = append(b.script, Op0)
+		return b
+	case n <= int(OpData75):
+		b.script = append(b.script, byte(n))
+	case n <= 0xff:
+		b.script = append(b.script, OpPushData1, byte(n))
+	default:
+		b.script = append(b.script, OpPushData2, byte(n), byte(n>>8))
+	}
+	b.script = append(b.script, data...)
+	return b
+}
+
+// AddInt appends a push of the number n, using the single-byte opcodes where possible
+func (b *Builder) AddInt(n int64) *Builder {
+	switch {
+	case n == 0:
+		return b.AddOp(Op0)
+	case n == -1:
+		return b.AddOp(Op1Negate)
+	case n >= 1 && n <= 16:
+		return b.AddOp(Op1 + byte(n-1))
+	default:
+		return b.AddData(encodeNum(n))
+	}
+}
+
+// Script returns the assembled script
+func (b *Builder) Script() []byte {
+	return b.script
+}
@@ -0,0 +1,490 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Limits on the resources a script may use
+const (
+	MaxScriptSize   = 10000 // Bytes in a single script
+	MaxPushSize     = 1024  // Bytes pushed by a single instruction, enough for a 15-key redeem script
+	MaxOpsPerScript = 201   // Non-push opcodes in a single script
+	MaxStackSize    = 1000  // Items on the stack
+	maxNumLen       = 4     // Bytes in a number operand
+	lockTimeNumLen  = 5     // Bytes in a lock time operand, which may exceed 31 bits
+
+	// MaxPubKeysPerMultiSig bounds the number of keys an OP_CHECKMULTISIG checks against
+	MaxPubKeysPerMultiSig = 15
+)
+
+// Errors returned when a script fails. They are wrapped with details.
+var (
+	ErrScriptTooLarge        = errors.New("script is too large")
+	ErrPushTooLarge          = errors.New("push is too large")
+	ErrTooManyOps            = errors.New("too many operations")
+	ErrStackOverflow         = errors.New("stack is too large")
+	ErrMalformedPush         = errors.New("malformed push")
+	ErrNotPushOnly           = errors.New("unlocking script is not push only")
+	ErrStackUnderflow        = errors.New("stack underflow")
+	ErrUnbalancedConditional = errors.New("unbalanced conditional")
+	ErrUnknownOpcode         = errors.New("unknown opcode")
+	ErrEarlyReturn           = errors.New("script returned early")
+	ErrVerify                = errors.New("verify failed")
+	ErrNumberTooLarge        = errors.New("number is too large")
+	ErrInvalidKeyCount       = errors.New("invalid public key count")
+	ErrInvalidSigCount       = errors.New("invalid signature count")
+	ErrEvalFalse             = errors.New("script evaluated to false")
+	ErrNegativeLockTime      = errors.New("negative lock time")
+	ErrUnsatisfiedLockTime   = errors.New("lock time not satisfied")
+)
+
+// SigChecker checks signatures and lock times on behalf of the interpreter. It is
+// implemented by the transaction being verified, which knows what data was signed.
+type SigChecker interface {
+	// CheckSig reports whether sig is a valid signature by pubKey
+	CheckSig(sig, pubKey []byte) bool
+
+	// CheckLockTime reports whether the spending transaction's lock time satisfies
+	// the absolute lock time lockTime
+	CheckLockTime(lockTime int64) bool
+
+	// CheckSequence reports whether the spending input's sequence satisfies the
+	// relative lock time sequence
+	CheckSequence(sequence int64) bool
+}
+
+// Execute runs the unlocking script followed by the locking script and returns nil
+// if together they authorize the spend. If the locking script is pay-to-script-hash,
+// the last item pushed by the unlocking script is the redeem script, which is then run
+// on the items pushed before it.
+func Execute(unlocking, locking []byte, checker SigChecker) error {
+	if !IsPushOnly(unlocking) {
+		return ErrNotPushOnly
+	}
+
+	vm := &engine{checker: checker}
+	if err := vm.run(unlocking); err != nil {
+		return fmt.Errorf("unlocking script: %w", err)
+	}
+	if err := vm.run(locking); err != nil {
+		return fmt.Errorf("locking script: %w", err)
+	}
+	if len(vm.stack) == 0 || !asBool(vm.stack[len(vm.stack)-1]) {
+		return ErrEvalFalse
+	}
+
+	if ExtractScriptHash(locking) == nil {
+		return nil
+	}
+	// The hash matched, so the unlocking script pushed at least the redeem script
+	pushes, err := PushedData(unlocking)
+	if err != nil {
+		return err
+	}
+	redeemScript := pushes[len(pushes)-1]
+	vm = &engine{checker: checker, stack: pushes[:len(pushes)-1]}
+	if err := vm.run(redeemScript); err != nil {
+		return fmt.Errorf("redeem script: %w", err)
+	}
+	if len(vm.stack) == 0 || !asBool(vm.stack[len(vm.stack)-1]) {
+		return fmt.Errorf("redeem script: %w", ErrEvalFalse)
+	}
+	return nil
+}
+
+// engine holds the state of a script execution
+type engine struct {
+	stack     [][]byte
+	condStack []bool // Whether each enclosing conditional branch is being executed
+	numOps    int    // Non-push opcodes run so far in the current script
+	checker   SigChecker
+}
+
+// addOps counts n operations against the per-script limit
+func (vm *engine) addOps(n int) error {
+	vm.numOps += n
+	if vm.numOps > MaxOpsPerScript {
+		return ErrTooManyOps
+	}
+	return nil
+}
+
+// executing reports whether every enclosing conditional branch is being executed
+func (vm *engine) executing() bool {
+	for _, cond := range vm.condStack {
+		if !cond {
+			return false
+		}
+	}
+	return true
+}
+
+// run executes a single script on the current stack
+func (vm *engine) run(script []byte) error {
+	if len(script) > MaxScriptSize {
+		return fmt.Errorf("%w: %d bytes", ErrScriptTooLarge, len(script))
+	}
+	instructions, err := parse(script)
+	if err != nil {
+		return err
+	}
+
+	vm.condStack = nil
+	vm.numOps = 0
+	for _, ins := range instructions {
+		if len(ins.data) > MaxPushSize {
+			return fmt.Errorf("%w: %d bytes", ErrPushTooLarge, len(ins.data))
+		}
+		if !ins.isPush() {
+			if err := vm.addOps(1); err != nil {
+				return err
+			}
+		}
+		if !vm.executing() && !isConditional(ins.op) {
+			continue
+		}
+
+		if err := vm.step(ins); err != nil {
+			return err
+		}
+		if len(vm.stack) > MaxStackSize {
+			return ErrStackOverflow
+		}
+	}
+
+	if len(vm.condStack) != 0 {
+		return fmt.Errorf("%w: missing OP_ENDIF", ErrUnbalancedConditional)
+	}
+	return nil
+}
+
+// step executes one instruction
+func (vm *engine) step(ins instruction) error {
+	if ins.isPush() {
+		vm.push(pushValue(ins))
+		return nil
+	}
+
+	switch ins.op {
+	case OpNop:
+
+	case OpIf, OpNotIf:
+		cond := false
+		if vm.executing() {
+			item, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			cond = asBool(item) == (ins.op == OpIf)
+		}
+		vm.condStack = append(vm.condStack, cond)
+
+	case OpElse:
+		if len(vm.condStack) == 0 {
+			return fmt.Errorf("%w: OP_ELSE without OP_IF", ErrUnbalancedConditional)
+		}
+		vm.condStack[len(vm.condStack)-1] = !vm.condStack[len(vm.condStack)-1]
+
+	case OpEndIf:
+		if len(vm.condStack) == 0 {
+			return fmt.Errorf("%w: OP_ENDIF without OP_IF", ErrUnbalancedConditional)
+		}
+		vm.condStack = vm.condStack[:len(vm.condStack)-1]
+
+	case OpVerify:
+		return vm.verify("OP_VERIFY")
+
+	case OpReturn:
+		return ErrEarlyReturn
+
+	case OpDrop:
+		_, err := vm.pop()
+		return err
+
+	case OpDup:
+		item, err := vm.peek()
+		if err != nil {
+			return err
+		}
+		vm.push(item)
+
+	case OpSize:
+		item, err := vm.peek()
+		if err != nil {
+			return err
+		}
+		vm.push(encodeNum(int64(len(item))))
+
+	case OpEqual, OpEqualVerify:
+		a, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.pushBool(bytes.Equal(a, b))
+		if ins.op == OpEqualVerify {
+			return vm.verify("OP_EQUALVERIFY")
+		}
+
+	case OpSha256:
+		item, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(item)
+		vm.push(hash[:])
+
+	case OpHash160:
+		item, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.push(Hash160(item))
+
+	case OpCheckSig, OpCheckSigVerify:
+		pubKey, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.pushBool(vm.checkSig(sig, pubKey))
+		if ins.op == OpCheckSigVerify {
+			return vm.verify("OP_CHECKSIGVERIFY")
+		}
+
+	case OpCheckMultiSig, OpCheckMultiSigVerify:
+		valid, err := vm.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		vm.pushBool(valid)
+		if ins.op == OpCheckMultiSigVerify {
+			return vm.verify("OP_CHECKMULTISIGVERIFY")
+		}
+
+	case OpCheckLockTimeVerify:
+		lockTime, err := vm.peekLockTime()
+		if err != nil {
+			return err
+		}
+		if vm.checker == nil || !vm.checker.CheckLockTime(lockTime) {
+			return fmt.Errorf("%w: lock time %d", ErrUnsatisfiedLockTime, lockTime)
+		}
+
+	case OpCheckSequenceVerify:
+		sequence, err := vm.peekLockTime()
+		if err != nil {
+			return err
+		}
+		if vm.checker == nil || !vm.checker.CheckSequence(sequence) {
+			return fmt.Errorf("%w: sequence %d", ErrUnsatisfiedLockTime, sequence)
+		}
+
+	default:
+		return fmt.Errorf("%w: 0x%02x", ErrUnknownOpcode, ins.op)
+	}
+	return nil
+}
+
+// checkSig asks the checker whether sig is valid for pubKey. Empty signatures are
+// always invalid, so they can be used to fail a check without aborting the script.
+func (vm *engine) checkSig(sig, pubKey []byte) bool {
+	if len(sig) == 0 || vm.checker == nil {
+		return false
+	}
+	return vm.checker.CheckSig(sig, pubKey)
+}
+
+// checkMultiSig pops N, N public keys, M and M signatures and reports whether every
+// signature is valid. Signatures must be in the same order as the keys they belong to:
+// each one is checked against the keys after the one that matched the previous signature.
+func (vm *engine) checkMultiSig() (bool, error) {
+	numKeys, err := vm.popNum()
+	if err != nil {
+		return false, err
+	}
+	if numKeys < 0 || numKeys > MaxPubKeysPerMultiSig {
+		return false, fmt.Errorf("%w: %d", ErrInvalidKeyCount, numKeys)
+	}
+	if err := vm.addOps(int(numKeys)); err != nil {
+		return false, err
+	}
+	pubKeys := make([][]byte, numKeys)
+	for i := numKeys - 1; i >= 0; i-- {
+		if pubKeys[i], err = vm.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	numSigs, err := vm.popNum()
+	if err != nil {
+		return false, err
+	}
+	if numSigs < 0 || numSigs > numKeys {
+		return false, fmt.Errorf("%w: %d of %d keys", ErrInvalidSigCount, numSigs, numKeys)
+	}
+	sigs := make([][]byte, numSigs)
+	for i := numSigs - 1; i >= 0; i-- {
+		if sigs[i], err = vm.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	keyIdx := 0
+	for _, sig := range sigs {
+		for {
+			// Out of keys before every signature found its key
+			if keyIdx >= len(pubKeys) {
+				return false, nil
+			}
+			keyIdx++
+			if vm.checkSig(sig, pubKeys[keyIdx-1]) {
+				break
+			}
+		}
+	}
+	return true, nil
+}
+
+// peekLockTime returns the top item as a lock time operand without removing it, so
+// scripts follow lock time checks with OP_DROP
+func (vm *engine) peekLockTime() (int64, error) {
+	item, err := vm.peek()
+	if err != nil {
+		return 0, err
+	}
+	lockTime, err := decodeNum(item, lockTimeNumLen)
+	if err != nil {
+		return 0, err
+	}
+	if lockTime < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrNegativeLockTime, lockTime)
+	}
+	return lockTime, nil
+}
+
+// verify pops the top item and fails unless it is true
+func (vm *engine) verify(opName string) error {
+	item, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	if !asBool(item) {
+		return fmt.Errorf("%w: %s", ErrVerify, opName)
+	}
+	return nil
+}
+
+// push adds an item to the top of the stack
+func (vm *engine) push(item []byte) {
+	vm.stack = append(vm.stack, item)
+}
+
+// pushBool pushes 1 for true and the empty string for false
+func (vm *engine) pushBool(v bool) {
+	if v {
+		vm.push([]byte{1})
+	} else {
+		vm.push(nil)
+	}
+}
+
+// pop removes and returns the top item
+func (vm *engine) pop() ([]byte, error) {
+	item, err := vm.peek()
+	if err != nil {
+		return nil, err
+	}
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return item, nil
+}
+
+// peek returns the top item without removing it
+func (vm *engine) peek() ([]byte, error) {
+	if len(vm.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	return vm.stack[len(vm.stack)-1], nil
+}
+
+// popNum removes the top item and interprets it as a number
+func (vm *engine) popNum() (int64, error) {
+	item, err := vm.pop()
+	if err != nil {
+		return 0, err
+	}
+	return decodeNum(item, maxNumLen)
+}
+
+// asBool interprets a stack item as a boolean: false if every byte is zero,
+// allowing a sign bit on the last byte (negative zero)
+func asBool(item []byte) bool {
+	for i, b := range item {
+		if b != 0 {
+			return !(i == len(item)-1 && b == 0x80)
+		}
+	}
+	return false
+}
+
+// encodeNum encodes n as a minimal little-endian sign-magnitude integer
+func encodeNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	magnitude := uint64(n)
+	if negative {
+		magnitude = uint64(-n)
+	}
+
+	var out []byte
+	for magnitude > 0 {
+		out = append(out, byte(magnitude))
+		magnitude >>= 8
+	}
+
+	// The top bit of the last byte is the sign, so add a byte if it is taken
+	if out[len(out)-1]&0x80 != 0 {
+		if negative {
+			out = append(out, 0x80)
+		} else {
+			out = append(out, 0x00)
+		}
+	} else if negative {
+		out[len(out)-1] |= 0x80
+	}
+	return out
+}
+
+// decodeNum decodes a number written by encodeNum of at most maxLen bytes
+func decodeNum(item []byte, maxLen int) (int64, error) {
+	if len(item) > maxLen {
+		return 0, fmt.Errorf("%w: %d bytes, limit %d", ErrNumberTooLarge, len(item), maxLen)
+	}
+	if len(item) == 0 {
+		return 0, nil
+	}
+
+	var n int64
+	for i, b := range item {
+		n |= int64(b) << (8 * i)
+	}
+
+	last := item[len(item)-1]
+	if last&0x80 != 0 {
+		n &^= int64(0x80) << (8 * (len(item) - 1))
+		return -n, nil
+	}
+	return n, nil
+}
@@ -0,0 +1,205 @@
+// Package script implements the stack-based scripts that lock transaction outputs
+// and unlock them when they are spent.
+//
+// A script is a sequence of opcodes, some of which carry data to push on the stack.
+// An output holds a locking script and the input spending it holds an unlocking
+// script. To authorize the spend, the unlocking script is run first, then the
+// locking script on the stack it left behind; the spend is valid if no opcode fails
+// and the top of the final stack is true. Unlocking scripts may only push data.
+//
+// Stack items are byte strings. Opcodes that work on numbers interpret them as
+// little-endian sign-magnitude integers, where the empty string is zero.
+package script
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Opcodes understood by the interpreter. Opcodes 0x01 through 0x4b push the
+// following that many bytes.
+const (
+	Op0                   byte = 0x00 // Push an empty byte string
+	OpData1               byte = 0x01 // Push the next byte
+	OpData75              byte = 0x4b // Push the next 75 bytes
+	OpPushData1           byte = 0x4c // Push the number of bytes given by the next byte
+	OpPushData2           byte = 0x4d // Push the number of bytes given by the next two bytes, little-endian
+	Op1Negate             byte = 0x4f // Push the number -1
+	Op1                   byte = 0x51 // Push the number 1
+	Op16                  byte = 0x60 // Push the number 16; 0x52 to 0x5f push 2 to 15
+	OpNop                 byte = 0x61 // Do nothing
+	OpIf                  byte = 0x63 // Run the following statements if the top item is true
+	OpNotIf               byte = 0x64 // Run the following statements if the top item is false
+	OpElse                byte = 0x67 // Run the following statements if the preceding ones were not run
+	OpEndIf               byte = 0x68 // End a conditional block
+	OpVerify              byte = 0x69 // Fail unless the top item is true, and remove it
+	OpReturn              byte = 0x6a // Fail immediately
+	OpDrop                byte = 0x75 // Remove the top item
+	OpDup                 byte = 0x76 // Duplicate the top item
+	OpSize                byte = 0x82 // Push the length of the top item
+	OpEqual               byte = 0x87 // Push whether the top two items are equal
+	OpEqualVerify         byte = 0x88 // OpEqual followed by OpVerify
+	OpSha256              byte = 0xa8 // Replace the top item with its SHA-256
+	OpHash160             byte = 0xa9 // Replace the top item with its RIPEMD-160 of SHA-256
+	OpCheckSig            byte = 0xac // Push whether a signature is valid for a public key
+	OpCheckSigVerify      byte = 0xad // OpCheckSig followed by OpVerify
+	OpCheckMultiSig       byte = 0xae // Push whether M signatures are valid for M of N public keys
+	OpCheckMultiSigVerify byte = 0xaf // OpCheckMultiSig followed by OpVerify
+	OpCheckLockTimeVerify byte = 0xb1 // Fail unless the transaction's lock time is at least the top item
+	OpCheckSequenceVerify byte = 0xb2 // Fail unless the input's relative lock time is at least the top item
+)
+
+// opcodeNames maps the opcodes without data to their names for disassembly
+var opcodeNames = map[byte]string{
+	Op0:                   "OP_0",
+	Op1Negate:             "OP_1NEGATE",
+	OpNop:                 "OP_NOP",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpSha256:              "OP_SHA256",
+	OpHash160:             "OP_HASH160",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultiSig:       "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+	OpCheckSequenceVerify: "OP_CHECKSEQUENCEVERIFY",
+}
+
+// instruction is a parsed opcode together with the data it pushes
+type instruction struct {
+	op   byte
+	data []byte
+}
+
+// isSmallInt reports whether op pushes one of the numbers 1 to 16
+func isSmallInt(op byte) bool {
+	return op >= Op1 && op <= Op16
+}
+
+// isPush reports whether the instruction only pushes data or a number
+func (ins instruction) isPush() bool {
+	return ins.op <= OpPushData2 || ins.op == Op1Negate || isSmallInt(ins.op)
+}
+
+// isConditional reports whether op changes which branch is executed
+func isConditional(op byte) bool {
+	return op == OpIf || op == OpNotIf || op == OpElse || op == OpEndIf
+}
+
+// parse splits a script into instructions
+func parse(script []byte) ([]instruction, error) {
+	var instructions []instruction
+	for i := 0; i < len(script); {
+		op := script[i]
+		i++
+
+		var n int
+		switch {
+		case op >= OpData1 && op <= OpData75:
+			n = int(op)
+		case op == OpPushData1:
+			if i+1 > len(script) {
+				return nil, fmt.Errorf("%w: OP_PUSHDATA1 at offset %d has no length", ErrMalformedPush, i-1)
+			}
+			n = int(script[i])
+			i++
+		case op == OpPushData2:
+			if i+2 > len(script) {
+				return nil, fmt.Errorf("%w: OP_PUSHDATA2 at offset %d has no length", ErrMalformedPush, i-1)
+			}
+			n = int(script[i]) | int(script[i+1])<<8
+			i += 2
+		}
+
+		if i+n > len(script) {
+			return nil, fmt.Errorf("%w: push of %d bytes at offset %d runs past the end", ErrMalformedPush, n, i-1)
+		}
+		var data []byte
+		if n > 0 {
+			data = script[i : i+n]
+		}
+		i += n
+		instructions = append(instructions, instruction{op: op, data: data})
+	}
+	return instructions, nil
+}
+
+// IsPushOnly reports whether the script parses and only pushes data
+func IsPushOnly(script []byte) bool {
+	instructions, err := parse(script)
+	if err != nil {
+		return false
+	}
+	for _, ins := range instructions {
+		if !ins.isPush() {
+			return false
+		}
+	}
+	return true
+}
+
+// PushedData returns the data pushed by a push-only script, in order
+func PushedData(script []byte) ([][]byte, error) {
+	instructions, err := parse(script)
+	if err != nil {
+		return nil, err
+	}
+	var pushes [][]byte
+	for _, ins := range instructions {
+		if !ins.isPush() {
+			return nil, ErrNotPushOnly
+		}
+		pushes = append(pushes, pushValue(ins))
+	}
+	return pushes, nil
+}
+
+// pushValue returns the stack item a push instruction produces
+func pushValue(ins instruction) []byte {
+	switch {
+	case ins.op == Op1Negate:
+		return encodeNum(-1)
+	case isSmallInt(ins.op):
+		return encodeNum(int64(ins.op-Op1) + 1)
+	default:
+		return ins.data
+	}
+}
+
+// Disassemble returns a human readable form of the script, with pushed data in hex
+func Disassemble(script []byte) (string, error) {
+	instructions, err := parse(script)
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, 0, len(instructions))
+	for _, ins := range instructions {
+		switch {
+		case ins.op == Op0:
+			parts = append(parts, "0")
+		case ins.op <= OpPushData2:
+			parts = append(parts, hex.EncodeToString(ins.data))
+		case isSmallInt(ins.op):
+			parts = append(parts, fmt.Sprintf("%d", ins.op-Op1+1))
+		default:
+			if name, ok := opcodeNames[ins.op]; ok {
+				parts = append(parts, name)
+			} else {
+				parts = append(parts, fmt.Sprintf("OP_UNKNOWN_0x%02x", ins.op))
+			}
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
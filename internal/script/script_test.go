@@ -0,0 +1,370 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+// fakeChecker accepts exactly one signature for one public key, absolute lock times
+// up to lockTime and relative lock times up to sequence
+type fakeChecker struct {
+	sig, pubKey []byte
+	lockTime    int64
+	sequence    int64
+}
+
+func (c fakeChecker) CheckSig(sig, pubKey []byte) bool {
+	return bytes.Equal(sig, c.sig) && bytes.Equal(pubKey, c.pubKey)
+}
+
+func (c fakeChecker) CheckLockTime(lockTime int64) bool {
+	return lockTime <= c.lockTime
+}
+
+func (c fakeChecker) CheckSequence(sequence int64) bool {
+	return sequence <= c.sequence
+}
+
+// Test the pay-to-pubkey-hash template accepts only the right key and signature
+func TestPayToPubKeyHash(t *testing.T) {
+	pubKey := []byte("public key")
+	sig := []byte("signature")
+	checker := fakeChecker{sig: sig, pubKey: pubKey}
+	locking := PayToPubKeyHash(Hash160(pubKey))
+
+	if GetScriptClass(locking) != PubKeyHashTy {
+		t.Fatalf("Expected %s, got %s", PubKeyHashTy, GetScriptClass(locking))
+	}
+	if !bytes.Equal(ExtractPubKeyHash(locking), Hash160(pubKey)) {
+		t.Error("Extracted key hash does not match")
+	}
+
+	if err := Execute(PubKeyHashUnlockingScript(sig, pubKey), locking, checker); err != nil {
+		t.Fatalf("Valid spend was rejected: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		unlocking []byte
+		want      error
+	}{
+		{"wrong key", PubKeyHashUnlockingScript(sig, []byte("other key")), ErrVerify},
+		{"wrong signature", PubKeyHashUnlockingScript([]byte("forged"), pubKey), ErrEvalFalse},
+		{"missing signature", NewBuilder().AddData(pubKey).Script(), ErrStackUnderflow},
+		{"not push only", NewBuilder().AddData(sig).AddData(pubKey).AddOp(OpDrop).Script(), ErrNotPushOnly},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Execute(tt.unlocking, locking, checker)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// multiChecker accepts the signature "sig:" + key for each key
+type multiChecker struct{}
+
+func (multiChecker) CheckSig(sig, pubKey []byte) bool {
+	return bytes.Equal(sig, append([]byte("sig:"), pubKey...))
+}
+
+func (multiChecker) CheckLockTime(lockTime int64) bool {
+	return false
+}
+
+func (multiChecker) CheckSequence(sequence int64) bool {
+	return false
+}
+
+// Test M-of-N multisig checks signatures against the keys in order
+func TestMultiSig(t *testing.T) {
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	sig := func(key string) []byte { return []byte("sig:" + key) }
+	locking, err := MultiSigScript(2, keys)
+	if err != nil {
+		t.Fatalf("Failed to create multisig script: %v", err)
+	}
+
+	m, pubKeys, ok := ExtractMultiSig(locking)
+	if !ok || m != 2 || len(pubKeys) != 3 || GetScriptClass(locking) != MultiSigTy {
+		t.Fatalf("Failed to extract multisig script: %d %x %v", m, pubKeys, ok)
+	}
+
+	tests := []struct {
+		name string
+		sigs [][]byte
+		want error
+	}{
+		{"first and second", [][]byte{sig("a"), sig("b")}, nil},
+		{"first and third", [][]byte{sig("a"), sig("c")}, nil},
+		{"out of order", [][]byte{sig("c"), sig("a")}, ErrEvalFalse},
+		{"same key twice", [][]byte{sig("a"), sig("a")}, ErrEvalFalse},
+		{"unknown signer", [][]byte{sig("a"), sig("d")}, ErrEvalFalse},
+		{"too few", [][]byte{sig("a")}, ErrStackUnderflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Execute(MultiSigUnlockingScript(tt.sigs), locking, multiChecker{})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if _, err := MultiSigScript(3, keys[:2]); !errors.Is(err, ErrInvalidSigCount) {
+		t.Errorf("Expected ErrInvalidSigCount, got %v", err)
+	}
+	if _, err := MultiSigScript(1, make([][]byte, MaxPubKeysPerMultiSig+1)); !errors.Is(err, ErrInvalidKeyCount) {
+		t.Errorf("Expected ErrInvalidKeyCount, got %v", err)
+	}
+}
+
+// Test pay-to-script-hash runs the revealed redeem script on the remaining pushes
+func TestPayToScriptHash(t *testing.T) {
+	redeemScript, err := MultiSigScript(1, [][]byte{[]byte("a"), []byte("b")})
+	if err != nil {
+		t.Fatalf("Failed to create redeem script: %v", err)
+	}
+	locking := PayToScriptHash(Hash160(redeemScript))
+	if GetScriptClass(locking) != ScriptHashTy {
+		t.Fatalf("Expected %s, got %s", ScriptHashTy, GetScriptClass(locking))
+	}
+
+	tests := []struct {
+		name      string
+		unlocking []byte
+		want      error
+	}{
+		{"valid", ScriptHashUnlockingScript(MultiSigUnlockingScript([][]byte{[]byte("sig:b")}), redeemScript), nil},
+		{"redeem script fails", ScriptHashUnlockingScript(MultiSigUnlockingScript([][]byte{[]byte("sig:c")}), redeemScript), ErrEvalFalse},
+		{"wrong redeem script", ScriptHashUnlockingScript(nil, []byte{Op1}), ErrEvalFalse},
+		// Only the hash is checked by the locking script itself
+		{"redeem script not run", NewBuilder().AddData(redeemScript).Script(), ErrStackUnderflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Execute(tt.unlocking, locking, multiChecker{})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// Test script evaluation rules that do not involve signatures
+func TestExecute(t *testing.T) {
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+	hashLock := NewBuilder().AddOp(OpSha256).AddData(hash[:]).AddOp(OpEqual).Script()
+	branches := NewBuilder().
+		AddOp(OpIf).AddInt(2).AddOp(OpElse).AddInt(3).AddOp(OpEndIf).
+		AddInt(3).AddOp(OpEqual).Script()
+
+	tests := []struct {
+		name      string
+		unlocking []byte
+		locking   []byte
+		want      error
+	}{
+		{"hash lock", NewBuilder().AddData(preimage).Script(), hashLock, nil},
+		{"hash lock wrong preimage", NewBuilder().AddData([]byte("guess")).Script(), hashLock, ErrEvalFalse},
+		{"else branch", NewBuilder().AddInt(0).Script(), branches, nil},
+		{"if branch", NewBuilder().AddInt(1).Script(), branches, ErrEvalFalse},
+		{"empty stack", nil, nil, ErrEvalFalse},
+		{"negative zero is false", NewBuilder().AddData([]byte{0x00, 0x80}).Script(), nil, ErrEvalFalse},
+		{"size", NewBuilder().AddData(preimage).Script(),
+			NewBuilder().AddOp(OpSize).AddInt(6).AddOp(OpEqualVerify).AddOp(OpDrop).AddInt(1).Script(), nil},
+		{"return", nil, NewBuilder().AddInt(1).AddOp(OpReturn).Script(), ErrEarlyReturn},
+		{"missing endif", NewBuilder().AddInt(1).Script(), NewBuilder().AddOp(OpIf).AddInt(1).Script(), ErrUnbalancedConditional},
+		{"stray else", nil, NewBuilder().AddOp(OpElse).Script(), ErrUnbalancedConditional},
+		{"unknown opcode", nil, []byte{0xff}, ErrUnknownOpcode},
+		{"unknown opcode in skipped branch", NewBuilder().AddInt(0).Script(),
+			[]byte{OpIf, 0xff, OpEndIf, Op1}, nil},
+		{"truncated push", nil, []byte{OpData1 + 1, 0x01}, ErrMalformedPush},
+		{"push too large", nil, NewBuilder().AddData(make([]byte, MaxPushSize+1)).Script(), ErrPushTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Execute(tt.unlocking, tt.locking, nil)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// Test OP_CHECKSEQUENCEVERIFY asks the checker about its operand and leaves it on the stack
+func TestCheckSequenceVerify(t *testing.T) {
+	relativeLock := func(n int64) []byte {
+		return NewBuilder().AddInt(n).AddOp(OpCheckSequenceVerify).AddOp(OpDrop).Script()
+	}
+	unlocking := NewBuilder().AddInt(1).Script()
+	checker := fakeChecker{sequence: 10}
+
+	tests := []struct {
+		name    string
+		locking []byte
+		checker SigChecker
+		want    error
+	}{
+		{"satisfied", relativeLock(10), checker, nil},
+		{"not satisfied", relativeLock(11), checker, ErrUnsatisfiedLockTime},
+		{"negative", relativeLock(-1), checker, ErrNegativeLockTime},
+		{"five byte operand", relativeLock(1 << 32), checker, ErrUnsatisfiedLockTime},
+		{"operand too large", NewBuilder().AddData(make([]byte, 6)).AddOp(OpCheckSequenceVerify).Script(), checker, ErrNumberTooLarge},
+		{"empty stack", []byte{OpDrop, OpCheckSequenceVerify}, checker, ErrStackUnderflow},
+		{"no checker", relativeLock(0), nil, ErrUnsatisfiedLockTime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Execute(unlocking, tt.locking, tt.checker)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// Test a hashed time-locked contract pays the recipient with the secret, or the
+// sender after the lock time
+func TestHTLC(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x42}, HTLCSecretSize)
+	secretHash := sha256.Sum256(secret)
+	recipient, sender := []byte("recipient key"), []byte("sender key")
+	contract := HTLCContract{
+		SecretHash:          secretHash[:],
+		RecipientPubKeyHash: Hash160(recipient),
+		RefundPubKeyHash:    Hash160(sender),
+		LockTime:            100,
+	}
+	locking, err := HTLCScript(contract)
+	if err != nil {
+		t.Fatalf("Failed to create HTLC script: %v", err)
+	}
+
+	if GetScriptClass(locking) != HTLCTy {
+		t.Fatalf("Expected %s, got %s", HTLCTy, GetScriptClass(locking))
+	}
+	extracted, ok := ExtractHTLC(locking)
+	if !ok || extracted.LockTime != 100 || !bytes.Equal(extracted.RefundPubKeyHash, Hash160(sender)) {
+		t.Fatalf("Extracted contract does not match: %+v", extracted)
+	}
+
+	redeem := HTLCRedeemUnlockingScript([]byte("sig"), recipient, secret)
+	if !bytes.Equal(ExtractHTLCSecret(redeem), secret) {
+		t.Error("Secret was not extracted from the redeem script")
+	}
+	refund := HTLCRefundUnlockingScript([]byte("sig"), sender)
+	if ExtractHTLCSecret(refund) != nil {
+		t.Error("Refund script should not reveal a secret")
+	}
+
+	tests := []struct {
+		name      string
+		unlocking []byte
+		checker   fakeChecker
+		want      error
+	}{
+		{"redeem", redeem, fakeChecker{sig: []byte("sig"), pubKey: recipient}, nil},
+		{"redeem wrong secret", HTLCRedeemUnlockingScript([]byte("sig"), recipient, bytes.Repeat([]byte{1}, HTLCSecretSize)),
+			fakeChecker{sig: []byte("sig"), pubKey: recipient}, ErrVerify},
+		{"redeem short secret", HTLCRedeemUnlockingScript([]byte("sig"), recipient, secret[:16]),
+			fakeChecker{sig: []byte("sig"), pubKey: recipient}, ErrVerify},
+		{"redeem by sender", HTLCRedeemUnlockingScript([]byte("sig"), sender, secret),
+			fakeChecker{sig: []byte("sig"), pubKey: sender}, ErrVerify},
+		{"refund", refund, fakeChecker{sig: []byte("sig"), pubKey: sender, lockTime: 100}, nil},
+		{"refund before lock time", refund, fakeChecker{sig: []byte("sig"), pubKey: sender, lockTime: 99}, ErrUnsatisfiedLockTime},
+		{"refund by recipient", HTLCRefundUnlockingScript([]byte("sig"), recipient),
+			fakeChecker{sig: []byte("sig"), pubKey: recipient, lockTime: 100}, ErrVerify},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Execute(tt.unlocking, locking, tt.checker)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if _, err := HTLCScript(HTLCContract{SecretHash: []byte("short"), RecipientPubKeyHash: Hash160(recipient),
+		RefundPubKeyHash: Hash160(sender), LockTime: 100}); err == nil {
+		t.Error("Accepted a secret hash of the wrong size")
+	}
+}
+
+// Test null data outputs carry bounded data and can never be spent
+func TestNullData(t *testing.T) {
+	data := bytes.Repeat([]byte{0xab}, MaxDataCarrierSize)
+	locking, err := NullDataScript(data)
+	if err != nil {
+		t.Fatalf("Failed to create null data script: %v", err)
+	}
+	if GetScriptClass(locking) != NullDataTy {
+		t.Fatalf("Expected %s, got %s", NullDataTy, GetScriptClass(locking))
+	}
+	if got, ok := ExtractNullData(locking); !ok || !bytes.Equal(got, data) {
+		t.Errorf("Expected data %x, got %x", data, got)
+	}
+	if !IsUnspendable(locking) {
+		t.Error("Null data script is spendable")
+	}
+	if err := Execute(NewBuilder().AddInt(1).Script(), locking, multiChecker{}); !errors.Is(err, ErrEarlyReturn) {
+		t.Errorf("Expected %v, got %v", ErrEarlyReturn, err)
+	}
+
+	if _, err := NullDataScript(append(data, 0)); err == nil {
+		t.Error("Accepted more data than a null data output may carry")
+	}
+	nonMinimal := append([]byte{OpReturn, OpPushData1, 1}, 0xab)
+	if _, ok := ExtractNullData(nonMinimal); ok {
+		t.Error("Accepted a non-minimal push")
+	}
+	if _, ok := ExtractNullData(append(append([]byte{}, locking...), OpReturn)); ok {
+		t.Error("Accepted trailing opcodes")
+	}
+}
+
+// Test numbers round-trip through the minimal encoding
+func TestNumEncoding(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want []byte
+	}{
+		{0, nil},
+		{1, []byte{0x01}},
+		{-1, []byte{0x81}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x00}},
+		{-128, []byte{0x80, 0x80}},
+		{500000, []byte{0x20, 0xa1, 0x07}},
+	}
+	for _, tt := range tests {
+		got := encodeNum(tt.n)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeNum(%d) = %x, want %x", tt.n, got, tt.want)
+		}
+		back, err := decodeNum(got, maxNumLen)
+		if err != nil || back != tt.n {
+			t.Errorf("decodeNum(%x) = %d, %v, want %d", got, back, err, tt.n)
+		}
+	}
+
+	if _, err := decodeNum([]byte{1, 2, 3, 4, 5}, maxNumLen); !errors.Is(err, ErrNumberTooLarge) {
+		t.Errorf("Expected ErrNumberTooLarge, got %v", err)
+	}
+}
+
+// Test scripts disassemble into readable opcodes
+func TestDisassemble(t *testing.T) {
+	got, err := Disassemble(PayToPubKeyHash([]byte{0xab, 0xcd}))
+	if err != nil {
+		t.Fatalf("Failed to disassemble: %v", err)
+	}
+	if want := "OP_DUP OP_HASH160 abcd OP_EQUALVERIFY OP_CHECKSIG"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
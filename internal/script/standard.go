@@ -0,0 +1,323 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	// pubKeyHashLen is the length of a HASH160 digest
+	pubKeyHashLen = 20
+
+	// HTLCSecretSize is the length of the secret of a hashed time-locked contract.
+	// Fixing it keeps a secret accepted on one chain from being rejected on another.
+	HTLCSecretSize = 32
+
+	// MaxDataCarrierSize bounds the data a null data output carries
+	MaxDataCarrierSize = 80
+)
+
+// ScriptClass identifies the standard template a locking script follows
+type ScriptClass int
+
+const (
+	NonStandardTy ScriptClass = iota // Script does not follow a known template
+	PubKeyHashTy                     // Pay to the holder of the key with a given hash
+	MultiSigTy                       // Pay to any M of N listed keys
+	ScriptHashTy                     // Pay to whoever reveals and satisfies a script with a given hash
+	HTLCTy                           // Pay to a key with a secret, or back to another key after a lock time
+	NullDataTy                       // Carry data in a provably unspendable output
+)
+
+// scriptClassNames maps each ScriptClass to its name
+var scriptClassNames = map[ScriptClass]string{
+	NonStandardTy: "nonstandard",
+	PubKeyHashTy:  "pubkeyhash",
+	MultiSigTy:    "multisig",
+	ScriptHashTy:  "scripthash",
+	HTLCTy:        "htlc",
+	NullDataTy:    "nulldata",
+}
+
+// String returns the name of the template
+func (c ScriptClass) String() string {
+	if name, ok := scriptClassNames[c]; ok {
+		return name
+	}
+	return "invalid"
+}
+
+// Hash160 returns RIPEMD-160(SHA-256(data)), the hash used for keys and scripts
+func Hash160(data []byte) []byte {
+	sha := sha256.Sum256(data)
+	hasher := ripemd160.New()
+	hasher.Write(sha[:])
+	return hasher.Sum(nil)
+}
+
+// PayToPubKeyHash returns the locking script paying to the key with the given hash:
+// OP_DUP OP_HASH160 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG
+func PayToPubKeyHash(pubKeyHash []byte) []byte {
+	return NewBuilder().
+		AddOp(OpDup).
+		AddOp(OpHash160).
+		AddData(pubKeyHash).
+		AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+}
+
+// PubKeyHashUnlockingScript returns the unlocking script for a pay-to-pubkey-hash
+// output: <sig> <pubKey>
+func PubKeyHashUnlockingScript(sig, pubKey []byte) []byte {
+	return NewBuilder().AddData(sig).AddData(pubKey).Script()
+}
+
+// ExtractPubKeyHash returns the key hash of a pay-to-pubkey-hash locking script,
+// or nil if the script follows another template
+func ExtractPubKeyHash(script []byte) []byte {
+	if len(script) != pubKeyHashLen+5 ||
+		script[0] != OpDup ||
+		script[1] != OpHash160 ||
+		script[2] != pubKeyHashLen ||
+		script[pubKeyHashLen+3] != OpEqualVerify ||
+		script[pubKeyHashLen+4] != OpCheckSig {
+		return nil
+	}
+	return script[3 : pubKeyHashLen+3]
+}
+
+// PayToScriptHash returns the locking script paying to the redeem script with the
+// given hash: OP_HASH160 <scriptHash> OP_EQUAL
+func PayToScriptHash(scriptHash []byte) []byte {
+	return NewBuilder().
+		AddOp(OpHash160).
+		AddData(scriptHash).
+		AddOp(OpEqual).
+		Script()
+}
+
+// ScriptHashUnlockingScript returns the unlocking script for a pay-to-script-hash
+// output: the unlocking script of the redeem script followed by the redeem script
+func ScriptHashUnlockingScript(redeemUnlocking, redeemScript []byte) []byte {
+	return append(append([]byte{}, redeemUnlocking...), NewBuilder().AddData(redeemScript).Script()...)
+}
+
+// ExtractScriptHash returns the redeem script hash of a pay-to-script-hash locking
+// script, or nil if the script follows another template
+func ExtractScriptHash(script []byte) []byte {
+	if len(script) != pubKeyHashLen+3 ||
+		script[0] != OpHash160 ||
+		script[1] != pubKeyHashLen ||
+		script[pubKeyHashLen+2] != OpEqual {
+		return nil
+	}
+	return script[2 : pubKeyHashLen+2]
+}
+
+// MultiSigScript returns the locking script requiring m signatures from the given keys:
+// <m> <pubKey>... <n> OP_CHECKMULTISIG
+func MultiSigScript(m int, pubKeys [][]byte) ([]byte, error) {
+	n := len(pubKeys)
+	if n == 0 || n > MaxPubKeysPerMultiSig {
+		return nil, fmt.Errorf("%w: %d, must be between 1 and %d", ErrInvalidKeyCount, n, MaxPubKeysPerMultiSig)
+	}
+	if m < 1 || m > n {
+		return nil, fmt.Errorf("%w: %d, must be between 1 and %d", ErrInvalidSigCount, m, n)
+	}
+
+	b := NewBuilder().AddInt(int64(m))
+	for _, pubKey := range pubKeys {
+		b.AddData(pubKey)
+	}
+	return b.AddInt(int64(n)).AddOp(OpCheckMultiSig).Script(), nil
+}
+
+// MultiSigUnlockingScript returns the unlocking script for a multisig output.
+// The signatures must be in the order of the keys that made them.
+func MultiSigUnlockingScript(sigs [][]byte) []byte {
+	b := NewBuilder()
+	for _, sig := range sigs {
+		b.AddData(sig)
+	}
+	return b.Script()
+}
+
+// ExtractMultiSig returns the number of required signatures and the keys of a
+// multisig locking script. The boolean is false if the script follows another template.
+func ExtractMultiSig(script []byte) (int, [][]byte, bool) {
+	instructions, err := parse(script)
+	if err != nil || len(instructions) < 4 {
+		return 0, nil, false
+	}
+
+	last := len(instructions) - 1
+	first, count := instructions[0].op, instructions[last-1].op
+	if instructions[last].op != OpCheckMultiSig || !isSmallInt(first) || !isSmallInt(count) {
+		return 0, nil, false
+	}
+
+	m := int(first-Op1) + 1
+	n := int(count-Op1) + 1
+	keys := instructions[1 : last-1]
+	if len(keys) != n || m > n || n > MaxPubKeysPerMultiSig {
+		return 0, nil, false
+	}
+
+	pubKeys := make([][]byte, 0, n)
+	for _, ins := range keys {
+		if ins.op < OpData1 || ins.op > OpPushData2 {
+			return 0, nil, false
+		}
+		pubKeys = append(pubKeys, ins.data)
+	}
+	return m, pubKeys, true
+}
+
+// HTLCContract holds the terms of a hashed time-locked contract
+type HTLCContract struct {
+	SecretHash          []byte // SHA-256 of the secret that lets the recipient claim the output
+	RecipientPubKeyHash []byte // Key hash of the recipient
+	RefundPubKeyHash    []byte // Key hash of the sender, who can take the output back
+	LockTime            int64  // Block height or Unix time from which the sender can take it back
+}
+
+// HTLCScript returns the locking script of a hashed time-locked contract:
+//
+//	OP_IF
+//	    OP_SIZE <HTLCSecretSize> OP_EQUALVERIFY OP_SHA256 <secretHash> OP_EQUALVERIFY
+//	    OP_DUP OP_HASH160 <recipientPubKeyHash>
+//	OP_ELSE
+//	    <lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP OP_DUP OP_HASH160 <refundPubKeyHash>
+//	OP_ENDIF
+//	OP_EQUALVERIFY OP_CHECKSIG
+func HTLCScript(c HTLCContract) ([]byte, error) {
+	if len(c.SecretHash) != sha256.Size {
+		return nil, fmt.Errorf("secret hash must be %d bytes, got %d", sha256.Size, len(c.SecretHash))
+	}
+	if len(c.RecipientPubKeyHash) != pubKeyHashLen || len(c.RefundPubKeyHash) != pubKeyHashLen {
+		return nil, fmt.Errorf("key hashes must be %d bytes", pubKeyHashLen)
+	}
+	if c.LockTime <= 0 || c.LockTime >= 1<<32 {
+		return nil, fmt.Errorf("lock time %d is out of range", c.LockTime)
+	}
+
+	return NewBuilder().
+		AddOp(OpIf).
+		AddOp(OpSize).AddInt(HTLCSecretSize).AddOp(OpEqualVerify).
+		AddOp(OpSha256).AddData(c.SecretHash).AddOp(OpEqualVerify).
+		AddOp(OpDup).AddOp(OpHash160).AddData(c.RecipientPubKeyHash).
+		AddOp(OpElse).
+		AddInt(c.LockTime).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).
+		AddOp(OpDup).AddOp(OpHash160).AddData(c.RefundPubKeyHash).
+		AddOp(OpEndIf).
+		AddOp(OpEqualVerify).AddOp(OpCheckSig).
+		Script(), nil
+}
+
+// HTLCRedeemUnlockingScript returns the unlocking script with which the recipient
+// claims a hashed time-locked output: <sig> <pubKey> <secret> 1
+func HTLCRedeemUnlockingScript(sig, pubKey, secret []byte) []byte {
+	return NewBuilder().AddData(sig).AddData(pubKey).AddData(secret).AddInt(1).Script()
+}
+
+// HTLCRefundUnlockingScript returns the unlocking script with which the sender takes
+// a hashed time-locked output back: <sig> <pubKey> 0
+func HTLCRefundUnlockingScript(sig, pubKey []byte) []byte {
+	return NewBuilder().AddData(sig).AddData(pubKey).AddInt(0).Script()
+}
+
+// ExtractHTLC returns the terms of a hashed time-locked contract locking script.
+// The boolean is false if the script follows another template.
+func ExtractHTLC(script []byte) (HTLCContract, bool) {
+	instructions, err := parse(script)
+	if err != nil || len(instructions) != 20 {
+		return HTLCContract{}, false
+	}
+
+	if !instructions[11].isPush() {
+		return HTLCContract{}, false
+	}
+	lockTime, err := decodeNum(pushValue(instructions[11]), lockTimeNumLen)
+	if err != nil {
+		return HTLCContract{}, false
+	}
+	c := HTLCContract{
+		SecretHash:          instructions[5].data,
+		RecipientPubKeyHash: instructions[9].data,
+		RefundPubKeyHash:    instructions[16].data,
+		LockTime:            lockTime,
+	}
+
+	// Rebuilding the script from the terms checks every other instruction
+	rebuilt, err := HTLCScript(c)
+	if err != nil || !bytes.Equal(rebuilt, script) {
+		return HTLCContract{}, false
+	}
+	return c, true
+}
+
+// ExtractHTLCSecret returns the secret revealed by an unlocking script that claimed
+// a hashed time-locked output, or nil if it is not such a script
+func ExtractHTLCSecret(unlocking []byte) []byte {
+	pushes, err := PushedData(unlocking)
+	if err != nil || len(pushes) != 4 || !asBool(pushes[3]) || len(pushes[2]) != HTLCSecretSize {
+		return nil
+	}
+	return pushes[2]
+}
+
+// NullDataScript returns the locking script of an output carrying data, which no
+// unlocking script can satisfy: OP_RETURN <data>
+func NullDataScript(data []byte) ([]byte, error) {
+	if len(data) > MaxDataCarrierSize {
+		return nil, fmt.Errorf("%w: %d bytes of data, at most %d are allowed", ErrPushTooLarge, len(data), MaxDataCarrierSize)
+	}
+	return NewBuilder().AddOp(OpReturn).AddData(data).Script(), nil
+}
+
+// ExtractNullData returns the data carried by a null data locking script. The
+// boolean is false if the script follows another template.
+func ExtractNullData(script []byte) ([]byte, bool) {
+	instructions, err := parse(script)
+	if err != nil || len(instructions) != 2 || instructions[0].op != OpReturn {
+		return nil, false
+	}
+	push := instructions[1]
+	if push.op > OpPushData2 || len(push.data) > MaxDataCarrierSize {
+		return nil, false
+	}
+	if !bytes.Equal(NewBuilder().AddOp(OpReturn).AddData(push.data).Script(), script) {
+		return nil, false
+	}
+	return push.data, true
+}
+
+// IsUnspendable reports whether no unlocking script can satisfy the locking script
+// because it starts with OP_RETURN
+func IsUnspendable(script []byte) bool {
+	return len(script) > 0 && script[0] == OpReturn
+}
+
+// GetScriptClass returns the standard template the locking script follows
+func GetScriptClass(script []byte) ScriptClass {
+	if ExtractPubKeyHash(script) != nil {
+		return PubKeyHashTy
+	}
+	if ExtractScriptHash(script) != nil {
+		return ScriptHashTy
+	}
+	if _, _, ok := ExtractMultiSig(script); ok {
+		return MultiSigTy
+	}
+	if _, ok := ExtractHTLC(script); ok {
+		return HTLCTy
+	}
+	if _, ok := ExtractNullData(script); ok {
+		return NullDataTy
+	}
+	return NonStandardTy
+}
@@ -0,0 +1,29 @@
+package transaction
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/codec"
+)
+
+// ChainID identifies a chain by the name of its network and the hash of its genesis
+// block. It is mixed into every transaction and block signature, so a signature made
+// on one chain is not valid on another, even where the same keys are used.
+type ChainID [32]byte
+
+// NewChainID returns the ID of the chain on network whose genesis block has hash
+// genesisHash: the SHA-256 of network and genesisHash as byte strings. The genesis
+// block is signed before its hash is known, so it is signed with the ID for an
+// empty genesisHash.
+func NewChainID(genesisHash []byte, network string) ChainID {
+    w := codec.NewWriter()
+    w.WriteBytes([]byte(network))
+    w.WriteBytes(genesisHash)
+    return sha256.Sum256(w.Bytes())
+}
+
+// String returns the ID in hex
+func (id ChainID) String() string {
+    return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,65 @@
+package transaction
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "fmt"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/script"
+    "github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// NewHTLCSpend creates an unsigned transaction paying the hashed time-locked output at
+// index vout of transaction txid to toScript, less fee. A refund must set lockTime to
+// the lock time of the contract; a redeem may leave it 0.
+func NewHTLCSpend(txid []byte, vout int, prevOut TxOutput, toScript []byte, fee int, lockTime int64) (*Transaction, error) {
+    if _, ok := script.ExtractHTLC(prevOut.LockingScript); !ok {
+        return nil, fmt.Errorf("output %x:%d is not a hashed time-locked contract", txid, vout)
+    }
+    if fee < 0 || fee >= prevOut.Value {
+        return nil, fmt.Errorf("fee %d must be at least 0 and less than the contract's %d", fee, prevOut.Value)
+    }
+
+    tx := &Transaction{
+        ID:       []byte{},
+        Vin:      []TxInput{{Txid: txid, Vout: vout}},
+        Vout:     []TxOutput{{Value: prevOut.Value - fee, LockingScript: toScript}},
+        LockTime: lockTime,
+    }
+    tx.ID = tx.Hash()
+    return tx, nil
+}
+
+// SignHTLC signs input inIdx, which spends the hashed time-locked output prevOut, for
+// chain chainID. With a secret the wallet must be the recipient's and claims the output; without
+// one it must be the sender's and takes the output back.
+func (tx *Transaction) SignHTLC(inIdx int, w *wallet.Wallet, prevOut TxOutput, chainID ChainID, secret []byte) error {
+    contract, ok := script.ExtractHTLC(prevOut.LockingScript)
+    if !ok {
+        return fmt.Errorf("input %d does not spend a hashed time-locked contract", inIdx)
+    }
+
+    pubKeyHash := wallet.HashPubKey(w.PublicKey)
+    if secret != nil {
+        if hash := sha256.Sum256(secret); !bytes.Equal(hash[:], contract.SecretHash) {
+            return fmt.Errorf("secret does not match the contract's hash")
+        }
+        if !bytes.Equal(pubKeyHash, contract.RecipientPubKeyHash) {
+            return fmt.Errorf("wallet is not the recipient of the contract")
+        }
+    } else if !bytes.Equal(pubKeyHash, contract.RefundPubKeyHash) {
+        return fmt.Errorf("wallet is not the sender of the contract")
+    }
+
+    signature, err := NewSigHashes(tx).signatureFor(inIdx, w, prevOut.LockingScript, chainID, SigHashAll)
+    if err != nil {
+        return err
+    }
+
+    if secret != nil {
+        tx.Vin[inIdx].UnlockingScript = script.HTLCRedeemUnlockingScript(signature, w.PublicKey, secret)
+    } else {
+        tx.Vin[inIdx].UnlockingScript = script.HTLCRefundUnlockingScript(signature, w.PublicKey)
+    }
+    return nil
+}
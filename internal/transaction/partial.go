@@ -0,0 +1,369 @@
+package transaction
+
+import (
+    "bytes"
+    "fmt"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/codec"
+    "github.com/OmSingh2003/decentralized-ledger/internal/script"
+    "github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// PartialTx is a partially signed transaction: a transaction whose inputs are being
+// signed, possibly by several parties on machines without the chain. It carries the
+// outputs being spent and their redeem scripts, so signers do not need the chain, and
+// the signatures collected so far for each key of each input. The signatures are for
+// one chain, whose ID it carries as well.
+//
+// Inputs may spend pay-to-pubkey-hash, multisig or pay-to-script-hash multisig outputs.
+type PartialTx struct {
+    Tx            *Transaction
+    ChainID       ChainID    // Chain the signatures are made for
+    PrevOutputs   []TxOutput // Output spent by each input
+    RedeemScripts [][]byte   // Multisig redeem script of each pay-to-script-hash input, empty for others
+    Signatures    [][][]byte // Signature of each input by each key of its script, empty if missing
+    PubKeys       [][]byte   // Public key that signed each pay-to-pubkey-hash input, empty for others
+}
+
+// NewPartialTx starts collecting signatures for tx on chain chainID, where it spends
+// prevOutputs in order. Outputs locked with pay-to-script-hash need their multisig redeem script at the same
+// index of redeemScripts; redeemScripts may be nil if no output is pay-to-script-hash.
+func NewPartialTx(tx *Transaction, chainID ChainID, prevOutputs []TxOutput, redeemScripts [][]byte) (*PartialTx, error) {
+    if len(prevOutputs) != len(tx.Vin) {
+        return nil, fmt.Errorf("transaction has %d inputs but %d spent outputs were given", len(tx.Vin), len(prevOutputs))
+    }
+    if redeemScripts == nil {
+        redeemScripts = make([][]byte, len(prevOutputs))
+    }
+    if len(redeemScripts) != len(prevOutputs) {
+        return nil, fmt.Errorf("transaction has %d inputs but %d redeem scripts were given", len(tx.Vin), len(redeemScripts))
+    }
+
+    p := &PartialTx{
+        Tx:            tx,
+        ChainID:       chainID,
+        PrevOutputs:   prevOutputs,
+        RedeemScripts: redeemScripts,
+        PubKeys:       make([][]byte, len(prevOutputs)),
+    }
+    for i, prevOut := range prevOutputs {
+        if scriptHash := script.ExtractScriptHash(prevOut.LockingScript); scriptHash != nil {
+            if !bytes.Equal(script.Hash160(redeemScripts[i]), scriptHash) {
+                return nil, fmt.Errorf("redeem script of input %d does not match the script hash", i)
+            }
+            if _, _, ok := script.ExtractMultiSig(redeemScripts[i]); !ok {
+                return nil, fmt.Errorf("redeem script of input %d is not a multisig script", i)
+            }
+        } else if len(redeemScripts[i]) > 0 {
+            return nil, fmt.Errorf("input %d has a redeem script but does not spend a script hash output", i)
+        }
+
+        signingScript := p.signingScript(i)
+        switch script.GetScriptClass(signingScript) {
+        case script.PubKeyHashTy:
+            p.Signatures = append(p.Signatures, make([][]byte, 1))
+        case script.MultiSigTy:
+            _, pubKeys, _ := script.ExtractMultiSig(signingScript)
+            p.Signatures = append(p.Signatures, make([][]byte, len(pubKeys)))
+        default:
+            return nil, fmt.Errorf("input %d spends a %s output, which partially signed transactions do not support",
+                i, script.GetScriptClass(signingScript))
+        }
+    }
+    return p, nil
+}
+
+// signingScript returns the script whose keys sign input i: its redeem script, or the
+// locking script of the output it spends
+func (p *PartialTx) signingScript(i int) []byte {
+    if len(p.RedeemScripts[i]) > 0 {
+        return p.RedeemScripts[i]
+    }
+    return p.PrevOutputs[i].LockingScript
+}
+
+// requiredSignatures returns the number of signatures input i needs
+func (p *PartialTx) requiredSignatures(i int) int {
+    if m, _, ok := script.ExtractMultiSig(p.signingScript(i)); ok {
+        return m
+    }
+    return 1
+}
+
+// Sign adds the wallet's signature to every input locked with its key and returns
+// the number of signatures added
+func (p *PartialTx) Sign(w *wallet.Wallet) (int, error) {
+    return p.SignWithHashType(w, SigHashAll)
+}
+
+// SignWithHashType is like Sign, committing each signature to the parts of the
+// transaction selected by hashType
+func (p *PartialTx) SignWithHashType(w *wallet.Wallet, hashType SigHashType) (int, error) {
+    added := 0
+    hashes := NewSigHashes(p.Tx)
+    for i, prevOut := range p.PrevOutputs {
+        signingScript := p.signingScript(i)
+
+        if pubKeyHash := script.ExtractPubKeyHash(signingScript); pubKeyHash != nil {
+            if !bytes.Equal(pubKeyHash, wallet.HashPubKey(w.PublicKey)) || len(p.Signatures[i][0]) > 0 {
+                continue
+            }
+            signature, err := hashes.signatureFor(i, w, prevOut.LockingScript, p.ChainID, hashType)
+            if err != nil {
+                return added, err
+            }
+            p.Signatures[i][0] = signature
+            p.PubKeys[i] = w.PublicKey
+            added++
+            continue
+        }
+
+        _, pubKeys, _ := script.ExtractMultiSig(signingScript)
+        for k, pubKey := range pubKeys {
+            if !bytes.Equal(pubKey, w.PublicKey) || len(p.Signatures[i][k]) > 0 {
+                continue
+            }
+
+            signature, err := hashes.signatureFor(i, w, prevOut.LockingScript, p.ChainID, hashType)
+            if err != nil {
+                return added, err
+            }
+            p.Signatures[i][k] = signature
+            added++
+        }
+    }
+    return added, nil
+}
+
+// Combine adds the signatures of other, a copy of the same partially signed
+// transaction signed by other parties, and returns the number of signatures added
+func (p *PartialTx) Combine(other *PartialTx) (int, error) {
+    if !bytes.Equal(p.Tx.Hash(), other.Tx.Hash()) {
+        return 0, fmt.Errorf("partially signed transactions are for different transactions %x and %x", p.Tx.Hash(), other.Tx.Hash())
+    }
+    if p.ChainID != other.ChainID {
+        return 0, fmt.Errorf("partially signed transactions are for different chains %s and %s", p.ChainID, other.ChainID)
+    }
+    if len(other.PrevOutputs) != len(p.PrevOutputs) || len(other.RedeemScripts) != len(p.RedeemScripts) {
+        return 0, fmt.Errorf("partially signed transactions spend %d and %d outputs", len(p.PrevOutputs), len(other.PrevOutputs))
+    }
+    if len(other.Signatures) != len(p.Signatures) || len(other.PubKeys) != len(p.PubKeys) {
+        return 0, fmt.Errorf("partially signed transactions have signatures for %d and %d inputs", len(p.Signatures), len(other.Signatures))
+    }
+    for i := range p.PrevOutputs {
+        mine, theirs := p.PrevOutputs[i], other.PrevOutputs[i]
+        if mine.Value != theirs.Value || !bytes.Equal(mine.LockingScript, theirs.LockingScript) ||
+            !bytes.Equal(p.RedeemScripts[i], other.RedeemScripts[i]) {
+            return 0, fmt.Errorf("partially signed transactions disagree on the output spent by input %d", i)
+        }
+        if len(other.Signatures[i]) != len(p.Signatures[i]) {
+            return 0, fmt.Errorf("input %d has signature slots for %d and %d keys", i, len(p.Signatures[i]), len(other.Signatures[i]))
+        }
+    }
+
+    added := 0
+    for i, sigs := range other.Signatures {
+        for k, sig := range sigs {
+            if len(sig) == 0 || len(p.Signatures[i][k]) > 0 {
+                continue
+            }
+            p.Signatures[i][k] = sig
+            if len(other.PubKeys[i]) > 0 {
+                p.PubKeys[i] = other.PubKeys[i]
+            }
+            added++
+        }
+    }
+    return added, nil
+}
+
+// RemainingSignatures returns how many more signatures the input needing the most
+// still requires. The transaction can be finalized when it is zero.
+func (p *PartialTx) RemainingSignatures() int {
+    remaining := 0
+    for i := range p.PrevOutputs {
+        missing := p.requiredSignatures(i) - countSignatures(p.Signatures[i])
+        if missing > remaining {
+            remaining = missing
+        }
+    }
+    return remaining
+}
+
+// countSignatures returns the number of keys that have signed
+func countSignatures(sigs [][]byte) int {
+    n := 0
+    for _, sig := range sigs {
+        if len(sig) > 0 {
+            n++
+        }
+    }
+    return n
+}
+
+// Finalize builds the unlocking script of every input from the collected signatures,
+// in the order of the keys that made them, followed by the redeem script if there is
+// one, checks them against the spent outputs and returns the signed transaction
+func (p *PartialTx) Finalize() (*Transaction, error) {
+    for i := range p.PrevOutputs {
+        var unlocking []byte
+        if len(p.PubKeys[i]) > 0 {
+            unlocking = script.PubKeyHashUnlockingScript(p.Signatures[i][0], p.PubKeys[i])
+        } else {
+            m := p.requiredSignatures(i)
+
+            var sigs [][]byte
+            for _, sig := range p.Signatures[i] {
+                if len(sig) > 0 && len(sigs) < m {
+                    sigs = append(sigs, sig)
+                }
+            }
+            if len(sigs) < m {
+                return nil, fmt.Errorf("input %d has %d of %d required signatures", i, len(sigs), m)
+            }
+            unlocking = script.MultiSigUnlockingScript(sigs)
+            if len(p.RedeemScripts[i]) > 0 {
+                unlocking = script.ScriptHashUnlockingScript(unlocking, p.RedeemScripts[i])
+            }
+        }
+        p.Tx.Vin[i].UnlockingScript = unlocking
+    }
+
+    hashes := NewSigHashes(p.Tx)
+    for i, prevOut := range p.PrevOutputs {
+        checker := sigChecker{tx: p.Tx, hashes: hashes, inIdx: i, lockingScript: prevOut.LockingScript, chainID: p.ChainID}
+        if err := script.Execute(p.Tx.Vin[i].UnlockingScript, prevOut.LockingScript, checker); err != nil {
+            return nil, fmt.Errorf("input %d: %v", i, err)
+        }
+    }
+    return p.Tx, nil
+}
+
+// SignedSize returns the encoded size of the transaction once every input has its
+// required signatures. Signatures and public keys have a fixed length, so the fee for
+// that size can be set before anyone signs.
+func (p *PartialTx) SignedSize() int {
+    signed := *p.Tx
+    signed.Vin = append([]TxInput{}, p.Tx.Vin...)
+
+    sig := make([]byte, 65) // r, s and the hash type
+    pubKey := make([]byte, 64)
+    for i := range signed.Vin {
+        if script.ExtractPubKeyHash(p.signingScript(i)) != nil {
+            signed.Vin[i].UnlockingScript = script.PubKeyHashUnlockingScript(sig, pubKey)
+            continue
+        }
+
+        sigs := make([][]byte, p.requiredSignatures(i))
+        for k := range sigs {
+            sigs[k] = sig
+        }
+        unlocking := script.MultiSigUnlockingScript(sigs)
+        if len(p.RedeemScripts[i]) > 0 {
+            unlocking = script.ScriptHashUnlockingScript(unlocking, p.RedeemScripts[i])
+        }
+        signed.Vin[i].UnlockingScript = unlocking
+    }
+    return len(signed.Serialize())
+}
+
+// Serialize encodes the partially signed transaction: Tx, ChainID (bytes),
+// PrevOutputs (list of TxOutput), RedeemScripts (list of bytes), Signatures (per
+// input, a list of signatures as bytes) and PubKeys (list of bytes)
+func (p *PartialTx) Serialize() []byte {
+    w := codec.NewWriter()
+    p.Tx.Encode(w)
+    w.WriteBytes(p.ChainID[:])
+
+    w.WriteCount(len(p.PrevOutputs))
+    for i := range p.PrevOutputs {
+        p.PrevOutputs[i].Encode(w)
+    }
+
+    w.WriteCount(len(p.RedeemScripts))
+    for _, redeemScript := range p.RedeemScripts {
+        w.WriteBytes(redeemScript)
+    }
+
+    w.WriteCount(len(p.Signatures))
+    for _, sigs := range p.Signatures {
+        w.WriteCount(len(sigs))
+        for _, sig := range sigs {
+            w.WriteBytes(sig)
+        }
+    }
+
+    w.WriteCount(len(p.PubKeys))
+    for _, pubKey := range p.PubKeys {
+        w.WriteBytes(pubKey)
+    }
+    return w.Bytes()
+}
+
+// DeserializePartialTx decodes a partially signed transaction written by Serialize
+func DeserializePartialTx(data []byte) (*PartialTx, error) {
+    r := codec.NewReader(data)
+    tx := DecodeTransaction(r)
+    chainIDBytes := r.ReadBytes()
+
+    var prevOutputs []TxOutput
+    n := r.ReadCount(minOutputSize)
+    for i := 0; i < n; i++ {
+        prevOutputs = append(prevOutputs, DecodeTxOutput(r))
+    }
+
+    var redeemScripts [][]byte
+    n = r.ReadCount(4)
+    for i := 0; i < n; i++ {
+        redeemScripts = append(redeemScripts, r.ReadBytes())
+    }
+
+    var signatures [][][]byte
+    n = r.ReadCount(4)
+    for i := 0; i < n; i++ {
+        sigs := make([][]byte, r.ReadCount(4))
+        for k := range sigs {
+            sigs[k] = r.ReadBytes()
+        }
+        signatures = append(signatures, sigs)
+    }
+
+    var pubKeys [][]byte
+    n = r.ReadCount(4)
+    for i := 0; i < n; i++ {
+        pubKeys = append(pubKeys, r.ReadBytes())
+    }
+    if err := r.Finish(); err != nil {
+        return nil, fmt.Errorf("failed to decode partially signed transaction: %v", err)
+    }
+
+    var chainID ChainID
+    if len(chainIDBytes) != len(chainID) {
+        return nil, fmt.Errorf("partially signed transaction has a chain ID of %d bytes", len(chainIDBytes))
+    }
+    copy(chainID[:], chainIDBytes)
+
+    p, err := NewPartialTx(tx, chainID, prevOutputs, redeemScripts)
+    if err != nil {
+        return nil, err
+    }
+    if len(signatures) != len(p.Signatures) {
+        return nil, fmt.Errorf("partially signed transaction has signatures for %d of %d inputs", len(signatures), len(p.Signatures))
+    }
+    for i := range signatures {
+        if len(signatures[i]) != len(p.Signatures[i]) {
+            return nil, fmt.Errorf("input %d has signature slots for %d of %d keys", i, len(signatures[i]), len(p.Signatures[i]))
+        }
+    }
+    if len(pubKeys) != len(p.PubKeys) {
+        return nil, fmt.Errorf("partially signed transaction has public keys for %d of %d inputs", len(pubKeys), len(p.PubKeys))
+    }
+    for i, pubKey := range pubKeys {
+        if len(pubKey) > 0 && script.GetScriptClass(p.signingScript(i)) != script.PubKeyHashTy {
+            return nil, fmt.Errorf("input %d has a public key but does not spend a pay-to-pubkey-hash output", i)
+        }
+    }
+    p.Signatures = signatures
+    p.PubKeys = pubKeys
+    return p, nil
+}
@@ -0,0 +1,101 @@
+package transaction
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// Helper to create a partially signed transaction spending two outputs, one paying
+// to w's key hash and one to a 2-of-2 multisig of w and another key
+func newTestPartialTx(t *testing.T, w *wallet.Wallet) *PartialTx {
+	t.Helper()
+	multisig, err := script.MultiSigScript(2, [][]byte{w.PublicKey, wallet.NewWallet().PublicKey})
+	if err != nil {
+		t.Fatalf("Failed to create multisig script: %v", err)
+	}
+	prevOutputs := []TxOutput{
+		NewTxOutput(10, wallet.HashPubKey(w.PublicKey)),
+		{Value: 20, LockingScript: multisig},
+	}
+	p, err := NewPartialTx(spendTx([]byte("previous"), []int{0, 1}, 30), testChainID, prevOutputs, nil)
+	if err != nil {
+		t.Fatalf("Failed to create partial transaction: %v", err)
+	}
+	return p
+}
+
+// Test a partially signed transaction survives serialization with its signatures
+func TestPartialTxRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	w := wallet.NewWallet()
+	p := newTestPartialTx(t, w)
+	if added, err := p.Sign(w); err != nil || added != 2 {
+		t.Fatalf("Expected 2 signatures, added %d: %v", added, err)
+	}
+
+	decoded, err := DeserializePartialTx(p.Serialize())
+	if err != nil {
+		t.Fatalf("Failed to deserialize: %v", err)
+	}
+	if !bytes.Equal(decoded.Serialize(), p.Serialize()) {
+		t.Error("Round trip changed the partial transaction")
+	}
+	if decoded.ChainID != testChainID || !bytes.Equal(decoded.PubKeys[0], w.PublicKey) ||
+		!bytes.Equal(decoded.Signatures[1][0], p.Signatures[1][0]) || len(decoded.Signatures[1][1]) != 0 {
+		t.Error("Round trip lost the chain ID, a public key or a signature")
+	}
+
+	data := p.Serialize()
+	if _, err := DeserializePartialTx(data[:len(data)-1]); err == nil {
+		t.Error("Truncated partial transaction was decoded")
+	}
+}
+
+// Test Combine refuses copies of the transaction that disagree on the chain or the
+// outputs spent, including ones with fewer outputs or signature slots, instead of
+// indexing past them
+func TestPartialTxCombineMismatch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	w := wallet.NewWallet()
+	p := newTestPartialTx(t, w)
+	copyOf := func() *PartialTx {
+		c, err := DeserializePartialTx(p.Serialize())
+		if err != nil {
+			t.Fatalf("Failed to copy partial transaction: %v", err)
+		}
+		if _, err := c.Sign(w); err != nil {
+			t.Fatalf("Failed to sign copy: %v", err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*PartialTx)
+	}{
+		{"chain ID", func(c *PartialTx) { c.ChainID = NewChainID([]byte("test genesis"), "testnet") }},
+		{"output value", func(c *PartialTx) { c.PrevOutputs[0].Value++ }},
+		{"output script", func(c *PartialTx) { c.PrevOutputs[1] = NewTxOutput(20, []byte("someone else")) }},
+		{"fewer outputs", func(c *PartialTx) { c.PrevOutputs = c.PrevOutputs[:1] }},
+		{"fewer redeem scripts", func(c *PartialTx) { c.RedeemScripts = nil }},
+		{"fewer inputs signed", func(c *PartialTx) { c.Signatures = c.Signatures[:1] }},
+		{"fewer signature slots", func(c *PartialTx) { c.Signatures[1] = c.Signatures[1][:1] }},
+		{"fewer public keys", func(c *PartialTx) { c.PubKeys = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := copyOf()
+			tt.modify(other)
+			if added, err := p.Combine(other); err == nil {
+				t.Errorf("Combined a copy with a different %s, adding %d signatures", tt.name, added)
+			}
+		})
+	}
+
+	if added, err := p.Combine(copyOf()); err != nil || added != 2 {
+		t.Fatalf("Expected 2 combined signatures, added %d: %v", added, err)
+	}
+}
@@ -1,33 +1,102 @@
 package transaction
 
 import (
-    "bytes"
-    "encoding/gob"
-    "log"
+    "fmt"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/codec"
+)
+
+// Minimum encoded sizes, used to bound list counts while decoding
+const (
+    minInputSize  = 4 + 4 + 4 + 4
+    minOutputSize = 8 + 4
 )
 
-// SerializeOutputs serializes TxOutput array
-func SerializeOutputs(outs []TxOutput) []byte {
-    var buff bytes.Buffer
+// Encode writes the canonical encoding of the transaction:
+// ID (bytes), Vin (list of TxInput), Vout (list of TxOutput), LockTime (int64)
+func (tx *Transaction) Encode(w *codec.Writer) {
+    w.WriteBytes(tx.ID)
 
-    enc := gob.NewEncoder(&buff)
-    err := enc.Encode(outs)
-    if err != nil {
-        log.Panic(err)
+    w.WriteCount(len(tx.Vin))
+    for i := range tx.Vin {
+        tx.Vin[i].Encode(w)
     }
 
-    return buff.Bytes()
+    w.WriteCount(len(tx.Vout))
+    for i := range tx.Vout {
+        tx.Vout[i].Encode(w)
+    }
+
+    w.WriteInt64(tx.LockTime)
 }
 
-// DeserializeOutputs deserializes TxOutput array
-func DeserializeOutputs(data []byte) []TxOutput {
-    var outputs []TxOutput
+// DecodeTransaction reads a transaction written by Encode
+func DecodeTransaction(r *codec.Reader) *Transaction {
+    tx := &Transaction{}
+    tx.ID = r.ReadBytes()
 
-    dec := gob.NewDecoder(bytes.NewReader(data))
-    err := dec.Decode(&outputs)
-    if err != nil {
-        log.Panic(err)
+    n := r.ReadCount(minInputSize)
+    for i := 0; i < n; i++ {
+        tx.Vin = append(tx.Vin, DecodeTxInput(r))
     }
 
-    return outputs
+    n = r.ReadCount(minOutputSize)
+    for i := 0; i < n; i++ {
+        tx.Vout = append(tx.Vout, DecodeTxOutput(r))
+    }
+
+    tx.LockTime = r.ReadInt64()
+
+    return tx
+}
+
+// Encode writes the canonical encoding of the input:
+// Txid (bytes), Vout (int32), UnlockingScript (bytes), Sequence (uint32)
+func (in *TxInput) Encode(w *codec.Writer) {
+    w.WriteBytes(in.Txid)
+    w.WriteInt32(int32(in.Vout))
+    w.WriteBytes(in.UnlockingScript)
+    w.WriteUint32(in.Sequence)
+}
+
+// DecodeTxInput reads an input written by TxInput.Encode
+func DecodeTxInput(r *codec.Reader) TxInput {
+    var in TxInput
+    in.Txid = r.ReadBytes()
+    in.Vout = int(r.ReadInt32())
+    in.UnlockingScript = r.ReadBytes()
+    in.Sequence = r.ReadUint32()
+    return in
+}
+
+// Encode writes the canonical encoding of the output:
+// Value (int64), LockingScript (bytes)
+func (out *TxOutput) Encode(w *codec.Writer) {
+    w.WriteInt(out.Value)
+    w.WriteBytes(out.LockingScript)
+}
+
+// DecodeTxOutput reads an output written by TxOutput.Encode
+func DecodeTxOutput(r *codec.Reader) TxOutput {
+    var out TxOutput
+    out.Value = r.ReadInt()
+    out.LockingScript = r.ReadBytes()
+    return out
+}
+
+// Serialize returns the canonical encoding of the transaction
+func (tx *Transaction) Serialize() []byte {
+    w := codec.NewWriter()
+    tx.Encode(w)
+    return w.Bytes()
+}
+
+// DeserializeTransaction decodes a transaction produced by Serialize
+func DeserializeTransaction(data []byte) (*Transaction, error) {
+    r := codec.NewReader(data)
+    tx := DecodeTransaction(r)
+    if err := r.Finish(); err != nil {
+        return nil, fmt.Errorf("failed to decode transaction: %v", err)
+    }
+    return tx, nil
 }
@@ -0,0 +1,100 @@
+package transaction
+
+import (
+    "crypto/sha256"
+    "sync"
+    "sync/atomic"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/codec"
+)
+
+// DefaultSigCacheSize is the number of signatures a blockchain remembers as verified
+const DefaultSigCacheSize = 50000
+
+// SigCache remembers signatures that verified, so a transaction checked when it is
+// sent is not checked again when its block is validated. An entry is keyed by the
+// signature hash, which commits to the transaction, the input, the spent output's
+// locking script, the chain and the hash type, together with the signature and the
+// public key. The cache holds at most a fixed number of entries and evicts a random
+// one when full. It is safe for concurrent use; a nil cache remembers nothing.
+type SigCache struct {
+    mu         sync.RWMutex
+    entries    map[[32]byte]struct{}
+    maxEntries int
+    hits       atomic.Uint64 // Lookups that found their signature
+}
+
+// NewSigCache returns an empty cache holding at most maxEntries signatures
+func NewSigCache(maxEntries int) *SigCache {
+    return &SigCache{
+        entries:    make(map[[32]byte]struct{}, maxEntries),
+        maxEntries: maxEntries,
+    }
+}
+
+// Exists reports whether sig by pubKey over sigHash was added to the cache
+func (c *SigCache) Exists(sigHash, sig, pubKey []byte) bool {
+    if c == nil {
+        return false
+    }
+    key := sigCacheKey(sigHash, sig, pubKey)
+
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    _, ok := c.entries[key]
+    if ok {
+        c.hits.Add(1)
+    }
+    return ok
+}
+
+// Add records that sig by pubKey over sigHash is valid, evicting a random entry if
+// the cache is full
+func (c *SigCache) Add(sigHash, sig, pubKey []byte) {
+    if c == nil || c.maxEntries <= 0 {
+        return
+    }
+    key := sigCacheKey(sigHash, sig, pubKey)
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if _, ok := c.entries[key]; ok {
+        return
+    }
+    if len(c.entries) >= c.maxEntries {
+        // Map iteration order is randomized, so this evicts an arbitrary entry
+        for evict := range c.entries {
+            delete(c.entries, evict)
+            break
+        }
+    }
+    c.entries[key] = struct{}{}
+}
+
+// Len returns the number of signatures in the cache
+func (c *SigCache) Len() int {
+    if c == nil {
+        return 0
+    }
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return len(c.entries)
+}
+
+// Hits returns the number of times Exists found a signature, each a verification
+// saved
+func (c *SigCache) Hits() uint64 {
+    if c == nil {
+        return 0
+    }
+    return c.hits.Load()
+}
+
+// sigCacheKey returns the SHA-256 of sigHash, sig and pubKey as byte strings
+func sigCacheKey(sigHash, sig, pubKey []byte) [32]byte {
+    w := codec.NewWriter()
+    w.WriteBytes(sigHash)
+    w.WriteBytes(sig)
+    w.WriteBytes(pubKey)
+    return sha256.Sum256(w.Bytes())
+}
@@ -0,0 +1,64 @@
+package transaction
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Test that the signature cache remembers verified signatures, holds at most its
+// size and does not let a modified transaction through
+func TestSigCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cache := NewSigCache(3)
+	for i := 0; i < 5; i++ {
+		cache.Add([]byte{byte(i)}, []byte("sig"), []byte("key"))
+	}
+	if cache.Len() != 3 {
+		t.Errorf("Expected the cache to hold 3 signatures, got %d", cache.Len())
+	}
+	if cache.Exists([]byte{4}, []byte("sig"), []byte("other key")) {
+		t.Error("Expected a signature by another key not to be found")
+	}
+
+	tx, prevTXs := manyInputTx(t, 4)
+	cache = NewSigCache(DefaultSigCacheSize)
+	if valid, err := tx.VerifyWithCache(prevTXs, testChainID, cache); !valid {
+		t.Fatalf("Expected the transaction to verify: %v", err)
+	}
+	if cache.Len() != len(tx.Vin) {
+		t.Fatalf("Expected %d cached signatures, got %d", len(tx.Vin), cache.Len())
+	}
+	if valid, err := tx.VerifyWithCache(prevTXs, testChainID, cache); !valid || cache.Len() != len(tx.Vin) {
+		t.Fatalf("Expected the transaction to verify from the cache: %v", err)
+	}
+	if cache.Hits() != uint64(len(tx.Vin)) {
+		t.Fatalf("Expected %d cache hits, got %d", len(tx.Vin), cache.Hits())
+	}
+
+	// The cached signatures are over the original outputs and chain
+	tampered := *tx
+	tampered.Vout = []TxOutput{NewTxOutput(4, []byte("thief"))}
+	tampered.ID = tampered.Hash()
+	if valid, _ := tampered.VerifyWithCache(prevTXs, testChainID, cache); valid {
+		t.Error("Expected a transaction with changed outputs to be rejected")
+	}
+	otherChain := NewChainID([]byte("other genesis"), "mainnet")
+	if valid, _ := tx.VerifyWithCache(prevTXs, otherChain, cache); valid {
+		t.Error("Expected the transaction to be rejected on another chain")
+	}
+}
+
+// Benchmark verifying every input of transactions with many inputs
+func BenchmarkVerifyManyInputs(b *testing.B) {
+	b.Setenv("HOME", b.TempDir())
+	for _, n := range []int{10, 100, 500} {
+		tx, prevTXs := manyInputTx(b, n)
+		b.Run(fmt.Sprintf("inputs=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if valid, err := tx.Verify(prevTXs, testChainID); !valid {
+					b.Fatalf("Transaction was rejected: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package transaction
+
+import (
+    "crypto/sha256"
+    "fmt"
+    "strings"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/codec"
+    "github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// SigHashType selects the parts of a transaction a signature commits to. It is
+// appended to every signature as a single byte.
+type SigHashType byte
+
+const (
+    SigHashAll          SigHashType = 0x01 // Sign every input and output
+    SigHashNone         SigHashType = 0x02 // Sign every input but no outputs, which anyone may change
+    SigHashSingle       SigHashType = 0x03 // Sign every input and only the output at the same index
+    SigHashAnyOneCanPay SigHashType = 0x80 // Combined with one of the above, sign only the own input so others can add theirs
+
+    sigHashBaseMask = 0x1f
+)
+
+// sigHashNames maps the base types to their names
+var sigHashNames = map[SigHashType]string{
+    SigHashAll:    "ALL",
+    SigHashNone:   "NONE",
+    SigHashSingle: "SINGLE",
+}
+
+// base returns the type without the ANYONECANPAY flag
+func (t SigHashType) base() SigHashType {
+    return t & sigHashBaseMask
+}
+
+// IsValid reports whether t is ALL, NONE or SINGLE, optionally with ANYONECANPAY
+func (t SigHashType) IsValid() bool {
+    _, ok := sigHashNames[t.base()]
+    return ok && t&^(SigHashAnyOneCanPay|sigHashBaseMask) == 0
+}
+
+// String returns the name of the type, like ALL or SINGLE|ANYONECANPAY
+func (t SigHashType) String() string {
+    if !t.IsValid() {
+        return fmt.Sprintf("0x%02x", byte(t))
+    }
+    name := sigHashNames[t.base()]
+    if t&SigHashAnyOneCanPay != 0 {
+        name += "|ANYONECANPAY"
+    }
+    return name
+}
+
+// ParseSigHashType parses a type named as by String, case-insensitively
+func ParseSigHashType(s string) (SigHashType, error) {
+    parts := strings.Split(strings.ToUpper(s), "|")
+    for t, name := range sigHashNames {
+        if parts[0] != name {
+            continue
+        }
+        switch {
+        case len(parts) == 1:
+            return t, nil
+        case len(parts) == 2 && parts[1] == "ANYONECANPAY":
+            return t | SigHashAnyOneCanPay, nil
+        }
+    }
+    return 0, fmt.Errorf("unknown signature hash type %q, expected ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY", s)
+}
+
+// splitSignature separates the signature hash type from the end of a signature
+func splitSignature(sig []byte) ([]byte, SigHashType, bool) {
+    if len(sig) == 0 {
+        return nil, 0, false
+    }
+    hashType := SigHashType(sig[len(sig)-1])
+    if !hashType.IsValid() {
+        return nil, 0, false
+    }
+    return sig[:len(sig)-1], hashType, true
+}
+
+// SigHashes holds the hashes of the parts of a transaction that the signature hashes
+// of all its inputs share, so that computing the signature hash of one input takes
+// work independent of the number of inputs and outputs. They stay valid while only
+// the unlocking scripts of the transaction change, as they do during signing.
+type SigHashes struct {
+    tx        *Transaction
+    prevouts  [32]byte // Outpoints of all inputs
+    sequences [32]byte // Sequences of all inputs
+    outputs   [32]byte // All outputs
+}
+
+// NewSigHashes computes the shared signature hash parts of tx
+func NewSigHashes(tx *Transaction) *SigHashes {
+    h := &SigHashes{tx: tx}
+
+    w := codec.NewWriter()
+    for i := range tx.Vin {
+        w.WriteBytes(tx.Vin[i].Txid)
+        w.WriteInt32(int32(tx.Vin[i].Vout))
+    }
+    h.prevouts = sha256.Sum256(w.Bytes())
+
+    w = codec.NewWriter()
+    for i := range tx.Vin {
+        w.WriteUint32(tx.Vin[i].Sequence)
+    }
+    h.sequences = sha256.Sum256(w.Bytes())
+
+    w = codec.NewWriter()
+    for i := range tx.Vout {
+        tx.Vout[i].Encode(w)
+    }
+    h.outputs = sha256.Sum256(w.Bytes())
+    return h
+}
+
+// SignatureHash returns the hash signed for input inIdx on chain chainID. It computes
+// the shared parts for this input alone; use NewSigHashes to hash several inputs.
+func (tx *Transaction) SignatureHash(inIdx int, lockingScript []byte, chainID ChainID, hashType SigHashType) ([]byte, error) {
+    return NewSigHashes(tx).SignatureHash(inIdx, lockingScript, chainID, hashType)
+}
+
+// SignatureHash returns the hash signed for input inIdx, which spends an output locked
+// with lockingScript, on chain chainID: the SHA-256 of chainID, the hash of all
+// outpoints, the hash of all sequences, the input's outpoint, lockingScript, the
+// input's sequence, the hash of the signed outputs, the lock time and hashType as a
+// uint32, encoded with the codec.
+//
+// NONE signs no outputs and SINGLE only the output at inIdx. Both leave out the
+// sequences of the other inputs so their owners can update them. ANYONECANPAY leaves
+// out the other inputs altogether. Parts left out are hashed as zeros.
+func (h *SigHashes) SignatureHash(inIdx int, lockingScript []byte, chainID ChainID, hashType SigHashType) ([]byte, error) {
+    if !hashType.IsValid() {
+        return nil, fmt.Errorf("invalid signature hash type 0x%02x", byte(hashType))
+    }
+    tx := h.tx
+    if inIdx < 0 || inIdx >= len(tx.Vin) {
+        return nil, fmt.Errorf("input %d does not exist", inIdx)
+    }
+
+    var prevouts, sequences, outputs [32]byte
+    if hashType&SigHashAnyOneCanPay == 0 {
+        prevouts = h.prevouts
+        if hashType.base() == SigHashAll {
+            sequences = h.sequences
+        }
+    }
+    switch hashType.base() {
+    case SigHashAll:
+        outputs = h.outputs
+    case SigHashSingle:
+        if inIdx >= len(tx.Vout) {
+            return nil, fmt.Errorf("input %d has no output at the same index to sign with SINGLE", inIdx)
+        }
+        w := codec.NewWriter()
+        tx.Vout[inIdx].Encode(w)
+        outputs = sha256.Sum256(w.Bytes())
+    }
+
+    in := &tx.Vin[inIdx]
+    w := codec.NewWriter()
+    w.WriteBytes(chainID[:])
+    w.WriteBytes(prevouts[:])
+    w.WriteBytes(sequences[:])
+    w.WriteBytes(in.Txid)
+    w.WriteInt32(int32(in.Vout))
+    w.WriteBytes(lockingScript)
+    w.WriteUint32(in.Sequence)
+    w.WriteBytes(outputs[:])
+    w.WriteInt64(tx.LockTime)
+    w.WriteUint32(uint32(hashType))
+
+    hash := sha256.Sum256(w.Bytes())
+    return hash[:], nil
+}
+
+// signatureFor signs input inIdx, which spends an output locked with lockingScript,
+// for chain chainID and returns the signature followed by hashType
+func (h *SigHashes) signatureFor(inIdx int, w *wallet.Wallet, lockingScript []byte, chainID ChainID, hashType SigHashType) ([]byte, error) {
+    hash, err := h.SignatureHash(inIdx, lockingScript, chainID, hashType)
+    if err != nil {
+        return nil, err
+    }
+    signature, err := w.SignData(hash)
+    if err != nil {
+        return nil, fmt.Errorf("failed to sign input %d: %v", inIdx, err)
+    }
+    return append(signature, byte(hashType)), nil
+}
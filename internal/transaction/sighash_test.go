@@ -0,0 +1,130 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// Test each signature hash type commits to the inputs and outputs it names, so the
+// others can change without invalidating the signature
+func TestSigHashTypes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	alice, bob := wallet.NewWallet(), wallet.NewWallet()
+	prev := Transaction{Vout: []TxOutput{
+		NewTxOutput(30, wallet.HashPubKey(alice.PublicKey)),
+		NewTxOutput(20, wallet.HashPubKey(bob.PublicKey)),
+	}}
+	prev.ID = prev.Hash()
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): prev}
+
+	// newTx returns a transaction spending Alice's output with two outputs
+	newTx := func() *Transaction {
+		tx := &Transaction{
+			Vin: []TxInput{{Txid: prev.ID, Vout: 0}},
+			Vout: []TxOutput{
+				NewTxOutput(25, wallet.HashPubKey([]byte("project"))),
+				NewTxOutput(5, wallet.HashPubKey(alice.PublicKey)),
+			},
+		}
+		tx.ID = tx.Hash()
+		return tx
+	}
+	addInput := func(tx *Transaction) {
+		tx.Vin = append(tx.Vin, TxInput{Txid: prev.ID, Vout: 1})
+		if err := tx.SignInput(1, bob, prev.Vout[1], testChainID, SigHashAll|SigHashAnyOneCanPay); err != nil {
+			t.Fatalf("Failed to sign the added input: %v", err)
+		}
+	}
+	changeFirstOutput := func(tx *Transaction) { tx.Vout[0].Value = 24 }
+	changeSecondOutput := func(tx *Transaction) { tx.Vout[1].Value = 4 }
+
+	tests := []struct {
+		name     string
+		hashType SigHashType
+		change   func(*Transaction)
+		valid    bool
+	}{
+		{"ALL unchanged", SigHashAll, func(*Transaction) {}, true},
+		{"ALL changed output", SigHashAll, changeSecondOutput, false},
+		{"ALL added input", SigHashAll, addInput, false},
+		{"ALL|ANYONECANPAY added input", SigHashAll | SigHashAnyOneCanPay, addInput, true},
+		{"ALL|ANYONECANPAY changed output", SigHashAll | SigHashAnyOneCanPay, changeFirstOutput, false},
+		{"NONE changed output", SigHashNone, changeFirstOutput, true},
+		{"NONE added input", SigHashNone, addInput, false},
+		{"NONE|ANYONECANPAY added input", SigHashNone | SigHashAnyOneCanPay, addInput, true},
+		{"SINGLE changed other output", SigHashSingle, changeSecondOutput, true},
+		{"SINGLE changed own output", SigHashSingle, changeFirstOutput, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := newTx()
+			if err := tx.SignInput(0, alice, prev.Vout[0], testChainID, tt.hashType); err != nil {
+				t.Fatalf("Failed to sign: %v", err)
+			}
+			tt.change(tx)
+			valid, err := tx.Verify(prevTXs, testChainID)
+			if valid != tt.valid {
+				t.Errorf("Expected valid %v, got %v (%v)", tt.valid, valid, err)
+			}
+		})
+	}
+
+	// NONE and SINGLE let the owners of the other inputs update their sequences
+	for _, hashType := range []SigHashType{SigHashNone, SigHashSingle} {
+		tx := newTx()
+		addInput(tx)
+		if err := tx.SignInput(0, alice, prev.Vout[0], testChainID, hashType); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		tx.Vin[1].Sequence = 7
+		if err := tx.SignInput(1, bob, prev.Vout[1], testChainID, SigHashAll|SigHashAnyOneCanPay); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		if valid, err := tx.Verify(prevTXs, testChainID); !valid {
+			t.Errorf("%s signature was invalidated by another input's sequence: %v", hashType, err)
+		}
+	}
+
+	// SINGLE needs an output at the index of the input
+	tx := newTx()
+	tx.Vout = tx.Vout[:0]
+	if err := tx.SignInput(0, alice, prev.Vout[0], testChainID, SigHashSingle); err == nil {
+		t.Error("Signed SINGLE without a matching output")
+	}
+
+	// Signatures with an unknown type byte are rejected
+	tx = newTx()
+	if err := tx.SignInput(0, alice, prev.Vout[0], testChainID, SigHashAll); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	pushes, _ := script.PushedData(tx.Vin[0].UnlockingScript)
+	pushes[0][len(pushes[0])-1] = 0x04
+	tx.Vin[0].UnlockingScript = script.PubKeyHashUnlockingScript(pushes[0], pushes[1])
+	if valid, _ := tx.Verify(prevTXs, testChainID); valid {
+		t.Error("Accepted a signature with an unknown hash type")
+	}
+}
+
+// Benchmark computing the signature hash of every input of transactions with many
+// inputs
+func BenchmarkSignatureHashManyInputs(b *testing.B) {
+	b.Setenv("HOME", b.TempDir())
+	for _, n := range []int{10, 100, 500} {
+		tx, prevTXs := manyInputTx(b, n)
+		prevOut := prevTXs[hex.EncodeToString(tx.Vin[0].Txid)].Vout[0]
+		b.Run(fmt.Sprintf("inputs=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				hashes := NewSigHashes(tx)
+				for inIdx := range tx.Vin {
+					if _, err := hashes.SignatureHash(inIdx, prevOut.LockingScript, testChainID, SigHashAll); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
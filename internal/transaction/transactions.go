@@ -4,63 +4,103 @@ import (
     "bytes"
     "crypto/rand"
     "crypto/sha256"
-    "encoding/gob"
+    "encoding/binary"
     "encoding/hex"
     "fmt"
     "log"
+    "math"
     
+    "github.com/OmSingh2003/decentralized-ledger/internal/script"
     "github.com/OmSingh2003/decentralized-ledger/internal/wallet"
 )
 
-// Rest of the file content remains the same...
+// Subsidy is the number of coins a coinbase transaction may create besides collected fees
+const Subsidy = 50
+
+// DustThreshold is the smallest value of a spendable output that nodes relay by
+// default. Smaller outputs cost more to keep and spend than they are worth, so the
+// wallet leaves change below it to the fee instead.
+const DustThreshold = 3
+
+// LockTimeThreshold separates the two meanings of LockTime: below it the lock time is
+// a block height, from it on a Unix timestamp
+const LockTimeThreshold = 500000000
+
+// Relative lock time encoding of TxInput.Sequence, modelled on BIP 68. Unless the
+// disable flag is set, the low bits give the number of blocks, or of 512 second units
+// if the seconds flag is set, that must pass after the spent output was confirmed.
+const (
+    SequenceLockTimeDisabled    = 1 << 31 // Sequence carries no relative lock time
+    SequenceLockTimeIsSeconds   = 1 << 22 // Lock time counts 512 second units instead of blocks
+    SequenceLockTimeMask        = 0xffff  // Bits holding the lock time value
+    SequenceLockTimeGranularity = 9       // Seconds units are 1 << 9 seconds
+)
 
 // Transaction represents a blockchain transaction
 type Transaction struct {
-    ID   []byte
-    Vin  []TxInput
-    Vout []TxOutput
+    ID       []byte
+    Vin      []TxInput
+    Vout     []TxOutput
+    LockTime int64 // Block height, or Unix time if at least LockTimeThreshold, before which the transaction cannot be included; 0 for none
 }
 
 // TxInput represents a transaction input
 type TxInput struct {
-    Txid      []byte // The ID of the transaction containing the output to spend
-    Vout      int    // The index of the output in the transaction
-    Signature []byte // The digital signature that proves ownership
-    PubKey    []byte // The public key of the sender
+    Txid            []byte // The ID of the transaction containing the output to spend
+    Vout            int    // The index of the output in the transaction
+    UnlockingScript []byte // Script satisfying the locking script of the spent output
+    Sequence        uint32 // Relative lock time, see SequenceLockTimeDisabled; 0 for none
 }
 
 // TxOutput represents a transaction output
 type TxOutput struct {
-    Value      int    // The amount of coins
-    PubKeyHash []byte // The hash of the public key (address) of the recipient
+    Value         int    // The amount of coins
+    LockingScript []byte // Script that must be satisfied to spend the output
 }
 
-// Hash returns the hash of the Transaction
+// NewTxOutput creates an output paying value to the key with the given hash
+func NewTxOutput(value int, pubKeyHash []byte) TxOutput {
+    return TxOutput{
+        Value:         value,
+        LockingScript: script.PayToPubKeyHash(pubKeyHash),
+    }
+}
+
+// Hash returns the hash of the Transaction, which is its ID.
+// The ID is set before the inputs are signed, so unlocking scripts are not covered,
+// except for the coinbase, whose input data commits to the block height. Changing a
+// signature therefore keeps the ID, so transactions spending unconfirmed outputs
+// stay valid.
 func (tx *Transaction) Hash() []byte {
     var hash [32]byte
     txCopy := *tx
     txCopy.ID = []byte{}
-    
-    encoded, err := serializeTransaction(txCopy)
-    if err != nil {
-        log.Panic(err)
+    if !tx.IsCoinbase() {
+        txCopy.Vin = make([]TxInput, len(tx.Vin))
+        for i, vin := range tx.Vin {
+            vin.UnlockingScript = nil
+            txCopy.Vin[i] = vin
+        }
     }
-    
-    hash = sha256.Sum256(encoded)
+
+    hash = sha256.Sum256(txCopy.Serialize())
     return hash[:]
 }
 
-// serializeTransaction serializes a transaction
-func serializeTransaction(tx Transaction) ([]byte, error) {
-    var encoded bytes.Buffer
-    enc := gob.NewEncoder(&encoded)
-    
-    err := enc.Encode(tx)
-    if err != nil {
-        return nil, fmt.Errorf("failed to encode transaction: %v", err)
-    }
-    
-    return encoded.Bytes(), nil
+// WitnessHash returns the hash of the Transaction including its unlocking scripts: the
+// SHA-256 of its encoding with an empty ID. Blocks commit to it as well as to the ID,
+// so the signatures of included transactions cannot be altered. The witness hash of a
+// coinbase equals its ID.
+func (tx *Transaction) WitnessHash() []byte {
+    txCopy := *tx
+    txCopy.ID = []byte{}
+    hash := sha256.Sum256(txCopy.Serialize())
+    return hash[:]
+}
+
+// HasValidID reports whether the transaction ID equals the recomputed hash
+func (tx *Transaction) HasValidID() bool {
+    return bytes.Equal(tx.ID, tx.Hash())
 }
 
 // IsCoinbase checks whether the transaction is coinbase
@@ -68,114 +108,219 @@ func (tx *Transaction) IsCoinbase() bool {
     return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
+// IsFinal reports whether the transaction's lock time allows it in a block at the given
+// height whose median time past is medianTime. A height lock time is reached at that
+// height, a time lock time once the median time past is at least the lock time.
+func (tx *Transaction) IsFinal(height, medianTime int64) bool {
+    if tx.LockTime == 0 {
+        return true
+    }
+    if tx.LockTime < LockTimeThreshold {
+        return height >= tx.LockTime
+    }
+    return medianTime >= tx.LockTime
+}
+
+// RelativeLock returns how long after the spent output was confirmed the input may be
+// included in a block: a number of blocks, or of seconds if isSeconds. The boolean is
+// false if the sequence carries no relative lock time.
+func (in *TxInput) RelativeLock() (value int64, isSeconds bool, ok bool) {
+    if in.Sequence&SequenceLockTimeDisabled != 0 {
+        return 0, false, false
+    }
+    value = int64(in.Sequence & SequenceLockTimeMask)
+    if in.Sequence&SequenceLockTimeIsSeconds != 0 {
+        return value << SequenceLockTimeGranularity, true, true
+    }
+    return value, false, true
+}
+
 // TrimmedCopy creates a trimmed copy of Transaction to be used in signing
 func (tx *Transaction) TrimmedCopy() Transaction {
     var inputs []TxInput
     var outputs []TxOutput
 
     for _, vin := range tx.Vin {
-        inputs = append(inputs, TxInput{vin.Txid, vin.Vout, nil, nil})
+        inputs = append(inputs, TxInput{vin.Txid, vin.Vout, nil, vin.Sequence})
     }
 
     for _, vout := range tx.Vout {
-        outputs = append(outputs, TxOutput{vout.Value, vout.PubKeyHash})
+        outputs = append(outputs, TxOutput{vout.Value, vout.LockingScript})
     }
 
-    txCopy := Transaction{tx.ID, inputs, outputs}
+    txCopy := Transaction{tx.ID, inputs, outputs, tx.LockTime}
     return txCopy
 }
 
-// Sign signs each input of a Transaction
-func (tx *Transaction) Sign(walletInstance *wallet.Wallet, prevTXs map[string]Transaction) error {
+// sigChecker checks signatures in the scripts of one input against its signature hash
+// on chain chainID, and lock times against the input's sequence. Signatures found in
+// sigCache, which may be nil, are not checked again.
+type sigChecker struct {
+    tx            *Transaction
+    hashes        *SigHashes
+    inIdx         int
+    lockingScript []byte
+    chainID       ChainID
+    sigCache      *SigCache
+}
+
+// CheckSig reports whether sig is a valid signature of the input by pubKey, over the
+// signature hash selected by the type byte it ends with
+func (c sigChecker) CheckSig(sig, pubKey []byte) bool {
+    sig, hashType, ok := splitSignature(sig)
+    if !ok {
+        return false
+    }
+    hash, err := c.hashes.SignatureHash(c.inIdx, c.lockingScript, c.chainID, hashType)
+    if err != nil {
+        return false
+    }
+    if c.sigCache.Exists(hash, sig, pubKey) {
+        return true
+    }
+    if !wallet.VerifySignature(pubKey, hash, sig) {
+        return false
+    }
+    c.sigCache.Add(hash, sig, pubKey)
+    return true
+}
+
+// CheckLockTime reports whether the transaction's lock time is of the same kind as
+// lockTime, height or Unix time, and at least as late. Blocks only include the
+// transaction once its own lock time has been reached.
+func (c sigChecker) CheckLockTime(lockTime int64) bool {
+    if (lockTime < LockTimeThreshold) != (c.tx.LockTime < LockTimeThreshold) {
+        return false
+    }
+    return lockTime <= c.tx.LockTime
+}
+
+// CheckSequence reports whether the input's sequence carries a relative lock time of
+// the same kind as sequence and at least as long. A sequence with the disable flag
+// set requires nothing.
+func (c sigChecker) CheckSequence(sequence int64) bool {
+    if sequence&SequenceLockTimeDisabled != 0 {
+        return true
+    }
+    txSequence := int64(c.tx.Vin[c.inIdx].Sequence)
+    if txSequence&SequenceLockTimeDisabled != 0 {
+        return false
+    }
+
+    const typeAndValue = SequenceLockTimeIsSeconds | SequenceLockTimeMask
+    sequence &= typeAndValue
+    txSequence &= typeAndValue
+    if (sequence < SequenceLockTimeIsSeconds) != (txSequence < SequenceLockTimeIsSeconds) {
+        return false
+    }
+    return sequence <= txSequence
+}
+
+// prevOutput returns the output spent by vin
+func prevOutput(prevTXs map[string]Transaction, vin TxInput) (TxOutput, error) {
+    txID := hex.EncodeToString(vin.Txid)
+    prevTx, exists := prevTXs[txID]
+    if !exists {
+        return TxOutput{}, fmt.Errorf("referenced input transaction not found: %s", txID)
+    }
+    if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+        return TxOutput{}, fmt.Errorf("referenced output %d of transaction %s does not exist", vin.Vout, txID)
+    }
+    return prevTx.Vout[vin.Vout], nil
+}
+
+// Sign signs each input of a Transaction with SigHashAll for chain chainID. Every
+// input must spend a pay-to-pubkey-hash output locked to the wallet's key.
+func (tx *Transaction) Sign(walletInstance *wallet.Wallet, prevTXs map[string]Transaction, chainID ChainID) error {
+    return tx.SignWithHashType(walletInstance, prevTXs, chainID, SigHashAll)
+}
+
+// SignWithHashType is like Sign, committing each signature to the parts of the
+// transaction selected by hashType
+func (tx *Transaction) SignWithHashType(walletInstance *wallet.Wallet, prevTXs map[string]Transaction, chainID ChainID, hashType SigHashType) error {
     if tx.IsCoinbase() {
         return nil
     }
 
-    // Verify all referenced inputs exist in prevTXs
-    for _, vin := range tx.Vin {
-        if len(vin.Txid) == 0 {
-            continue // Skip coinbase
+    hashes := NewSigHashes(tx)
+    for inID, vin := range tx.Vin {
+        prevOut, err := prevOutput(prevTXs, vin)
+        if err != nil {
+            return err
         }
-        
-        txID := hex.EncodeToString(vin.Txid)
-        _, exists := prevTXs[txID]
-        if !exists {
-            return fmt.Errorf("referenced input transaction not found: %s", txID)
+        if err := tx.signInput(hashes, inID, walletInstance, prevOut, chainID, hashType); err != nil {
+            return err
         }
     }
 
-    txCopy := tx.TrimmedCopy()
+    return nil
+}
 
-    for inID, vin := range txCopy.Vin {
-        if len(vin.Txid) == 0 {
-            continue // Skip coinbase
-        }
-        
-        txID := hex.EncodeToString(vin.Txid)
-        prevTx := prevTXs[txID]
-        txCopy.Vin[inID].Signature = nil
-        txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-        txCopy.ID = txCopy.Hash()
-        txCopy.Vin[inID].PubKey = nil
-
-        // Use wallet's SignData function for signing
-        signature, err := walletInstance.SignData(txCopy.ID)
-        if err != nil {
-            return fmt.Errorf("failed to sign transaction input: %v", err)
-        }
-        
-        tx.Vin[inID].Signature = signature
+// SignInput signs input inIdx, which spends the pay-to-pubkey-hash output prevOut
+// locked to the wallet's key, for chain chainID. Signing only the own inputs with ANYONECANPAY lets
+// several wallets fund one transaction.
+func (tx *Transaction) SignInput(inIdx int, walletInstance *wallet.Wallet, prevOut TxOutput, chainID ChainID, hashType SigHashType) error {
+    return tx.signInput(NewSigHashes(tx), inIdx, walletInstance, prevOut, chainID, hashType)
+}
+
+// signInput is SignInput with the shared signature hash parts of tx already computed
+func (tx *Transaction) signInput(hashes *SigHashes, inIdx int, walletInstance *wallet.Wallet, prevOut TxOutput, chainID ChainID, hashType SigHashType) error {
+    lockedTo := script.ExtractPubKeyHash(prevOut.LockingScript)
+    if lockedTo == nil {
+        return fmt.Errorf("input %d spends a %s output, which cannot be signed with a single key",
+            inIdx, script.GetScriptClass(prevOut.LockingScript))
+    }
+    if !bytes.Equal(lockedTo, wallet.HashPubKey(walletInstance.PublicKey)) {
+        return fmt.Errorf("input %d spends an output that is not locked to this wallet", inIdx)
     }
 
+    signature, err := hashes.signatureFor(inIdx, walletInstance, prevOut.LockingScript, chainID, hashType)
+    if err != nil {
+        return err
+    }
+    tx.Vin[inIdx].UnlockingScript = script.PubKeyHashUnlockingScript(signature, walletInstance.PublicKey)
     return nil
 }
 
-// Verify verifies signatures of Transaction inputs
-func (tx *Transaction) Verify(prevTXs map[string]Transaction) (bool, error) {
+// Verify runs the unlocking script of each input against the locking script of the
+// output it spends. Signatures must have been made for chain chainID.
+func (tx *Transaction) Verify(prevTXs map[string]Transaction, chainID ChainID) (bool, error) {
+    return tx.VerifyWithCache(prevTXs, chainID, nil)
+}
+
+// VerifyWithCache is like Verify, skipping signatures found in sigCache and adding the
+// ones it checks. sigCache may be nil.
+func (tx *Transaction) VerifyWithCache(prevTXs map[string]Transaction, chainID ChainID, sigCache *SigCache) (bool, error) {
     if tx.IsCoinbase() {
         return true, nil
     }
 
-    // Verify all referenced inputs exist in prevTXs
-    for _, vin := range tx.Vin {
-        if len(vin.Txid) == 0 {
-            continue // Skip coinbase
-        }
-        
-        txID := hex.EncodeToString(vin.Txid)
-        _, exists := prevTXs[txID]
-        if !exists {
-            return false, fmt.Errorf("referenced input transaction not found: %s", txID)
-        }
-    }
-
-    txCopy := tx.TrimmedCopy()
-
+    hashes := NewSigHashes(tx)
     for inID, vin := range tx.Vin {
-        if len(vin.Txid) == 0 {
-            continue // Skip coinbase
+        prevOut, err := prevOutput(prevTXs, vin)
+        if err != nil {
+            return false, err
         }
-        
-        txID := hex.EncodeToString(vin.Txid)
-        prevTx := prevTXs[txID]
-        txCopy.Vin[inID].Signature = nil
-        txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-        txCopy.ID = txCopy.Hash()
-        txCopy.Vin[inID].PubKey = nil
-
-        if !wallet.VerifySignature(vin.PubKey, txCopy.ID, vin.Signature) {
-            return false, nil
+
+        checker := sigChecker{tx: tx, hashes: hashes, inIdx: inID, lockingScript: prevOut.LockingScript, chainID: chainID, sigCache: sigCache}
+        if err := script.Execute(vin.UnlockingScript, prevOut.LockingScript, checker); err != nil {
+            return false, fmt.Errorf("input %d: %v", inID, err)
         }
     }
 
     return true, nil
 }
 
-// ValidateTransaction validates a transaction
-func (tx *Transaction) ValidateTransaction(prevTXs map[string]Transaction) error {
+// ValidateTransaction validates a transaction whose signatures are for chain chainID,
+// consulting sigCache, which may be nil, for signatures already checked
+func (tx *Transaction) ValidateTransaction(prevTXs map[string]Transaction, chainID ChainID, sigCache *SigCache) error {
     if len(tx.ID) == 0 {
         return fmt.Errorf("transaction ID cannot be empty")
     }
+
+    if !tx.HasValidID() {
+        return fmt.Errorf("transaction ID %x does not match its hash", tx.ID)
+    }
     
     if len(tx.Vin) == 0 {
         return fmt.Errorf("transaction must have at least one input")
@@ -187,7 +332,7 @@ func (tx *Transaction) ValidateTransaction(prevTXs map[string]Transaction) error
     
     // Verify signatures if not a coinbase transaction
     if !tx.IsCoinbase() {
-        valid, err := tx.Verify(prevTXs)
+        valid, err := tx.VerifyWithCache(prevTXs, chainID, sigCache)
         if err != nil {
             return fmt.Errorf("signature verification error: %v", err)
         }
@@ -199,19 +344,35 @@ func (tx *Transaction) ValidateTransaction(prevTXs map[string]Transaction) error
     return nil
 }
 
-// UsesKey checks whether the input uses the specified public key hash
-func (in *TxInput) UsesKey(pubKeyHash []byte) bool {
-    lockingHash := wallet.HashPubKey(in.PubKey)
-    return bytes.Compare(lockingHash, pubKeyHash) == 0
+// IsLockedWithKey checks if the output is a pay-to-pubkey-hash output locked with the
+// specified public key hash
+func (out *TxOutput) IsLockedWithKey(pubKeyHash []byte) bool {
+    lockedTo := script.ExtractPubKeyHash(out.LockingScript)
+    return lockedTo != nil && bytes.Equal(lockedTo, pubKeyHash)
 }
 
-// IsLockedWithKey checks if the output is locked with the specified public key hash
-func (out *TxOutput) IsLockedWithKey(pubKeyHash []byte) bool {
-    return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
+// IsUnspendable reports whether the output can never be spent, like a null data
+// output. Such outputs are not added to the UTXO set.
+func (out *TxOutput) IsUnspendable() bool {
+    return script.IsUnspendable(out.LockingScript)
+}
+
+// CoinbaseHeight returns the block height a coinbase transaction commits to.
+// The coinbase input data starts with the height as an 8-byte little-endian integer.
+func CoinbaseHeight(tx *Transaction) (int64, error) {
+    if !tx.IsCoinbase() {
+        return 0, fmt.Errorf("transaction %x is not a coinbase", tx.ID)
+    }
+    data := tx.Vin[0].UnlockingScript
+    if len(data) < 8 {
+        return 0, fmt.Errorf("coinbase %x does not commit to a height", tx.ID)
+    }
+    return int64(binary.LittleEndian.Uint64(data[:8])), nil
 }
 
-// NewCoinbaseTx creates a new coinbase transaction
-func NewCoinbaseTx(to []byte, data string) *Transaction {
+// NewCoinbaseTx creates a new coinbase transaction for the block at the given height.
+// Committing to the height makes every coinbase, and so its ID, unique.
+func NewCoinbaseTx(to []byte, data string, height int64) *Transaction {
     if data == "" {
         randData := make([]byte, 20)
         _, err := rand.Read(randData)
@@ -221,20 +382,20 @@ func NewCoinbaseTx(to []byte, data string) *Transaction {
         data = fmt.Sprintf("Reward to '%x'", randData)
     }
 
+    coinbaseData := make([]byte, 8, 8+len(data))
+    binary.LittleEndian.PutUint64(coinbaseData, uint64(height))
+    coinbaseData = append(coinbaseData, data...)
+
     txin := TxInput{
-        Txid:      []byte{},
-        Vout:      -1,
-        Signature: nil,
-        PubKey:    []byte(data),
+        Txid:            []byte{},
+        Vout:            -1,
+        UnlockingScript: coinbaseData,
     }
 
     // Ensure the pubKeyHash is derived properly from the public key
     pubKeyHash := wallet.HashPubKey(to)
 
-    txout := TxOutput{
-        Value:      50, // Mining reward
-        PubKeyHash: pubKeyHash,
-    }
+    txout := NewTxOutput(Subsidy, pubKeyHash) // Mining reward
 
     tx := &Transaction{
         ID:   []byte{},
@@ -247,14 +408,71 @@ func NewCoinbaseTx(to []byte, data string) *Transaction {
     return tx
 }
 
-// NewUTXOTransaction creates a new transaction
-func NewUTXOTransaction(w *wallet.Wallet, to []byte, amount int, findSpendableOutputs func([]byte, int) (int, map[string][]int, error)) (*Transaction, error) {
+// NewUTXOTransaction creates a new transaction paying amount from the wallet's outputs
+// to the locking script toScript and paying fee, with change back to the wallet. Change
+// below DustThreshold is added to the fee. Inputs are left unsigned.
+func NewUTXOTransaction(w *wallet.Wallet, toScript []byte, amount, fee int, findSpendableOutputs func([]byte, int) (int, map[string][]int, error)) (*Transaction, error) {
+    pubKeyHash := wallet.HashPubKey(w.PublicKey)
+    payment := []TxOutput{{Value: amount, LockingScript: toScript}}
+    return newPayment(pubKeyHash, script.PayToPubKeyHash(pubKeyHash), payment, fee, findSpendableOutputs)
+}
+
+// NewBatchTransaction creates a new transaction making every payment in outputs from
+// the wallet's outputs and paying fee, with one change output back to the wallet after
+// them. Inputs are left unsigned.
+func NewBatchTransaction(w *wallet.Wallet, outputs []TxOutput, fee int, findSpendableOutputs func([]byte, int) (int, map[string][]int, error)) (*Transaction, error) {
+    pubKeyHash := wallet.HashPubKey(w.PublicKey)
+    return NewBatchTransactionWithChange(w, outputs, script.PayToPubKeyHash(pubKeyHash), fee, findSpendableOutputs)
+}
+
+// NewBatchTransactionWithChange is like NewBatchTransaction, paying change to
+// changeScript instead, such as a change address of a deterministic wallet
+func NewBatchTransactionWithChange(w *wallet.Wallet, outputs []TxOutput, changeScript []byte, fee int, findSpendableOutputs func([]byte, int) (int, map[string][]int, error)) (*Transaction, error) {
+    if len(outputs) == 0 {
+        return nil, fmt.Errorf("no payments given")
+    }
+    return newPayment(wallet.HashPubKey(w.PublicKey), changeScript, outputs, fee, findSpendableOutputs)
+}
+
+// NewScriptTransaction creates a new transaction paying amount from outputs locked with
+// fromScript to toScript and paying fee, with change back to fromScript. Inputs are
+// left unsigned. findSpendableOutputs is given fromScript to select the outputs.
+func NewScriptTransaction(fromScript, toScript []byte, amount, fee int, findSpendableOutputs func([]byte, int) (int, map[string][]int, error)) (*Transaction, error) {
+    payment := []TxOutput{{Value: amount, LockingScript: toScript}}
+    return newPayment(fromScript, fromScript, payment, fee, findSpendableOutputs)
+}
+
+// NewScriptBatchTransaction is like NewBatchTransaction for outputs locked with
+// fromScript, with change back to fromScript
+func NewScriptBatchTransaction(fromScript []byte, outputs []TxOutput, fee int, findSpendableOutputs func([]byte, int) (int, map[string][]int, error)) (*Transaction, error) {
+    if len(outputs) == 0 {
+        return nil, fmt.Errorf("no payments given")
+    }
+    return newPayment(fromScript, fromScript, outputs, fee, findSpendableOutputs)
+}
+
+// newPayment creates an unsigned transaction spending the outputs selected by
+// findSpendableOutputs for owner, making the payments, paying fee and paying change to
+// changeScript unless it is dust, which goes to the fee
+func newPayment(owner, changeScript []byte, payments []TxOutput, fee int, findSpendableOutputs func([]byte, int) (int, map[string][]int, error)) (*Transaction, error) {
     var inputs []TxInput
     var outputs []TxOutput
 
-    pubKeyHash := wallet.HashPubKey(w.PublicKey)
+    if fee < 0 {
+        return nil, fmt.Errorf("fee cannot be negative")
+    }
+    amount := fee
+    for i, payment := range payments {
+        if payment.Value <= 0 {
+            return nil, fmt.Errorf("payment %d has non-positive amount %d", i, payment.Value)
+        }
+        if amount > math.MaxInt-payment.Value {
+            return nil, fmt.Errorf("payment amounts overflow")
+        }
+        amount += payment.Value
+    }
 
-    acc, validOutputs, err := findSpendableOutputs(pubKeyHash, amount)
+    acc, validOutputs, err := findSpendableOutputs(owner, amount)
     if err != nil {
         return nil, fmt.Errorf("failed to find spendable outputs: %v", err)
     }
@@ -263,35 +481,16 @@ func NewUTXOTransaction(w *wallet.Wallet, to []byte, amount int, findSpendableOu
         return nil, fmt.Errorf("not enough funds: got %d, need %d", acc, amount)
     }
 
-    // Build a list of inputs
-    for txid, outs := range validOutputs {
-        txID, err := hex.DecodeString(txid)
-        if err != nil {
-            return nil, fmt.Errorf("failed to decode transaction ID: %v", err)
-        }
-
-        for _, out := range outs {
-            input := TxInput{
-                Txid:      txID,
-                Vout:      out,
-                Signature: nil,
-                PubKey:    w.PublicKey,
-            }
-            inputs = append(inputs, input)
-        }
+    inputs, err = newInputs(validOutputs)
+    if err != nil {
+        return nil, err
     }
 
     // Create the outputs
-    outputs = append(outputs, TxOutput{
-        Value:      amount,
-        PubKeyHash: to,
-    })
+    outputs = append(outputs, payments...)
 
-    if acc > amount {
-        outputs = append(outputs, TxOutput{
-            Value:      acc - amount,
-            PubKeyHash: pubKeyHash,
-        })
+    if acc-amount >= DustThreshold {
+        outputs = append(outputs, TxOutput{Value: acc - amount, LockingScript: changeScript})
     }
 
     tx := &Transaction{
@@ -304,3 +503,65 @@ func NewUTXOTransaction(w *wallet.Wallet, to []byte, amount int, findSpendableOu
 
     return tx, nil
 }
+
+// NewNullDataTransaction creates a new transaction recording data in a null data
+// output and paying fee. It spends at least one of the wallet's outputs, as every
+// transaction must, and returns what the fee leaves as change unless it is dust.
+// Inputs are left unsigned.
+func NewNullDataTransaction(w *wallet.Wallet, data []byte, fee int, findSpendableOutputs func([]byte, int) (int, map[string][]int, error)) (*Transaction, error) {
+    if fee < 0 {
+        return nil, fmt.Errorf("fee cannot be negative")
+    }
+    dataScript, err := script.NullDataScript(data)
+    if err != nil {
+        return nil, err
+    }
+
+    pubKeyHash := wallet.HashPubKey(w.PublicKey)
+    acc, validOutputs, err := findSpendableOutputs(pubKeyHash, max(fee, 1))
+    if err != nil {
+        return nil, fmt.Errorf("failed to find spendable outputs: %v", err)
+    }
+    if acc < 1 {
+        return nil, fmt.Errorf("not enough funds: the wallet has no spendable outputs")
+    }
+    if acc < fee {
+        return nil, fmt.Errorf("not enough funds: got %d, need %d for the fee", acc, fee)
+    }
+
+    inputs, err := newInputs(validOutputs)
+    if err != nil {
+        return nil, err
+    }
+
+    tx := &Transaction{
+        ID:   []byte{},
+        Vin:  inputs,
+        Vout: []TxOutput{{Value: 0, LockingScript: dataScript}},
+    }
+    if acc-fee >= DustThreshold {
+        tx.Vout = append(tx.Vout, TxOutput{Value: acc - fee, LockingScript: script.PayToPubKeyHash(pubKeyHash)})
+    }
+    tx.ID = tx.Hash()
+
+    return tx, nil
+}
+
+// newInputs returns unsigned inputs spending the outputs selected by findSpendableOutputs
+func newInputs(validOutputs map[string][]int) ([]TxInput, error) {
+    var inputs []TxInput
+    for txid, outs := range validOutputs {
+        txID, err := hex.DecodeString(txid)
+        if err != nil {
+            return nil, fmt.Errorf("failed to decode transaction ID: %v", err)
+        }
+
+        for _, out := range outs {
+            inputs = append(inputs, TxInput{
+                Txid: txID,
+                Vout: out,
+            })
+        }
+    }
+    return inputs, nil
+}
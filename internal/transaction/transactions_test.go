@@ -0,0 +1,87 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+	"github.com/OmSingh2003/decentralized-ledger/internal/wallet"
+)
+
+// testChainID is the chain ID transactions of the tests are signed for
+var testChainID = NewChainID([]byte("test genesis"), "mainnet")
+
+// Helper to create an unsigned transaction spending the given outpoints
+func spendTx(prevID []byte, vouts []int, values ...int) *Transaction {
+	tx := &Transaction{}
+	for _, vout := range vouts {
+		tx.Vin = append(tx.Vin, TxInput{Txid: prevID, Vout: vout})
+	}
+	for _, value := range values {
+		tx.Vout = append(tx.Vout, NewTxOutput(value, []byte("recipient")))
+	}
+	tx.ID = tx.Hash()
+	return tx
+}
+
+// Helper to create a spend of output 0 of prevID with the given input sequence
+func sequencedSpend(prevID []byte, sequence uint32, value int) *Transaction {
+	tx := spendTx(prevID, []int{0}, value)
+	tx.Vin[0].Sequence = sequence
+	tx.ID = tx.Hash()
+	return tx
+}
+
+// Helper to create a transaction spending n outputs of one wallet, signed for
+// testChainID, with the transactions it spends
+func manyInputTx(tb testing.TB, n int) (*Transaction, map[string]Transaction) {
+	tb.Helper()
+	w := wallet.NewWallet()
+	prev := Transaction{}
+	for i := 0; i < n; i++ {
+		prev.Vout = append(prev.Vout, NewTxOutput(1, wallet.HashPubKey(w.PublicKey)))
+	}
+	prev.ID = prev.Hash()
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): prev}
+
+	vouts := make([]int, n)
+	for i := range vouts {
+		vouts[i] = i
+	}
+	tx := spendTx(prev.ID, vouts, n)
+	if err := tx.Sign(w, prevTXs, testChainID); err != nil {
+		tb.Fatalf("Failed to sign transaction: %v", err)
+	}
+	return tx, prevTXs
+}
+
+// Test OP_CHECKSEQUENCEVERIFY requires the spending input to carry a long enough
+// relative lock time of the same kind
+func TestCheckSequenceVerify(t *testing.T) {
+	locking := script.NewBuilder().
+		AddInt(2).AddOp(script.OpCheckSequenceVerify).AddOp(script.OpDrop).AddInt(1).Script()
+	prev := Transaction{Vout: []TxOutput{{Value: 10, LockingScript: locking}}}
+	prev.ID = prev.Hash()
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): prev}
+
+	tests := []struct {
+		name     string
+		sequence uint32
+		valid    bool
+	}{
+		{"equal", 2, true},
+		{"longer", 3, true},
+		{"shorter", 1, false},
+		{"seconds instead of blocks", SequenceLockTimeIsSeconds | 2, false},
+		{"disabled", SequenceLockTimeDisabled | 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spend := sequencedSpend(prev.ID, tt.sequence, 10)
+			valid, err := spend.Verify(prevTXs, testChainID)
+			if valid != tt.valid {
+				t.Errorf("Expected valid %v, got %v (%v)", tt.valid, valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package wallet
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "strings"
+)
+
+// AddressType tells what an address pays to
+type AddressType int
+
+const (
+    PubKeyHashAddr AddressType = iota // Pays to the hash of a public key
+    ScriptHashAddr                    // Pays to the hash of a redeem script
+)
+
+// String returns the name of the address type
+func (t AddressType) String() string {
+    switch t {
+    case PubKeyHashAddr:
+        return "pubkeyhash"
+    case ScriptHashAddr:
+        return "scripthash"
+    }
+    return fmt.Sprintf("unknown address type %d", int(t))
+}
+
+// AddressEncoding tells how an address is written
+type AddressEncoding int
+
+const (
+    Base58Encoding AddressEncoding = iota // Base58Check of a version byte and the hash
+    Bech32Encoding                        // Bech32m of a witness version and the hash
+)
+
+// String returns the name of the address encoding
+func (e AddressEncoding) String() string {
+    switch e {
+    case Base58Encoding:
+        return "base58"
+    case Bech32Encoding:
+        return "bech32"
+    }
+    return fmt.Sprintf("unknown address encoding %d", int(e))
+}
+
+// ParseAddressEncoding returns the address encoding called name
+func ParseAddressEncoding(name string) (AddressEncoding, error) {
+    for _, e := range []AddressEncoding{Base58Encoding, Bech32Encoding} {
+        if e.String() == name {
+            return e, nil
+        }
+    }
+    return 0, fmt.Errorf("unknown address encoding %q; use base58 or bech32", name)
+}
+
+// Bech32 addresses carry the address type as the witness version before the hash
+const (
+    pubKeyHashWitnessVersion = byte(0)
+    scriptHashWitnessVersion = byte(1)
+)
+
+// NetParams holds the address format of a network: the version byte that starts the
+// Base58Check encoding of each type of address, and the human-readable prefix of its
+// Bech32 addresses
+type NetParams struct {
+    Name             string
+    PubKeyHashAddrID byte
+    ScriptHashAddrID byte
+    NetID            []byte // Follows the version byte on networks without their own format
+    Bech32HRP        string
+}
+
+const (
+    // customPubKeyHashAddrID and customScriptHashAddrID are the version bytes of
+    // networks without their own format. The network's ID follows them, so their
+    // addresses start with C or B and are longer than those of the other networks.
+    customPubKeyHashAddrID = byte(0xf5)
+    customScriptHashAddrID = byte(0xdf)
+
+    // customHRPPrefix starts the human-readable prefix of Bech32 addresses of networks
+    // without their own format, followed by the network's ID in the Bech32 alphabet
+    customHRPPrefix = "dlx"
+
+    // netIDLen is the length of the ID of a network without its own format, the start
+    // of the SHA-256 of its name
+    netIDLen = 4
+)
+
+var (
+    // MainNetParams is the address format of mainnet, whose addresses start with D, d
+    // or dl1
+    MainNetParams = NetParams{Name: "mainnet", PubKeyHashAddrID: 0x1f, ScriptHashAddrID: 0x5a, Bech32HRP: "dl"}
+
+    // TestNetParams is the address format of testnet, whose addresses start with T, t
+    // or tdl1
+    TestNetParams = NetParams{Name: "testnet", PubKeyHashAddrID: 0x42, ScriptHashAddrID: 0x80, Bech32HRP: "tdl"}
+
+    // RegTestParams is the address format of regtest, whose addresses start with R, r
+    // or rdl1
+    RegTestParams = NetParams{Name: "regtest", PubKeyHashAddrID: 0x3d, ScriptHashAddrID: 0x7b, Bech32HRP: "rdl"}
+
+    // registeredNets are the networks with their own address format
+    registeredNets = []NetParams{MainNetParams, TestNetParams, RegTestParams}
+
+    // activeNet is the network whose addresses are shown and paid to
+    activeNet = MainNetParams
+)
+
+// ParamsForNetwork returns the address format of the network called name. A network
+// without its own format gets one from the SHA-256 of its name, so two such networks
+// cannot pay to each other's addresses.
+func ParamsForNetwork(name string) NetParams {
+    for _, params := range registeredNets {
+        if params.Name == name {
+            return params
+        }
+    }
+    hash := sha256.Sum256([]byte(name))
+    return customNetParams(name, hash[:netIDLen])
+}
+
+// customNetParams returns the address format of the network called name without its
+// own format whose ID is netID
+func customNetParams(name string, netID []byte) NetParams {
+    program, _ := convertBits(netID, 8, 5, true)
+    var hrp strings.Builder
+    hrp.WriteString(customHRPPrefix)
+    for _, v := range program {
+        hrp.WriteByte(bech32Charset[v])
+    }
+    return NetParams{
+        Name:             name,
+        PubKeyHashAddrID: customPubKeyHashAddrID,
+        ScriptHashAddrID: customScriptHashAddrID,
+        NetID:            netID,
+        Bech32HRP:        hrp.String(),
+    }
+}
+
+// unknownNetParams returns the address format of a network without its own format
+// that is not the active network, whose name is unknown, from its ID
+func unknownNetParams(netID []byte) NetParams {
+    return customNetParams(fmt.Sprintf("network %x", netID), netID)
+}
+
+// SetNetwork selects the network whose addresses are shown and paid to, normally the
+// network of the open chain
+func SetNetwork(name string) {
+    activeNet = ParamsForNetwork(name)
+}
+
+// ActiveNetwork returns the address format selected with SetNetwork, mainnet's by
+// default
+func ActiveNetwork() NetParams {
+    return activeNet
+}
+
+// addrID returns the version byte of addresses of type t
+func (p NetParams) addrID(t AddressType) byte {
+    if t == ScriptHashAddr {
+        return p.ScriptHashAddrID
+    }
+    return p.PubKeyHashAddrID
+}
+
+// encode returns the address of type t paying to hash on the network
+func (p NetParams) encode(enc AddressEncoding, t AddressType, hash []byte) string {
+    if enc == Bech32Encoding {
+        witnessVersion := pubKeyHashWitnessVersion
+        if t == ScriptHashAddr {
+            witnessVersion = scriptHashWitnessVersion
+        }
+        program, _ := convertBits(hash, 8, 5, true)
+        return bech32Encode(p.Bech32HRP, append([]byte{witnessVersion}, program...))
+    }
+    return base58CheckEncode(p.addrID(t), append(append([]byte{}, p.NetID...), hash...))
+}
+
+// AddressInfo describes a valid address
+type AddressInfo struct {
+    Network  string          // Network the address belongs to
+    Type     AddressType     // What the address pays to
+    Encoding AddressEncoding // How the address is written
+    Hash     []byte          // Public key hash or redeem script hash
+}
+
+// encodeAddress returns the Base58Check address of type t paying to hash on the
+// active network
+func encodeAddress(t AddressType, hash []byte) string {
+    return activeNet.encode(Base58Encoding, t, hash)
+}
+
+// EncodeAddress returns address, of any network, written with encoding enc
+func EncodeAddress(address string, enc AddressEncoding) (string, error) {
+    info, params, err := decodeAddress(address)
+    if err != nil {
+        return "", err
+    }
+    return params.encode(enc, info.Type, info.Hash), nil
+}
+
+// knownNets returns the active network followed by the networks with their own
+// format, the order in which an address's network is looked up
+func knownNets() []NetParams {
+    return append([]NetParams{activeNet}, registeredNets...)
+}
+
+// ValidateAddress decodes a Base58Check or Bech32 address of any network and reports
+// its network and type, or why it is invalid. A network without its own format other
+// than the active network is reported by its ID, as its name is not known.
+func ValidateAddress(address string) (*AddressInfo, error) {
+    info, _, err := decodeAddress(address)
+    return info, err
+}
+
+// decodeAddress decodes an address like ValidateAddress, also returning the format of
+// its network
+func decodeAddress(address string) (*AddressInfo, NetParams, error) {
+    params, ok := bech32Params(address)
+    if !ok {
+        return decodeBase58Address(address)
+    }
+    info, params, err := decodeBech32Address(address, params)
+    if err != nil {
+        // A Base58Check address can also start with a prefix followed by 1, as in
+        // dL1Rnm2yAXv937SmAcUMqzaCeQnnuRUe4F, so the Bech32 error is only reported if
+        // the address is not valid Base58Check either
+        if info, params, base58Err := decodeBase58Address(address); base58Err == nil {
+            return info, params, nil
+        }
+    }
+    return info, params, err
+}
+
+// bech32Params returns the network whose Bech32 human-readable prefix address starts
+// with, if any
+func bech32Params(address string) (NetParams, bool) {
+    sep := strings.LastIndexByte(address, '1')
+    if sep <= 0 {
+        return NetParams{}, false
+    }
+    hrp := strings.ToLower(address[:sep])
+    for _, params := range knownNets() {
+        if hrp == params.Bech32HRP {
+            return params, true
+        }
+    }
+    if strings.HasPrefix(hrp, customHRPPrefix) {
+        program := make([]byte, len(hrp)-len(customHRPPrefix))
+        for i := range program {
+            program[i] = byte(bech32Index[hrp[len(customHRPPrefix)+i]])
+        }
+        if netID, err := convertBits(program, 5, 8, false); err == nil && len(netID) == netIDLen {
+            return unknownNetParams(netID), true
+        }
+    }
+    return NetParams{}, false
+}
+
+// decodeBase58Address decodes a Base58Check address of any network
+func decodeBase58Address(address string) (*AddressInfo, NetParams, error) {
+    version, payload, err := base58CheckDecode(address)
+    if err != nil {
+        return nil, NetParams{}, fmt.Errorf("address %s is invalid: %v", address, err)
+    }
+    var netID []byte
+    switch len(payload) {
+    case hashLen:
+    case netIDLen + hashLen:
+        netID, payload = payload[:netIDLen], payload[netIDLen:]
+    default:
+        return nil, NetParams{}, fmt.Errorf("address %s has the wrong length", address)
+    }
+
+    // Only the active network of those without their own format has a known name
+    nets := knownNets()
+    if netID != nil {
+        nets = []NetParams{activeNet, unknownNetParams(netID)}
+    }
+    for _, params := range nets {
+        if !bytes.Equal(params.NetID, netID) {
+            continue
+        }
+        for _, t := range []AddressType{PubKeyHashAddr, ScriptHashAddr} {
+            if version == params.addrID(t) {
+                return &AddressInfo{Network: params.Name, Type: t, Encoding: Base58Encoding, Hash: payload}, params, nil
+            }
+        }
+    }
+    return nil, NetParams{}, fmt.Errorf("address %s has unknown version 0x%02x", address, version)
+}
+
+// decodeBech32Address decodes a Bech32 address with the human-readable prefix of the
+// network params
+func decodeBech32Address(address string, params NetParams) (*AddressInfo, NetParams, error) {
+    _, data, err := bech32Decode(address)
+    if err != nil {
+        return nil, NetParams{}, fmt.Errorf("address %s is invalid: %v", address, err)
+    }
+    if len(data) == 0 {
+        return nil, NetParams{}, fmt.Errorf("address %s has no witness version", address)
+    }
+
+    var t AddressType
+    switch data[0] {
+    case pubKeyHashWitnessVersion:
+        t = PubKeyHashAddr
+    case scriptHashWitnessVersion:
+        t = ScriptHashAddr
+    default:
+        return nil, NetParams{}, fmt.Errorf("address %s has unknown witness version %d", address, data[0])
+    }
+    hash, err := convertBits(data[1:], 5, 8, false)
+    if err != nil {
+        return nil, NetParams{}, fmt.Errorf("address %s is invalid: %v", address, err)
+    }
+    if len(hash) != hashLen {
+        return nil, NetParams{}, fmt.Errorf("address %s has the wrong length", address)
+    }
+    return &AddressInfo{Network: params.Name, Type: t, Encoding: Bech32Encoding, Hash: hash}, params, nil
+}
+
+// DecodeAddress returns the type and hash of an address of the active network. An
+// address of another network is rejected, so coins are not sent where they cannot be
+// spent.
+func DecodeAddress(address string) (AddressType, []byte, error) {
+    info, err := ValidateAddress(address)
+    if err != nil {
+        return 0, nil, err
+    }
+    if info.Network != activeNet.Name {
+        return 0, nil, fmt.Errorf("address %s is for %s, not %s", address, info.Network, activeNet.Name)
+    }
+    return info.Type, info.Hash, nil
+}
+
+// Wallet and redeem script files are named by the hex encoding of a version byte, the
+// hash and its checksum, the address format before Base58Check, so the files do not
+// depend on the network
+const (
+    keyFileVersion    = byte(0x00)
+    scriptFileVersion = byte(0x05)
+)
+
+// fileID returns the name, without extension, of the file for an address of type t
+// paying to hash
+func fileID(t AddressType, hash []byte) string {
+    version := keyFileVersion
+    if t == ScriptHashAddr {
+        version = scriptFileVersion
+    }
+    payload := append([]byte{version}, hash...)
+    return hex.EncodeToString(append(payload, checksum(payload)...))
+}
+
+// parseFileID returns the address type and hash of a file name written by fileID
+func parseFileID(id string) (AddressType, []byte, bool) {
+    data, err := hex.DecodeString(id)
+    if err != nil || len(data) != 1+hashLen+addressChecksumLen {
+        return 0, nil, false
+    }
+    payload := data[:1+hashLen]
+    if !bytes.Equal(checksum(payload), data[1+hashLen:]) {
+        return 0, nil, false
+    }
+    switch payload[0] {
+    case keyFileVersion:
+        return PubKeyHashAddr, payload[1:], true
+    case scriptFileVersion:
+        return ScriptHashAddr, payload[1:], true
+    }
+    return 0, nil, false
+}
+
+// addressFileID returns the file name, without extension, for an address of type t of
+// any network
+func addressFileID(address string, t AddressType) (string, error) {
+    info, err := ValidateAddress(address)
+    if err != nil || info.Type != t {
+        return "", fmt.Errorf("%w for address: %s", ErrWalletNotFound, address)
+    }
+    return fileID(t, info.Hash), nil
+}
@@ -0,0 +1,191 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/script"
+)
+
+// Test addresses are Base58Check encoded with the version byte of their network and
+// type, followed by the ID of networks without their own format, and decode back to
+// their network, type and hash
+func TestBase58CheckAddresses(t *testing.T) {
+	defer SetNetwork(MainNetParams.Name)
+
+	hash, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f10111213")
+	tests := []struct {
+		network  string
+		addrType AddressType
+		address  string
+	}{
+		{"mainnet", PubKeyHashAddr, "DUUijPpZLwQdgQPEph3JdK5ocKikzEXNbz"},
+		{"mainnet", ScriptHashAddr, "dDRJpoQZEakKvycMGSh7Ei9Dk5vQw3iMqK"},
+		{"testnet", PubKeyHashAddr, "TZJqCCFeCFdJJaGGgNhTbhcLdyjmqUrgFq"},
+		{"testnet", ScriptHashAddr, "tWGEEvjWDSMd1SueCPMDfUV7fFiFstVQTx"},
+		{"regtest", PubKeyHashAddr, "RYcpGemCeMJvDQZqZH2sB5FQVTT4EXBgMW"},
+		{"regtest", ScriptHashAddr, "rVaDKPF4fY3EvHDD5HgdEr8BWjRYNmbGw6"},
+		{"alpha", PubKeyHashAddr, "C9ZWnrLfeeLjtWdZ8U1gWyNBw2qw85Rosa1mm7PT"},
+		{"alpha", ScriptHashAddr, "B9dmkGDKmRh1kJPVc5NrDwZrHqbh4Ugr4DYgG6iy"},
+	}
+	for _, test := range tests {
+		SetNetwork(test.network)
+		address := KeyHashAddress(hash)
+		if test.addrType == ScriptHashAddr {
+			address = ScriptHashAddress(hash)
+		}
+		if address != test.address {
+			t.Errorf("%s %s address is %s, want %s", test.network, test.addrType, address, test.address)
+		}
+
+		info, err := ValidateAddress(test.address)
+		if err != nil {
+			t.Fatalf("Failed to validate %s: %v", test.address, err)
+		}
+		if info.Network != test.network || info.Type != test.addrType || !bytes.Equal(info.Hash, hash) {
+			t.Errorf("%s decoded as %s %s %x", test.address, info.Network, info.Type, info.Hash)
+		}
+	}
+
+	// A network without its own format is only known by name while it is active
+	SetNetwork(MainNetParams.Name)
+	info, err := ValidateAddress("C9ZWnrLfeeLjtWdZ8U1gWyNBw2qw85Rosa1mm7PT")
+	if err != nil {
+		t.Fatalf("Failed to validate alpha address: %v", err)
+	}
+	if info.Network == "alpha" || !strings.HasPrefix(info.Network, "network ") || !bytes.Equal(info.Hash, hash) {
+		t.Errorf("Inactive alpha address decoded as %s %x", info.Network, info.Hash)
+	}
+}
+
+// Test mistyped addresses are rejected, and addresses of other networks cannot be
+// paid to
+func TestInvalidAddresses(t *testing.T) {
+	defer SetNetwork(MainNetParams.Name)
+	SetNetwork("mainnet")
+
+	address := "DUUijPpZLwQdgQPEph3JdK5ocKikzEXNbz"
+	for _, bad := range []string{
+		"DUUijPpZLwQdgQPEph3JdK5ocKikzEXNby", // Checksum fails
+		"DUUijPpZLwQdgQPEph3JdK5ocKikzEX0bz", // 0 is not in the alphabet
+		"DUUijPpZLwQdgQPEph3JdK5ocKikz",      // Truncated
+		"",
+	} {
+		if _, err := ValidateAddress(bad); err == nil {
+			t.Errorf("Invalid address %q was accepted", bad)
+		}
+	}
+
+	info, err := ValidateAddress(address)
+	if err != nil {
+		t.Fatalf("Failed to validate %s: %v", address, err)
+	}
+	SetNetwork("testnet")
+	testnetAddress := KeyHashAddress(info.Hash)
+	if _, err := AddressLockingScript(address); err == nil {
+		t.Errorf("Mainnet address was payable on testnet")
+	}
+	lockingScript, err := AddressLockingScript(testnetAddress)
+	if err != nil {
+		t.Fatalf("Failed to pay to testnet address: %v", err)
+	}
+	if !bytes.Equal(script.ExtractPubKeyHash(lockingScript), info.Hash) {
+		t.Errorf("Locking script pays to the wrong key hash")
+	}
+}
+
+// Test Bech32 addresses carry the human-readable prefix of their network, decode to
+// the same hash as Base58Check ones, and are case-insensitive
+func TestBech32Addresses(t *testing.T) {
+	defer SetNetwork(MainNetParams.Name)
+	useTempWalletDirs(t)
+
+	for network, prefix := range map[string]string{"mainnet": "dl1q", "testnet": "tdl1q", "regtest": "rdl1q", "alpha": "dlx3mfldtg1q"} {
+		SetNetwork(network)
+		w := NewWallet()
+		address, err := EncodeAddress(w.GetAddress(), Bech32Encoding)
+		if err != nil {
+			t.Fatalf("Failed to encode %s address: %v", network, err)
+		}
+		if !strings.HasPrefix(address, prefix) {
+			t.Errorf("%s address %s does not start with %s", network, address, prefix)
+		}
+
+		for _, written := range []string{address, strings.ToUpper(address)} {
+			info, err := ValidateAddress(written)
+			if err != nil {
+				t.Fatalf("Failed to validate %s: %v", written, err)
+			}
+			if info.Network != network || info.Type != PubKeyHashAddr || info.Encoding != Bech32Encoding ||
+				!bytes.Equal(info.Hash, HashPubKey(w.PublicKey)) {
+				t.Errorf("%s decoded as %s %s %s %x", written, info.Network, info.Type, info.Encoding, info.Hash)
+			}
+		}
+		loaded, err := LoadWallet(address)
+		if err != nil {
+			t.Fatalf("Failed to load wallet of %s: %v", address, err)
+		}
+		if !bytes.Equal(loaded.PublicKey, w.PublicKey) {
+			t.Errorf("Wallet of %s has the wrong key", address)
+		}
+	}
+
+	SetNetwork("mainnet")
+	scriptHash := bytes.Repeat([]byte{0xab}, 20)
+	address, err := EncodeAddress(ScriptHashAddress(scriptHash), Bech32Encoding)
+	if err != nil {
+		t.Fatalf("Failed to encode script address: %v", err)
+	}
+	lockingScript, err := AddressLockingScript(address)
+	if err != nil {
+		t.Fatalf("Failed to pay to %s: %v", address, err)
+	}
+	if !bytes.Equal(script.ExtractScriptHash(lockingScript), scriptHash) {
+		t.Errorf("Locking script of %s pays to the wrong script hash", address)
+	}
+
+	// A mix of cases is rejected
+	if _, err := ValidateAddress(address[:6] + strings.ToUpper(address[6:])); err == nil {
+		t.Errorf("Address mixing cases was accepted")
+	}
+}
+
+// Test Base58Check addresses that start with a Bech32 prefix followed by 1 are not
+// mistaken for Bech32 ones
+func TestBase58AddressWithBech32Prefix(t *testing.T) {
+	defer SetNetwork(MainNetParams.Name)
+	useTempWalletDirs(t)
+
+	for network, address := range map[string]string{
+		"mainnet": "dL1Rnm2yAXv937SmAcUMqzaCeQnnuRUe4F",
+		"testnet": "tdL1KJCthjQC9dd2ibmeMM9rWjAkv8Yb63",
+	} {
+		SetNetwork(network)
+		info, err := ValidateAddress(address)
+		if err != nil {
+			t.Fatalf("Failed to validate %s: %v", address, err)
+		}
+		if info.Network != network || info.Encoding != Base58Encoding || info.Type != ScriptHashAddr {
+			t.Errorf("%s decoded as %s %s %s", address, info.Network, info.Type, info.Encoding)
+		}
+		if encoded := ScriptHashAddress(info.Hash); encoded != address {
+			t.Errorf("%s encoded back as %s", address, encoded)
+		}
+		if _, err := AddressLockingScript(address); err != nil {
+			t.Errorf("Failed to pay to %s: %v", address, err)
+		}
+	}
+
+	// A mistyped Bech32 address is still reported as such
+	SetNetwork(MainNetParams.Name)
+	address := MainNetParams.encode(Bech32Encoding, PubKeyHashAddr, make([]byte, hashLen))
+	typo := address[:len(address)-1] + "q"
+	if address[len(address)-1] == 'q' {
+		typo = address[:len(address)-1] + "p"
+	}
+	if _, err := ValidateAddress(typo); err == nil || !strings.Contains(err.Error(), "likely wrong") {
+		t.Errorf("Expected the Bech32 error for %s, got %v", typo, err)
+	}
+}
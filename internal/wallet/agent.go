@@ -0,0 +1,178 @@
+package wallet
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "syscall"
+    "time"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/codec"
+)
+
+// AgentCommand is the hidden command that runs RunAgent. Unlocking a wallet starts
+// the running program again with it, so main must hand it to RunAgent before
+// parsing anything else.
+const AgentCommand = "walletagent"
+
+const (
+    agentGetKey = 'k'
+    agentLock   = 'l'
+    agentReady  = "ready\n"
+
+    // agentTimeout bounds how long a client or the agent waits for the other side
+    agentTimeout = 5 * time.Second
+)
+
+// agentSocket returns the path of the socket of the agent holding the key named
+// name. The file name is a short hash of name, as socket paths are limited to about
+// 100 bytes.
+func agentSocket(name string) (string, error) {
+    dir, err := unlockDir()
+    if err != nil {
+        return "", err
+    }
+    hash := sha256.Sum256([]byte(name))
+    return filepath.Join(dir, hex.EncodeToString(hash[:8])+".sock"), nil
+}
+
+// startAgent starts an agent process that hands out key under name until expiry,
+// replacing any agent holding that name. The key reaches the agent through a pipe
+// and is never written to disk.
+func startAgent(name string, key []byte, expiry time.Time) error {
+    if err := stopAgent(name); err != nil {
+        return err
+    }
+    path, err := agentSocket(name)
+    if err != nil {
+        return err
+    }
+    exe, err := os.Executable()
+    if err != nil {
+        return fmt.Errorf("failed to start wallet agent: %v", err)
+    }
+
+    w := codec.NewWriter()
+    w.WriteInt64(expiry.UnixNano())
+    w.WriteBytes(key)
+    cmd := exec.Command(exe, AgentCommand, path)
+    cmd.Stdin = bytes.NewReader(w.Bytes())
+    stdout, err := cmd.StdoutPipe()
+    if err != nil {
+        return fmt.Errorf("failed to start wallet agent: %v", err)
+    }
+    if err := cmd.Start(); err != nil {
+        return fmt.Errorf("failed to start wallet agent: %v", err)
+    }
+    // The agent closes its standard output once it listens, or exits on failure
+    ready, _ := io.ReadAll(io.LimitReader(stdout, int64(len(agentReady))))
+    go cmd.Wait()
+    if string(ready) != agentReady {
+        return fmt.Errorf("wallet agent failed to start")
+    }
+    return nil
+}
+
+// stopAgent makes the agent holding the key named name forget it and exit. It
+// returns once the agent has removed its socket.
+func stopAgent(name string) error {
+    _, err := agentRequest(name, agentLock)
+    return err
+}
+
+// agentKey returns the key the agent holding name hands out, or nil if no agent
+// holds it
+func agentKey(name string) []byte {
+    key, err := agentRequest(name, agentGetKey)
+    if err != nil || len(key) == 0 {
+        return nil
+    }
+    return key
+}
+
+// agentRequest sends request to the agent holding name and returns its reply, or
+// nil if no agent is running. The socket of an agent that died is removed.
+func agentRequest(name string, request byte) ([]byte, error) {
+    path, err := agentSocket(name)
+    if err != nil {
+        return nil, err
+    }
+    conn, err := net.DialTimeout("unix", path, agentTimeout)
+    if errors.Is(err, syscall.ECONNREFUSED) {
+        os.Remove(path)
+    }
+    if err != nil {
+        return nil, nil
+    }
+    defer conn.Close()
+
+    conn.SetDeadline(time.Now().Add(agentTimeout))
+    if _, err := conn.Write([]byte{request}); err != nil {
+        return nil, fmt.Errorf("failed to reach wallet agent: %v", err)
+    }
+    reply, err := io.ReadAll(conn)
+    if err != nil {
+        return nil, fmt.Errorf("failed to reach wallet agent: %v", err)
+    }
+    return reply, nil
+}
+
+// RunAgent holds the key written to standard input by startAgent in memory and hands
+// it out on the socket at args[0]. It exits, removing the socket, at the expiry
+// written before the key or when asked to lock.
+func RunAgent(args []string) error {
+    if len(args) != 1 {
+        return fmt.Errorf("usage: %s SOCKET", AgentCommand)
+    }
+    data, err := io.ReadAll(os.Stdin)
+    if err != nil {
+        return err
+    }
+    r := codec.NewReader(data)
+    expiry := time.Unix(0, r.ReadInt64())
+    key := r.ReadBytes()
+    if err := r.Finish(); err != nil {
+        return fmt.Errorf("failed to read key: %v", err)
+    }
+    defer clear(key)
+
+    listener, err := net.Listen("unix", args[0])
+    if err != nil {
+        return err
+    }
+    defer listener.Close()
+    timer := time.AfterFunc(time.Until(expiry), func() { listener.Close() })
+    defer timer.Stop()
+
+    fmt.Print(agentReady)
+    os.Stdout.Close()
+
+    for {
+        conn, err := listener.Accept()
+        if err != nil {
+            // The listener is closed at expiry
+            return nil
+        }
+        conn.SetDeadline(time.Now().Add(agentTimeout))
+        var request [1]byte
+        if _, err := io.ReadFull(conn, request[:]); err == nil {
+            switch request[0] {
+            case agentGetKey:
+                conn.Write(key)
+            case agentLock:
+                // Remove the socket before replying, so a new agent can take its place
+                listener.Close()
+                conn.Close()
+                return nil
+            }
+        }
+        conn.Close()
+    }
+}
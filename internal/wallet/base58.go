@@ -0,0 +1,90 @@
+package wallet
+
+import (
+    "bytes"
+    "fmt"
+    "math/big"
+)
+
+// base58Alphabet leaves out 0, O, I and l, which are easily mistaken for each other
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Index = func() [256]int {
+    var index [256]int
+    for i := range index {
+        index[i] = -1
+    }
+    for i := 0; i < len(base58Alphabet); i++ {
+        index[base58Alphabet[i]] = i
+    }
+    return index
+}()
+
+// base58Encode encodes data as a base 58 number, with a leading '1' for each leading
+// zero byte
+func base58Encode(data []byte) string {
+    n := new(big.Int).SetBytes(data)
+    radix := big.NewInt(58)
+    mod := new(big.Int)
+
+    var encoded []byte
+    for n.Sign() > 0 {
+        n.DivMod(n, radix, mod)
+        encoded = append(encoded, base58Alphabet[mod.Int64()])
+    }
+    for _, b := range data {
+        if b != 0 {
+            break
+        }
+        encoded = append(encoded, base58Alphabet[0])
+    }
+
+    for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+        encoded[i], encoded[j] = encoded[j], encoded[i]
+    }
+    return string(encoded)
+}
+
+// base58Decode decodes a string written by base58Encode
+func base58Decode(s string) ([]byte, error) {
+    n := new(big.Int)
+    radix := big.NewInt(58)
+    for i := 0; i < len(s); i++ {
+        digit := base58Index[s[i]]
+        if digit < 0 {
+            return nil, fmt.Errorf("character %d, %q, is not in the Base58 alphabet", i+1, s[i])
+        }
+        n.Mul(n, radix)
+        n.Add(n, big.NewInt(int64(digit)))
+    }
+
+    zeros := 0
+    for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+        zeros++
+    }
+    return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// base58CheckEncode encodes version and payload followed by the first 4 bytes of their
+// double SHA-256
+func base58CheckEncode(version byte, payload []byte) string {
+    data := append([]byte{version}, payload...)
+    return base58Encode(append(data, checksum(data)...))
+}
+
+// base58CheckDecode decodes a string written by base58CheckEncode, verifying its
+// checksum
+func base58CheckDecode(s string) (byte, []byte, error) {
+    data, err := base58Decode(s)
+    if err != nil {
+        return 0, nil, err
+    }
+    if len(data) < 1+addressChecksumLen {
+        return 0, nil, fmt.Errorf("too short")
+    }
+    payload, sum := data[:len(data)-addressChecksumLen], data[len(data)-addressChecksumLen:]
+    if !bytes.Equal(checksum(payload), sum) {
+        return 0, nil, fmt.Errorf("invalid checksum")
+    }
+    return payload[0], payload[1:], nil
+}
@@ -0,0 +1,183 @@
+package wallet
+
+import (
+    "fmt"
+    "strings"
+)
+
+const (
+    // bech32Charset leaves out 1, b, i and o, and orders the characters so that ones
+    // that look alike mostly differ in one bit
+    bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+    // bech32mConst is the constant the checksum is XORed with. Bech32m replaced the
+    // constant 1 of Bech32, under which inserting or deleting a q before a final p
+    // went unnoticed.
+    bech32mConst = 0x2bc830a3
+
+    bech32ChecksumLen = 6
+    bech32MaxLen      = 90
+)
+
+var bech32Index = func() [256]int {
+    var index [256]int
+    for i := range index {
+        index[i] = -1
+    }
+    for i := 0; i < len(bech32Charset); i++ {
+        index[bech32Charset[i]] = i
+    }
+    return index
+}()
+
+// bech32Polymod returns the remainder of values divided by the BCH code's generator
+func bech32Polymod(values []byte) uint32 {
+    generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+    chk := uint32(1)
+    for _, v := range values {
+        top := chk >> 25
+        chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+        for i := 0; i < 5; i++ {
+            if (top>>uint(i))&1 == 1 {
+                chk ^= generator[i]
+            }
+        }
+    }
+    return chk
+}
+
+// bech32HRPExpand returns the human-readable prefix as the 5 bit values the checksum
+// covers
+func bech32HRPExpand(hrp string) []byte {
+    expanded := make([]byte, 0, 2*len(hrp)+1)
+    for i := 0; i < len(hrp); i++ {
+        expanded = append(expanded, hrp[i]>>5)
+    }
+    expanded = append(expanded, 0)
+    for i := 0; i < len(hrp); i++ {
+        expanded = append(expanded, hrp[i]&31)
+    }
+    return expanded
+}
+
+// bech32Checksum returns the Bech32m checksum of the 5 bit values data under hrp
+func bech32Checksum(hrp string, data []byte) []byte {
+    values := append(bech32HRPExpand(hrp), data...)
+    values = append(values, make([]byte, bech32ChecksumLen)...)
+    mod := bech32Polymod(values) ^ bech32mConst
+
+    checksum := make([]byte, bech32ChecksumLen)
+    for i := range checksum {
+        checksum[i] = byte(mod>>uint(5*(5-i))) & 31
+    }
+    return checksum
+}
+
+// bech32Verify reports whether the 5 bit values data end with their Bech32m checksum
+func bech32Verify(hrp string, data []byte) bool {
+    return bech32Polymod(append(bech32HRPExpand(hrp), data...)) == bech32mConst
+}
+
+// bech32Encode encodes the 5 bit values data under hrp, followed by their checksum
+func bech32Encode(hrp string, data []byte) string {
+    var sb strings.Builder
+    sb.WriteString(hrp)
+    sb.WriteByte('1')
+    for _, v := range append(append([]byte{}, data...), bech32Checksum(hrp, data)...) {
+        sb.WriteByte(bech32Charset[v])
+    }
+    return sb.String()
+}
+
+// bech32Decode decodes a string written by bech32Encode, returning its lowercase
+// human-readable prefix and 5 bit values without the checksum. A checksum mismatch
+// a single substituted character explains is reported with that character's position.
+func bech32Decode(s string) (string, []byte, error) {
+    if len(s) > bech32MaxLen {
+        return "", nil, fmt.Errorf("longer than %d characters", bech32MaxLen)
+    }
+    lower := strings.ToLower(s)
+    if lower != s && strings.ToUpper(s) != s {
+        return "", nil, fmt.Errorf("mixes upper and lower case")
+    }
+
+    sep := strings.LastIndexByte(lower, '1')
+    if sep < 1 {
+        return "", nil, fmt.Errorf("has no human-readable prefix before the separator 1")
+    }
+    if len(lower)-sep-1 < bech32ChecksumLen {
+        return "", nil, fmt.Errorf("too short")
+    }
+    hrp := lower[:sep]
+    for i := 0; i < len(hrp); i++ {
+        if hrp[i] < 33 || hrp[i] > 126 {
+            return "", nil, fmt.Errorf("character %d is not printable", i+1)
+        }
+    }
+
+    data := make([]byte, len(lower)-sep-1)
+    for i := range data {
+        v := bech32Index[lower[sep+1+i]]
+        if v < 0 {
+            return "", nil, fmt.Errorf("character %d, %q, is not in the Bech32 alphabet", sep+2+i, s[sep+1+i])
+        }
+        data[i] = byte(v)
+    }
+
+    if !bech32Verify(hrp, data) {
+        if i := bech32LocateError(hrp, data); i >= 0 {
+            return "", nil, fmt.Errorf("invalid checksum; character %d, %q, is likely wrong", sep+2+i, s[sep+1+i])
+        }
+        return "", nil, fmt.Errorf("invalid checksum")
+    }
+    return hrp, data[:len(data)-bech32ChecksumLen], nil
+}
+
+// bech32LocateError returns the index of the one value of data whose substitution
+// makes the checksum valid, or -1 if there is none. The checksum detects any 4
+// errors, so no two substitutions can both fix it.
+func bech32LocateError(hrp string, data []byte) int {
+    candidate := append([]byte{}, data...)
+    for i := range candidate {
+        original := candidate[i]
+        for v := byte(0); v < 32; v++ {
+            if v == original {
+                continue
+            }
+            candidate[i] = v
+            if bech32Verify(hrp, candidate) {
+                return i
+            }
+        }
+        candidate[i] = original
+    }
+    return -1
+}
+
+// convertBits regroups data of fromBits bit values into toBits bit values. With pad
+// the last value is padded with zeros; without, leftover bits must be zero padding.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+    var acc uint32
+    var bits uint
+    maxValue := uint32(1)<<toBits - 1
+    var out []byte
+    for _, v := range data {
+        if uint32(v)>>fromBits != 0 {
+            return nil, fmt.Errorf("value %d does not fit in %d bits", v, fromBits)
+        }
+        acc = acc<<fromBits | uint32(v)
+        bits += fromBits
+        for bits >= toBits {
+            bits -= toBits
+            out = append(out, byte(acc>>bits&maxValue))
+        }
+    }
+    if pad {
+        if bits > 0 {
+            out = append(out, byte(acc<<(toBits-bits)&maxValue))
+        }
+    } else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+        return nil, fmt.Errorf("invalid padding")
+    }
+    return out, nil
+}
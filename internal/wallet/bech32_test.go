@@ -0,0 +1,67 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Test a mainnet address of a known hash and that it decodes back to the hash
+func TestBech32Vector(t *testing.T) {
+	defer SetNetwork(MainNetParams.Name)
+	SetNetwork(MainNetParams.Name)
+
+	hash, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f10111213")
+	address := MainNetParams.encode(Bech32Encoding, PubKeyHashAddr, hash)
+	if want := "dl1qqqqsyqcyq5rqwzqfpg9scrgwpugpzysnlslgya"; address != want {
+		t.Errorf("Address is %s, want %s", address, want)
+	}
+	info, err := ValidateAddress(address)
+	if err != nil {
+		t.Fatalf("Failed to validate %s: %v", address, err)
+	}
+	if info.Network != MainNetParams.Name || info.Type != PubKeyHashAddr || !bytes.Equal(info.Hash, hash) {
+		t.Errorf("%s decoded as %s %s %x", address, info.Network, info.Type, info.Hash)
+	}
+}
+
+// Test substituting any one character after the separator is reported at its
+// position, whichever character replaces it
+func TestBech32ErrorPosition(t *testing.T) {
+	defer SetNetwork(MainNetParams.Name)
+	SetNetwork(MainNetParams.Name)
+
+	address := MainNetParams.encode(Bech32Encoding, ScriptHashAddr, bytes.Repeat([]byte{0xab}, 20))
+	sep := strings.LastIndexByte(address, '1')
+	for i := sep + 1; i < len(address); i++ {
+		for _, c := range []byte(bech32Charset) {
+			if c == address[i] {
+				continue
+			}
+			typo := address[:i] + string(c) + address[i+1:]
+			want := fmt.Sprintf("character %d, %q, is likely wrong", i+1, c)
+			if _, err := ValidateAddress(typo); err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("Expected %s to fail with %q, got %v", typo, want, err)
+			}
+		}
+	}
+
+	// A character outside the alphabet is reported at its position too
+	typo := address[:sep+3] + "b" + address[sep+4:]
+	want := fmt.Sprintf("character %d, 'b', is not in the Bech32 alphabet", sep+4)
+	if _, err := ValidateAddress(typo); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected %s to fail with %q, got %v", typo, want, err)
+	}
+
+	// Two wrong characters are not attributed to either
+	typo = address[:sep+3] + "q" + address[sep+4:]
+	if address[sep+3] == 'q' {
+		typo = address[:sep+3] + "p" + address[sep+4:]
+	}
+	typo = typo[:len(typo)-2] + string(bech32Charset[(bech32Index[typo[len(typo)-2]]+1)%32]) + typo[len(typo)-1:]
+	if _, err := ValidateAddress(typo); err == nil || strings.Contains(err.Error(), "likely wrong") {
+		t.Errorf("Expected %s to fail without locating a character, got %v", typo, err)
+	}
+}
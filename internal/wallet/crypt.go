@@ -0,0 +1,498 @@
+package wallet
+
+import (
+    "bytes"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/codec"
+    "golang.org/x/crypto/scrypt"
+)
+
+const (
+    // encryptedMagic starts every encrypted wallet file; files without it hold a
+    // plain gob encoded key
+    encryptedMagic = "DLWE"
+
+    // scrypt parameters of newly encrypted wallets: 2^15 iterations take about
+    // 100ms and 32MB, which makes guessing passphrases expensive
+    scryptLogN = 15
+    scryptR    = 8
+    scryptP    = 1
+
+    saltLen = 16
+    keyLen  = 32 // AES-256
+)
+
+var (
+    // ErrWalletNotFound is returned for an address without a wallet file
+    ErrWalletNotFound = errors.New("wallet not found")
+
+    // ErrWalletLocked is returned when loading an encrypted wallet that is not unlocked
+    ErrWalletLocked = errors.New("wallet is locked")
+
+    // ErrWrongPassphrase is returned when a passphrase does not decrypt a wallet. A
+    // corrupt ciphertext cannot be told apart from a wrong passphrase.
+    ErrWrongPassphrase = errors.New("wrong passphrase or corrupt wallet file")
+)
+
+// envelope is a secret sealed with AES-256-GCM under a key derived from a passphrase
+// with scrypt. Wallet files seal their private key in one, the deterministic wallet
+// its seed.
+type envelope struct {
+    Salt       []byte
+    LogN       uint32 // scrypt cost as a power of two
+    R          uint32 // scrypt block size
+    P          uint32 // scrypt parallelism
+    Nonce      []byte
+    Ciphertext []byte
+}
+
+// newEnvelope returns an envelope with a new salt, not sealed yet, and the key
+// passphrase derives for it
+func newEnvelope(passphrase []byte) (*envelope, []byte, error) {
+    if len(passphrase) == 0 {
+        return nil, nil, fmt.Errorf("passphrase cannot be empty")
+    }
+
+    e := &envelope{
+        Salt: make([]byte, saltLen),
+        LogN: scryptLogN,
+        R:    scryptR,
+        P:    scryptP,
+    }
+    if _, err := rand.Read(e.Salt); err != nil {
+        return nil, nil, err
+    }
+    key, err := e.deriveKey(passphrase)
+    if err != nil {
+        return nil, nil, err
+    }
+    return e, key, nil
+}
+
+// writeParams encodes Salt (bytes), LogN, R and P (uint32 each)
+func (e *envelope) writeParams(w *codec.Writer) {
+    w.WriteBytes(e.Salt)
+    w.WriteUint32(e.LogN)
+    w.WriteUint32(e.R)
+    w.WriteUint32(e.P)
+}
+
+// readParams decodes what writeParams wrote
+func (e *envelope) readParams(r *codec.Reader) {
+    e.Salt = r.ReadBytes()
+    e.LogN = r.ReadUint32()
+    e.R = r.ReadUint32()
+    e.P = r.ReadUint32()
+}
+
+// writeSealed encodes Nonce and Ciphertext (bytes each)
+func (e *envelope) writeSealed(w *codec.Writer) {
+    w.WriteBytes(e.Nonce)
+    w.WriteBytes(e.Ciphertext)
+}
+
+// readSealed decodes what writeSealed wrote
+func (e *envelope) readSealed(r *codec.Reader) {
+    e.Nonce = r.ReadBytes()
+    e.Ciphertext = r.ReadBytes()
+}
+
+// checkParams rejects a decoded scrypt cost that would make deriving the key fail
+// or take forever
+func (e *envelope) checkParams() error {
+    if e.LogN == 0 || e.LogN > 30 {
+        return fmt.Errorf("scrypt cost 2^%d", e.LogN)
+    }
+    return nil
+}
+
+// deriveKey returns the AES key for passphrase
+func (e *envelope) deriveKey(passphrase []byte) ([]byte, error) {
+    key, err := scrypt.Key(passphrase, e.Salt, 1<<e.LogN, int(e.R), int(e.P), keyLen)
+    if err != nil {
+        return nil, fmt.Errorf("failed to derive key: %v", err)
+    }
+    return key, nil
+}
+
+// seal encrypts secret with key under a new nonce, authenticating header with it
+func (e *envelope) seal(key, secret, header []byte) error {
+    aead, err := newAEAD(key)
+    if err != nil {
+        return err
+    }
+    e.Nonce = make([]byte, aead.NonceSize())
+    if _, err := rand.Read(e.Nonce); err != nil {
+        return err
+    }
+    e.Ciphertext = aead.Seal(nil, e.Nonce, secret, header)
+    return nil
+}
+
+// open decrypts the secret with key, checking that header is the one it was sealed
+// with
+func (e *envelope) open(key, header []byte) ([]byte, error) {
+    aead, err := newAEAD(key)
+    if err != nil {
+        return nil, err
+    }
+    if len(e.Nonce) != aead.NonceSize() {
+        return nil, fmt.Errorf("corrupt wallet file: nonce of %d bytes", len(e.Nonce))
+    }
+    secret, err := aead.Open(nil, e.Nonce, e.Ciphertext, header)
+    if err != nil {
+        return nil, ErrWrongPassphrase
+    }
+    return secret, nil
+}
+
+// encryptedWallet is the content of an encrypted wallet file. The private key scalar
+// D is sealed in the envelope. The public key stays readable, so addresses and keys
+// can be shown while the wallet is locked, and is authenticated together with the
+// key derivation parameters.
+type encryptedWallet struct {
+    PublicKey []byte
+    envelope
+}
+
+// header returns encryptedMagic followed by the encoding of PublicKey (bytes), Salt
+// (bytes), LogN, R and P (uint32 each), which the ciphertext authenticates
+func (e *encryptedWallet) header() []byte {
+    w := codec.NewWriter()
+    w.WriteBytes(e.PublicKey)
+    e.writeParams(w)
+    return append([]byte(encryptedMagic), w.Bytes()...)
+}
+
+// serialize encodes the file: the header, then Nonce and Ciphertext (bytes each)
+func (e *encryptedWallet) serialize() []byte {
+    w := codec.NewWriter()
+    e.writeSealed(w)
+    return append(e.header(), w.Bytes()...)
+}
+
+// isEncrypted reports whether the content of a wallet file is encrypted
+func isEncrypted(data []byte) bool {
+    return bytes.HasPrefix(data, []byte(encryptedMagic))
+}
+
+// parseEncryptedWallet decodes a file written by serialize
+func parseEncryptedWallet(data []byte) (*encryptedWallet, error) {
+    var e encryptedWallet
+    r := codec.NewReader(data[len(encryptedMagic):])
+    e.PublicKey = r.ReadBytes()
+    e.readParams(r)
+    e.readSealed(r)
+    if err := r.Finish(); err != nil {
+        return nil, fmt.Errorf("corrupt wallet file: %v", err)
+    }
+    if err := e.checkParams(); err != nil {
+        return nil, fmt.Errorf("corrupt wallet file: %v", err)
+    }
+    return &e, nil
+}
+
+// open decrypts the wallet with key
+func (e *encryptedWallet) open(key []byte) (*Wallet, error) {
+    d, err := e.envelope.open(key, e.header())
+    if err != nil {
+        return nil, err
+    }
+    return walletFromD(d, e.PublicKey)
+}
+
+// sealWallet encrypts w under passphrase with a new salt and returns the file
+// content and the derived key
+func sealWallet(w *Wallet, passphrase []byte) (*encryptedWallet, []byte, error) {
+    env, key, err := newEnvelope(passphrase)
+    if err != nil {
+        return nil, nil, err
+    }
+    e := &encryptedWallet{PublicKey: w.PublicKey, envelope: *env}
+    if err := e.seal(key, w.PrivateKey.D.FillBytes(make([]byte, 32)), e.header()); err != nil {
+        return nil, nil, err
+    }
+    return e, key, nil
+}
+
+// newAEAD returns AES-256-GCM with key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+// isDerived reports whether address has no wallet file but is an address of the
+// deterministic wallet, whose seed then holds its key. Encrypting, unlocking and
+// locking such an address applies to the whole deterministic wallet.
+func isDerived(address string) bool {
+    if _, err := readWalletFile(address); !errors.Is(err, ErrWalletNotFound) {
+        return false
+    }
+    d, _ := FindDerivedAddress(address)
+    return d != nil
+}
+
+// IsEncrypted reports whether the wallet of address is encrypted
+func IsEncrypted(address string) (bool, error) {
+    if isDerived(address) {
+        h, err := readHDWalletFile()
+        if err != nil {
+            return false, err
+        }
+        return h.sealed != nil, nil
+    }
+    data, err := readWalletFile(address)
+    if err != nil {
+        return false, err
+    }
+    return isEncrypted(data), nil
+}
+
+// LoadPublicKey returns the public key of the wallet of address, which is readable
+// also while the wallet is locked
+func LoadPublicKey(address string) ([]byte, error) {
+    data, err := readWalletFile(address)
+    if errors.Is(err, ErrWalletNotFound) {
+        if pubKey, derr := loadDerivedPublicKey(address); pubKey != nil || derr != nil {
+            return pubKey, derr
+        }
+    }
+    if err != nil {
+        return nil, err
+    }
+    if !isEncrypted(data) {
+        w, err := decodePlainWallet(data)
+        if err != nil {
+            return nil, err
+        }
+        return w.PublicKey, nil
+    }
+    e, err := parseEncryptedWallet(data)
+    if err != nil {
+        return nil, err
+    }
+    return e.PublicKey, nil
+}
+
+// LoadWalletWithPassphrase loads the wallet of address, decrypting it with passphrase
+// if it is encrypted
+func LoadWalletWithPassphrase(address string, passphrase []byte) (*Wallet, error) {
+    if isDerived(address) {
+        h, err := loadHDWalletWithPassphrase(passphrase)
+        if err != nil {
+            return nil, err
+        }
+        d, err := FindDerivedAddress(address)
+        if err != nil {
+            return nil, err
+        }
+        return h.DeriveWallet(d.Account, d.Chain, d.Index)
+    }
+    data, err := readWalletFile(address)
+    if err != nil {
+        return nil, err
+    }
+    if !isEncrypted(data) {
+        return decodePlainWallet(data)
+    }
+    e, err := parseEncryptedWallet(data)
+    if err != nil {
+        return nil, err
+    }
+    key, err := e.deriveKey(passphrase)
+    if err != nil {
+        return nil, err
+    }
+    return e.open(key)
+}
+
+// NewEncryptedWallet creates a wallet whose file is encrypted with passphrase from the
+// start, so its private key is never written in the clear. The wallet is locked.
+func NewEncryptedWallet(passphrase []byte) (*Wallet, error) {
+    private, public := newKeyPair()
+    w := &Wallet{private, public}
+
+    e, _, err := sealWallet(w, passphrase)
+    if err != nil {
+        return nil, err
+    }
+    if err := os.MkdirAll(getWalletDir(), 0700); err != nil {
+        return nil, fmt.Errorf("failed to create wallet directory: %v", err)
+    }
+    if err := writeWalletFile(w.GetAddress(), e.serialize()); err != nil {
+        return nil, err
+    }
+    return w, nil
+}
+
+// EncryptWallet encrypts the unencrypted wallet of address with passphrase. The
+// wallet is locked afterwards.
+func EncryptWallet(address string, passphrase []byte) error {
+    if isDerived(address) {
+        return encryptHDWallet(passphrase)
+    }
+    data, err := readWalletFile(address)
+    if err != nil {
+        return err
+    }
+    if isEncrypted(data) {
+        return fmt.Errorf("wallet %s is already encrypted; use walletpassphrasechange", address)
+    }
+    w, err := decodePlainWallet(data)
+    if err != nil {
+        return err
+    }
+
+    e, _, err := sealWallet(w, passphrase)
+    if err != nil {
+        return err
+    }
+    return writeWalletFile(address, e.serialize())
+}
+
+// ChangePassphrase re-encrypts the wallet of address, encrypted with oldPassphrase,
+// with newPassphrase. The wallet is locked afterwards.
+func ChangePassphrase(address string, oldPassphrase, newPassphrase []byte) error {
+    if isDerived(address) {
+        return changeHDPassphrase(oldPassphrase, newPassphrase)
+    }
+    encrypted, err := IsEncrypted(address)
+    if err != nil {
+        return err
+    }
+    if !encrypted {
+        return fmt.Errorf("wallet %s is not encrypted; use encryptwallet", address)
+    }
+    w, err := LoadWalletWithPassphrase(address, oldPassphrase)
+    if err != nil {
+        return err
+    }
+
+    e, _, err := sealWallet(w, newPassphrase)
+    if err != nil {
+        return err
+    }
+    if err := writeWalletFile(address, e.serialize()); err != nil {
+        return err
+    }
+    return LockWallet(address)
+}
+
+// UnlockWallet makes the encrypted wallet of address loadable with LoadWallet for
+// timeout. The key derived from passphrase is held in memory by an agent process,
+// which exits when the timeout expires.
+func UnlockWallet(address string, passphrase []byte, timeout time.Duration) error {
+    if timeout <= 0 {
+        return fmt.Errorf("timeout must be positive")
+    }
+    if isDerived(address) {
+        return unlockHDWallet(passphrase, timeout)
+    }
+    data, err := readWalletFile(address)
+    if err != nil {
+        return err
+    }
+    if !isEncrypted(data) {
+        return fmt.Errorf("wallet %s is not encrypted", address)
+    }
+    e, err := parseEncryptedWallet(data)
+    if err != nil {
+        return err
+    }
+    key, err := e.deriveKey(passphrase)
+    if err != nil {
+        return err
+    }
+    if _, err := e.open(key); err != nil {
+        return err
+    }
+
+    id, err := addressFileID(address, PubKeyHashAddr)
+    if err != nil {
+        return err
+    }
+    if err := startAgent(id, key, time.Now().Add(timeout)); err != nil {
+        return fmt.Errorf("failed to unlock wallet: %v", err)
+    }
+    return nil
+}
+
+// LockWallet forgets the key of the wallet of address before its unlock timeout
+func LockWallet(address string) error {
+    name := hdWalletFile
+    if !isDerived(address) {
+        var err error
+        if name, err = addressFileID(address, PubKeyHashAddr); err != nil {
+            return err
+        }
+    }
+    if err := stopAgent(name); err != nil {
+        return fmt.Errorf("failed to lock wallet: %v", err)
+    }
+    return nil
+}
+
+// unlockedKey returns the key of the unlocked wallet of address, or nil if it is
+// locked
+func unlockedKey(address string) []byte {
+    id, err := addressFileID(address, PubKeyHashAddr)
+    if err != nil {
+        return nil
+    }
+    return agentKey(id)
+}
+
+// unlockDir returns the directory holding the sockets of the agents of unlocked
+// wallets, creating it if needed. It must be private to the user.
+func unlockDir() (string, error) {
+    dir := filepath.Join(os.TempDir(), fmt.Sprintf("blockchain-wallets-%d", os.Getuid()))
+    if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+        dir = filepath.Join(runtimeDir, "blockchain-wallets")
+    }
+    if err := os.MkdirAll(dir, 0700); err != nil {
+        return "", fmt.Errorf("failed to create %s: %v", dir, err)
+    }
+    info, err := os.Lstat(dir)
+    if err != nil {
+        return "", err
+    }
+    if !info.IsDir() || info.Mode().Perm() != 0700 {
+        return "", fmt.Errorf("%s must be a directory only its owner can access", dir)
+    }
+    return dir, nil
+}
+
+// writeWalletFile replaces the wallet file of address with data
+func writeWalletFile(address string, data []byte) error {
+    id, err := addressFileID(address, PubKeyHashAddr)
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(filepath.Join(getWalletDir(), id+".wallet"), data)
+}
+
+// writeFileAtomic replaces the file at path with data, so that a failed write leaves
+// the old file
+func writeFileAtomic(path string, data []byte) error {
+    tmpPath := path + ".tmp"
+    if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+        return fmt.Errorf("failed to write wallet: %v", err)
+    }
+    if err := os.Rename(tmpPath, path); err != nil {
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to write wallet: %v", err)
+    }
+    return nil
+}
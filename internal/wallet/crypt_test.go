@@ -0,0 +1,253 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMain runs the test binary as the wallet agent when unlocking starts it again
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == AgentCommand {
+		if err := RunAgent(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Helper to keep wallet files and agent sockets in temporary directories, returning
+// the directory of the sockets
+func useTempWalletDirs(t *testing.T) string {
+	t.Setenv("HOME", t.TempDir())
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	return filepath.Join(runtimeDir, "blockchain-wallets")
+}
+
+// Helper to create a wallet encrypted with passphrase and return its address
+func newEncryptedWallet(t *testing.T, passphrase string) string {
+	t.Helper()
+	address := NewWallet().GetAddress()
+	if err := EncryptWallet(address, []byte(passphrase)); err != nil {
+		t.Fatalf("Failed to encrypt wallet: %v", err)
+	}
+	return address
+}
+
+// Test an unlocked wallet locks itself when the timeout expires, without a load, and
+// its key is never written to a file
+func TestUnlockTimeout(t *testing.T) {
+	socketDir := useTempWalletDirs(t)
+	address := newEncryptedWallet(t, "secret")
+
+	if err := UnlockWallet(address, []byte("secret"), time.Second); err != nil {
+		t.Fatalf("Failed to unlock wallet: %v", err)
+	}
+	if _, err := LoadWallet(address); err != nil {
+		t.Fatalf("Failed to load unlocked wallet: %v", err)
+	}
+	entries, _ := os.ReadDir(socketDir)
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSocket == 0 {
+			t.Errorf("Unlocking left %s, which is not a socket", entry.Name())
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for entries, _ = os.ReadDir(socketDir); len(entries) > 0; entries, _ = os.ReadDir(socketDir) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Agent still listens after the timeout")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if _, err := LoadWallet(address); !errors.Is(err, ErrWalletLocked) {
+		t.Errorf("Expected the wallet to be locked after the timeout, got %v", err)
+	}
+}
+
+// Helper to return the path of the wallet file of address
+func walletFilePath(t *testing.T, address string) string {
+	t.Helper()
+	id, err := addressFileID(address, PubKeyHashAddr)
+	if err != nil {
+		t.Fatalf("Invalid address %s: %v", address, err)
+	}
+	return filepath.Join(getWalletDir(), id+".wallet")
+}
+
+// Test an encrypted wallet loads only between unlocking and locking, with the same
+// key it had before encryption
+func TestEncryptUnlockLock(t *testing.T) {
+	useTempWalletDirs(t)
+	w := NewWallet()
+	address := w.GetAddress()
+	if err := EncryptWallet(address, []byte("secret")); err != nil {
+		t.Fatalf("Failed to encrypt wallet: %v", err)
+	}
+	if err := EncryptWallet(address, []byte("secret")); err == nil {
+		t.Error("Encrypted an encrypted wallet again")
+	}
+
+	if _, err := LoadWallet(address); !errors.Is(err, ErrWalletLocked) {
+		t.Fatalf("Expected a locked wallet after encryption, got %v", err)
+	}
+	if pubKey, err := LoadPublicKey(address); err != nil || string(pubKey) != string(w.PublicKey) {
+		t.Errorf("Public key of the locked wallet is unreadable: %v", err)
+	}
+
+	if err := UnlockWallet(address, []byte("secret"), time.Minute); err != nil {
+		t.Fatalf("Failed to unlock wallet: %v", err)
+	}
+	loaded, err := LoadWallet(address)
+	if err != nil {
+		t.Fatalf("Failed to load unlocked wallet: %v", err)
+	}
+	if loaded.PrivateKey.D.Cmp(w.PrivateKey.D) != 0 {
+		t.Error("Unlocked wallet has a different private key")
+	}
+
+	if err := LockWallet(address); err != nil {
+		t.Fatalf("Failed to lock wallet: %v", err)
+	}
+	if _, err := LoadWallet(address); !errors.Is(err, ErrWalletLocked) {
+		t.Errorf("Expected a locked wallet after locking, got %v", err)
+	}
+	if err := LockWallet(address); err != nil {
+		t.Errorf("Locking a locked wallet failed: %v", err)
+	}
+}
+
+// Test a wallet created encrypted never has its private key written in the clear and
+// starts locked
+func TestNewEncryptedWallet(t *testing.T) {
+	useTempWalletDirs(t)
+	if _, err := NewEncryptedWallet(nil); err == nil {
+		t.Error("Created a wallet with an empty passphrase")
+	}
+
+	w, err := NewEncryptedWallet([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	address := w.GetAddress()
+	data, err := os.ReadFile(walletFilePath(t, address))
+	if err != nil {
+		t.Fatalf("Failed to read wallet file: %v", err)
+	}
+	if !isEncrypted(data) || bytes.Contains(data, w.PrivateKey.D.Bytes()) {
+		t.Error("Wallet file holds the private key in the clear")
+	}
+	if _, err := LoadWallet(address); !errors.Is(err, ErrWalletLocked) {
+		t.Errorf("Expected a new encrypted wallet to be locked, got %v", err)
+	}
+	loaded, err := LoadWalletWithPassphrase(address, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Failed to load wallet with its passphrase: %v", err)
+	}
+	if loaded.PrivateKey.D.Cmp(w.PrivateKey.D) != 0 {
+		t.Error("Loaded wallet has a different private key")
+	}
+}
+
+// Test a wrong passphrase neither unlocks nor decrypts the wallet
+func TestWrongPassphrase(t *testing.T) {
+	useTempWalletDirs(t)
+	address := newEncryptedWallet(t, "secret")
+
+	if err := UnlockWallet(address, []byte("wrong"), time.Minute); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected a wrong passphrase error from unlocking, got %v", err)
+	}
+	if _, err := LoadWallet(address); !errors.Is(err, ErrWalletLocked) {
+		t.Errorf("Expected the wallet to stay locked, got %v", err)
+	}
+	if _, err := LoadWalletWithPassphrase(address, []byte("wrong")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected a wrong passphrase error from loading, got %v", err)
+	}
+	if err := ChangePassphrase(address, []byte("wrong"), []byte("new")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected a wrong passphrase error from changing it, got %v", err)
+	}
+}
+
+// Test changing the passphrase makes only the new one work and locks the wallet
+func TestChangePassphrase(t *testing.T) {
+	useTempWalletDirs(t)
+	address := newEncryptedWallet(t, "old")
+	if err := UnlockWallet(address, []byte("old"), time.Minute); err != nil {
+		t.Fatalf("Failed to unlock wallet: %v", err)
+	}
+
+	if err := ChangePassphrase(address, []byte("old"), []byte("")); err == nil {
+		t.Error("Changed to an empty passphrase")
+	}
+	if err := ChangePassphrase(address, []byte("old"), []byte("new")); err != nil {
+		t.Fatalf("Failed to change passphrase: %v", err)
+	}
+	if _, err := LoadWallet(address); !errors.Is(err, ErrWalletLocked) {
+		t.Errorf("Expected the wallet to be locked after the change, got %v", err)
+	}
+	if _, err := LoadWalletWithPassphrase(address, []byte("old")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Old passphrase still decrypts the wallet: %v", err)
+	}
+	if err := UnlockWallet(address, []byte("new"), time.Minute); err != nil {
+		t.Fatalf("Failed to unlock with the new passphrase: %v", err)
+	}
+	if _, err := LoadWallet(address); err != nil {
+		t.Errorf("Failed to load the wallet unlocked with the new passphrase: %v", err)
+	}
+	LockWallet(address)
+}
+
+// Test truncated and tampered wallet files are reported as errors, not panics, for
+// both plain and encrypted wallets
+func TestCorruptWalletFile(t *testing.T) {
+	useTempWalletDirs(t)
+	plain := NewWallet().GetAddress()
+	encrypted := newEncryptedWallet(t, "secret")
+
+	for _, address := range []string{plain, encrypted} {
+		path := walletFilePath(t, address)
+		original, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read wallet file: %v", err)
+		}
+
+		var corrupt [][]byte
+		for _, n := range []int{0, 3, len(encryptedMagic), len(encryptedMagic) + 10, len(original) / 2, len(original) - 1} {
+			corrupt = append(corrupt, original[:n])
+		}
+		// The key is at the end of both formats; an encrypted file's header is
+		// authenticated too
+		positions := []int{len(original) - 20, len(original) - 1}
+		if isEncrypted(original) {
+			positions = append(positions, len(encryptedMagic)+2)
+		}
+		for _, i := range positions {
+			tampered := append([]byte{}, original...)
+			tampered[i] ^= 0x01
+			corrupt = append(corrupt, tampered)
+		}
+
+		for i, data := range corrupt {
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatalf("Failed to write wallet file: %v", err)
+			}
+			if _, err := LoadWallet(address); err == nil {
+				t.Errorf("Corrupt file %d of %s was loaded", i, address)
+			}
+			if _, err := LoadWalletWithPassphrase(address, []byte("secret")); err == nil {
+				t.Errorf("Corrupt file %d of %s was decrypted", i, address)
+			}
+			if err := UnlockWallet(address, []byte("secret"), time.Minute); err == nil {
+				LockWallet(address)
+				t.Errorf("Corrupt file %d of %s was unlocked", i, address)
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package wallet
+
+import (
+    "crypto/elliptic"
+    "crypto/hmac"
+    "crypto/sha512"
+    "encoding/binary"
+    "fmt"
+    "math/big"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/codec"
+)
+
+const (
+    // HardenedKeyStart is the first hardened child index. Hardened children are
+    // derived from the parent's private key, so a leaked child key and the parent's
+    // chain code do not reveal the parent.
+    HardenedKeyStart = uint32(0x80000000)
+
+    // MinSeedLen and MaxSeedLen bound the length of a master seed in bytes
+    MinSeedLen = 16
+    MaxSeedLen = 64
+
+    // masterKeySalt is the HMAC key deriving the master key from a seed, as defined by
+    // SLIP-10 for the NIST P-256 curve
+    masterKeySalt = "Nist256p1 seed"
+)
+
+// ExtendedKey is a private key with the chain code needed to derive its children,
+// following BIP32 as generalized to the P-256 curve by SLIP-10
+type ExtendedKey struct {
+    key       []byte // Private key scalar, 32 bytes
+    chainCode []byte // 32 bytes
+    depth     uint8
+    childNum  uint32
+}
+
+// NewMasterKey derives the root of a key tree from seed
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+    if len(seed) < MinSeedLen || len(seed) > MaxSeedLen {
+        return nil, fmt.Errorf("seed must be %d to %d bytes, got %d", MinSeedLen, MaxSeedLen, len(seed))
+    }
+
+    mac := hmac.New(sha512.New, []byte(masterKeySalt))
+    mac.Write(seed)
+    sum := mac.Sum(nil)
+    for !isValidScalar(sum[:32]) {
+        // Astronomically unlikely; SLIP-10 derives again from the whole result
+        mac.Reset()
+        mac.Write(sum)
+        sum = mac.Sum(nil)
+    }
+    return &ExtendedKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// Child derives the child key with index i, hardened if i is at least HardenedKeyStart
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+    if k.depth == 255 {
+        return nil, fmt.Errorf("cannot derive beyond depth 255")
+    }
+
+    // Hardened children hash the private key, normal ones the compressed public key
+    var data []byte
+    if i >= HardenedKeyStart {
+        data = append([]byte{0x00}, k.key...)
+    } else {
+        curve := elliptic.P256()
+        x, y := curve.ScalarBaseMult(k.key)
+        data = elliptic.MarshalCompressed(curve, x, y)
+    }
+    data = binary.BigEndian.AppendUint32(data, i)
+
+    n := elliptic.P256().Params().N
+    for {
+        mac := hmac.New(sha512.New, k.chainCode)
+        mac.Write(data)
+        sum := mac.Sum(nil)
+
+        il := new(big.Int).SetBytes(sum[:32])
+        if il.Cmp(n) < 0 {
+            child := il.Add(il, new(big.Int).SetBytes(k.key))
+            child.Mod(child, n)
+            if child.Sign() != 0 {
+                return &ExtendedKey{
+                    key:       child.FillBytes(make([]byte, 32)),
+                    chainCode: sum[32:],
+                    depth:     k.depth + 1,
+                    childNum:  i,
+                }, nil
+            }
+        }
+        // Invalid key: SLIP-10 retries with the right half of the result
+        data = append([]byte{0x01}, sum[32:]...)
+        data = binary.BigEndian.AppendUint32(data, i)
+    }
+}
+
+// Derive follows path from k, one child index per level
+func (k *ExtendedKey) Derive(path []uint32) (*ExtendedKey, error) {
+    key := k
+    for _, i := range path {
+        var err error
+        if key, err = key.Child(i); err != nil {
+            return nil, err
+        }
+    }
+    return key, nil
+}
+
+// Wallet returns the key pair of k
+func (k *ExtendedKey) Wallet() (*Wallet, error) {
+    curve := elliptic.P256()
+    x, y := curve.ScalarBaseMult(k.key)
+    pubKey := make([]byte, 64)
+    x.FillBytes(pubKey[:32])
+    y.FillBytes(pubKey[32:])
+    return walletFromD(k.key, pubKey)
+}
+
+// ExtendedPubKey is the public half of an ExtendedKey. It derives the public keys of
+// normal children, so the addresses of a key tree can be derived without its private
+// keys.
+type ExtendedPubKey struct {
+    pubKey    []byte // Compressed public key, 33 bytes
+    chainCode []byte // 32 bytes
+    depth     uint8
+    childNum  uint32
+}
+
+// Public returns the public half of k
+func (k *ExtendedKey) Public() *ExtendedPubKey {
+    curve := elliptic.P256()
+    x, y := curve.ScalarBaseMult(k.key)
+    return &ExtendedPubKey{
+        pubKey:    elliptic.MarshalCompressed(curve, x, y),
+        chainCode: k.chainCode,
+        depth:     k.depth,
+        childNum:  k.childNum,
+    }
+}
+
+// Child derives the public half of the normal child with index i. Hardened children
+// need the private key.
+func (k *ExtendedPubKey) Child(i uint32) (*ExtendedPubKey, error) {
+    if i >= HardenedKeyStart {
+        return nil, fmt.Errorf("hardened child %d cannot be derived from a public key", i-HardenedKeyStart)
+    }
+    if k.depth == 255 {
+        return nil, fmt.Errorf("cannot derive beyond depth 255")
+    }
+    curve := elliptic.P256()
+    px, py := elliptic.UnmarshalCompressed(curve, k.pubKey)
+    if px == nil {
+        return nil, fmt.Errorf("invalid public key")
+    }
+
+    data := binary.BigEndian.AppendUint32(append([]byte{}, k.pubKey...), i)
+    n := curve.Params().N
+    for {
+        mac := hmac.New(sha512.New, k.chainCode)
+        mac.Write(data)
+        sum := mac.Sum(nil)
+
+        // The child is the parent plus the point of the scalar the private
+        // derivation adds to the parent's private key
+        if new(big.Int).SetBytes(sum[:32]).Cmp(n) < 0 {
+            x, y := curve.ScalarBaseMult(sum[:32])
+            x, y = curve.Add(x, y, px, py)
+            if x.Sign() != 0 || y.Sign() != 0 {
+                return &ExtendedPubKey{
+                    pubKey:    elliptic.MarshalCompressed(curve, x, y),
+                    chainCode: sum[32:],
+                    depth:     k.depth + 1,
+                    childNum:  i,
+                }, nil
+            }
+        }
+        // Invalid key: SLIP-10 retries with the right half of the result
+        data = append([]byte{0x01}, sum[32:]...)
+        data = binary.BigEndian.AppendUint32(data, i)
+    }
+}
+
+// Derive follows path from k, one normal child index per level
+func (k *ExtendedPubKey) Derive(path []uint32) (*ExtendedPubKey, error) {
+    key := k
+    for _, i := range path {
+        var err error
+        if key, err = key.Child(i); err != nil {
+            return nil, err
+        }
+    }
+    return key, nil
+}
+
+// PublicKey returns the public key as wallets hold it, X followed by Y
+func (k *ExtendedPubKey) PublicKey() []byte {
+    x, y := elliptic.UnmarshalCompressed(elliptic.P256(), k.pubKey)
+    pubKey := make([]byte, 64)
+    x.FillBytes(pubKey[:32])
+    y.FillBytes(pubKey[32:])
+    return pubKey
+}
+
+// encode writes the public key and chain code (bytes each); the position in the tree
+// is known from where the key is stored
+func (k *ExtendedPubKey) encode(w *codec.Writer) {
+    w.WriteBytes(k.pubKey)
+    w.WriteBytes(k.chainCode)
+}
+
+// decodeExtendedPubKey reads a key written by encode, which sits at depth with
+// index childNum
+func decodeExtendedPubKey(r *codec.Reader, depth uint8, childNum uint32) (*ExtendedPubKey, error) {
+    k := &ExtendedPubKey{pubKey: r.ReadBytes(), chainCode: r.ReadBytes(), depth: depth, childNum: childNum}
+    if err := r.Err(); err != nil {
+        return nil, err
+    }
+    if x, _ := elliptic.UnmarshalCompressed(elliptic.P256(), k.pubKey); x == nil || len(k.chainCode) != 32 {
+        return nil, fmt.Errorf("invalid extended public key")
+    }
+    return k, nil
+}
+
+// isValidScalar reports whether b is a usable private key: not zero and below the
+// order of the curve
+func isValidScalar(b []byte) bool {
+    d := new(big.Int).SetBytes(b)
+    return d.Sign() != 0 && d.Cmp(elliptic.P256().Params().N) < 0
+}
+
+// FormatPath returns path in the usual notation, such as m/44'/1'/0'/0/1
+func FormatPath(path []uint32) string {
+    s := "m"
+    for _, i := range path {
+        if i >= HardenedKeyStart {
+            s += fmt.Sprintf("/%d'", i-HardenedKeyStart)
+        } else {
+            s += fmt.Sprintf("/%d", i)
+        }
+    }
+    return s
+}
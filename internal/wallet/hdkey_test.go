@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Test the key tree matches test vector 1 of SLIP-10 for the nist256p1 curve
+func TestSLIP10Vector(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		t.Fatalf("Failed to derive master key: %v", err)
+	}
+
+	tests := []struct {
+		path      []uint32
+		chainCode string
+		key       string
+		pubKey    string
+	}{
+		{
+			nil,
+			"beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea",
+			"612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2",
+			"0266874dc6ade47b3ecd096745ca09bcd29638dd52c2c12117b11ed3e458cfa9e8",
+		},
+		{
+			[]uint32{HardenedKeyStart},
+			"3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11",
+			"6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c",
+			"0384610f5ecffe8fda089363a41f56a5c7ffc1d81b59a612d0d649b2d22355590c",
+		},
+		{
+			[]uint32{HardenedKeyStart, 1},
+			"4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c",
+			"284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129",
+			"03526c63f8d0b4bbbf9c80df553fe66742df4676b241dabefdef67733e070f6844",
+		},
+	}
+	for _, test := range tests {
+		k, err := master.Derive(test.path)
+		if err != nil {
+			t.Fatalf("Failed to derive %s: %v", FormatPath(test.path), err)
+		}
+		if got := hex.EncodeToString(k.chainCode); got != test.chainCode {
+			t.Errorf("%s: chain code is %s, want %s", FormatPath(test.path), got, test.chainCode)
+		}
+		if got := hex.EncodeToString(k.key); got != test.key {
+			t.Errorf("%s: private key is %s, want %s", FormatPath(test.path), got, test.key)
+		}
+		if got := hex.EncodeToString(k.Public().pubKey); got != test.pubKey {
+			t.Errorf("%s: public key is %s, want %s", FormatPath(test.path), got, test.pubKey)
+		}
+		if k.depth != uint8(len(test.path)) {
+			t.Errorf("%s: depth is %d", FormatPath(test.path), k.depth)
+		}
+	}
+}
+
+// Test deriving normal children from a public key gives the public keys of the
+// children derived from the private key, and hardened ones need the private key
+func TestPublicDerivation(t *testing.T) {
+	master, err := NewMasterKey(bytes.Repeat([]byte{0x42}, 32))
+	if err != nil {
+		t.Fatalf("Failed to derive master key: %v", err)
+	}
+	account, err := master.Derive(accountPath(0))
+	if err != nil {
+		t.Fatalf("Failed to derive account key: %v", err)
+	}
+
+	path := []uint32{InternalChain, 7}
+	private, err := account.Derive(path)
+	if err != nil {
+		t.Fatalf("Failed to derive private key: %v", err)
+	}
+	public, err := account.Public().Derive(path)
+	if err != nil {
+		t.Fatalf("Failed to derive public key: %v", err)
+	}
+	if !bytes.Equal(public.pubKey, private.Public().pubKey) || !bytes.Equal(public.chainCode, private.chainCode) {
+		t.Error("Public derivation differs from private derivation")
+	}
+	w, err := private.Wallet()
+	if err != nil {
+		t.Fatalf("Failed to make wallet: %v", err)
+	}
+	if !bytes.Equal(public.PublicKey(), w.PublicKey) {
+		t.Error("Public key of the derived wallet differs from the derived public key")
+	}
+
+	if _, err := account.Public().Child(HardenedKeyStart); err == nil {
+		t.Error("Derived a hardened child from a public key")
+	}
+	for _, n := range []int{MinSeedLen - 1, MaxSeedLen + 1} {
+		if _, err := NewMasterKey(make([]byte, n)); err == nil {
+			t.Errorf("Accepted a %d byte seed", n)
+		}
+	}
+}
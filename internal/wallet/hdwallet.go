@@ -0,0 +1,604 @@
+package wallet
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/codec"
+)
+
+const (
+    // hdWalletFile is the name of the file holding the master seed of the
+    // deterministic wallet and how many keys of each chain are in use
+    hdWalletFile = "seed.hd"
+
+    // hdMagic starts the deterministic wallet file, hdEncryptedMagic the file of an
+    // encrypted deterministic wallet
+    hdMagic          = "DLHD"
+    hdEncryptedMagic = "DLHE"
+
+    // Keys are derived at m/44'/1'/account'/chain/index, the layout of BIP44. The
+    // chain has no coin type registered in SLIP-44, so it uses 1, which SLIP-44
+    // reserves for test networks of all coins, rather than claim the 0 of Bitcoin.
+    hdPurpose  = 44
+    hdCoinType = 1
+
+    // DefaultGapLimit is how many unused addresses in a row end the search for used
+    // ones when restoring, as in BIP44
+    DefaultGapLimit = 20
+
+    // ExternalChain holds the receiving addresses of an account, InternalChain the
+    // addresses its change is paid to
+    ExternalChain = uint32(0)
+    InternalChain = uint32(1)
+)
+
+// ErrNoHDWallet is returned when no deterministic wallet was created
+var ErrNoHDWallet = errors.New("no deterministic wallet; create one with createwallet -hd")
+
+// errHDWalletLocked is returned when the seed of the encrypted deterministic wallet is
+// needed while it is locked
+var errHDWalletLocked = fmt.Errorf("%w: unlock the deterministic wallet with walletpassphrase and one of its addresses", ErrWalletLocked)
+
+// HDWallet derives every key from one master seed, so a backup of the seed recovers
+// all addresses, also those made after the backup. Keys are grouped in accounts, each
+// with a chain of receiving addresses and a chain of change addresses.
+//
+// An encrypted deterministic wallet seals its seed like a wallet file seals its key.
+// The public keys of its accounts stay readable, so its addresses can be listed and
+// new ones derived while it is locked; signing and adding accounts need the seed.
+type HDWallet struct {
+    Seed     []byte // Nil while the wallet is encrypted and locked
+    Accounts []HDAccount
+
+    accountKeys []*ExtendedPubKey // Public keys of the first accounts, known without the seed
+    sealed      *envelope         // Sealed seed of an encrypted wallet, nil if not encrypted
+    key         []byte            // Key of sealed while the wallet is unlocked
+}
+
+// HDAccount records how many keys of each chain of an account are in use
+type HDAccount struct {
+    Next [2]uint32 // Index of the next unused key of ExternalChain and InternalChain
+}
+
+// DerivedAddress is an address of the deterministic wallet and where its key is in
+// the key tree
+type DerivedAddress struct {
+    Address string
+    Account uint32
+    Chain   uint32
+    Index   uint32
+}
+
+// Path returns the derivation path of the address's key
+func (d DerivedAddress) Path() []uint32 {
+    return append(accountPath(d.Account), d.Chain, d.Index)
+}
+
+// accountPath returns the derivation path of the key of account
+func accountPath(account uint32) []uint32 {
+    return []uint32{HardenedKeyStart + hdPurpose, HardenedKeyStart + hdCoinType, HardenedKeyStart + account}
+}
+
+// NewHDWallet returns a deterministic wallet with one account and no keys in use
+func NewHDWallet(seed []byte) (*HDWallet, error) {
+    if _, err := NewMasterKey(seed); err != nil {
+        return nil, err
+    }
+    return &HDWallet{Seed: seed, Accounts: []HDAccount{{}}}, nil
+}
+
+// CreateHDWallet saves a new deterministic wallet with seed. There is at most one;
+// its file is not replaced.
+func CreateHDWallet(seed []byte) (*HDWallet, error) {
+    h, err := NewHDWallet(seed)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := os.Stat(HDWalletPath()); err == nil {
+        return nil, fmt.Errorf("a deterministic wallet already exists in %s", HDWalletPath())
+    }
+    if err := h.Save(); err != nil {
+        return nil, err
+    }
+    return h, nil
+}
+
+// LoadHDWallet loads the deterministic wallet, or returns ErrNoHDWallet if none was
+// created. An encrypted wallet has its seed only if it was unlocked with
+// UnlockWallet.
+func LoadHDWallet() (*HDWallet, error) {
+    h, err := readHDWalletFile()
+    if err != nil || h.sealed == nil {
+        return h, err
+    }
+    if key := agentKey(hdWalletFile); key != nil {
+        // A key that no longer opens the seed is from before a passphrase change
+        if err := h.unlock(key); err != nil && err != ErrWrongPassphrase {
+            return nil, err
+        }
+    }
+    return h, nil
+}
+
+// readHDWalletFile reads the deterministic wallet, leaving an encrypted one locked
+func readHDWalletFile() (*HDWallet, error) {
+    data, err := ioutil.ReadFile(HDWalletPath())
+    if os.IsNotExist(err) {
+        return nil, ErrNoHDWallet
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read deterministic wallet: %v", err)
+    }
+    if bytes.HasPrefix(data, []byte(hdEncryptedMagic)) {
+        return parseEncryptedHDWallet(data)
+    }
+    if !bytes.HasPrefix(data, []byte(hdMagic)) {
+        return nil, fmt.Errorf("corrupt deterministic wallet file")
+    }
+
+    var h HDWallet
+    r := codec.NewReader(data[len(hdMagic):])
+    h.Seed = r.ReadBytes()
+    h.Accounts = readHDAccounts(r)
+    if err := r.Finish(); err != nil {
+        return nil, fmt.Errorf("corrupt deterministic wallet file: %v", err)
+    }
+    if _, err := NewMasterKey(h.Seed); err != nil {
+        return nil, fmt.Errorf("corrupt deterministic wallet file: %v", err)
+    }
+    return &h, nil
+}
+
+// parseEncryptedHDWallet decodes a file written by Save for an encrypted wallet: its
+// header, then Nonce and Ciphertext (bytes each) and the accounts
+func parseEncryptedHDWallet(data []byte) (*HDWallet, error) {
+    h := &HDWallet{sealed: &envelope{}}
+    r := codec.NewReader(data[len(hdEncryptedMagic):])
+    h.sealed.readParams(r)
+    n := r.ReadCount(8)
+    for account := 0; account < n; account++ {
+        key, err := decodeExtendedPubKey(r, uint8(len(accountPath(0))), HardenedKeyStart+uint32(account))
+        if err != nil {
+            return nil, fmt.Errorf("corrupt deterministic wallet file: %v", err)
+        }
+        h.accountKeys = append(h.accountKeys, key)
+    }
+    h.sealed.readSealed(r)
+    h.Accounts = readHDAccounts(r)
+    if err := r.Finish(); err != nil {
+        return nil, fmt.Errorf("corrupt deterministic wallet file: %v", err)
+    }
+    if err := h.sealed.checkParams(); err != nil {
+        return nil, fmt.Errorf("corrupt deterministic wallet file: %v", err)
+    }
+    if len(h.Accounts) != len(h.accountKeys) {
+        return nil, fmt.Errorf("corrupt deterministic wallet file: %d accounts but %d account keys", len(h.Accounts), len(h.accountKeys))
+    }
+    return h, nil
+}
+
+// readHDAccounts decodes a list of the next index of each chain of each account, as
+// uint32
+func readHDAccounts(r *codec.Reader) []HDAccount {
+    var accounts []HDAccount
+    n := r.ReadCount(8)
+    for i := 0; i < n; i++ {
+        var account HDAccount
+        account.Next[ExternalChain] = r.ReadUint32()
+        account.Next[InternalChain] = r.ReadUint32()
+        accounts = append(accounts, account)
+    }
+    return accounts
+}
+
+// header returns what the seed of an encrypted wallet is sealed with:
+// hdEncryptedMagic, the scrypt parameters and the public key and chain code of each
+// account (bytes each). The counts of keys in use change while the wallet is locked,
+// so they are not part of it.
+func (h *HDWallet) header() []byte {
+    w := codec.NewWriter()
+    h.sealed.writeParams(w)
+    w.WriteCount(len(h.Accounts))
+    for _, key := range h.accountKeys[:len(h.Accounts)] {
+        key.encode(w)
+    }
+    return append([]byte(hdEncryptedMagic), w.Bytes()...)
+}
+
+// unlock decrypts the seed of an encrypted wallet with key
+func (h *HDWallet) unlock(key []byte) error {
+    seed, err := h.sealed.open(key, h.header())
+    if err != nil {
+        return err
+    }
+    if _, err := NewMasterKey(seed); err != nil {
+        return fmt.Errorf("corrupt deterministic wallet file: %v", err)
+    }
+    h.Seed, h.key = seed, key
+    return nil
+}
+
+// Save writes the wallet to its file: hdMagic, then Seed (bytes) and Accounts (list
+// of the next index of each chain as uint32), or for an encrypted wallet, its header,
+// sealed seed and Accounts
+func (h *HDWallet) Save() error {
+    w := codec.NewWriter()
+    var data []byte
+    if h.sealed == nil {
+        w.WriteBytes(h.Seed)
+        data = []byte(hdMagic)
+    } else {
+        // Accounts may have been added since the seed was sealed, so it is sealed
+        // again with the new header whenever it is known
+        for account := range h.Accounts {
+            if _, err := h.accountPubKey(uint32(account)); err != nil {
+                return err
+            }
+        }
+        if h.Seed != nil {
+            if err := h.sealed.seal(h.key, h.Seed, h.header()); err != nil {
+                return err
+            }
+        }
+        data = h.header()
+        h.sealed.writeSealed(w)
+    }
+    w.WriteCount(len(h.Accounts))
+    for _, account := range h.Accounts {
+        w.WriteUint32(account.Next[ExternalChain])
+        w.WriteUint32(account.Next[InternalChain])
+    }
+    if err := os.MkdirAll(getWalletDir(), 0700); err != nil {
+        return err
+    }
+    return writeFileAtomic(HDWalletPath(), append(data, w.Bytes()...))
+}
+
+// accountKey returns the extended key of account, from which its chains derive
+func (h *HDWallet) accountKey(account uint32) (*ExtendedKey, error) {
+    if account >= HardenedKeyStart {
+        return nil, fmt.Errorf("account %d is out of range", account)
+    }
+    if h.Seed == nil {
+        return nil, errHDWalletLocked
+    }
+    master, err := NewMasterKey(h.Seed)
+    if err != nil {
+        return nil, err
+    }
+    return master.Derive(accountPath(account))
+}
+
+// accountPubKey returns the public half of the extended key of account, which is
+// known without the seed for the accounts of an encrypted wallet
+func (h *HDWallet) accountPubKey(account uint32) (*ExtendedPubKey, error) {
+    if int64(account) < int64(len(h.accountKeys)) {
+        return h.accountKeys[account], nil
+    }
+    key, err := h.accountKey(account)
+    if err != nil {
+        return nil, err
+    }
+    pubKey := key.Public()
+    if int(account) == len(h.accountKeys) {
+        h.accountKeys = append(h.accountKeys, pubKey)
+    }
+    return pubKey, nil
+}
+
+// deriveAddress returns the address of the key at index of chain under accountKey
+func deriveAddress(accountKey *ExtendedPubKey, account, chain, index uint32) (DerivedAddress, error) {
+    if chain != ExternalChain && chain != InternalChain {
+        return DerivedAddress{}, fmt.Errorf("unknown chain %d", chain)
+    }
+    if index >= HardenedKeyStart {
+        return DerivedAddress{}, fmt.Errorf("chain %d of account %d has no unused keys left", chain, account)
+    }
+    key, err := accountKey.Derive([]uint32{chain, index})
+    if err != nil {
+        return DerivedAddress{}, err
+    }
+    return DerivedAddress{KeyHashAddress(HashPubKey(key.PublicKey())), account, chain, index}, nil
+}
+
+// DeriveWallet returns the key pair at index of chain of account
+func (h *HDWallet) DeriveWallet(account, chain, index uint32) (*Wallet, error) {
+    accountKey, err := h.accountKey(account)
+    if err != nil {
+        return nil, err
+    }
+    key, err := accountKey.Derive([]uint32{chain, index})
+    if err != nil {
+        return nil, err
+    }
+    return key.Wallet()
+}
+
+// derivePublicKey returns the public key at index of chain of account
+func (h *HDWallet) derivePublicKey(account, chain, index uint32) ([]byte, error) {
+    accountKey, err := h.accountPubKey(account)
+    if err != nil {
+        return nil, err
+    }
+    key, err := accountKey.Derive([]uint32{chain, index})
+    if err != nil {
+        return nil, err
+    }
+    return key.PublicKey(), nil
+}
+
+// PeekAddress returns the next unused address of chain of account without marking it
+// used
+func (h *HDWallet) PeekAddress(account, chain uint32) (DerivedAddress, error) {
+    if chain != ExternalChain && chain != InternalChain {
+        return DerivedAddress{}, fmt.Errorf("unknown chain %d", chain)
+    }
+    var next uint32
+    if int(account) < len(h.Accounts) {
+        next = h.Accounts[account].Next[chain]
+    } else if int64(account) > int64(len(h.Accounts)) {
+        return DerivedAddress{}, fmt.Errorf("account %d does not exist; the next new account is %d", account, len(h.Accounts))
+    }
+    accountKey, err := h.accountPubKey(account)
+    if err != nil {
+        return DerivedAddress{}, err
+    }
+    return deriveAddress(accountKey, account, chain, next)
+}
+
+// NextAddress derives the next unused address of chain of account and marks it used.
+// Passing the number of accounts adds an account. The caller saves the wallet.
+func (h *HDWallet) NextAddress(account, chain uint32) (DerivedAddress, error) {
+    d, err := h.PeekAddress(account, chain)
+    if err != nil {
+        return DerivedAddress{}, err
+    }
+    if int(account) == len(h.Accounts) {
+        h.Accounts = append(h.Accounts, HDAccount{})
+    }
+    h.Accounts[account].Next[chain]++
+    return d, nil
+}
+
+// Addresses returns the addresses of every key in use, by account, then chain, then
+// index
+func (h *HDWallet) Addresses() ([]DerivedAddress, error) {
+    var addresses []DerivedAddress
+    for account, a := range h.Accounts {
+        accountKey, err := h.accountPubKey(uint32(account))
+        if err != nil {
+            return nil, err
+        }
+        for _, chain := range []uint32{ExternalChain, InternalChain} {
+            for index := uint32(0); index < a.Next[chain]; index++ {
+                d, err := deriveAddress(accountKey, uint32(account), chain, index)
+                if err != nil {
+                    return nil, err
+                }
+                addresses = append(addresses, d)
+            }
+        }
+    }
+    return addresses, nil
+}
+
+// Rescan finds the keys in use after restoring the wallet from its seed, where
+// isUsed reports whether the chain pays to a public key hash. A chain's keys are
+// searched until gapLimit unused ones in a row, and accounts until one without used
+// keys. Keys already in use stay in use.
+func (h *HDWallet) Rescan(isUsed func(pubKeyHash []byte) bool, gapLimit int) error {
+    if gapLimit <= 0 {
+        return fmt.Errorf("gap limit must be positive")
+    }
+
+    var found []HDAccount
+    for account := uint32(0); ; account++ {
+        accountKey, err := h.accountPubKey(account)
+        if err != nil {
+            return err
+        }
+        var a HDAccount
+        for _, chain := range []uint32{ExternalChain, InternalChain} {
+            chainKey, err := accountKey.Child(chain)
+            if err != nil {
+                return err
+            }
+            for index, gap := uint32(0), 0; gap < gapLimit && index < HardenedKeyStart; index++ {
+                key, err := chainKey.Child(index)
+                if err != nil {
+                    return err
+                }
+                if isUsed(HashPubKey(key.PublicKey())) {
+                    a.Next[chain] = index + 1
+                    gap = 0
+                } else {
+                    gap++
+                }
+            }
+        }
+        if a.Next == [2]uint32{} && account > 0 {
+            break
+        }
+        found = append(found, a)
+        if a.Next == [2]uint32{} {
+            break
+        }
+    }
+
+    for account, a := range h.Accounts {
+        if account >= len(found) {
+            found = append(found, a)
+            continue
+        }
+        for _, chain := range []uint32{ExternalChain, InternalChain} {
+            if a.Next[chain] > found[account].Next[chain] {
+                found[account].Next[chain] = a.Next[chain]
+            }
+        }
+    }
+    h.Accounts = found
+    return nil
+}
+
+// NewDerivedAddress derives, records and returns the next unused address of chain of
+// account of the deterministic wallet
+func NewDerivedAddress(account, chain uint32) (DerivedAddress, error) {
+    h, err := LoadHDWallet()
+    if err != nil {
+        return DerivedAddress{}, err
+    }
+    d, err := h.NextAddress(account, chain)
+    if err != nil {
+        return DerivedAddress{}, err
+    }
+    if err := h.Save(); err != nil {
+        return DerivedAddress{}, err
+    }
+    return d, nil
+}
+
+// ListDerivedAddresses returns the addresses of every key of the deterministic wallet
+// in use, or none if there is no deterministic wallet
+func ListDerivedAddresses() ([]DerivedAddress, error) {
+    h, err := readHDWalletFile()
+    if err == ErrNoHDWallet {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return h.Addresses()
+}
+
+// FindDerivedAddress returns where the key of address, in either encoding, is in the
+// deterministic wallet, or nil if address is not one of its addresses in use
+func FindDerivedAddress(address string) (*DerivedAddress, error) {
+    info, err := ValidateAddress(address)
+    if err != nil || info.Type != PubKeyHashAddr {
+        return nil, nil
+    }
+    addresses, err := ListDerivedAddresses()
+    if err != nil {
+        return nil, err
+    }
+    for _, d := range addresses {
+        if _, hash, err := DecodeAddress(d.Address); err == nil && bytes.Equal(hash, info.Hash) {
+            return &d, nil
+        }
+    }
+    return nil, nil
+}
+
+// loadDerivedWallet returns the key pair of address from the deterministic wallet, or
+// nil if address is not one of its addresses in use
+func loadDerivedWallet(address string) (*Wallet, error) {
+    d, err := FindDerivedAddress(address)
+    if err != nil || d == nil {
+        return nil, err
+    }
+    h, err := LoadHDWallet()
+    if err != nil {
+        return nil, err
+    }
+    return h.DeriveWallet(d.Account, d.Chain, d.Index)
+}
+
+// loadDerivedPublicKey returns the public key of address from the deterministic
+// wallet, which is known also while it is locked, or nil if address is not one of its
+// addresses in use
+func loadDerivedPublicKey(address string) ([]byte, error) {
+    d, err := FindDerivedAddress(address)
+    if err != nil || d == nil {
+        return nil, err
+    }
+    h, err := readHDWalletFile()
+    if err != nil {
+        return nil, err
+    }
+    return h.derivePublicKey(d.Account, d.Chain, d.Index)
+}
+
+// loadHDWalletWithPassphrase loads the deterministic wallet, decrypting its seed with
+// passphrase if it is encrypted
+func loadHDWalletWithPassphrase(passphrase []byte) (*HDWallet, error) {
+    h, err := readHDWalletFile()
+    if err != nil || h.sealed == nil {
+        return h, err
+    }
+    key, err := h.sealed.deriveKey(passphrase)
+    if err != nil {
+        return nil, err
+    }
+    if err := h.unlock(key); err != nil {
+        return nil, err
+    }
+    return h, nil
+}
+
+// encryptHDWallet encrypts the seed of the unencrypted deterministic wallet with
+// passphrase. The wallet is locked afterwards.
+func encryptHDWallet(passphrase []byte) error {
+    h, err := readHDWalletFile()
+    if err != nil {
+        return err
+    }
+    if h.sealed != nil {
+        return fmt.Errorf("the deterministic wallet is already encrypted; use walletpassphrasechange")
+    }
+    if h.sealed, h.key, err = newEnvelope(passphrase); err != nil {
+        return err
+    }
+    return h.Save()
+}
+
+// changeHDPassphrase re-encrypts the seed of the deterministic wallet, encrypted with
+// oldPassphrase, with newPassphrase. The wallet is locked afterwards.
+func changeHDPassphrase(oldPassphrase, newPassphrase []byte) error {
+    h, err := readHDWalletFile()
+    if err != nil {
+        return err
+    }
+    if h.sealed == nil {
+        return fmt.Errorf("the deterministic wallet is not encrypted; use encryptwallet")
+    }
+    if h, err = loadHDWalletWithPassphrase(oldPassphrase); err != nil {
+        return err
+    }
+    if h.sealed, h.key, err = newEnvelope(newPassphrase); err != nil {
+        return err
+    }
+    if err := h.Save(); err != nil {
+        return err
+    }
+    return stopAgent(hdWalletFile)
+}
+
+// unlockHDWallet makes the seed of the encrypted deterministic wallet available to
+// LoadHDWallet for timeout, like UnlockWallet does for a wallet file
+func unlockHDWallet(passphrase []byte, timeout time.Duration) error {
+    h, err := readHDWalletFile()
+    if err != nil {
+        return err
+    }
+    if h.sealed == nil {
+        return fmt.Errorf("the deterministic wallet is not encrypted")
+    }
+    if h, err = loadHDWalletWithPassphrase(passphrase); err != nil {
+        return err
+    }
+    if err := startAgent(hdWalletFile, h.key, time.Now().Add(timeout)); err != nil {
+        return fmt.Errorf("failed to unlock wallet: %v", err)
+    }
+    return nil
+}
+
+// HDWalletPath returns the path of the deterministic wallet file, which holds its seed
+func HDWalletPath() string {
+    return filepath.Join(getWalletDir(), hdWalletFile)
+}
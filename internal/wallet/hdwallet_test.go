@@ -0,0 +1,254 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// Test encrypting an address of the deterministic wallet seals its seed, leaves its
+// addresses derivable while locked and makes signing wait for unlocking
+func TestEncryptHDWallet(t *testing.T) {
+	useTempWalletDirs(t)
+	seed := bytes.Repeat([]byte{0x42}, 32)
+	if _, err := CreateHDWallet(seed); err != nil {
+		t.Fatalf("Failed to create deterministic wallet: %v", err)
+	}
+	first, err := NewDerivedAddress(0, ExternalChain)
+	if err != nil {
+		t.Fatalf("Failed to derive address: %v", err)
+	}
+	plain, err := LoadWallet(first.Address)
+	if err != nil {
+		t.Fatalf("Failed to load derived wallet: %v", err)
+	}
+
+	if err := EncryptWallet(first.Address, []byte("secret")); err != nil {
+		t.Fatalf("Failed to encrypt deterministic wallet: %v", err)
+	}
+	data, err := os.ReadFile(HDWalletPath())
+	if err != nil {
+		t.Fatalf("Failed to read deterministic wallet file: %v", err)
+	}
+	if bytes.Contains(data, seed) {
+		t.Fatal("Encrypted deterministic wallet file holds the seed")
+	}
+	if encrypted, err := IsEncrypted(first.Address); err != nil || !encrypted {
+		t.Errorf("Derived address is not reported as encrypted: %v", err)
+	}
+	if err := EncryptWallet(first.Address, []byte("secret")); err == nil {
+		t.Error("Encrypted the deterministic wallet again")
+	}
+
+	// Locked: public keys and addresses of existing accounts, but no signing or new accounts
+	if _, err := LoadWallet(first.Address); !errors.Is(err, ErrWalletLocked) {
+		t.Errorf("Expected a locked derived wallet, got %v", err)
+	}
+	if pubKey, err := LoadPublicKey(first.Address); err != nil || !bytes.Equal(pubKey, plain.PublicKey) {
+		t.Errorf("Public key of the locked derived address is unreadable: %v", err)
+	}
+	second, err := NewDerivedAddress(0, ExternalChain)
+	if err != nil || second.Index != 1 {
+		t.Fatalf("Failed to derive an address while locked: %v", err)
+	}
+	if _, err := NewDerivedAddress(1, ExternalChain); !errors.Is(err, ErrWalletLocked) {
+		t.Errorf("Expected adding an account to need the seed, got %v", err)
+	}
+
+	// Unlocked: signing and new accounts, which seal the seed again with their keys
+	if err := UnlockWallet(second.Address, []byte("wrong"), time.Minute); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected a wrong passphrase error, got %v", err)
+	}
+	if err := UnlockWallet(second.Address, []byte("secret"), time.Minute); err != nil {
+		t.Fatalf("Failed to unlock deterministic wallet: %v", err)
+	}
+	loaded, err := LoadWallet(first.Address)
+	if err != nil || loaded.PrivateKey.D.Cmp(plain.PrivateKey.D) != 0 {
+		t.Fatalf("Unlocked derived wallet has the wrong key: %v", err)
+	}
+	account, err := NewDerivedAddress(1, ExternalChain)
+	if err != nil {
+		t.Fatalf("Failed to add an account while unlocked: %v", err)
+	}
+	if err := LockWallet(first.Address); err != nil {
+		t.Fatalf("Failed to lock deterministic wallet: %v", err)
+	}
+	if _, err := LoadWallet(account.Address); !errors.Is(err, ErrWalletLocked) {
+		t.Errorf("Expected the new account to be locked, got %v", err)
+	}
+
+	// The new passphrase alone opens the seed, whose accounts are authenticated
+	if err := ChangePassphrase(account.Address, []byte("secret"), []byte("new")); err != nil {
+		t.Fatalf("Failed to change passphrase: %v", err)
+	}
+	if _, err := LoadWalletWithPassphrase(account.Address, []byte("secret")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Old passphrase still opens the seed: %v", err)
+	}
+	h, err := loadHDWalletWithPassphrase([]byte("new"))
+	if err != nil || !bytes.Equal(h.Seed, seed) || len(h.Accounts) != 2 {
+		t.Fatalf("New passphrase does not open the seed with both accounts: %v", err)
+	}
+
+	data, _ = os.ReadFile(HDWalletPath())
+	i := bytes.Index(data, h.accountKeys[1].chainCode)
+	data[i] ^= 0x01
+	if err := os.WriteFile(HDWalletPath(), data, 0600); err != nil {
+		t.Fatalf("Failed to write deterministic wallet file: %v", err)
+	}
+	if _, err := loadHDWalletWithPassphrase([]byte("new")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Tampered account key was not detected: %v", err)
+	}
+}
+
+// Test peeking at the next address leaves it unused, taking it moves each chain of
+// each account on separately, and accounts are added one at a time
+func TestNextAddress(t *testing.T) {
+	h, err := NewHDWallet(bytes.Repeat([]byte{0x42}, 32))
+	if err != nil {
+		t.Fatalf("Failed to create deterministic wallet: %v", err)
+	}
+	derived := func(account, chain, index uint32) string {
+		w, err := h.DeriveWallet(account, chain, index)
+		if err != nil {
+			t.Fatalf("Failed to derive key: %v", err)
+		}
+		return w.GetAddress()
+	}
+
+	peeked, err := h.PeekAddress(0, ExternalChain)
+	if err != nil {
+		t.Fatalf("Failed to peek at address: %v", err)
+	}
+	again, _ := h.PeekAddress(0, ExternalChain)
+	if peeked != again || h.Accounts[0].Next != [2]uint32{} {
+		t.Errorf("Peeking marked an address used: %v, %v", again, h.Accounts[0].Next)
+	}
+	first, err := h.NextAddress(0, ExternalChain)
+	if err != nil || first != peeked {
+		t.Fatalf("Expected the peeked address %v, got %v: %v", peeked, first, err)
+	}
+	second, _ := h.NextAddress(0, ExternalChain)
+	if second.Index != 1 || second.Address == first.Address || second.Address != derived(0, ExternalChain, 1) {
+		t.Errorf("Second receiving address is %v", second)
+	}
+
+	// Change addresses come from their own chain
+	change, err := h.NextAddress(0, InternalChain)
+	if err != nil {
+		t.Fatalf("Failed to derive change address: %v", err)
+	}
+	if change.Chain != InternalChain || change.Index != 0 || change.Address != derived(0, InternalChain, 0) {
+		t.Errorf("Change address is %v", change)
+	}
+	if change.Address == first.Address || FormatPath(change.Path()) != "m/44'/1'/0'/1/0" {
+		t.Errorf("Change address %v shares the receiving chain", change)
+	}
+	if h.Accounts[0].Next != [2]uint32{2, 1} {
+		t.Errorf("Expected 2 receiving and 1 change address in use, got %v", h.Accounts[0].Next)
+	}
+	if _, err := h.NextAddress(0, 2); err == nil {
+		t.Error("Derived an address of an unknown chain")
+	}
+
+	// Account 1 is added by taking its first address; account 3 cannot skip account 2
+	if _, err := h.PeekAddress(2, ExternalChain); err == nil {
+		t.Error("Peeked at an account beyond the next new one")
+	}
+	added, err := h.NextAddress(1, ExternalChain)
+	if err != nil {
+		t.Fatalf("Failed to add an account: %v", err)
+	}
+	if len(h.Accounts) != 2 || added.Account != 1 || added.Index != 0 || added.Address != derived(1, ExternalChain, 0) {
+		t.Errorf("Added account address is %v with accounts %v", added, h.Accounts)
+	}
+	if _, err := h.NextAddress(3, ExternalChain); err == nil || len(h.Accounts) != 2 {
+		t.Errorf("Skipped an account: %v", h.Accounts)
+	}
+
+	addresses, err := h.Addresses()
+	if err != nil {
+		t.Fatalf("Failed to list addresses: %v", err)
+	}
+	want := []DerivedAddress{first, second, change, added}
+	if len(addresses) != len(want) {
+		t.Fatalf("Expected addresses %v, got %v", want, addresses)
+	}
+	for i := range want {
+		if addresses[i] != want[i] {
+			t.Errorf("Expected addresses %v, got %v", want, addresses)
+		}
+	}
+}
+
+// Test restoring a deterministic wallet from its mnemonic finds the addresses in use,
+// searching each chain up to the gap limit
+func TestRescanHDWallet(t *testing.T) {
+	seed, err := MnemonicToSeed(testMnemonic, "")
+	if err != nil {
+		t.Fatalf("Failed to derive seed: %v", err)
+	}
+	h, err := NewHDWallet(seed)
+	if err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+
+	// Receiving addresses 0 and 4 and change address 1 of account 0, receiving address
+	// 2 of account 1 and receiving address 30 of account 0, far beyond any gap
+	used := make(map[string]bool)
+	for _, key := range [][3]uint32{
+		{0, ExternalChain, 0},
+		{0, ExternalChain, 4},
+		{0, InternalChain, 1},
+		{1, ExternalChain, 2},
+		{0, ExternalChain, 30},
+	} {
+		w, err := h.DeriveWallet(key[0], key[1], key[2])
+		if err != nil {
+			t.Fatalf("Failed to derive key: %v", err)
+		}
+		used[string(HashPubKey(w.PublicKey))] = true
+	}
+	isUsed := func(pubKeyHash []byte) bool { return used[string(pubKeyHash)] }
+
+	tests := []struct {
+		gapLimit int
+		want     []HDAccount
+	}{
+		{5, []HDAccount{{Next: [2]uint32{5, 2}}, {Next: [2]uint32{3, 0}}}},
+		{3, []HDAccount{{Next: [2]uint32{1, 2}}, {Next: [2]uint32{3, 0}}}},
+		{2, []HDAccount{{Next: [2]uint32{1, 2}}}},
+	}
+	for _, test := range tests {
+		restored, _ := NewHDWallet(seed)
+		if err := restored.Rescan(isUsed, test.gapLimit); err != nil {
+			t.Fatalf("Failed to rescan: %v", err)
+		}
+		if len(restored.Accounts) != len(test.want) {
+			t.Errorf("Gap limit %d: expected accounts %v, got %v", test.gapLimit, test.want, restored.Accounts)
+			continue
+		}
+		for i := range test.want {
+			if restored.Accounts[i] != test.want[i] {
+				t.Errorf("Gap limit %d: expected accounts %v, got %v", test.gapLimit, test.want, restored.Accounts)
+			}
+		}
+	}
+	if err := h.Rescan(isUsed, 0); err == nil {
+		t.Error("Rescanned with a gap limit of 0")
+	}
+
+	// Addresses handed out but not paid to yet stay in use
+	for i := 0; i < 7; i++ {
+		if _, err := h.NextAddress(0, ExternalChain); err != nil {
+			t.Fatalf("Failed to derive address: %v", err)
+		}
+	}
+	if err := h.Rescan(isUsed, 5); err != nil {
+		t.Fatalf("Failed to rescan: %v", err)
+	}
+	if h.Accounts[0].Next != [2]uint32{7, 2} {
+		t.Errorf("Expected account 0 to keep its 7 receiving addresses, got %v", h.Accounts[0].Next)
+	}
+}
@@ -0,0 +1,120 @@
+package wallet
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/sha512"
+    _ "embed"
+    "fmt"
+    "math/big"
+    "strings"
+
+    "golang.org/x/crypto/pbkdf2"
+)
+
+const (
+    // mnemonicIterations and mnemonicSaltPrefix are the PBKDF2 parameters turning a
+    // mnemonic into a seed, as defined by BIP39
+    mnemonicIterations = 2048
+    mnemonicSaltPrefix = "mnemonic"
+
+    bitsPerWord = 11
+)
+
+// englishWordList is the BIP39 English word list, 2048 words sorted so that the first
+// four letters identify a word
+//go:embed wordlist_english.txt
+var englishWordList string
+
+var (
+    words     = strings.Fields(englishWordList)
+    wordIndex = make(map[string]int, len(words))
+)
+
+func init() {
+    for i, word := range words {
+        wordIndex[word] = i
+    }
+}
+
+// NewMnemonic returns a BIP39 mnemonic of numWords words for new random entropy: 12
+// words for 128 bits, up to 24 words for 256 bits
+func NewMnemonic(numWords int) (string, error) {
+    if numWords < 12 || numWords > 24 || numWords%3 != 0 {
+        return "", fmt.Errorf("a mnemonic has 12, 15, 18, 21 or 24 words, not %d", numWords)
+    }
+    entropy := make([]byte, numWords/3*4)
+    if _, err := rand.Read(entropy); err != nil {
+        return "", fmt.Errorf("failed to generate entropy: %v", err)
+    }
+    return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic encodes entropy of 16 to 32 bytes, a multiple of 4, as words. The
+// entropy is followed by the first bits of its SHA-256, one bit per 32 bits of
+// entropy, and every 11 bits select a word.
+func EntropyToMnemonic(entropy []byte) (string, error) {
+    if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+        return "", fmt.Errorf("entropy must be 16 to 32 bytes in steps of 4, got %d", len(entropy))
+    }
+
+    checksumBits := len(entropy) / 4
+    hash := sha256.Sum256(entropy)
+    bits := new(big.Int).SetBytes(entropy)
+    bits.Lsh(bits, uint(checksumBits))
+    bits.Or(bits, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+    numWords := (len(entropy)*8 + checksumBits) / bitsPerWord
+    mask := big.NewInt(1<<bitsPerWord - 1)
+    result := make([]string, numWords)
+    for i := numWords - 1; i >= 0; i-- {
+        result[i] = words[new(big.Int).And(bits, mask).Int64()]
+        bits.Rsh(bits, bitsPerWord)
+    }
+    return strings.Join(result, " "), nil
+}
+
+// MnemonicToEntropy decodes mnemonic, checking its words and checksum
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+    mnemonicWords := strings.Fields(mnemonic)
+    numWords := len(mnemonicWords)
+    if numWords < 12 || numWords > 24 || numWords%3 != 0 {
+        return nil, fmt.Errorf("a mnemonic has 12, 15, 18, 21 or 24 words, not %d", numWords)
+    }
+
+    bits := new(big.Int)
+    for i, word := range mnemonicWords {
+        index, ok := wordIndex[strings.ToLower(word)]
+        if !ok {
+            return nil, fmt.Errorf("word %d, %q, is not in the word list", i+1, word)
+        }
+        bits.Lsh(bits, bitsPerWord)
+        bits.Or(bits, big.NewInt(int64(index)))
+    }
+
+    checksumBits := numWords / 3
+    checksum := new(big.Int).And(bits, big.NewInt(1<<checksumBits-1)).Int64()
+    entropy := bits.Rsh(bits, uint(checksumBits)).FillBytes(make([]byte, checksumBits*4))
+    hash := sha256.Sum256(entropy)
+    if int64(hash[0]>>(8-checksumBits)) != checksum {
+        return nil, fmt.Errorf("mnemonic checksum does not match; a word is wrong or the words are out of order")
+    }
+    return entropy, nil
+}
+
+// MnemonicToSeed returns the seed of a valid mnemonic protected by passphrase, which
+// may be empty. Passphrases must be ASCII: BIP39 normalizes other Unicode text, which
+// this implementation does not.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+    if _, err := MnemonicToEntropy(mnemonic); err != nil {
+        return nil, err
+    }
+    for _, r := range passphrase {
+        if r > 0x7f {
+            return nil, fmt.Errorf("mnemonic passphrase must be ASCII")
+        }
+    }
+    normalized := strings.ToLower(strings.Join(strings.Fields(mnemonic), " "))
+    salt := mnemonicSaltPrefix + passphrase
+    return pbkdf2.Key([]byte(normalized), []byte(salt), mnemonicIterations, 64, sha512.New), nil
+}
@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// testMnemonic is the first BIP39 test vector
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// Test mnemonics and seeds match the BIP39 test vectors, which use the passphrase
+// TREZOR
+func TestBIP39Vectors(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			testMnemonic,
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"80808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+			"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			strings.Repeat("abandon ", 23) + "art",
+			"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+	}
+	for _, test := range tests {
+		entropy, _ := hex.DecodeString(test.entropy)
+		mnemonic, err := EntropyToMnemonic(entropy)
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %v", test.entropy, err)
+		}
+		if mnemonic != test.mnemonic {
+			t.Errorf("%s encoded as %q, want %q", test.entropy, mnemonic, test.mnemonic)
+		}
+		decoded, err := MnemonicToEntropy(test.mnemonic)
+		if err != nil || hex.EncodeToString(decoded) != test.entropy {
+			t.Errorf("%q decoded as %x: %v", test.mnemonic, decoded, err)
+		}
+		seed, err := MnemonicToSeed(test.mnemonic, "TREZOR")
+		if err != nil {
+			t.Fatalf("Failed to derive seed of %q: %v", test.mnemonic, err)
+		}
+		if got := hex.EncodeToString(seed); got != test.seed {
+			t.Errorf("Seed of %q is %s, want %s", test.mnemonic, got, test.seed)
+		}
+	}
+
+	// Case and spacing do not change the seed
+	seed, _ := MnemonicToSeed(testMnemonic, "TREZOR")
+	messy, err := MnemonicToSeed("  "+strings.ToUpper(strings.ReplaceAll(testMnemonic, " ", "\t ")), "TREZOR")
+	if err != nil || hex.EncodeToString(messy) != hex.EncodeToString(seed) {
+		t.Errorf("Reformatted mnemonic gave another seed: %v", err)
+	}
+}
+
+// Test mistyped mnemonics are rejected with the reason
+func TestInvalidMnemonics(t *testing.T) {
+	tests := []struct {
+		mnemonic string
+		err      string
+	}{
+		// The last word carries the checksum, which "abandon" does not match
+		{strings.Repeat("abandon ", 12), "checksum"},
+		// Swapping two words keeps every word valid but breaks the checksum
+		{"letter advice cage absurd amount doctor acoustic avoid letter advice above cage", "checksum"},
+		{"abandon abandon abandon abandon abandon abandon abandon abandn abandon abandon abandon about", `word 8, "abandn", is not in the word list`},
+		{strings.Repeat("abandon ", 10) + "about", "not 11"},
+		{"", "not 0"},
+	}
+	for _, test := range tests {
+		_, err := MnemonicToEntropy(test.mnemonic)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected %q to fail with %q, got %v", test.mnemonic, test.err, err)
+		}
+		if _, err := MnemonicToSeed(test.mnemonic, ""); err == nil {
+			t.Errorf("Derived a seed from invalid mnemonic %q", test.mnemonic)
+		}
+	}
+	if _, err := MnemonicToSeed(testMnemonic, "pässword"); err == nil {
+		t.Error("Accepted a non-ASCII passphrase")
+	}
+}
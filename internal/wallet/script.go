@@ -0,0 +1,62 @@
+package wallet
+
+import (
+    "bytes"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+
+    "github.com/OmSingh2003/decentralized-ledger/internal/script"
+)
+
+// scriptFileExt is the extension of files holding the redeem scripts of script addresses
+const scriptFileExt = ".script"
+
+// ScriptAddress returns the pay-to-script-hash address of a redeem script
+func ScriptAddress(redeemScript []byte) string {
+    return ScriptHashAddress(script.Hash160(redeemScript))
+}
+
+// ScriptHashAddress returns the pay-to-script-hash address of the redeem script with
+// the given hash
+func ScriptHashAddress(scriptHash []byte) string {
+    return encodeAddress(ScriptHashAddr, scriptHash)
+}
+
+// SaveRedeemScript stores a redeem script under its address, so it can be revealed
+// when outputs paid to the address are spent. It returns the address.
+func SaveRedeemScript(redeemScript []byte) (string, error) {
+    walletDir := getWalletDir()
+    if err := os.MkdirAll(walletDir, 0700); err != nil {
+        return "", err
+    }
+
+    scriptHash := script.Hash160(redeemScript)
+    scriptPath := filepath.Join(walletDir, fileID(ScriptHashAddr, scriptHash)+scriptFileExt)
+    if err := ioutil.WriteFile(scriptPath, redeemScript, 0600); err != nil {
+        return "", fmt.Errorf("failed to save redeem script: %v", err)
+    }
+    return ScriptHashAddress(scriptHash), nil
+}
+
+// LoadRedeemScript returns the redeem script saved for address, or nil if there is none
+func LoadRedeemScript(address string) ([]byte, error) {
+    info, err := ValidateAddress(address)
+    if err != nil || info.Type != ScriptHashAddr {
+        return nil, nil
+    }
+
+    scriptPath := filepath.Join(getWalletDir(), fileID(ScriptHashAddr, info.Hash)+scriptFileExt)
+    redeemScript, err := ioutil.ReadFile(scriptPath)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to load redeem script: %v", err)
+    }
+    if !bytes.Equal(script.Hash160(redeemScript), info.Hash) {
+        return nil, fmt.Errorf("redeem script file for %s does not match its address", address)
+    }
+    return redeemScript, nil
+}
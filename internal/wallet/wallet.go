@@ -7,7 +7,7 @@ import (
     "crypto/rand"
     "crypto/sha256"
     "encoding/gob"
-    "encoding/hex"
+    "errors"
     "fmt"
     "io/ioutil"
     "log"
@@ -15,13 +15,13 @@ import (
     "os"
     "path/filepath"
 
-    "golang.org/x/crypto/ripemd160"
+    "github.com/OmSingh2003/decentralized-ledger/internal/script"
 )
 
 const (
-    version            = byte(0x00)
     walletFile        = "wallet.dat"
     addressChecksumLen = 4
+    hashLen            = 20
 )
 
 // Wallet stores private and public keys
@@ -48,61 +48,100 @@ func NewWallet() *Wallet {
     wallet := Wallet{private, public}
     
     // Save the wallet immediately after creation
-    SaveWallet(wallet.GetAddress(), &wallet)
+    SaveWallet(&wallet)
     
     return &wallet
 }
 
-// LoadWallet loads a wallet from a file
-func LoadWallet(address string) *Wallet {
-    if !ValidateAddress(address) {
-        return nil
+// LoadWallet loads the wallet of address from its file, or derives it from the seed of
+// the deterministic wallet. An encrypted wallet must have been unlocked with
+// UnlockWallet; otherwise the error is ErrWalletLocked.
+func LoadWallet(address string) (*Wallet, error) {
+    data, err := readWalletFile(address)
+    if errors.Is(err, ErrWalletNotFound) {
+        if w, derr := loadDerivedWallet(address); w != nil || derr != nil {
+            return w, derr
+        }
+    }
+    if err != nil {
+        return nil, err
+    }
+    if !isEncrypted(data) {
+        return decodePlainWallet(data)
     }
 
-    walletDir := getWalletDir()
-    walletPath := filepath.Join(walletDir, fmt.Sprintf("%s.wallet", address))
+    e, err := parseEncryptedWallet(data)
+    if err != nil {
+        return nil, err
+    }
+    key := unlockedKey(address)
+    if key == nil {
+        return nil, fmt.Errorf("%w: unlock %s with walletpassphrase", ErrWalletLocked, address)
+    }
+    w, err := e.open(key)
+    if err == ErrWrongPassphrase {
+        // The passphrase changed since the wallet was unlocked
+        return nil, fmt.Errorf("%w: unlock %s with walletpassphrase", ErrWalletLocked, address)
+    }
+    return w, err
+}
 
-    if _, err := os.Stat(walletPath); os.IsNotExist(err) {
-        return nil
+// readWalletFile returns the content of the wallet file of address
+func readWalletFile(address string) ([]byte, error) {
+    id, err := addressFileID(address, PubKeyHashAddr)
+    if err != nil {
+        return nil, err
     }
 
+    walletPath := filepath.Join(getWalletDir(), fmt.Sprintf("%s.wallet", id))
     fileContent, err := ioutil.ReadFile(walletPath)
+    if os.IsNotExist(err) {
+        return nil, fmt.Errorf("%w for address: %s", ErrWalletNotFound, address)
+    }
     if err != nil {
-        log.Panic(err)
+        return nil, fmt.Errorf("failed to read wallet: %v", err)
     }
+    return fileContent, nil
+}
 
+// decodePlainWallet decodes the content of an unencrypted wallet file
+func decodePlainWallet(fileContent []byte) (*Wallet, error) {
     var ws walletSerializable
     decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-    err = decoder.Decode(&ws)
-    if err != nil {
-        log.Panic(err)
+    if err := decoder.Decode(&ws); err != nil {
+        return nil, fmt.Errorf("corrupt wallet file: %v", err)
     }
+    return walletFromD(ws.PrivateKeyD, ws.PublicKey)
+}
 
+// walletFromD returns the wallet with private key scalar d and public key pubKey as
+// stored, checking that they belong together
+func walletFromD(d, pubKey []byte) (*Wallet, error) {
     curve := elliptic.P256()
-    x := new(big.Int).SetBytes(ws.PrivateKeyX)
-    y := new(big.Int).SetBytes(ws.PrivateKeyY)
-    d := new(big.Int).SetBytes(ws.PrivateKeyD)
-
-    privateKey := ecdsa.PrivateKey{
-        PublicKey: ecdsa.PublicKey{
-            Curve: curve,
-            X:     x,
-            Y:     y,
-        },
-        D: d,
+    privateKey := ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+    privateKey.PublicKey.Curve = curve
+    privateKey.PublicKey.X, privateKey.PublicKey.Y = curve.ScalarBaseMult(privateKey.D.FillBytes(make([]byte, 32)))
+
+    // Wallets created before coordinates were padded store them without leading zeros
+    padded := make([]byte, 64)
+    privateKey.PublicKey.X.FillBytes(padded[:32])
+    privateKey.PublicKey.Y.FillBytes(padded[32:])
+    unpadded := append(privateKey.PublicKey.X.Bytes(), privateKey.PublicKey.Y.Bytes()...)
+    if !bytes.Equal(pubKey, padded) && !bytes.Equal(pubKey, unpadded) {
+        return nil, fmt.Errorf("corrupt wallet file: private key does not match public key")
     }
-
-    return &Wallet{privateKey, ws.PublicKey}
+    return &Wallet{privateKey, pubKey}, nil
 }
 
-// SaveWallet saves the wallet to a file
-func SaveWallet(address string, wallet *Wallet) {
+// SaveWallet saves the wallet to a file named after its key, for every network
+func SaveWallet(wallet *Wallet) {
     walletDir := getWalletDir()
     if err := os.MkdirAll(walletDir, 0700); err != nil {
         log.Panic(err)
     }
 
-    walletPath := filepath.Join(walletDir, fmt.Sprintf("%s.wallet", address))
+    id := fileID(PubKeyHashAddr, HashPubKey(wallet.PublicKey))
+    walletPath := filepath.Join(walletDir, fmt.Sprintf("%s.wallet", id))
 
     ws := walletSerializable{
         PrivateKeyD: wallet.PrivateKey.D.Bytes(),
@@ -124,81 +163,79 @@ func SaveWallet(address string, wallet *Wallet) {
     }
 }
 
-// ListAddresses returns a list of addresses of all wallets
-func ListAddresses() []string {
+// ListAddresses returns the addresses of all wallet files, followed by those of the
+// deterministic wallet's keys in use
+func ListAddresses() ([]string, error) {
     var addresses []string
     walletDir := getWalletDir()
     
     files, err := ioutil.ReadDir(walletDir)
     if err != nil && !os.IsNotExist(err) {
-        log.Panic(err)
+        return nil, fmt.Errorf("failed to list wallets: %v", err)
     }
 
     for _, f := range files {
         if filepath.Ext(f.Name()) == ".wallet" {
-            address := f.Name()[:len(f.Name())-7] // Remove .wallet extension
-            if ValidateAddress(address) {
-                addresses = append(addresses, address)
+            id := f.Name()[:len(f.Name())-7] // Remove .wallet extension
+            if t, hash, ok := parseFileID(id); ok && t == PubKeyHashAddr {
+                addresses = append(addresses, KeyHashAddress(hash))
             }
         }
     }
 
-    return addresses
+    derived, err := ListDerivedAddresses()
+    if err != nil {
+        return nil, err
+    }
+    for _, d := range derived {
+        addresses = append(addresses, d.Address)
+    }
+    return addresses, nil
 }
 
-// GetAddress returns wallet address
+// GetAddress returns the address of the wallet's key on the active network
 func (w *Wallet) GetAddress() string {
-    pubKeyHash := HashPubKey(w.PublicKey)
-
-    versionedPayload := append([]byte{version}, pubKeyHash...)
-    checksum := checksum(versionedPayload)
-
-    fullPayload := append(versionedPayload, checksum...)
-    address := hex.EncodeToString(fullPayload)
+    return encodeAddress(PubKeyHashAddr, HashPubKey(w.PublicKey))
+}
 
-    return address
+// KeyHashAddress returns the address of the key with the given hash on the active
+// network
+func KeyHashAddress(pubKeyHash []byte) string {
+    return encodeAddress(PubKeyHashAddr, pubKeyHash)
 }
 
 // HashPubKey hashes public key
 func HashPubKey(pubKey []byte) []byte {
-    publicSHA256 := sha256.Sum256(pubKey)
-
-    RIPEMD160Hasher := ripemd160.New()
-    _, err := RIPEMD160Hasher.Write(publicSHA256[:])
-    if err != nil {
-        log.Panic(err)
-    }
-    publicRIPEMD160 := RIPEMD160Hasher.Sum(nil)
-
-    return publicRIPEMD160
+    return script.Hash160(pubKey)
 }
 
-// ValidateAddress check if address if valid
-func ValidateAddress(address string) bool {
-    pubKeyHash, err := hex.DecodeString(address)
+// AddressLockingScript returns the locking script paying to address, which must be an
+// address of the active network
+func AddressLockingScript(address string) ([]byte, error) {
+    addrType, hash, err := DecodeAddress(address)
     if err != nil {
-        return false
+        return nil, err
     }
-
-    if len(pubKeyHash) < addressChecksumLen+1 {
-        return false
+    if addrType == ScriptHashAddr {
+        return script.PayToScriptHash(hash), nil
     }
-
-    actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
-    version := pubKeyHash[0]
-    pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
-    targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
-
-    return bytes.Compare(actualChecksum, targetChecksum) == 0
+    return script.PayToPubKeyHash(hash), nil
 }
 
-// SignData signs data using the wallet's private key
+// SignData signs data using the wallet's private key. The signature has a low S value,
+// the only form VerifySignature accepts, so it cannot be altered into another valid
+// signature of the same data.
 func (w *Wallet) SignData(data []byte) ([]byte, error) {
     r, s, err := ecdsa.Sign(rand.Reader, &w.PrivateKey, data)
     if err != nil {
         return nil, err
     }
 
+    // (r, N-s) is just as valid as (r, s); keep the lower of the two
+    if !isLowS(s) {
+        s.Sub(elliptic.P256().Params().N, s)
+    }
+
     // Ensure each component is exactly 32 bytes
     rBytes := r.Bytes()
     sBytes := s.Bytes()
@@ -214,7 +251,8 @@ func (w *Wallet) SignData(data []byte) ([]byte, error) {
     return signature, nil
 }
 
-// VerifySignature verifies a signature against public key and data
+// VerifySignature verifies a signature against public key and data. Signatures with a
+// high S value are rejected, as anyone can derive one from a low S signature.
 func VerifySignature(pubKey []byte, data []byte, signature []byte) bool {
 	if len(pubKey) != 64 {
 		return false // Public key should be 64 bytes (32 bytes X + 32 bytes Y)
@@ -236,10 +274,19 @@ func VerifySignature(pubKey []byte, data []byte, signature []byte) bool {
 
 	rSign := new(big.Int).SetBytes(signature[:32])
 	sSign := new(big.Int).SetBytes(signature[32:])
+	if !isLowS(sSign) {
+		return false
+	}
 
 	return ecdsa.Verify(&publicKey, data, rSign, sSign)
 }
 
+// isLowS reports whether s is at most half the order of the curve
+func isLowS(s *big.Int) bool {
+	halfOrder := new(big.Int).Rsh(elliptic.P256().Params().N, 1)
+	return s.Cmp(halfOrder) <= 0
+}
+
 // Checksum generates a checksum for a public key
 func checksum(payload []byte) []byte {
     firstSHA := sha256.Sum256(payload)
@@ -256,7 +303,10 @@ func newKeyPair() (ecdsa.PrivateKey, []byte) {
         log.Panic(err)
     }
     
-    pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+    // Both coordinates take 32 bytes, also when they start with zeros
+    pubKey := make([]byte, 64)
+    private.PublicKey.X.FillBytes(pubKey[:32])
+    private.PublicKey.Y.FillBytes(pubKey[32:])
     return *private, pubKey
 }
 
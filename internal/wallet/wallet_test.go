@@ -0,0 +1,38 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+)
+
+// Test signatures have a low S value and their high S form, which anyone can derive,
+// is rejected
+func TestLowSSignatures(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	w := NewWallet()
+	hash := sha256.Sum256([]byte("data"))
+	n := elliptic.P256().Params().N
+	halfOrder := new(big.Int).Rsh(n, 1)
+
+	for i := 0; i < 20; i++ {
+		sig, err := w.SignData(hash[:])
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		s := new(big.Int).SetBytes(sig[32:])
+		if s.Cmp(halfOrder) > 0 {
+			t.Fatalf("Signature has a high S value %x", sig[32:])
+		}
+		if !VerifySignature(w.PublicKey, hash[:], sig) {
+			t.Fatal("Low S signature was rejected")
+		}
+
+		highS := append([]byte{}, sig[:32]...)
+		highS = append(highS, new(big.Int).Sub(n, s).FillBytes(make([]byte, 32))...)
+		if VerifySignature(w.PublicKey, hash[:], highS) {
+			t.Fatal("High S signature was accepted")
+		}
+	}
+}
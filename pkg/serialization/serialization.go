@@ -1,27 +1,54 @@
+// Package serialization exposes the canonical binary encoding of blocks and
+// transactions. The format is documented in the internal codec package: fixed-width
+// little-endian integers, uint32 length-prefixed byte strings and lists.
 package serialization
 
 import (
-    "bytes"
-    "encoding/gob"
     "github.com/OmSingh2003/decentralized-ledger/internal/block"
+    "github.com/OmSingh2003/decentralized-ledger/internal/transaction"
 )
 
+// SerializeBlock returns the canonical encoding of a block, or nil on failure
 func SerializeBlock(b *block.Block) []byte {
-    var result bytes.Buffer
-    encoder := gob.NewEncoder(&result)
-    err := encoder.Encode(b)
+    data, err := b.Serialize()
     if err != nil {
         return nil
     }
-    return result.Bytes()
+    return data
 }
 
+// DeserializeBlock decodes a block produced by SerializeBlock, or returns nil on failure
 func DeserializeBlock(d []byte) *block.Block {
-    var block block.Block
-    decoder := gob.NewDecoder(bytes.NewReader(d))
-    err := decoder.Decode(&block)
+    b, err := block.DeserializeBlock(d)
     if err != nil {
         return nil
     }
-    return &block
+    return b
+}
+
+// SerializeTransaction returns the canonical encoding of a transaction
+func SerializeTransaction(tx *transaction.Transaction) []byte {
+    return tx.Serialize()
+}
+
+// DeserializeTransaction decodes a transaction produced by SerializeTransaction, or returns nil on failure
+func DeserializeTransaction(d []byte) *transaction.Transaction {
+    tx, err := transaction.DeserializeTransaction(d)
+    if err != nil {
+        return nil
+    }
+    return tx
+}
+
+// TransactionHash returns the hash committed to by a transaction ID:
+// SHA-256 of the canonical encoding with an empty ID and, except for a coinbase,
+// empty unlocking scripts
+func TransactionHash(tx *transaction.Transaction) []byte {
+    return tx.Hash()
+}
+
+// TransactionWitnessHash returns the hash of a transaction including its unlocking
+// scripts: SHA-256 of the canonical encoding with an empty ID
+func TransactionWitnessHash(tx *transaction.Transaction) []byte {
+    return tx.WitnessHash()
 }
@@ -0,0 +1,132 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/OmSingh2003/decentralized-ledger/internal/block"
+	"github.com/OmSingh2003/decentralized-ledger/internal/transaction"
+)
+
+// Golden vectors for the canonical encoding, split per field
+var (
+	goldenTx = strings.Join([]string{
+		"02000000", "aabb", // ID
+		"01000000",           // input count
+		"03000000", "010203", // Txid
+		"01000000",         // Vout
+		"02000000", "0405", // UnlockingScript
+		"06000000",         // Sequence
+		"01000000",         // output count
+		"3200000000000000", // Value
+		"01000000", "07",   // LockingScript
+		"9000000000000000", // LockTime
+	}, "")
+
+	goldenTxHash = "75c2ccc275d621324446285b8553ec9dffc981a429615b7ea39b0a98beba8a95"
+
+	goldenTxWitnessHash = "20652783465995112ecf73dc47cb1ed0d728715ac9ba5dee9dffa89982f6b40f"
+
+	goldenBlock = strings.Join([]string{
+		"00000020",         // Version
+		"0200000000000000", // Height
+		"00f1536500000000", // Timestamp
+		"02000000", "1122", // PrevBlockHash
+		"0700000000000000", // Nonce
+		"1800000000000000", // Bits
+		"00000000",         // ValidatorPubKey
+		"00000000",         // Signature
+		"01000000", "33",   // Hash
+		"01000000", // transaction count
+		goldenTx,
+	}, "")
+)
+
+// Helper to build the transaction described by goldenTx
+func goldenTransaction() *transaction.Transaction {
+	return &transaction.Transaction{
+		ID: []byte{0xaa, 0xbb},
+		Vin: []transaction.TxInput{{
+			Txid: []byte{0x01, 0x02, 0x03}, Vout: 1, UnlockingScript: []byte{0x04, 0x05}, Sequence: 6,
+		}},
+		Vout: []transaction.TxOutput{{
+			Value: 50, LockingScript: []byte{0x07},
+		}},
+		LockTime: 144,
+	}
+}
+
+// Helper to build the block described by goldenBlock
+func goldenBlockValue() *block.Block {
+	return &block.Block{
+		BlockHeader: block.BlockHeader{
+			Version:       0x20000000,
+			Height:        2,
+			Timestamp:     1700000000,
+			PrevBlockHash: []byte{0x11, 0x22},
+			Nonce:         7,
+			Bits:          24,
+		},
+		Hash:         []byte{0x33},
+		Transactions: []*transaction.Transaction{goldenTransaction()},
+	}
+}
+
+// Test transactions encode to the golden vector and decode back
+func TestTransactionGoldenVector(t *testing.T) {
+	encoded := SerializeTransaction(goldenTransaction())
+	if got := hex.EncodeToString(encoded); got != goldenTx {
+		t.Fatalf("Unexpected encoding:\n got %s\nwant %s", got, goldenTx)
+	}
+
+	if got := hex.EncodeToString(TransactionHash(goldenTransaction())); got != goldenTxHash {
+		t.Errorf("Unexpected hash: got %s, want %s", got, goldenTxHash)
+	}
+	if got := hex.EncodeToString(TransactionWitnessHash(goldenTransaction())); got != goldenTxWitnessHash {
+		t.Errorf("Unexpected witness hash: got %s, want %s", got, goldenTxWitnessHash)
+	}
+
+	decoded := DeserializeTransaction(encoded)
+	if decoded == nil {
+		t.Fatal("Failed to decode golden transaction")
+	}
+	if !bytes.Equal(SerializeTransaction(decoded), encoded) {
+		t.Error("Decoded transaction does not re-encode to the golden vector")
+	}
+}
+
+// Test blocks encode to the golden vector and decode back
+func TestBlockGoldenVector(t *testing.T) {
+	encoded := SerializeBlock(goldenBlockValue())
+	if got := hex.EncodeToString(encoded); got != goldenBlock {
+		t.Fatalf("Unexpected encoding:\n got %s\nwant %s", got, goldenBlock)
+	}
+
+	decoded := DeserializeBlock(encoded)
+	if decoded == nil {
+		t.Fatal("Failed to decode golden block")
+	}
+	if decoded.Height != 2 || decoded.Nonce != 7 || len(decoded.Transactions) != 1 {
+		t.Errorf("Decoded block has unexpected fields: %+v", decoded.BlockHeader)
+	}
+	if !bytes.Equal(SerializeBlock(decoded), encoded) {
+		t.Error("Decoded block does not re-encode to the golden vector")
+	}
+}
+
+// Test truncated and padded input is rejected
+func TestDeserializeRejectsMalformedData(t *testing.T) {
+	encoded := SerializeTransaction(goldenTransaction())
+
+	if DeserializeTransaction(encoded[:len(encoded)-1]) != nil {
+		t.Error("Truncated transaction should not decode")
+	}
+	if DeserializeTransaction(append(encoded, 0x00)) != nil {
+		t.Error("Transaction with trailing bytes should not decode")
+	}
+	if DeserializeBlock([]byte{0x01, 0x02}) != nil {
+		t.Error("Truncated block should not decode")
+	}
+}